@@ -0,0 +1,276 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
+	"github.com/robmcelhinney/spanforge/internal/sink"
+)
+
+// rateController sets the trace rate of a --rate-control=adaptive run. Each
+// window it backs off by a factor when the sink pushed back and otherwise
+// adds a fixed step, so the rate settles around what the sink sustains.
+type rateController struct {
+	start, min, max, step float64
+	backoff               float64
+	window                time.Duration
+	// maxLatency is the window mean send latency that counts as the sink
+	// slowing down; 0 derives it from baseline.
+	maxLatency time.Duration
+
+	mu      sync.Mutex
+	current float64
+	peak    float64
+	// The window's requests, latency and signs of backpressure.
+	requests  uint64
+	latency   time.Duration
+	throttled uint64
+	timedOut  uint64
+	stalls    uint64
+	// baseline is the lowest mean latency of a healthy window.
+	baseline time.Duration
+	// Totals over the run.
+	windows, decreases                   uint64
+	totalThrottled, totalTimedOut, total uint64
+	totalStalls                          uint64
+	// healthy sums the rates of healthy windows after the first decrease,
+	// which the rate converged on.
+	healthy        float64
+	healthyWindows uint64
+}
+
+// adaptiveReport is how a --rate-control=adaptive run's rate moved. Rates
+// are traces per second; the converged rate is the mean of the healthy
+// windows after the sink first pushed back, or the final rate when it never
+// did.
+type adaptiveReport struct {
+	StartTracesPerSecond     float64 `json:"start_traces_per_second"`
+	FinalTracesPerSecond     float64 `json:"final_traces_per_second"`
+	PeakTracesPerSecond      float64 `json:"peak_traces_per_second"`
+	ConvergedTracesPerSecond float64 `json:"converged_traces_per_second"`
+	ConvergedSpansPerSecond  float64 `json:"converged_spans_per_second"`
+	Backpressure             bool    `json:"backpressure"`
+	Windows                  uint64  `json:"windows"`
+	Decreases                uint64  `json:"decreases"`
+	Requests                 uint64  `json:"requests"`
+	ThrottledRequests        uint64  `json:"throttled_requests"`
+	TimedOutRequests         uint64  `json:"timed_out_requests"`
+	Stalls                   uint64  `json:"stalls"`
+	// FailedSpans were dropped once their batch ran out of retries.
+	FailedSpans uint64 `json:"failed_spans"`
+}
+
+func newRateController(cfg config.Config) *rateController {
+	perSecond := func(value float64) float64 {
+		c := cfg
+		c.RateValue = value
+		return effectiveTracesPerInterval(c) / cfg.RateInterval.Seconds()
+	}
+	start := perSecond(cfg.RateValue)
+	c := &rateController{
+		start:      start,
+		min:        start / 100,
+		step:       start * cfg.AdaptiveStep,
+		backoff:    cfg.AdaptiveBackoff,
+		window:     cfg.AdaptiveWindow,
+		maxLatency: cfg.AdaptiveMaxLatency,
+		current:    start,
+		peak:       start,
+	}
+	if cfg.AdaptiveMinRate > 0 {
+		c.min = perSecond(cfg.AdaptiveMinRate)
+	}
+	if cfg.AdaptiveMaxRate > 0 {
+		c.max = perSecond(cfg.AdaptiveMaxRate)
+	}
+	return c
+}
+
+// rate returns the traces per second to generate at.
+func (c *rateController) rate() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+// observe counts one request to the sink.
+func (c *rateController) observe(latency time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests++
+	c.total++
+	c.latency += latency
+	switch {
+	case errors.Is(err, sink.ErrThrottled):
+		c.throttled++
+		c.totalThrottled++
+	case isTimeout(err):
+		c.timedOut++
+		c.totalTimedOut++
+	}
+}
+
+// stall counts a generated trace that had to wait because the sink was not
+// taking traces as fast as they were generated.
+func (c *rateController) stall() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stalls++
+	c.totalStalls++
+}
+
+// adjust ends a window, sets the rate for the next one and says why it
+// backed off, if it did.
+func (c *rateController) adjust() (rate float64, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var mean time.Duration
+	if c.requests > 0 {
+		mean = c.latency / time.Duration(c.requests)
+	}
+	limit := c.maxLatency
+	if limit == 0 && c.baseline > 0 {
+		limit = 2*c.baseline + 50*time.Millisecond
+	}
+	switch {
+	case c.throttled > 0:
+		reason = fmt.Sprintf("%d throttled requests", c.throttled)
+	case c.timedOut > 0:
+		reason = fmt.Sprintf("%d timed out requests", c.timedOut)
+	case limit > 0 && mean > limit:
+		reason = fmt.Sprintf("mean latency %s over %s", mean, limit)
+	case c.stalls > 0:
+		reason = fmt.Sprintf("%d traces waited on the sink", c.stalls)
+	}
+	c.windows++
+	if reason != "" {
+		c.decreases++
+		c.current *= c.backoff
+		if c.current < c.min {
+			c.current = c.min
+		}
+	} else {
+		if c.decreases > 0 {
+			c.healthy += c.current
+			c.healthyWindows++
+		}
+		if c.requests > 0 && (c.baseline == 0 || mean < c.baseline) {
+			c.baseline = mean
+		}
+		c.current += c.step
+		if c.max > 0 && c.current > c.max {
+			c.current = c.max
+		}
+	}
+	if c.current > c.peak {
+		c.peak = c.current
+	}
+	c.requests, c.latency, c.throttled, c.timedOut, c.stalls = 0, 0, 0, 0, 0
+	return c.current, reason
+}
+
+// report summarises the run, using snapshot's spans per trace to convert
+// the converged rate.
+func (c *rateController) report(snapshot statsSnapshot) *adaptiveReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := &adaptiveReport{
+		StartTracesPerSecond:     c.start,
+		FinalTracesPerSecond:     c.current,
+		PeakTracesPerSecond:      c.peak,
+		ConvergedTracesPerSecond: c.current,
+		Backpressure:             c.decreases > 0,
+		Windows:                  c.windows,
+		Decreases:                c.decreases,
+		Requests:                 c.total,
+		ThrottledRequests:        c.totalThrottled,
+		TimedOutRequests:         c.totalTimedOut,
+		Stalls:                   c.totalStalls,
+	}
+	if c.healthyWindows > 0 {
+		r.ConvergedTracesPerSecond = c.healthy / float64(c.healthyWindows)
+	}
+	if snapshot.EmittedTraces > 0 {
+		r.ConvergedSpansPerSecond = r.ConvergedTracesPerSecond * float64(snapshot.EmittedSpans) / float64(snapshot.EmittedTraces)
+	}
+	return r
+}
+
+// isTimeout reports whether a request failed by running out of time.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// produceTraceAdaptive generates traces at the rate control sets, for
+// cfg.Count traces or cfg.Duration.
+func produceTraceAdaptive(ctx context.Context, cfg config.Config, traceCh chan<- model.Trace, control *rateController) error {
+	jobs, wait := startTraceWorkers(ctx, cfg, traceCh, control.stall)
+	defer wait()
+	defer close(jobs)
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	windows := time.NewTicker(control.window)
+	defer windows.Stop()
+
+	ratePerSecond := control.rate()
+	tokens := 1.0
+	lastRefill := time.Now()
+	var deadline time.Time
+	if cfg.Count <= 0 && cfg.Duration > 0 {
+		deadline = lastRefill.Add(cfg.Duration)
+	}
+	sent := 0
+	for {
+		if cfg.Count > 0 && sent >= cfg.Count {
+			return nil
+		}
+		now := time.Now()
+		if !deadline.IsZero() && now.After(deadline) {
+			return nil
+		}
+		tokens += now.Sub(lastRefill).Seconds() * ratePerSecond
+		lastRefill = now
+		if capacity := maxFloat(1, ratePerSecond*0.1); tokens > capacity {
+			tokens = capacity
+		}
+	dispatch:
+		for tokens >= 1 && (cfg.Count <= 0 || sent < cfg.Count) {
+			select {
+			case jobs <- time.Now().UTC():
+				sent++
+				tokens--
+			case <-ctx.Done():
+				return nil
+			default:
+				// The generators are behind, which is not the sink's
+				// doing, so the rate holds.
+				tokens = 0
+				break dispatch
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-windows.C:
+			var reason string
+			ratePerSecond, reason = control.adjust()
+			if reason != "" {
+				debugf(cfg, "adaptive rate backs off to %.2f traces/s: %s", ratePerSecond, reason)
+			} else {
+				debugf(cfg, "adaptive rate probes up to %.2f traces/s", ratePerSecond)
+			}
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/sink"
+)
+
+func TestRateControllerAIMD(t *testing.T) {
+	cfg := reportTestConfig("")
+	cfg.RateControl = config.RateControlAdaptive
+	cfg.AdaptiveWindow = time.Second
+	cfg.AdaptiveStep = 0.1
+	cfg.AdaptiveBackoff = 0.5
+	cfg.AdaptiveMaxRate = 125
+	c := newRateController(cfg)
+
+	steps := []struct {
+		observe func()
+		want    float64
+		backoff bool
+	}{
+		{func() { c.observe(10*time.Millisecond, nil) }, 110, false},
+		{func() { c.observe(10*time.Millisecond, sink.Throttle(fmt.Errorf("429"))) }, 55, true},
+		{func() { c.observe(10*time.Millisecond, nil) }, 65, false},
+		{func() { c.observe(time.Second, context.DeadlineExceeded) }, 32.5, true},
+		// Twice the 10ms baseline plus 50ms is the default latency limit.
+		{func() { c.observe(80*time.Millisecond, nil) }, 16.25, true},
+		{func() { c.stall() }, 8.125, true},
+		{func() {}, 18.125, false},
+	}
+	for i, step := range steps {
+		step.observe()
+		rate, reason := c.adjust()
+		if rate != step.want || (reason != "") != step.backoff {
+			t.Fatalf("step %d: rate=%v reason=%q want %v backoff=%v", i, rate, reason, step.want, step.backoff)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		c.adjust()
+	}
+	if got := c.rate(); got != 125 {
+		t.Fatalf("rate=%v want it capped at 125", got)
+	}
+
+	r := c.report(statsSnapshot{EmittedTraces: 10, EmittedSpans: 30})
+	if !r.Backpressure || r.Decreases != 4 || r.ThrottledRequests != 1 || r.TimedOutRequests != 1 || r.Stalls != 1 || r.PeakTracesPerSecond != 125 {
+		t.Fatalf("report=%+v", r)
+	}
+	// Healthy windows after the first decrease ran at 55, 8.125 and then
+	// upward to the cap.
+	if r.ConvergedTracesPerSecond <= 8 || r.ConvergedTracesPerSecond >= 125 || r.ConvergedSpansPerSecond != 3*r.ConvergedTracesPerSecond {
+		t.Fatalf("converged=%v traces/s %v spans/s", r.ConvergedTracesPerSecond, r.ConvergedSpansPerSecond)
+	}
+}
+
+// throttlingServer accepts one request per interval and answers 429 to the
+// rest.
+type throttlingServer struct {
+	interval time.Duration
+
+	mu        sync.Mutex
+	next      time.Time
+	accepted  int
+	throttled int
+}
+
+func (s *throttlingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Before(s.next) {
+		s.throttled++
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	s.next = now.Add(s.interval)
+	s.accepted++
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestRunAdaptiveBacksOffWhenThrottled(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	s := &throttlingServer{interval: 20 * time.Millisecond}
+	srv := httptest.NewUnstartedServer(s)
+	srv.Listener = lis
+	srv.Start()
+	defer srv.Close()
+
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	cfg := reportTestConfig(reportPath)
+	cfg.Output = "otlp"
+	cfg.OTLPEndpoint = srv.URL
+	cfg.Count = 0
+	cfg.Duration = 1500 * time.Millisecond
+	cfg.RateValue = 400
+	cfg.BatchSize = 1
+	// With the default retries some throttled batches run out of them,
+	// which an adaptive run counts and carries on past.
+	cfg.SinkRetries = 2
+	cfg.SinkRetryBackoff = 5 * time.Millisecond
+	cfg.RateControl = config.RateControlAdaptive
+	cfg.AdaptiveWindow = 100 * time.Millisecond
+	cfg.AdaptiveStep = 0.05
+	cfg.AdaptiveBackoff = 0.5
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := Run(cfg, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	a := readRunReport(t, reportPath).Adaptive
+	if a == nil {
+		t.Fatalf("report has no adaptive section")
+	}
+	s.mu.Lock()
+	throttled := s.throttled
+	s.mu.Unlock()
+	if !a.Backpressure || a.Decreases == 0 || a.ThrottledRequests != uint64(throttled) || throttled == 0 {
+		t.Fatalf("adaptive=%+v, server throttled %d", a, throttled)
+	}
+	// The server takes 50 requests a second; the rate settles well below
+	// the 400 it started at.
+	if a.StartTracesPerSecond != 400 || a.ConvergedTracesPerSecond >= 200 || a.ConvergedSpansPerSecond <= 0 {
+		t.Fatalf("adaptive=%+v", a)
+	}
+	if a.FailedSpans == 0 {
+		t.Fatalf("adaptive=%+v, want dropped batches counted", a)
+	}
+}
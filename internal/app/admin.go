@@ -4,15 +4,69 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"math/rand"
 	"net/http"
+	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/sink"
 )
 
 type emitterStats struct {
-	startedAt time.Time
-	traces    uint64
-	spans     uint64
+	startedAt    time.Time
+	traces       uint64
+	spans        uint64
+	delayedSpans uint64
+	// batches and the byte counts cover requests sent to an endpoint.
+	batches           uint64
+	uncompressedBytes uint64
+	compressedBytes   uint64
+	// run, when set, also counts the requests; a fan-out sink's stats
+	// point at the run's.
+	run *emitterStats
+	// control, when set, adjusts an adaptive run's rate to the requests.
+	control *rateController
+
+	mu        sync.Mutex
+	endpoints map[endpointKey]*endpointReport
+	// endpointOrder lists endpoints as first used.
+	endpointOrder []endpointKey
+	// requests and requestErrors count every request, and sendLatency
+	// samples their latencies.
+	requests      uint64
+	requestErrors uint64
+	sendLatency   []time.Duration
+	latencyRNG    *rand.Rand
+}
+
+// sendReport totals the requests sent to endpoints, retries included, and
+// their latency percentiles from up to latencyReservoirSize samples.
+type sendReport struct {
+	Requests  uint64             `json:"requests"`
+	Errors    uint64             `json:"errors"`
+	ErrorRate float64            `json:"error_rate"`
+	Latency   latencyPercentiles `json:"latency"`
+}
+
+type endpointKey struct {
+	endpoint, address string
+}
+
+// endpointReport counts the requests sent to one endpoint address. Sent
+// spans and bytes count the requests that were accepted; the latency covers
+// every request.
+type endpointReport struct {
+	Endpoint      string  `json:"endpoint"`
+	Address       string  `json:"address,omitempty"`
+	Requests      uint64  `json:"requests"`
+	Errors        uint64  `json:"errors"`
+	SentSpans     uint64  `json:"sent_spans"`
+	SentBytes     uint64  `json:"sent_bytes"`
+	MeanLatencyMs float64 `json:"mean_latency_ms"`
+
+	latency time.Duration
 }
 
 type statsSnapshot struct {
@@ -21,6 +75,18 @@ type statsSnapshot struct {
 	UptimeSeconds float64   `json:"uptime_seconds"`
 	EmittedTraces uint64    `json:"emitted_traces"`
 	EmittedSpans  uint64    `json:"emitted_spans"`
+	DelayedSpans  uint64    `json:"delayed_spans"`
+	// SentBatches and the byte counts are for network outputs: the request
+	// bodies sent, before and after compression.
+	SentBatches       uint64 `json:"sent_batches,omitempty"`
+	UncompressedBytes uint64 `json:"uncompressed_bytes,omitempty"`
+	CompressedBytes   uint64 `json:"compressed_bytes,omitempty"`
+	// Endpoints breaks network requests down by endpoint address.
+	Endpoints []endpointReport `json:"endpoints,omitempty"`
+	// Send totals the requests to endpoints and their latency.
+	Send *sendReport `json:"send,omitempty"`
+	// AdaptiveTracesPerSecond is the rate an adaptive run is generating at.
+	AdaptiveTracesPerSecond float64 `json:"adaptive_traces_per_second,omitempty"`
 }
 
 func newEmitterStats() *emitterStats {
@@ -36,15 +102,87 @@ func (s *emitterStats) add(traces, spans int) {
 	}
 }
 
+func (s *emitterStats) addDelayed(spans int) {
+	if spans > 0 {
+		atomic.AddUint64(&s.delayedSpans, uint64(spans))
+	}
+}
+
+// addPayload counts one request sent to an endpoint.
+func (s *emitterStats) addPayload(size sink.PayloadSize) {
+	if size.Uncompressed <= 0 {
+		return
+	}
+	atomic.AddUint64(&s.batches, 1)
+	atomic.AddUint64(&s.uncompressedBytes, uint64(size.Uncompressed))
+	atomic.AddUint64(&s.compressedBytes, uint64(size.Compressed))
+	if s.run != nil {
+		s.run.addPayload(size)
+	}
+}
+
+// addRequest counts one request to an endpoint address, with the size of
+// its body when it was accepted.
+func (s *emitterStats) addRequest(endpoint, address string, spans int, size sink.PayloadSize, latency time.Duration, err error) {
+	s.mu.Lock()
+	key := endpointKey{endpoint, address}
+	r, ok := s.endpoints[key]
+	if !ok {
+		if s.endpoints == nil {
+			s.endpoints = map[endpointKey]*endpointReport{}
+		}
+		r = &endpointReport{Endpoint: endpoint, Address: address}
+		s.endpoints[key] = r
+		s.endpointOrder = append(s.endpointOrder, key)
+	}
+	r.Requests++
+	r.latency += latency
+	if err != nil {
+		r.Errors++
+		s.requestErrors++
+	} else {
+		r.SentSpans += uint64(spans)
+		r.SentBytes += uint64(size.Compressed)
+	}
+	s.requests++
+	if len(s.sendLatency) < latencyReservoirSize {
+		s.sendLatency = append(s.sendLatency, latency)
+	} else {
+		if s.latencyRNG == nil {
+			s.latencyRNG = rand.New(rand.NewSource(1))
+		}
+		if i := s.latencyRNG.Int63n(int64(s.requests)); i < latencyReservoirSize {
+			s.sendLatency[i] = latency
+		}
+	}
+	s.mu.Unlock()
+	if s.control != nil {
+		s.control.observe(latency, err)
+	}
+	if s.run != nil {
+		s.run.addRequest(endpoint, address, spans, size, latency, err)
+	}
+}
+
 func (s *emitterStats) snapshot() statsSnapshot {
 	now := time.Now().UTC()
-	return statsSnapshot{
-		Status:        "ok",
-		StartedAt:     s.startedAt,
-		UptimeSeconds: now.Sub(s.startedAt).Seconds(),
-		EmittedTraces: atomic.LoadUint64(&s.traces),
-		EmittedSpans:  atomic.LoadUint64(&s.spans),
+	snapshot := statsSnapshot{
+		Status:            "ok",
+		StartedAt:         s.startedAt,
+		UptimeSeconds:     now.Sub(s.startedAt).Seconds(),
+		EmittedTraces:     atomic.LoadUint64(&s.traces),
+		EmittedSpans:      atomic.LoadUint64(&s.spans),
+		DelayedSpans:      atomic.LoadUint64(&s.delayedSpans),
+		SentBatches:       atomic.LoadUint64(&s.batches),
+		UncompressedBytes: atomic.LoadUint64(&s.uncompressedBytes),
+		CompressedBytes:   atomic.LoadUint64(&s.compressedBytes),
+		Endpoints:         s.endpointSnapshot(),
+		Send:              s.sendSnapshot(),
 	}
+	if s.control != nil {
+		snapshot.AdaptiveTracesPerSecond = s.control.rate()
+	}
+	return snapshot
 }
 
 func adminHandler(stats *emitterStats) http.Handler {
@@ -90,3 +228,38 @@ func runAdminServer(ctx context.Context, listenAddr string, stats *emitterStats)
 	}
 	return err
 }
+
+func (s *emitterStats) endpointSnapshot() []endpointReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.endpointOrder) == 0 {
+		return nil
+	}
+	reports := make([]endpointReport, 0, len(s.endpointOrder))
+	for _, key := range s.endpointOrder {
+		r := *s.endpoints[key]
+		r.MeanLatencyMs = float64(r.latency) / float64(time.Millisecond) / float64(r.Requests)
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+func (s *emitterStats) sendSnapshot() *sendReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.requests == 0 {
+		return nil
+	}
+	sorted := append([]time.Duration(nil), s.sendLatency...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return &sendReport{
+		Requests:  s.requests,
+		Errors:    s.requestErrors,
+		ErrorRate: float64(s.requestErrors) / float64(s.requests),
+		Latency: latencyPercentiles{
+			P50Ms: millis(percentile(sorted, 0.50)),
+			P95Ms: millis(percentile(sorted, 0.95)),
+			P99Ms: millis(percentile(sorted, 0.99)),
+		},
+	}
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+)
+
+const (
+	// defaultBurstSize is the traces per burst when --burst-size is unset.
+	defaultBurstSize = 20
+	// onOffShape is the Pareto shape of on and off periods. Shapes between
+	// 1 and 2 give the heavy-tailed periods that make traffic self-similar.
+	onOffShape = 1.5
+	// onOffMaxPeriods caps one on or off period at this many mean periods.
+	onOffMaxPeriods = 100
+)
+
+// arrivalProcess yields trace start offsets from the start of a run. Offsets
+// never decrease, and the long-run rate matches the configured rate.
+type arrivalProcess interface {
+	next() time.Duration
+}
+
+// newArrivalProcess returns the process for --arrival, or nil for uniform
+// arrivals, which use the token bucket in produceTraceSteady.
+func newArrivalProcess(cfg config.Config, ratePerSecond float64) arrivalProcess {
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	meanGap := float64(time.Second) / ratePerSecond
+	burstSize := cfg.BurstSize
+	if burstSize <= 0 {
+		burstSize = defaultBurstSize
+	}
+	switch cfg.Arrival {
+	case config.ArrivalPoisson:
+		return &poissonArrivals{rng: rng, meanGap: meanGap}
+	case config.ArrivalBursty:
+		if cfg.BurstInterval <= 0 {
+			return &burstyArrivals{rng: rng, size: burstSize, meanGap: meanGap * float64(burstSize)}
+		}
+		// Fixed bursts carry up to the whole rate; any rate left over
+		// arrives as Poisson background traffic between them.
+		size := int(math.Min(float64(burstSize), math.Max(1, ratePerSecond*cfg.BurstInterval.Seconds())))
+		background := ratePerSecond - float64(size)/cfg.BurstInterval.Seconds()
+		arrivals := &burstyArrivals{rng: rng, size: size, interval: cfg.BurstInterval}
+		if background > 0 {
+			arrivals.background = &poissonArrivals{rng: rng, meanGap: float64(time.Second) / background}
+			arrivals.nextBackground = arrivals.background.next()
+		}
+		return arrivals
+	case config.ArrivalOnOff:
+		period := cfg.BurstInterval
+		if period <= 0 {
+			period = time.Second
+		}
+		return &onOffArrivals{rng: rng, meanGap: meanGap / 2, meanPeriod: float64(period)}
+	}
+	return nil
+}
+
+// poissonArrivals spaces traces by exponential gaps.
+type poissonArrivals struct {
+	rng     *rand.Rand
+	meanGap float64
+	offset  time.Duration
+}
+
+func (p *poissonArrivals) next() time.Duration {
+	p.offset += time.Duration(p.rng.ExpFloat64() * p.meanGap)
+	return p.offset
+}
+
+// burstyArrivals sends traces in bursts of size. Bursts either start after
+// exponential gaps averaging meanGap or every interval, with optional
+// Poisson background traffic.
+type burstyArrivals struct {
+	rng            *rand.Rand
+	size           int
+	meanGap        float64
+	interval       time.Duration
+	background     *poissonArrivals
+	nextBackground time.Duration
+	burstStart     time.Duration
+	remaining      int
+	started        bool
+}
+
+func (b *burstyArrivals) next() time.Duration {
+	if b.remaining == 0 {
+		if b.started && b.interval > 0 {
+			b.burstStart += b.interval
+		} else if b.started {
+			b.burstStart += time.Duration(b.rng.ExpFloat64() * b.meanGap)
+		}
+		b.started = true
+		b.remaining = b.size
+	}
+	if b.background != nil && b.nextBackground < b.burstStart {
+		offset := b.nextBackground
+		b.nextBackground = b.background.next()
+		return offset
+	}
+	b.remaining--
+	return b.burstStart
+}
+
+// onOffArrivals alternates Pareto-length on and off periods of equal mean.
+// During on periods traces arrive as Poisson traffic at twice the rate.
+type onOffArrivals struct {
+	rng        *rand.Rand
+	meanGap    float64
+	meanPeriod float64
+	offset     time.Duration
+	onEnd      time.Duration
+	started    bool
+}
+
+func (o *onOffArrivals) next() time.Duration {
+	if !o.started {
+		o.started = true
+		o.onEnd = o.period()
+	}
+	o.offset += time.Duration(o.rng.ExpFloat64() * o.meanGap)
+	for o.offset >= o.onEnd {
+		// Gaps are memoryless, so the rest of the gap carries over into
+		// the next on period.
+		overshoot := o.offset - o.onEnd
+		onStart := o.onEnd + o.period()
+		o.onEnd = onStart + o.period()
+		o.offset = onStart + overshoot
+	}
+	return o.offset
+}
+
+// period samples a Pareto-distributed period length with mean meanPeriod.
+func (o *onOffArrivals) period() time.Duration {
+	scale := o.meanPeriod * (onOffShape - 1) / onOffShape
+	length := scale / math.Pow(1-o.rng.Float64(), 1/onOffShape)
+	return time.Duration(math.Min(length, o.meanPeriod*onOffMaxPeriods))
+}
+
+// produceArrivals dispatches trace starts at the offsets of arrivals and
+// waits for each one, so send pacing follows the same process as the
+// scheduled timestamps.
+func produceArrivals(ctx context.Context, cfg config.Config, arrivals arrivalProcess, jobs chan<- time.Time) {
+	start := time.Now().UTC()
+	hasDurationLimit := cfg.Count <= 0 && cfg.Duration > 0
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+	for sent := 0; cfg.Count <= 0 || sent < cfg.Count; sent++ {
+		offset := arrivals.next()
+		if hasDurationLimit && offset > cfg.Duration {
+			return
+		}
+		scheduled := start.Add(offset)
+		if wait := time.Until(scheduled); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+		}
+		select {
+		case jobs <- scheduled:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
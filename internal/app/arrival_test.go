@@ -0,0 +1,120 @@
+package app
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+)
+
+func arrivalOffsets(t *testing.T, cfg config.Config, rate float64, n int) []time.Duration {
+	t.Helper()
+	arrivals := newArrivalProcess(cfg, rate)
+	if arrivals == nil {
+		t.Fatalf("no arrival process for %q", cfg.Arrival)
+	}
+	offsets := make([]time.Duration, n)
+	for i := range offsets {
+		offsets[i] = arrivals.next()
+		if i > 0 && offsets[i] < offsets[i-1] {
+			t.Fatalf("offset %d=%s went back from %s", i, offsets[i], offsets[i-1])
+		}
+	}
+	return offsets
+}
+
+func checkMeanRate(t *testing.T, offsets []time.Duration, rate, tolerance float64) {
+	t.Helper()
+	got := float64(len(offsets)) / offsets[len(offsets)-1].Seconds()
+	if got < rate*(1-tolerance) || got > rate*(1+tolerance) {
+		t.Fatalf("mean rate=%.1f/s want %.1f/s", got, rate)
+	}
+}
+
+// dispersion is the variance over the mean of trace counts per window. It
+// is about 1 for Poisson traffic and grows with burstiness.
+func dispersion(offsets []time.Duration, window time.Duration) float64 {
+	counts := map[int64]float64{}
+	last := int64(offsets[len(offsets)-1] / window)
+	for _, offset := range offsets {
+		counts[int64(offset/window)]++
+	}
+	var sum, sumSq float64
+	for i := int64(0); i <= last; i++ {
+		sum += counts[i]
+		sumSq += counts[i] * counts[i]
+	}
+	n := float64(last + 1)
+	mean := sum / n
+	return (sumSq/n - mean*mean) / mean
+}
+
+func TestUniformArrivalUsesTokenBucket(t *testing.T) {
+	for _, arrival := range []string{"", config.ArrivalUniform} {
+		if newArrivalProcess(config.Config{Arrival: arrival}, 100) != nil {
+			t.Fatalf("arrival %q has a process, want the token bucket", arrival)
+		}
+	}
+}
+
+func TestPoissonArrivalsKeepRate(t *testing.T) {
+	offsets := arrivalOffsets(t, config.Config{Arrival: config.ArrivalPoisson, Seed: 1}, 200, 20000)
+	checkMeanRate(t, offsets, 200, 0.05)
+	if d := dispersion(offsets, 100*time.Millisecond); d < 0.8 || d > 1.2 {
+		t.Fatalf("poisson dispersion=%.2f want about 1", d)
+	}
+}
+
+func TestBurstyArrivalsSendWholeBursts(t *testing.T) {
+	offsets := arrivalOffsets(t, config.Config{Arrival: config.ArrivalBursty, BurstSize: 10, Seed: 1}, 200, 20000)
+	checkMeanRate(t, offsets, 200, 0.1)
+	for i := 0; i < len(offsets); i += 10 {
+		if offsets[i] != offsets[i+9] {
+			t.Fatalf("burst at %d spans %s..%s want one instant", i, offsets[i], offsets[i+9])
+		}
+	}
+	if d := dispersion(offsets, 100*time.Millisecond); d < 5 {
+		t.Fatalf("bursty dispersion=%.2f want well above poisson", d)
+	}
+}
+
+func TestBurstyArrivalsWithIntervalAddBackground(t *testing.T) {
+	cfg := config.Config{Arrival: config.ArrivalBursty, BurstSize: 50, BurstInterval: time.Second, Seed: 1}
+	offsets := arrivalOffsets(t, cfg, 200, 20000)
+	checkMeanRate(t, offsets, 200, 0.05)
+	atBurst := map[time.Duration]int{}
+	for _, offset := range offsets {
+		if offset%time.Second == 0 {
+			atBurst[offset]++
+		}
+	}
+	if atBurst[3*time.Second] != 50 {
+		t.Fatalf("burst at 3s has %d traces want 50", atBurst[3*time.Second])
+	}
+}
+
+func TestOnOffArrivalsAreSelfSimilar(t *testing.T) {
+	cfg := config.Config{Arrival: config.ArrivalOnOff, BurstInterval: 500 * time.Millisecond, Seed: 1}
+	offsets := arrivalOffsets(t, cfg, 200, 40000)
+	checkMeanRate(t, offsets, 200, 0.35)
+	// Heavy-tailed periods keep traffic bursty over longer windows.
+	if d := dispersion(offsets, 2*time.Second); d < 10 {
+		t.Fatalf("on-off dispersion over 2s=%.2f want well above poisson", d)
+	}
+}
+
+func TestRunWithPoissonArrivals(t *testing.T) {
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	cfg := reportTestConfig(reportPath)
+	cfg.Count = 20
+	cfg.RateValue = 2000
+	cfg.Arrival = config.ArrivalPoisson
+	if err := Run(cfg, bytes.NewBuffer(nil)); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got := readReport(t, reportPath)["emitted_traces"]; got != float64(20) {
+		t.Fatalf("emitted_traces=%v want 20", got)
+	}
+}
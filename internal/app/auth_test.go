@@ -0,0 +1,117 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	collectortracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRunAuthenticatesWithOAuth2(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	var tokens, accepted, rejected atomic.Int64
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, ok := r.BasicAuth(); !ok || id != "spanforge" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		tokens.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "good", "token_type": "bearer", "expires_in": 3600})
+	})
+	mux.HandleFunc("/v1/traces", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer good" {
+			rejected.Add(1)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		accepted.Add(1)
+	})
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Listener = lis
+	srv.Start()
+	defer srv.Close()
+
+	t.Setenv("SPANFORGE_TEST_CLIENT_SECRET", "s3cret")
+	cfg := reportTestConfig("")
+	cfg.Count = 10
+	cfg.BatchSize = 2
+	cfg.Output = "otlp"
+	cfg.OTLPEndpoint = srv.URL
+	if cfg.Auth, err = config.ParseAuth("oauth2,token-url=" + srv.URL + "/token,client-id=spanforge,client-secret-env=SPANFORGE_TEST_CLIENT_SECRET"); err != nil {
+		t.Fatalf("ParseAuth: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := Run(cfg, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if accepted.Load() < 2 || rejected.Load() != 0 || tokens.Load() != 1 {
+		t.Fatalf("accepted=%d rejected=%d tokens=%d want several accepted on one token", accepted.Load(), rejected.Load(), tokens.Load())
+	}
+}
+
+// authTraceSvc accepts exports carrying the bearer token it expects.
+type authTraceSvc struct {
+	collectortracev1.UnimplementedTraceServiceServer
+	want     string
+	accepted atomic.Int64
+}
+
+func (s *authTraceSvc) Export(ctx context.Context, _ *collectortracev1.ExportTraceServiceRequest) (*collectortracev1.ExportTraceServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if got := md.Get("authorization"); len(got) != 1 || got[0] != s.want {
+		return nil, status.Errorf(codes.Unauthenticated, "authorization=%v", got)
+	}
+	s.accepted.Add(1)
+	return &collectortracev1.ExportTraceServiceResponse{}, nil
+}
+
+func TestRunOTLPGRPCSendsBearerTokenFromFile(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	svc := &authTraceSvc{want: "Bearer from-file"}
+	srv := grpc.NewServer()
+	collectortracev1.RegisterTraceServiceServer(srv, svc)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	cfg := reportTestConfig("")
+	cfg.Format = "otlp-grpc"
+	cfg.Output = "otlp"
+	cfg.OTLPEndpoint = lis.Addr().String()
+	cfg.OTLPInsecure = true
+	cfg.Auth = &config.Auth{Method: config.AuthBearer, TokenFile: tokenFile}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := Run(cfg, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if svc.accepted.Load() == 0 {
+		t.Fatalf("no authenticated exports")
+	}
+}
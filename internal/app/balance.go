@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+// networkClient sends spans to one endpoint address.
+type networkClient interface {
+	SendSpans(ctx context.Context, spans []model.Span) error
+}
+
+// rawSender is a client that can send a body as it is, for
+// bad-encoded-payload.
+type rawSender interface {
+	SendRaw(ctx context.Context, body []byte) error
+}
+
+// backend is one address requests are balanced across: an endpoint, or one
+// address it resolves to under --lb-resolve.
+type backend struct {
+	endpoint string
+	// address is the resolved host:port, or empty to connect to the
+	// endpoint's host.
+	address  string
+	client   networkClient
+	inFlight atomic.Int64
+}
+
+// backendPool picks a backend for each request by its policy.
+type backendPool struct {
+	policy   string
+	backends []*backend
+	next     atomic.Uint64
+}
+
+// acquire picks a backend and counts a request in flight on it until done
+// is called.
+func (p *backendPool) acquire() (b *backend, done func()) {
+	start := int((p.next.Add(1) - 1) % uint64(len(p.backends)))
+	b = p.backends[start]
+	if p.policy == config.LBLeastLoaded {
+		// Ties go to the next backend in turn, so idle backends share
+		// the load.
+		for i := 1; i < len(p.backends); i++ {
+			candidate := p.backends[(start+i)%len(p.backends)]
+			if candidate.inFlight.Load() < b.inFlight.Load() {
+				b = candidate
+			}
+		}
+	}
+	b.inFlight.Add(1)
+	return b, func() { b.inFlight.Add(-1) }
+}
+
+// resolveEndpoint returns host:port for each address endpoint's host
+// resolves to, in a stable order.
+func resolveEndpoint(ctx context.Context, endpoint string) ([]string, error) {
+	host, port, err := endpointHostPort(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", endpoint, err)
+	}
+	sort.Strings(addrs)
+	resolved := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		resolved = append(resolved, net.JoinHostPort(addr, port))
+	}
+	return resolved, nil
+}
+
+// endpointHostPort splits an endpoint URL or host:port. URLs without a
+// port take their scheme's.
+func endpointHostPort(endpoint string) (string, string, error) {
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+		}
+		port := u.Port()
+		switch {
+		case port != "":
+		case u.Scheme == "https":
+			port = "443"
+		default:
+			port = "80"
+		}
+		return u.Hostname(), port, nil
+	}
+	hostPort, _, _ := strings.Cut(endpoint, "/")
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return "", "", fmt.Errorf("endpoint %q needs a port to resolve: %w", endpoint, err)
+	}
+	return host, port, nil
+}
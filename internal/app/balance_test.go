@@ -0,0 +1,215 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
+	collectortracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRunBalancesAcrossEndpoints(t *testing.T) {
+	decode := func(body []byte) error {
+		return proto.Unmarshal(body, &collectortracev1.ExportTraceServiceRequest{})
+	}
+	a := &sizeServer{decode: decode}
+	b := &sizeServer{decode: decode}
+	srvA, srvB := startSizeServer(t, a), startSizeServer(t, b)
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	cfg := reportTestConfig(reportPath)
+	cfg.Output = "otlp"
+	cfg.OTLPEndpoint = srvA.URL + ", " + srvB.URL
+	cfg.BatchSize = 1
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := Run(cfg, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	report := readRunReport(t, reportPath)
+	if len(report.Endpoints) != 2 {
+		t.Fatalf("endpoints=%+v want 2", report.Endpoints)
+	}
+	var spans uint64
+	for i, s := range []*sizeServer{a, b} {
+		r := report.Endpoints[i]
+		s.mu.Lock()
+		requests := s.requests
+		s.mu.Unlock()
+		if r.Requests != uint64(requests) || r.Errors != 0 {
+			t.Fatalf("endpoint %s requests=%d errors=%d, server saw %d", r.Endpoint, r.Requests, r.Errors, requests)
+		}
+		spans += r.SentSpans
+	}
+	// One request in flight at a time alternates between the two.
+	if diff := int(report.Endpoints[0].Requests) - int(report.Endpoints[1].Requests); diff < -1 || diff > 1 {
+		t.Fatalf("round-robin split %d/%d", report.Endpoints[0].Requests, report.Endpoints[1].Requests)
+	}
+	if spans != report.EmittedSpans {
+		t.Fatalf("endpoints sent %d spans, run emitted %d", spans, report.EmittedSpans)
+	}
+}
+
+func TestBackendPoolLeastLoaded(t *testing.T) {
+	pool := &backendPool{policy: config.LBLeastLoaded, backends: []*backend{{endpoint: "a"}, {endpoint: "b"}, {endpoint: "c"}}}
+	first, doneFirst := pool.acquire()
+	second, doneSecond := pool.acquire()
+	if first == second {
+		t.Fatalf("two requests went to %s while others were idle", first.endpoint)
+	}
+	doneFirst()
+	// first is idle again, so it and the untouched backend tie; second is
+	// busy.
+	for i := 0; i < 6; i++ {
+		b, done := pool.acquire()
+		if b == second {
+			t.Fatalf("request went to busy backend %s", b.endpoint)
+		}
+		done()
+	}
+	doneSecond()
+}
+
+func TestNetworkClientDialsResolvedAddress(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	var host atomic.Value
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host.Store(r.Host)
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.Listener = lis
+	srv.Start()
+	defer srv.Close()
+
+	cfg := reportTestConfig("")
+	cfg.Format = "otlp-http"
+	// The endpoint's host does not resolve; the client connects to the
+	// address it was given and still names the endpoint's host.
+	client := newNetworkClient(cfg, "http://collector.invalid:4318", lis.Addr().String(), nil, nil)
+	spans := []model.Span{{TraceID: model.TraceID{1}, SpanID: model.SpanID{1}, Name: "op"}}
+	if err := client.SendSpans(context.Background(), spans); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got := host.Load(); got != "collector.invalid:4318" {
+		t.Fatalf("host=%v want collector.invalid:4318", got)
+	}
+
+	addrs, err := resolveEndpoint(context.Background(), "http://127.0.0.1:4318/v1")
+	if err != nil || len(addrs) != 1 || addrs[0] != "127.0.0.1:4318" {
+		t.Fatalf("resolve=%v err=%v", addrs, err)
+	}
+	if _, err := resolveEndpoint(context.Background(), "collector"); err == nil {
+		t.Fatalf("resolving an endpoint without a port succeeded")
+	}
+}
+
+func TestRunHTTPVersion(t *testing.T) {
+	for _, tc := range []struct {
+		version string
+		major   int
+	}{
+		{"auto", 1},
+		{"1.1", 1},
+		{"2", 2},
+	} {
+		t.Run(tc.version, func(t *testing.T) {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Skipf("listen unavailable in this environment: %v", err)
+			}
+			var major atomic.Int64
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				major.Store(int64(r.ProtoMajor))
+				w.WriteHeader(http.StatusOK)
+			}))
+			srv.Listener = lis
+			srv.Config.Protocols = new(http.Protocols)
+			srv.Config.Protocols.SetHTTP1(true)
+			srv.Config.Protocols.SetUnencryptedHTTP2(true)
+			srv.Start()
+			defer srv.Close()
+
+			cfg := reportTestConfig("")
+			cfg.Output = "otlp"
+			cfg.OTLPEndpoint = srv.URL
+			cfg.HTTPVersion = tc.version
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			if err := Run(cfg, &bytes.Buffer{}); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if got := major.Load(); got != int64(tc.major) {
+				t.Fatalf("server saw HTTP/%d want HTTP/%d", got, tc.major)
+			}
+		})
+	}
+}
+
+// countingListener counts the connections it accepts.
+type countingListener struct {
+	net.Listener
+	accepted atomic.Int64
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Add(1)
+	}
+	return conn, err
+}
+
+func TestRunOTLPGRPCConnections(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	counting := &countingListener{Listener: lis}
+	svc := &testTraceSvc{}
+	srv := grpc.NewServer()
+	collectortracev1.RegisterTraceServiceServer(srv, svc)
+	go func() { _ = srv.Serve(counting) }()
+	defer srv.Stop()
+
+	cfg := reportTestConfig("")
+	cfg.Format = "otlp-grpc"
+	cfg.Output = "otlp"
+	cfg.OTLPEndpoint = lis.Addr().String()
+	cfg.OTLPInsecure = true
+	cfg.Connections = 3
+	cfg.GRPCKeepalive = time.Minute
+	cfg.GRPCKeepaliveWait = time.Second
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := Run(cfg, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if svc.spans == 0 {
+		t.Fatalf("server got no spans")
+	}
+	if got := counting.accepted.Load(); got != 3 {
+		t.Fatalf("server accepted %d connections want 3", got)
+	}
+
+	// Exports larger than --grpc-max-message-size fail before sending.
+	cfg.GRPCMaxMessage = 64
+	if err := Run(cfg, &bytes.Buffer{}); err == nil {
+		t.Fatalf("run with a 64-byte message limit succeeded")
+	}
+}
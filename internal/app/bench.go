@@ -0,0 +1,312 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+)
+
+// Bench search strategies.
+const (
+	// BenchSearchStep raises the rate by a fixed step until a step fails.
+	BenchSearchStep = "step"
+	// BenchSearchBinary doubles the rate until a step fails, then bisects
+	// between the last passing and first failing rates.
+	BenchSearchBinary = "binary"
+)
+
+// BenchOptions configures a search for the highest rate a sink sustains.
+// Rates are in the run's --rate-unit per --rate-interval.
+type BenchOptions struct {
+	Search    string
+	StartRate float64
+	// MaxRate caps the search; 0 is no cap for binary search.
+	MaxRate float64
+	// StepRate is what step search adds each step; 0 adds StartRate.
+	StepRate float64
+	// StepDuration is how long each rate is held.
+	StepDuration time.Duration
+	// Cooldown is a pause between steps, for the sink to drain.
+	Cooldown time.Duration
+	// MaxErrorRate is the highest fraction of failed requests a passing
+	// step may have.
+	MaxErrorRate float64
+	// MaxP99Latency is the highest p99 send latency a passing step may
+	// have; 0 does not check latency.
+	MaxP99Latency time.Duration
+	// MinThroughput is the lowest fraction of the target rate a passing
+	// step must achieve.
+	MinThroughput float64
+	// Precision ends a binary search once the failing rate is within this
+	// fraction of the passing rate.
+	Precision float64
+	// MaxSteps bounds the number of steps.
+	MaxSteps int
+}
+
+func (o BenchOptions) Validate() error {
+	switch o.Search {
+	case BenchSearchStep, BenchSearchBinary:
+	default:
+		return fmt.Errorf("search must be step or binary")
+	}
+	if o.StartRate <= 0 {
+		return fmt.Errorf("start-rate must be > 0")
+	}
+	if o.MaxRate < 0 || (o.MaxRate > 0 && o.MaxRate < o.StartRate) {
+		return fmt.Errorf("max-rate must be 0 or >= start-rate")
+	}
+	if o.StepRate < 0 {
+		return fmt.Errorf("rate-step must be >= 0")
+	}
+	if o.StepDuration <= 0 {
+		return fmt.Errorf("step-duration must be > 0")
+	}
+	if o.Cooldown < 0 {
+		return fmt.Errorf("cooldown must be >= 0")
+	}
+	if o.MaxErrorRate < 0 || o.MaxErrorRate > 1 || o.MinThroughput < 0 || o.MinThroughput > 1 {
+		return fmt.Errorf("max-error-rate and min-throughput must be in [0,1]")
+	}
+	if o.MaxP99Latency < 0 {
+		return fmt.Errorf("max-p99-latency must be >= 0")
+	}
+	if o.Precision <= 0 || o.Precision >= 1 {
+		return fmt.Errorf("precision must be in (0,1)")
+	}
+	if o.MaxSteps <= 0 {
+		return fmt.Errorf("max-steps must be > 0")
+	}
+	return nil
+}
+
+// BenchResult is the outcome of a bench search. The maximum sustainable
+// rate is the highest passing step's; its spans and traces per second are
+// what that step achieved.
+type BenchResult struct {
+	Search                        string      `json:"search"`
+	RateUnit                      string      `json:"rate_unit"`
+	StepSeconds                   float64     `json:"step_seconds"`
+	SLO                           benchSLO    `json:"slo"`
+	MaxSustainableRate            float64     `json:"max_sustainable_rate"`
+	MaxSustainableSpansPerSecond  float64     `json:"max_sustainable_spans_per_second"`
+	MaxSustainableTracesPerSecond float64     `json:"max_sustainable_traces_per_second"`
+	Steps                         []BenchStep `json:"steps"`
+}
+
+type benchSLO struct {
+	MaxErrorRate    float64 `json:"max_error_rate"`
+	MaxP99LatencyMs float64 `json:"max_p99_latency_ms,omitempty"`
+	MinThroughput   float64 `json:"min_throughput"`
+}
+
+// BenchStep is one rate held for the step duration. Requests and errors
+// count every attempt, retries included; failed spans are the ones
+// dropped once retries ran out.
+type BenchStep struct {
+	Step            int                `json:"step"`
+	Rate            float64            `json:"rate"`
+	TracesPerSecond float64            `json:"traces_per_second"`
+	SpansPerSecond  float64            `json:"spans_per_second"`
+	Throughput      float64            `json:"throughput"`
+	Requests        uint64             `json:"requests"`
+	Errors          uint64             `json:"errors"`
+	ErrorRate       float64            `json:"error_rate"`
+	FailedSpans     uint64             `json:"failed_spans"`
+	Latency         latencyPercentiles `json:"latency"`
+	Pass            bool               `json:"pass"`
+	Reason          string             `json:"reason,omitempty"`
+}
+
+// Bench searches for the highest rate the run's sink sustains, running cfg
+// at each rate the search picks for opts.StepDuration.
+func Bench(cfg config.Config, opts BenchOptions, progress io.Writer) (BenchResult, error) {
+	if err := opts.Validate(); err != nil {
+		return BenchResult{}, err
+	}
+	if cfg.Adaptive() || cfg.Curve != nil || strings.TrimSpace(cfg.PhaseFile) != "" || strings.TrimSpace(cfg.Load) != "" {
+		return BenchResult{}, fmt.Errorf("bench holds each rate steady and cannot be combined with rate-control=adaptive, curve, phase-file or load")
+	}
+	result := BenchResult{
+		Search:      opts.Search,
+		RateUnit:    fmt.Sprintf("%s/%s", cfg.RateUnit, cfg.RateInterval),
+		StepSeconds: opts.StepDuration.Seconds(),
+		SLO: benchSLO{
+			MaxErrorRate:    opts.MaxErrorRate,
+			MaxP99LatencyMs: millis(opts.MaxP99Latency),
+			MinThroughput:   opts.MinThroughput,
+		},
+	}
+	var best BenchStep
+	step := func(rate float64) (bool, error) {
+		if len(result.Steps) > 0 && opts.Cooldown > 0 {
+			time.Sleep(opts.Cooldown)
+		}
+		s, err := benchStep(cfg, opts, len(result.Steps)+1, rate)
+		if err != nil {
+			return false, err
+		}
+		result.Steps = append(result.Steps, s)
+		if progress != nil {
+			fmt.Fprintf(progress, "bench step %d: rate=%.2f spans/sec=%.2f error_rate=%.4f p99=%.1fms %s\n",
+				s.Step, s.Rate, s.SpansPerSecond, s.ErrorRate, s.Latency.P99Ms, passLabel(s))
+		}
+		if s.Pass && s.Rate > best.Rate {
+			best = s
+		}
+		return s.Pass, nil
+	}
+
+	var err error
+	if opts.Search == BenchSearchStep {
+		err = benchStepSearch(opts, step)
+	} else {
+		err = benchBinarySearch(opts, step)
+	}
+	if err != nil {
+		return BenchResult{}, err
+	}
+	result.MaxSustainableRate = best.Rate
+	result.MaxSustainableSpansPerSecond = best.SpansPerSecond
+	result.MaxSustainableTracesPerSecond = best.TracesPerSecond
+	return result, nil
+}
+
+func benchStepSearch(opts BenchOptions, step func(float64) (bool, error)) error {
+	increment := opts.StepRate
+	if increment == 0 {
+		increment = opts.StartRate
+	}
+	for i, rate := 0, opts.StartRate; i < opts.MaxSteps; i, rate = i+1, rate+increment {
+		if opts.MaxRate > 0 && rate > opts.MaxRate {
+			return nil
+		}
+		pass, err := step(rate)
+		if err != nil || !pass {
+			return err
+		}
+	}
+	return nil
+}
+
+func benchBinarySearch(opts BenchOptions, step func(float64) (bool, error)) error {
+	// lo passed and hi failed; hi is 0 until a step fails.
+	lo, hi := 0.0, 0.0
+	rate := opts.StartRate
+	for i := 0; i < opts.MaxSteps; i++ {
+		pass, err := step(rate)
+		if err != nil {
+			return err
+		}
+		if pass {
+			lo = rate
+		} else {
+			hi = rate
+		}
+		switch {
+		case hi == 0 && opts.MaxRate > 0 && lo >= opts.MaxRate:
+			return nil
+		case hi == 0:
+			rate = lo * 2
+			if opts.MaxRate > 0 {
+				rate = math.Min(rate, opts.MaxRate)
+			}
+		case hi-lo <= opts.Precision*hi:
+			return nil
+		default:
+			rate = (lo + hi) / 2
+		}
+	}
+	return nil
+}
+
+// benchStep runs cfg at rate for the step duration and checks the step
+// against the SLOs.
+func benchStep(cfg config.Config, opts BenchOptions, n int, rate float64) (BenchStep, error) {
+	cfg.RateValue = rate
+	cfg.Count = 0
+	cfg.Duration = opts.StepDuration
+	cfg.ReportFile = ""
+	if err := cfg.Validate(); err != nil {
+		return BenchStep{}, err
+	}
+	failures := &sinkFailures{}
+	report, err := run(cfg, io.Discard, failures)
+	if err != nil {
+		return BenchStep{}, fmt.Errorf("bench step %d at rate %.2f: %w", n, rate, err)
+	}
+	s := BenchStep{
+		Step:            n,
+		Rate:            rate,
+		TracesPerSecond: report.TracesPerSecond,
+		SpansPerSecond:  report.SpansPerSecond,
+		FailedSpans:     atomic.LoadUint64(&failures.spans),
+	}
+	if report.Send != nil {
+		s.Requests = report.Send.Requests
+		s.Errors = report.Send.Errors
+		s.ErrorRate = report.Send.ErrorRate
+		s.Latency = report.Send.Latency
+	}
+	achieved := report.SpansPerSecond
+	if cfg.RateUnit == config.RateUnitTraces {
+		achieved = report.TracesPerSecond
+	}
+	s.Throughput = achieved * cfg.RateInterval.Seconds() / rate
+
+	var reasons []string
+	if s.ErrorRate > opts.MaxErrorRate {
+		reasons = append(reasons, fmt.Sprintf("error rate %.4f over %.4f", s.ErrorRate, opts.MaxErrorRate))
+	}
+	if opts.MaxP99Latency > 0 && s.Latency.P99Ms > millis(opts.MaxP99Latency) {
+		reasons = append(reasons, fmt.Sprintf("p99 latency %.1fms over %s", s.Latency.P99Ms, opts.MaxP99Latency))
+	}
+	if s.Throughput < opts.MinThroughput {
+		reasons = append(reasons, fmt.Sprintf("achieved %.0f%% of the rate", s.Throughput*100))
+	}
+	s.Pass = len(reasons) == 0
+	s.Reason = strings.Join(reasons, "; ")
+	return s, nil
+}
+
+func passLabel(s BenchStep) string {
+	if s.Pass {
+		return "pass"
+	}
+	return "fail: " + s.Reason
+}
+
+// WriteBenchJSON writes result as indented JSON.
+func WriteBenchJSON(w io.Writer, result BenchResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+// WriteBenchText writes result as a table of steps and a summary line.
+func WriteBenchText(w io.Writer, result BenchResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "step\trate (%s)\tspans/sec\ttraces/sec\trequests\terror rate\tp50 ms\tp95 ms\tp99 ms\tresult\n", result.RateUnit)
+	for _, s := range result.Steps {
+		fmt.Fprintf(tw, "%d\t%.2f\t%.2f\t%.2f\t%d\t%.4f\t%.1f\t%.1f\t%.1f\t%s\n",
+			s.Step, s.Rate, s.SpansPerSecond, s.TracesPerSecond, s.Requests, s.ErrorRate,
+			s.Latency.P50Ms, s.Latency.P95Ms, s.Latency.P99Ms, passLabel(s))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if result.MaxSustainableRate == 0 {
+		_, err := fmt.Fprintln(w, "max sustainable: none, every step failed")
+		return err
+	}
+	_, err := fmt.Fprintf(w, "max sustainable: %.2f spans/sec (%.2f traces/sec) at rate %.2f %s\n",
+		result.MaxSustainableSpansPerSecond, result.MaxSustainableTracesPerSecond, result.MaxSustainableRate, result.RateUnit)
+	return err
+}
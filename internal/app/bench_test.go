@@ -0,0 +1,126 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// budgetServer accepts limit requests in each 100ms window and answers
+// 429 to the rest.
+type budgetServer struct {
+	limit int
+
+	mu     sync.Mutex
+	window time.Time
+	used   int
+}
+
+func (s *budgetServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := time.Now(); now.Sub(s.window) >= 100*time.Millisecond {
+		s.window, s.used = now, 0
+	}
+	if s.used >= s.limit {
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	s.used++
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestBenchFindsSustainableRate(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(&budgetServer{limit: 10})
+	srv.Listener = lis
+	srv.Start()
+	defer srv.Close()
+
+	cfg := reportTestConfig("")
+	cfg.Output = "otlp"
+	cfg.OTLPEndpoint = srv.URL
+	cfg.BatchSize = 1
+	cfg.SinkMaxInFlight = 4
+	progress := &bytes.Buffer{}
+	result, err := Bench(cfg, BenchOptions{
+		Search:        BenchSearchBinary,
+		StartRate:     40,
+		MaxRate:       400,
+		StepDuration:  500 * time.Millisecond,
+		MaxErrorRate:  0.01,
+		MinThroughput: 0.8,
+		Precision:     0.25,
+		MaxSteps:      8,
+	}, progress)
+	if err != nil {
+		t.Fatalf("bench: %v", err)
+	}
+	// The server takes 100 requests a second, one trace each.
+	if result.MaxSustainableRate < 80 || result.MaxSustainableRate >= 160 || result.MaxSustainableSpansPerSecond <= 0 {
+		t.Fatalf("max sustainable rate=%v spans/sec=%v steps=%+v", result.MaxSustainableRate, result.MaxSustainableSpansPerSecond, result.Steps)
+	}
+	var failed bool
+	for _, s := range result.Steps {
+		if !s.Pass {
+			failed = true
+			if s.Errors == 0 || s.FailedSpans == 0 || !strings.Contains(s.Reason, "error rate") {
+				t.Fatalf("failing step=%+v", s)
+			}
+		}
+	}
+	if !failed || strings.Count(progress.String(), "bench step") != len(result.Steps) {
+		t.Fatalf("steps=%+v progress=%q", result.Steps, progress.String())
+	}
+
+	var text bytes.Buffer
+	if err := WriteBenchText(&text, result); err != nil {
+		t.Fatalf("text: %v", err)
+	}
+	if !strings.Contains(text.String(), "rate (traces/1s)") || !strings.Contains(text.String(), "max sustainable:") {
+		t.Fatalf("text=%q", text.String())
+	}
+	var js bytes.Buffer
+	if err := WriteBenchJSON(&js, result); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	var decoded BenchResult
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil || len(decoded.Steps) != len(result.Steps) {
+		t.Fatalf("decode json: %v %s", err, js.String())
+	}
+}
+
+func TestBenchStepSearchStopsAtMaxRate(t *testing.T) {
+	cfg := reportTestConfig("")
+	result, err := Bench(cfg, BenchOptions{
+		Search:        BenchSearchStep,
+		StartRate:     50,
+		StepRate:      50,
+		MaxRate:       100,
+		StepDuration:  300 * time.Millisecond,
+		MinThroughput: 0.5,
+		Precision:     0.1,
+		MaxSteps:      10,
+	}, nil)
+	if err != nil {
+		t.Fatalf("bench: %v", err)
+	}
+	// The noop sink keeps up, so both steps pass and the search stops at
+	// the cap.
+	if len(result.Steps) != 2 || result.MaxSustainableRate != 100 || result.Steps[0].Requests != 0 {
+		t.Fatalf("result=%+v", result)
+	}
+
+	if _, err := Bench(cfg, BenchOptions{Search: "linear", StartRate: 1, StepDuration: time.Second, Precision: 0.1, MaxSteps: 1}, nil); err == nil {
+		t.Fatalf("unknown search validated")
+	}
+}
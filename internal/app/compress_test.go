@@ -0,0 +1,243 @@
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/robmcelhinney/spanforge/internal/config"
+	collectortracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// decodeBody undoes the Content-Encoding of a request body.
+func decodeBody(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case "":
+		return body, nil
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(zr)
+	case "zstd":
+		dec, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer dec.Close()
+		return dec.DecodeAll(body, nil)
+	case "snappy":
+		return snappy.Decode(nil, body)
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// sizeServer accepts requests on 127.0.0.1, checking each decodes, and
+// totals their sizes on the wire and decoded.
+type sizeServer struct {
+	t        *testing.T
+	encoding string
+	decode   func([]byte) error
+
+	mu           sync.Mutex
+	requests     int
+	wireBytes    int
+	decodedBytes int
+}
+
+func startSizeServer(t *testing.T, s *sizeServer) *httptest.Server {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	s.t = t
+	srv := httptest.NewUnstartedServer(s)
+	srv.Listener = lis
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (s *sizeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if got := r.Header.Get("Content-Encoding"); got != s.encoding {
+		s.t.Errorf("content-encoding=%q want %q", got, s.encoding)
+	}
+	wire, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.t.Errorf("read body: %v", err)
+	}
+	body, err := decodeBody(r.Header.Get("Content-Encoding"), wire)
+	if err != nil {
+		s.t.Errorf("decode %s body: %v", s.encoding, err)
+	} else if err := s.decode(body); err != nil {
+		s.t.Errorf("unmarshal: %v", err)
+	}
+	s.mu.Lock()
+	s.requests++
+	s.wireBytes += len(wire)
+	s.decodedBytes += len(body)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+func readRunReport(t *testing.T, path string) runReport {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var report runReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	return report
+}
+
+func TestRunCompressesHTTPRequests(t *testing.T) {
+	for _, tc := range []struct {
+		format   string
+		compress string
+		encoding string
+	}{
+		{"otlp-http", "none", ""},
+		{"otlp-http", "gzip", "gzip"},
+		{"otlp-http", "zstd", "zstd"},
+		{"otlp-http", "snappy", "snappy"},
+		{"zipkin-json", "gzip", "gzip"},
+	} {
+		t.Run(tc.format+"/"+tc.compress, func(t *testing.T) {
+			s := &sizeServer{encoding: tc.encoding, decode: func(body []byte) error {
+				if tc.format == "zipkin-json" {
+					var spans []map[string]any
+					return json.Unmarshal(body, &spans)
+				}
+				return proto.Unmarshal(body, &collectortracev1.ExportTraceServiceRequest{})
+			}}
+			srv := startSizeServer(t, s)
+			reportPath := filepath.Join(t.TempDir(), "report.json")
+			cfg := reportTestConfig(reportPath)
+			cfg.Format = tc.format
+			cfg.Output = "otlp"
+			cfg.OTLPEndpoint = srv.URL
+			if tc.format == "zipkin-json" {
+				cfg.Output = "zipkin"
+				cfg.ZipkinEndpoint = srv.URL
+			}
+			cfg.Compress = tc.compress
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			if err := Run(cfg, &bytes.Buffer{}); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+
+			c := readRunReport(t, reportPath).Compression
+			if c == nil {
+				t.Fatalf("report has no compression")
+			}
+			if c.Algorithm != tc.compress {
+				t.Fatalf("algorithm=%q want %q", c.Algorithm, tc.compress)
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if c.Batches != uint64(s.requests) || c.UncompressedBytes != uint64(s.decodedBytes) || c.CompressedBytes != uint64(s.wireBytes) {
+				t.Fatalf("report batches=%d bytes=%d/%d, server saw requests=%d bytes=%d/%d",
+					c.Batches, c.UncompressedBytes, c.CompressedBytes, s.requests, s.decodedBytes, s.wireBytes)
+			}
+			if tc.encoding == "" && c.Ratio != 1 {
+				t.Fatalf("uncompressed ratio=%v want 1", c.Ratio)
+			}
+		})
+	}
+}
+
+func TestRunCompressesOTLPGRPC(t *testing.T) {
+	for _, compress := range []string{"gzip", "zstd", "snappy"} {
+		t.Run(compress, func(t *testing.T) {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Skipf("listen unavailable in this environment: %v", err)
+			}
+			// The server decompresses with the compressors the client
+			// registers.
+			svc := &testTraceSvc{}
+			srv := grpc.NewServer()
+			collectortracev1.RegisterTraceServiceServer(srv, svc)
+			go func() { _ = srv.Serve(lis) }()
+			defer srv.Stop()
+
+			reportPath := filepath.Join(t.TempDir(), "report.json")
+			cfg := reportTestConfig(reportPath)
+			cfg.Format = "otlp-grpc"
+			cfg.Output = "otlp"
+			cfg.OTLPEndpoint = lis.Addr().String()
+			cfg.OTLPInsecure = true
+			cfg.Compress = compress
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			if err := Run(cfg, &bytes.Buffer{}); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if svc.spans == 0 {
+				t.Fatalf("server got no spans")
+			}
+			c := readRunReport(t, reportPath).Compression
+			if c == nil || c.Algorithm != compress || c.Batches == 0 || c.UncompressedBytes == 0 || c.CompressedBytes == 0 {
+				t.Fatalf("compression=%+v", c)
+			}
+		})
+	}
+}
+
+func TestRunReportsCompressionPerSink(t *testing.T) {
+	decode := func(body []byte) error {
+		return proto.Unmarshal(body, &collectortracev1.ExportTraceServiceRequest{})
+	}
+	gz := startSizeServer(t, &sizeServer{encoding: "gzip", decode: decode})
+	zs := startSizeServer(t, &sizeServer{encoding: "zstd", decode: decode})
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	cfg := reportTestConfig(reportPath)
+	cfg.Compress = "gzip"
+	for _, raw := range []string{
+		"name=a,format=otlp-http,endpoint=" + gz.URL,
+		"name=b,format=otlp-http,compress=zstd,endpoint=" + zs.URL,
+		"name=c,format=jsonl,output=noop",
+	} {
+		s, err := config.ParseSink(raw, cfg)
+		if err != nil {
+			t.Fatalf("ParseSink(%q): %v", raw, err)
+		}
+		cfg.Sinks = append(cfg.Sinks, s)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := Run(cfg, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	report := readRunReport(t, reportPath)
+	a, b, c := report.Sinks[0].Compression, report.Sinks[1].Compression, report.Sinks[2].Compression
+	if a == nil || a.Algorithm != "gzip" || b == nil || b.Algorithm != "zstd" || c != nil {
+		t.Fatalf("sink compression=%+v, %+v, %+v", a, b, c)
+	}
+	total := report.Compression
+	if total == nil || total.Algorithm != "gzip,zstd" || total.Batches != a.Batches+b.Batches || total.CompressedBytes != a.CompressedBytes+b.CompressedBytes {
+		t.Fatalf("run compression=%+v", total)
+	}
+}
@@ -0,0 +1,278 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+const (
+	// curveStep is how often curves and phase ramps recompute the rate,
+	// error rate and latency. Short runs step faster so the shape stays
+	// visible.
+	curveStep    = 10 * time.Second
+	minCurveStep = 100 * time.Millisecond
+	// minCurveLatency keeps scaled latencies positive when Follow is 100%
+	// and the load reaches zero.
+	minCurveLatency = 0.01
+)
+
+// loadCurve evaluates a --curve against the time elapsed since the run began.
+type loadCurve struct {
+	curve  config.Curve
+	start  time.Time
+	series curveSeries
+}
+
+// curvePoint is the traffic a curve asks for at one moment. latency
+// multiplies the configured latencies.
+type curvePoint struct {
+	position string
+	load     float64
+	rate     float64
+	errors   float64
+	latency  float64
+}
+
+// curveSeries is a rate series read from a CSV file. It starts at offset 0
+// and repeats once it reaches its last offset.
+type curveSeries struct {
+	samples    []curveSample
+	hasErrors  bool
+	hasLatency bool
+	peak       float64
+}
+
+type curveSample struct {
+	offset  time.Duration
+	rate    float64
+	errors  float64
+	latency float64
+}
+
+func newLoadCurve(curve config.Curve, now time.Time) (loadCurve, error) {
+	loc := curve.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	c := loadCurve{curve: curve, start: now.In(loc)}
+	if curve.Start != nil {
+		midnight := time.Date(c.start.Year(), c.start.Month(), c.start.Day(), 0, 0, 0, 0, loc)
+		c.start = midnight.Add(*curve.Start)
+	}
+	if curve.Kind == config.CurveCSV {
+		series, err := loadCurveFile(curve.Path)
+		if err != nil {
+			return loadCurve{}, err
+		}
+		c.series = series
+	}
+	return c, nil
+}
+
+// at returns the curve point elapsed into the run, relative to the rate,
+// error rate and latency in cfg.
+func (c loadCurve) at(cfg config.Config, elapsed time.Duration) curvePoint {
+	simulated := time.Duration(float64(elapsed) * c.curve.Speed)
+	if c.curve.Kind == config.CurveCSV {
+		return c.series.at(cfg, c.curve, simulated)
+	}
+	clock := c.start.Add(simulated)
+	load := c.curve.Load(clock)
+	scale := c.curve.Scale(load)
+	return curvePoint{
+		position: clock.Format(time.RFC3339),
+		load:     load,
+		rate:     cfg.RateValue * load,
+		errors:   cfg.Errors * scale,
+		latency:  scale,
+	}
+}
+
+// at interpolates the series linearly at offset. Without errors or latency
+// columns, those follow the rate as for diurnal curves.
+func (s curveSeries) at(cfg config.Config, curve config.Curve, offset time.Duration) curvePoint {
+	if period := s.samples[len(s.samples)-1].offset; period > 0 {
+		offset %= period
+	}
+	i := sort.Search(len(s.samples), func(i int) bool { return s.samples[i].offset > offset })
+	a, b, frac := s.samples[i-1], s.samples[i-1], 0.0
+	if i < len(s.samples) {
+		b = s.samples[i]
+		frac = float64(offset-a.offset) / float64(b.offset-a.offset)
+	}
+	lerp := func(x, y float64) float64 { return x + (y-x)*frac }
+	point := curvePoint{position: offset.String(), rate: lerp(a.rate, b.rate)}
+	if s.peak > 0 {
+		point.load = point.rate / s.peak
+	}
+	scale := curve.Scale(point.load)
+	point.errors = cfg.Errors * scale
+	if s.hasErrors {
+		point.errors = lerp(a.errors, b.errors)
+	}
+	point.latency = scale
+	if s.hasLatency {
+		point.latency = lerp(a.latency, b.latency)
+	}
+	return point
+}
+
+func (p curvePoint) apply(cfg config.Config) config.Config {
+	cfg.RateValue = p.rate
+	cfg.Errors = math.Min(1, p.errors)
+	latency := math.Max(minCurveLatency, p.latency)
+	cfg.P50 = scaleDuration(cfg.P50, latency)
+	cfg.P95 = scaleDuration(cfg.P95, latency)
+	cfg.P99 = scaleDuration(cfg.P99, latency)
+	if len(cfg.LatencyModels) > 0 {
+		models := make([]config.LatencyModel, len(cfg.LatencyModels))
+		for i, m := range cfg.LatencyModels {
+			m.P50 = scaleDuration(m.P50, latency)
+			m.P95 = scaleDuration(m.P95, latency)
+			m.P99 = scaleDuration(m.P99, latency)
+			models[i] = m
+		}
+		cfg.LatencyModels = models
+	}
+	cfg.CurvePosition = p.position
+	cfg.CurveLoad = p.load
+	return cfg
+}
+
+func scaleDuration(d time.Duration, factor float64) time.Duration {
+	return time.Duration(float64(d) * factor)
+}
+
+// loadCurveFile reads a CSV rate series. The header names the columns:
+// offset (a duration since the start) and rate are required; errors (a
+// percentage) and latency (a multiplier) are optional.
+func loadCurveFile(path string) (curveSeries, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return curveSeries{}, fmt.Errorf("read curve file: %w", err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return curveSeries{}, fmt.Errorf("parse curve file: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	offsetCol, hasOffset := columns["offset"]
+	rateCol, hasRate := columns["rate"]
+	if !hasOffset || !hasRate {
+		return curveSeries{}, fmt.Errorf("curve file %s needs offset and rate columns", path)
+	}
+	errorsCol, hasErrors := columns["errors"]
+	latencyCol, hasLatency := columns["latency"]
+	series := curveSeries{hasErrors: hasErrors, hasLatency: hasLatency}
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return curveSeries{}, fmt.Errorf("parse curve file: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+		bad := func(column string) error {
+			return fmt.Errorf("curve file %s line %d: bad %s %q", path, line, column, row[columns[column]])
+		}
+		var sample curveSample
+		if sample.offset, err = time.ParseDuration(strings.TrimSpace(row[offsetCol])); err != nil || sample.offset < 0 {
+			return curveSeries{}, bad("offset")
+		}
+		if sample.rate, err = strconv.ParseFloat(strings.TrimSpace(row[rateCol]), 64); err != nil || sample.rate < 0 {
+			return curveSeries{}, bad("rate")
+		}
+		if hasErrors {
+			if sample.errors, err = config.ParsePercent(row[errorsCol]); err != nil {
+				return curveSeries{}, bad("errors")
+			}
+		}
+		if hasLatency {
+			if sample.latency, err = strconv.ParseFloat(strings.TrimSpace(row[latencyCol]), 64); err != nil || sample.latency <= 0 {
+				return curveSeries{}, bad("latency")
+			}
+		}
+		switch n := len(series.samples); {
+		case n == 0 && sample.offset != 0:
+			return curveSeries{}, fmt.Errorf("curve file %s line %d: first offset must be 0s", path, line)
+		case n > 0 && sample.offset <= series.samples[n-1].offset:
+			return curveSeries{}, fmt.Errorf("curve file %s line %d: offsets must increase", path, line)
+		}
+		series.peak = math.Max(series.peak, sample.rate)
+		series.samples = append(series.samples, sample)
+	}
+	if len(series.samples) == 0 {
+		return curveSeries{}, fmt.Errorf("curve file %s has no rows", path)
+	}
+	return series, nil
+}
+
+// stepLength returns the step for a curve or ramp lasting total, where 0
+// means no time limit.
+func stepLength(total time.Duration) time.Duration {
+	if total > 0 && total/20 < curveStep {
+		return max(total/20, minCurveStep)
+	}
+	return curveStep
+}
+
+// produceTraceCurve runs cfg in short steps, each at the rate, error rate and
+// latency the curve gives for that moment.
+func produceTraceCurve(ctx context.Context, cfg config.Config, traceCh chan<- model.Trace) error {
+	curve, err := newLoadCurve(*cfg.Curve, time.Now())
+	if err != nil {
+		return err
+	}
+	step := stepLength(cfg.Duration)
+	start := time.Now()
+	for i := 0; ctx.Err() == nil; i++ {
+		elapsed := time.Since(start)
+		segment := step
+		if cfg.Duration > 0 {
+			if elapsed >= cfg.Duration {
+				return nil
+			}
+			segment = min(step, cfg.Duration-elapsed)
+		}
+		point := curve.at(cfg, elapsed)
+		stepCfg := point.apply(cfg)
+		stepCfg.Seed = cfg.Seed + int64(i*maxInt(1, cfg.Workers))
+		stepCfg.Duration = segment
+		debugf(stepCfg, "curve position=%s load=%.3f rate=%.2f/%s errors=%.4f p95=%s", point.position, point.load, stepCfg.RateValue, stepCfg.RateUnit, stepCfg.Errors, stepCfg.P95)
+		if stepCfg.RateValue <= 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(segment):
+			}
+			continue
+		}
+		if err := stepCfg.Validate(); err != nil {
+			return fmt.Errorf("invalid curve point %s: %w", point.position, err)
+		}
+		if err := produceTraceSteady(ctx, stepCfg, traceCh); err != nil {
+			return err
+		}
+	}
+	return nil
+}
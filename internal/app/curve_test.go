@@ -0,0 +1,145 @@
+package app
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+func writeCurveFile(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rates.csv")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write curve file: %v", err)
+	}
+	return path
+}
+
+func TestCSVCurveInterpolatesAndRepeats(t *testing.T) {
+	path := writeCurveFile(t, `# one compressed day
+offset,rate,errors,latency
+0s,100,1%,1
+6h,400,5%,2
+12h,100,1%,1
+`)
+	curve, err := config.ParseCurve("csv:" + path + ",speed=3600")
+	if err != nil {
+		t.Fatalf("ParseCurve: %v", err)
+	}
+	lc, err := newLoadCurve(*curve, time.Now())
+	if err != nil {
+		t.Fatalf("newLoadCurve: %v", err)
+	}
+	cfg := reportTestConfig("")
+	cases := []struct {
+		elapsed  time.Duration
+		position string
+		rate     float64
+		errors   float64
+		latency  float64
+	}{
+		{0, "0s", 100, 0.01, 1},
+		{3 * time.Second, "3h0m0s", 250, 0.03, 1.5},
+		{6 * time.Second, "6h0m0s", 400, 0.05, 2},
+		{15 * time.Second, "3h0m0s", 250, 0.03, 1.5},
+	}
+	for _, tc := range cases {
+		p := lc.at(cfg, tc.elapsed)
+		if p.position != tc.position || math.Abs(p.rate-tc.rate) > 1e-9 || math.Abs(p.errors-tc.errors) > 1e-9 || math.Abs(p.latency-tc.latency) > 1e-9 {
+			t.Fatalf("at(%s)=%+v want position=%s rate=%.0f errors=%.2f latency=%.1f", tc.elapsed, p, tc.position, tc.rate, tc.errors, tc.latency)
+		}
+	}
+
+	stepCfg := lc.at(cfg, 3*time.Second).apply(cfg)
+	if stepCfg.RateValue != 250 || stepCfg.P95 != 75*time.Millisecond || stepCfg.CurvePosition != "3h0m0s" || stepCfg.CurveLoad != 0.625 {
+		t.Fatalf("rate=%.0f p95=%s position=%s load=%.3f", stepCfg.RateValue, stepCfg.P95, stepCfg.CurvePosition, stepCfg.CurveLoad)
+	}
+}
+
+func TestDiurnalCurveScalesErrorsAndLatencyWithFollow(t *testing.T) {
+	curve, err := config.ParseCurve("diurnal,peak=12:00,trough=20%,follow=50%,start=00:00,speed=3600")
+	if err != nil {
+		t.Fatalf("ParseCurve: %v", err)
+	}
+	lc, err := newLoadCurve(*curve, time.Date(2026, 10, 14, 17, 45, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("newLoadCurve: %v", err)
+	}
+	cfg := reportTestConfig("")
+	cfg.Errors = 0.1
+
+	trough := lc.at(cfg, 0)
+	if trough.position != "2026-10-14T00:00:00Z" || trough.load != 0.2 || trough.rate != 20 {
+		t.Fatalf("trough=%+v", trough)
+	}
+	if math.Abs(trough.errors-0.06) > 1e-9 || math.Abs(trough.latency-0.6) > 1e-9 {
+		t.Fatalf("trough errors=%.3f latency=%.3f want 0.06 and 0.6", trough.errors, trough.latency)
+	}
+	peak := lc.at(cfg, 12*time.Second)
+	if peak.position != "2026-10-14T12:00:00Z" || peak.rate != 100 || peak.errors != 0.1 || peak.latency != 1 {
+		t.Fatalf("peak=%+v", peak)
+	}
+}
+
+func TestLoadCurveFileRejectsBadRows(t *testing.T) {
+	cases := map[string]string{
+		"no rate column":     "offset,errors\n0s,1%\n",
+		"late first offset":  "offset,rate\n1m,10\n",
+		"offsets go back":    "offset,rate\n0s,10\n2m,10\n1m,10\n",
+		"negative rate":      "offset,rate\n0s,-1\n",
+		"bad errors":         "offset,rate,errors\n0s,10,200%\n",
+		"zero latency":       "offset,rate,latency\n0s,10,0\n",
+		"header without row": "offset,rate\n",
+	}
+	for name, body := range cases {
+		if _, err := loadCurveFile(writeCurveFile(t, body)); err == nil {
+			t.Fatalf("%s: loadCurveFile succeeded, want error", name)
+		}
+	}
+	_, err := loadCurveFile(writeCurveFile(t, "offset,rate\n0s,10\n\n1m,ten\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Fatalf("err=%v want the bad row's line", err)
+	}
+}
+
+func TestProduceTraceCurveLabelsTraces(t *testing.T) {
+	cfg := reportTestConfig("")
+	cfg.Count = 0
+	cfg.Duration = 300 * time.Millisecond
+	curve, err := config.ParseCurve("weekly,speed=3600")
+	if err != nil {
+		t.Fatalf("ParseCurve: %v", err)
+	}
+	cfg.Curve = curve
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	traceCh := make(chan model.Trace, 1024)
+	if err := produceTraces(context.Background(), cfg, traceCh); err != nil {
+		t.Fatalf("produceTraces: %v", err)
+	}
+	close(traceCh)
+	positions := map[string]bool{}
+	for trace := range traceCh {
+		attrs := trace.Spans[0].Attributes
+		position, _ := attrs["spanforge.curve.position"].(string)
+		if _, err := time.Parse(time.RFC3339, position); err != nil {
+			t.Fatalf("spanforge.curve.position=%v: %v", attrs["spanforge.curve.position"], err)
+		}
+		if load, ok := attrs["spanforge.curve.load"].(float64); !ok || load <= 0 || load > 1 {
+			t.Fatalf("spanforge.curve.load=%v want a share of the peak", attrs["spanforge.curve.load"])
+		}
+		positions[position] = true
+	}
+	if len(positions) < 2 {
+		t.Fatalf("positions=%v want the curve to move during the run", positions)
+	}
+}
@@ -0,0 +1,230 @@
+package app
+
+import (
+	"container/heap"
+	"context"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+// deliveryPiece is a group of spans from one trace that is released to the sink at releaseAt.
+type deliveryPiece struct {
+	releaseAt time.Time
+	seq       uint64
+	trace     model.Trace
+	// held pieces wait for their spans to end, plus delay.
+	held  bool
+	delay time.Duration
+}
+
+type deliveryQueue []deliveryPiece
+
+func (q deliveryQueue) Len() int { return len(q) }
+
+func (q deliveryQueue) Less(i, j int) bool {
+	if q[i].releaseAt.Equal(q[j].releaseAt) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].releaseAt.Before(q[j].releaseAt)
+}
+
+func (q deliveryQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *deliveryQueue) Push(x any) { *q = append(*q, x.(deliveryPiece)) }
+
+func (q *deliveryQueue) Pop() any {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[:n-1]
+	return item
+}
+
+type deliveryScheduler struct {
+	cfg   config.Config
+	rng   *rand.Rand
+	stats *emitterStats
+	queue deliveryQueue
+	seq   uint64
+}
+
+func deliveryEnabled(cfg config.Config) bool {
+	return cfg.DeliverySplit > 0 || cfg.DeliveryShuffle > 0 || cfg.DeliveryDelay > 0 || realtimeDelivery(cfg)
+}
+
+// realtimeDelivery reports whether spans are held until their simulated end
+// time, like an SDK exporting each span when it finishes. The workflow profile
+// uses it so long-running traces arrive over minutes instead of all at once,
+// and linked messaging uses it so consumer traces arrive after their lag.
+// With a profile mix it holds only the traces of those profiles.
+func realtimeDelivery(cfg config.Config) bool {
+	for _, profile := range cfg.Profiles() {
+		if realtimeProfile(cfg, profile) {
+			return true
+		}
+	}
+	return false
+}
+
+func realtimeProfile(cfg config.Config, profile string) bool {
+	return profile == "workflow" || (profile == "queue" && cfg.MessagingMode == "linked")
+}
+
+func newDeliveryScheduler(cfg config.Config, stats *emitterStats) *deliveryScheduler {
+	return &deliveryScheduler{
+		cfg:   cfg,
+		rng:   rand.New(rand.NewSource(cfg.Seed)),
+		stats: stats,
+	}
+}
+
+// runDelivery sits between the producers and the sink. It reorders, splits and
+// delays spans so collectors see traces arrive in pieces. Pending spans are
+// drained at their release time after in closes, except that spans held
+// until their end time are released at once, so the run ends on time.
+func runDelivery(ctx context.Context, cfg config.Config, in <-chan model.Trace, out chan<- model.Trace, stats *emitterStats) error {
+	s := newDeliveryScheduler(cfg, stats)
+	for {
+		if !s.releaseDue(ctx, time.Now(), out) {
+			return nil
+		}
+		var timerC <-chan time.Time
+		var timer *time.Timer
+		if next, ok := s.next(); ok {
+			timer = time.NewTimer(time.Until(next))
+			timerC = timer.C
+		}
+		if in == nil && timer == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+		case trace, ok := <-in:
+			if !ok {
+				in = nil
+				s.releaseHeld(time.Now())
+			} else {
+				s.schedule(trace, time.Now())
+			}
+		case <-timerC:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (s *deliveryScheduler) schedule(trace model.Trace, now time.Time) {
+	spans := append([]model.Span(nil), trace.Spans...)
+	if len(spans) > 1 && s.rng.Float64() < s.cfg.DeliveryShuffle {
+		s.rng.Shuffle(len(spans), func(i, j int) { spans[i], spans[j] = spans[j], spans[i] })
+	}
+
+	realtime := realtimeDelivery(s.cfg)
+	if len(s.cfg.ProfileMix) > 0 {
+		realtime = realtimeProfile(s.cfg, traceProfile(trace))
+	}
+	var pieces []deliveryPiece
+	kept := spans[:0:0]
+	for _, span := range spans {
+		base := now
+		if end := span.StartTime.Add(span.Duration); realtime && end.After(now) {
+			base = end
+		}
+		if s.cfg.DeliveryDelay > 0 && s.rng.Float64() < s.cfg.DeliveryDelay {
+			delay := s.cfg.DeliveryDelayDist.Sample(s.rng.Float64)
+			pieces = append(pieces, deliveryPiece{releaseAt: base.Add(delay), trace: tracePiece(trace, []model.Span{span}), held: realtime, delay: delay})
+			s.stats.addDelayed(1)
+			continue
+		}
+		if realtime {
+			pieces = append(pieces, deliveryPiece{releaseAt: base, trace: tracePiece(trace, []model.Span{span}), held: true})
+			continue
+		}
+		kept = append(kept, span)
+	}
+
+	parts := 1
+	if len(kept) > 1 && s.rng.Float64() < s.cfg.DeliverySplit {
+		parts = 2 + s.rng.Intn(minInt(3, len(kept)-1))
+	}
+	size := (len(kept) + parts - 1) / parts
+	for i := 0; len(kept) > 0; i++ {
+		n := minInt(size, len(kept))
+		pieces = append(pieces, deliveryPiece{
+			releaseAt: now.Add(time.Duration(i) * s.cfg.FlushInterval),
+			trace:     tracePiece(trace, kept[:n]),
+		})
+		kept = kept[n:]
+	}
+
+	sort.SliceStable(pieces, func(i, j int) bool { return pieces[i].releaseAt.Before(pieces[j].releaseAt) })
+	for i := range pieces {
+		pieces[i].trace.Fragment = trace.Fragment || i > 0
+		pieces[i].seq = s.seq
+		s.seq++
+		heap.Push(&s.queue, pieces[i])
+	}
+}
+
+// releaseHeld stops holding spans until their end time, releasing them at
+// now plus any delivery delay.
+func (s *deliveryScheduler) releaseHeld(now time.Time) {
+	for i := range s.queue {
+		if piece := &s.queue[i]; piece.held && piece.releaseAt.After(now.Add(piece.delay)) {
+			piece.releaseAt = now.Add(piece.delay)
+		}
+	}
+	heap.Init(&s.queue)
+}
+
+func (s *deliveryScheduler) next() (time.Time, bool) {
+	if len(s.queue) == 0 {
+		return time.Time{}, false
+	}
+	return s.queue[0].releaseAt, true
+}
+
+func (s *deliveryScheduler) releaseDue(ctx context.Context, now time.Time, out chan<- model.Trace) bool {
+	for len(s.queue) > 0 && !s.queue[0].releaseAt.After(now) {
+		piece := heap.Pop(&s.queue).(deliveryPiece)
+		select {
+		case out <- piece.trace:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+func tracePiece(trace model.Trace, spans []model.Span) model.Trace {
+	return model.Trace{
+		TraceID:  trace.TraceID,
+		Resource: trace.Resource,
+		Spans:    append([]model.Span(nil), spans...),
+		Headers:  trace.Headers,
+		Tenant:   trace.Tenant,
+	}
+}
+
+func traceCount(trace model.Trace) int {
+	if trace.Fragment {
+		return 0
+	}
+	return 1
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
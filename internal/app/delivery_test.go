@@ -0,0 +1,173 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/generator"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+func TestDeliverySplitsTracesAcrossFragments(t *testing.T) {
+	cfg := reportTestConfig("")
+	cfg.Depth = 3
+	cfg.Fanout = 2
+	cfg.FlushInterval = time.Millisecond
+	cfg.DeliverySplit = 1
+	cfg.DeliveryShuffle = 1
+
+	got := runDeliveryForTest(t, cfg, 5)
+	heads := map[model.TraceID]int{}
+	pieces := map[model.TraceID]int{}
+	for _, trace := range got {
+		pieces[trace.TraceID]++
+		heads[trace.TraceID] += traceCount(trace)
+	}
+	if len(heads) != 5 {
+		t.Fatalf("delivered traces=%d want 5", len(heads))
+	}
+	for id, n := range heads {
+		if n != 1 {
+			t.Fatalf("trace %x counted %d times want 1", id, n)
+		}
+		if pieces[id] < 2 {
+			t.Fatalf("trace %x delivered in %d pieces want split", id, pieces[id])
+		}
+	}
+}
+
+func TestDeliveryDelaysSpansAndCountsThem(t *testing.T) {
+	cfg := reportTestConfig("")
+	cfg.DeliveryDelay = 1
+	cfg.DeliveryDelayDist = config.Distribution{Kind: config.DistributionFixed, A: 30 * time.Millisecond}
+
+	stats := newEmitterStats()
+	in := make(chan model.Trace, 4)
+	out := make(chan model.Trace, 64)
+	trace := generator.New(cfg).GenerateTrace(time.Now().UTC())
+	in <- trace
+	close(in)
+
+	started := time.Now()
+	if err := runDelivery(context.Background(), cfg, in, out, stats); err != nil {
+		t.Fatalf("runDelivery: %v", err)
+	}
+	close(out)
+	if elapsed := time.Since(started); elapsed < 30*time.Millisecond {
+		t.Fatalf("delivery finished after %s want delayed release", elapsed)
+	}
+	spans := 0
+	for piece := range out {
+		spans += len(piece.Spans)
+	}
+	if spans != len(trace.Spans) {
+		t.Fatalf("delivered spans=%d want %d", spans, len(trace.Spans))
+	}
+	if got := stats.snapshot().DelayedSpans; got != uint64(len(trace.Spans)) {
+		t.Fatalf("delayed_spans=%d want %d", got, len(trace.Spans))
+	}
+}
+
+func TestDeliveryReleasesWorkflowSpansAtEndTime(t *testing.T) {
+	cfg := reportTestConfig("")
+	cfg.Profile = "workflow"
+	cfg.WorkflowLifetime = 80 * time.Millisecond
+	cfg.Errors = 0
+	cfg.Retries = 0
+
+	in := make(chan model.Trace, 1)
+	out := make(chan model.Trace, 64)
+	started := time.Now().UTC()
+	trace := generator.New(cfg).GenerateTrace(started)
+	in <- trace
+
+	go func() {
+		_ = runDelivery(context.Background(), cfg, in, out, newEmitterStats())
+		close(out)
+	}()
+	// The run is still going, so nothing is released early.
+	spans := 0
+	for spans < len(trace.Spans) {
+		piece := <-out
+		for _, span := range piece.Spans {
+			spans++
+			if end := span.StartTime.Add(span.Duration); time.Now().Before(end) {
+				t.Fatalf("span %q released before its end time", span.Name)
+			}
+		}
+	}
+	close(in)
+	for piece := range out {
+		spans += len(piece.Spans)
+	}
+	if spans != len(trace.Spans) {
+		t.Fatalf("delivered spans=%d want %d", spans, len(trace.Spans))
+	}
+}
+
+func TestRunWithLongWorkflowsEndsOnTime(t *testing.T) {
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	cfg := reportTestConfig(reportPath)
+	cfg.Profile = "workflow"
+	cfg.WorkflowLifetime = 10 * time.Minute
+	cfg.Count = 0
+	cfg.Duration = 300 * time.Millisecond
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	started := time.Now()
+	if err := Run(cfg, bytes.NewBuffer(nil)); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("run took %s want about its 300ms duration", elapsed)
+	}
+	report := readReport(t, reportPath)
+	if got, ok := report["emitted_spans"].(float64); !ok || got == 0 {
+		t.Fatalf("emitted_spans=%v want the held spans flushed", report["emitted_spans"])
+	}
+}
+
+func TestRunLinkedMessagingEmitsConsumerTraces(t *testing.T) {
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	cfg := reportTestConfig(reportPath)
+	cfg.Profile = "queue"
+	cfg.MessagingMode = "linked"
+	cfg.MessagingBatch = 2
+	cfg.MessagingLag = config.Distribution{Kind: config.DistributionFixed, A: 20 * time.Millisecond}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := Run(cfg, bytes.NewBuffer(nil)); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	report := readReport(t, reportPath)
+	// Three producer traces publish three messages; batches of two give two consumer traces.
+	if got := report["emitted_traces"]; got != float64(5) {
+		t.Fatalf("emitted_traces=%v want 5", got)
+	}
+}
+
+func runDeliveryForTest(t *testing.T, cfg config.Config, traces int) []model.Trace {
+	t.Helper()
+	in := make(chan model.Trace, traces)
+	out := make(chan model.Trace, traces*64)
+	g := generator.New(cfg)
+	for i := 0; i < traces; i++ {
+		in <- g.GenerateTrace(time.Now().UTC())
+	}
+	close(in)
+	if err := runDelivery(context.Background(), cfg, in, out, newEmitterStats()); err != nil {
+		t.Fatalf("runDelivery: %v", err)
+	}
+	close(out)
+	var got []model.Trace
+	for trace := range out {
+		got = append(got, trace)
+	}
+	return got
+}
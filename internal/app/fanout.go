@@ -0,0 +1,235 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+// fanOut feeds every trace of a --sink run to each of its sinks.
+type fanOut struct {
+	sinks []*sinkRun
+}
+
+// sinkRun is one sink of a fan-out run with its own writer and stats.
+type sinkRun struct {
+	sink  config.Sink
+	cfg   config.Config
+	out   streamWriter
+	file  streamFile
+	stats *emitterStats
+	// failures is nil for sinks that stop the run on error.
+	failures *sinkFailures
+}
+
+// sinkFailures counts what a sink with on-error=continue failed to write.
+type sinkFailures struct {
+	traces  uint64
+	spans   uint64
+	errors  uint64
+	mu      sync.Mutex
+	lastErr string
+}
+
+func (f *sinkFailures) add(err error, traces, spans int) {
+	if traces > 0 {
+		atomic.AddUint64(&f.traces, uint64(traces))
+	}
+	if spans > 0 {
+		atomic.AddUint64(&f.spans, uint64(spans))
+	}
+	if err != nil {
+		atomic.AddUint64(&f.errors, 1)
+		f.mu.Lock()
+		f.lastErr = err.Error()
+		f.mu.Unlock()
+	}
+}
+
+// sinkReport is one sink's share of a run report. Emitted counts are what
+// the sink wrote or had accepted; failed counts are what it dropped under
+// on-error=continue.
+type sinkReport struct {
+	Name          string `json:"name"`
+	Format        string `json:"format"`
+	Output        string `json:"output"`
+	Target        string `json:"target,omitempty"`
+	OnError       string `json:"on_error"`
+	EmittedTraces uint64 `json:"emitted_traces"`
+	EmittedSpans  uint64 `json:"emitted_spans"`
+	FailedTraces  uint64 `json:"failed_traces"`
+	FailedSpans   uint64 `json:"failed_spans"`
+	Errors        uint64 `json:"errors"`
+	LastError     string `json:"last_error,omitempty"`
+	// Compression and Endpoints are set for sinks sending to an endpoint.
+	Compression *compressionReport `json:"compression,omitempty"`
+	Endpoints   []endpointReport   `json:"endpoints,omitempty"`
+}
+
+// openFanOut opens cfg's sinks. A sink writing to stdout writes to out.
+func openFanOut(cfg config.Config, out io.Writer) (*fanOut, error) {
+	f := &fanOut{}
+	for _, s := range cfg.Sinks {
+		run := &sinkRun{sink: s, cfg: cfg.WithSink(s), stats: newEmitterStats()}
+		if s.OnError == config.SinkOnErrorContinue {
+			run.failures = &sinkFailures{}
+		}
+		run.out = bufio.NewWriter(out)
+		if s.Output == "file" {
+			file, err := openStreamFile(cfg, s.File)
+			if err != nil {
+				_ = f.close()
+				return nil, fmt.Errorf("sink %s: %w", s.Name, err)
+			}
+			run.file = file
+			run.out = file
+		}
+		f.sinks = append(f.sinks, run)
+	}
+	return f, nil
+}
+
+// run copies each trace from in to every sink until in closes. The run's
+// stats and manifest count traces as they enter the fan-out; each sink keeps
+// its own stats. A sink that fails with on-error=fail stops the others.
+func (f *fanOut) run(ctx context.Context, in <-chan model.Trace, stats *emitterStats, manifest *reportManifest) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errCh := make(chan error, len(f.sinks))
+	chans := make([]chan model.Trace, len(f.sinks))
+	var wg sync.WaitGroup
+	for i, s := range f.sinks {
+		s.stats.run = stats
+		ch := make(chan model.Trace, s.cfg.BatchSize)
+		chans[i] = ch
+		wg.Add(1)
+		go func(s *sinkRun) {
+			defer wg.Done()
+			err := consumeTraces(ctx, s.out, s.cfg, ch, s.stats, nil, s.failures)
+			if err == nil {
+				return
+			}
+			if s.failures == nil {
+				errCh <- fmt.Errorf("sink %s: %w", s.sink.Name, err)
+				cancel()
+				return
+			}
+			// The sink cannot go on, so count the rest of the stream as
+			// failed and let the other sinks carry on.
+			debugf(s.cfg, "sink %s stopped: %v", s.sink.Name, err)
+			s.failures.add(err, 0, 0)
+			for trace := range ch {
+				s.failures.add(nil, traceCount(trace), len(trace.Spans))
+			}
+		}(s)
+	}
+
+feed:
+	for {
+		select {
+		case <-ctx.Done():
+			break feed
+		case trace, ok := <-in:
+			if !ok {
+				break feed
+			}
+			manifest.observe(trace)
+			stats.add(traceCount(trace), len(trace.Spans))
+			for _, ch := range chans {
+				select {
+				case ch <- trace:
+				case <-ctx.Done():
+					break feed
+				}
+			}
+		}
+	}
+	for _, ch := range chans {
+		close(ch)
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return err
+	default:
+		return nil
+	}
+}
+
+// close flushes stdout and completes the sinks' files, returning the first
+// error.
+func (f *fanOut) close() error {
+	var first error
+	for _, s := range f.sinks {
+		var err error
+		if s.file != nil {
+			err = s.file.Close()
+		} else {
+			err = s.out.Flush()
+		}
+		if err != nil && first == nil {
+			first = fmt.Errorf("sink %s: %w", s.sink.Name, err)
+		}
+	}
+	return first
+}
+
+// describe names the run's formats, outputs and compression algorithms in
+// report, in sink order without repeats, and adds each sink's report.
+func (f *fanOut) describe(report *runReport) {
+	var formats, outputs, algorithms []string
+	for _, s := range f.sinks {
+		formats = appendUnique(formats, s.sink.Format)
+		outputs = appendUnique(outputs, s.sink.Output)
+		snap := s.stats.snapshot()
+		r := sinkReport{
+			Name:          s.sink.Name,
+			Format:        s.sink.Format,
+			Output:        s.sink.Output,
+			OnError:       s.sink.OnError,
+			EmittedTraces: snap.EmittedTraces,
+			EmittedSpans:  snap.EmittedSpans,
+			Compression:   newCompressionReport(s.sink.Compress, snap),
+			Endpoints:     snap.Endpoints,
+		}
+		switch s.sink.Output {
+		case "file":
+			r.Target = s.sink.File
+		case "otlp", "zipkin":
+			r.Target = s.sink.Endpoint
+		}
+		if s.failures != nil {
+			r.FailedTraces = atomic.LoadUint64(&s.failures.traces)
+			r.FailedSpans = atomic.LoadUint64(&s.failures.spans)
+			r.Errors = atomic.LoadUint64(&s.failures.errors)
+			s.failures.mu.Lock()
+			r.LastError = s.failures.lastErr
+			s.failures.mu.Unlock()
+		}
+		if r.Compression != nil {
+			algorithms = appendUnique(algorithms, r.Compression.Algorithm)
+		}
+		report.Sinks = append(report.Sinks, r)
+	}
+	report.Format = strings.Join(formats, ",")
+	report.Output = strings.Join(outputs, ",")
+	if report.Compression != nil {
+		report.Compression.Algorithm = strings.Join(algorithms, ",")
+	}
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+)
+
+func TestSinksFanOutTheSameTraces(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	var accepted, rejected int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken/v1/traces" {
+			atomic.AddInt64(&rejected, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		atomic.AddInt64(&accepted, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.Listener = lis
+	srv.Start()
+	defer srv.Close()
+
+	tmp := t.TempDir()
+	reportPath := filepath.Join(tmp, "report.json")
+	copyPath := filepath.Join(tmp, "copy.jsonl")
+	cfg := reportTestConfig(reportPath)
+	cfg.Count = 20
+	for _, raw := range []string{
+		"name=collector,format=otlp-http,endpoint=" + srv.URL + ",batch-size=8",
+		"name=copy,format=jsonl,file=" + copyPath,
+		"name=broken,format=otlp-http,endpoint=" + srv.URL + "/broken,retries=0,on-error=continue",
+	} {
+		sink, err := config.ParseSink(raw, cfg)
+		if err != nil {
+			t.Fatalf("ParseSink: %v", err)
+		}
+		cfg.Sinks = append(cfg.Sinks, sink)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := Run(cfg, &strings.Builder{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var report runReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if report.Format != "otlp-http,jsonl" || report.Output != "otlp,file" || report.EmittedTraces != 20 {
+		t.Fatalf("format=%s output=%s traces=%d", report.Format, report.Output, report.EmittedTraces)
+	}
+	if len(report.Sinks) != 3 {
+		t.Fatalf("sinks=%+v want 3", report.Sinks)
+	}
+	collector, copied, broken := report.Sinks[0], report.Sinks[1], report.Sinks[2]
+	if collector.EmittedTraces != 20 || collector.EmittedSpans != report.EmittedSpans || collector.Target != srv.URL {
+		t.Fatalf("collector=%+v want every trace", collector)
+	}
+	if copied.EmittedSpans != report.EmittedSpans || copied.Target != copyPath {
+		t.Fatalf("copy=%+v want every span", copied)
+	}
+	if broken.EmittedTraces != 0 || broken.FailedTraces != 20 || broken.FailedSpans != report.EmittedSpans || broken.Errors == 0 || broken.LastError == "" {
+		t.Fatalf("broken=%+v want every trace failed", broken)
+	}
+	if atomic.LoadInt64(&accepted) < 3 || atomic.LoadInt64(&rejected) == 0 {
+		t.Fatalf("accepted=%d rejected=%d", accepted, rejected)
+	}
+
+	file, err := os.Open(copyPath)
+	if err != nil {
+		t.Fatalf("open copy: %v", err)
+	}
+	defer file.Close()
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	if uint64(lines) != report.EmittedSpans {
+		t.Fatalf("copy has %d lines, want %d spans", lines, report.EmittedSpans)
+	}
+}
+
+func TestFailingSinkStopsRun(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	srv.Listener = lis
+	srv.Start()
+	defer srv.Close()
+
+	cfg := reportTestConfig(filepath.Join(t.TempDir(), "report.json"))
+	cfg.Count = 20
+	for _, raw := range []string{"format=otlp-http,retries=0,endpoint=" + srv.URL, "format=jsonl,output=noop"} {
+		sink, err := config.ParseSink(raw, cfg)
+		if err != nil {
+			t.Fatalf("ParseSink: %v", err)
+		}
+		cfg.Sinks = append(cfg.Sinks, sink)
+	}
+	err = Run(cfg, &strings.Builder{})
+	if err == nil || !strings.Contains(err.Error(), "sink otlp-http") {
+		t.Fatalf("err=%v want the otlp-http sink's failure", err)
+	}
+}
@@ -0,0 +1,126 @@
+package app
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/generator"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+const (
+	// latencyReservoirSize is how many durations the report keeps per operation.
+	latencyReservoirSize = 2048
+	// maxReportedOperations bounds the report when routes are high-cardinality.
+	maxReportedOperations = 200
+	// unmodelledOperation marks operations no latency model covers. They have
+	// no target because profile multipliers and children shape their latency.
+	unmodelledOperation = "none"
+)
+
+type operationReport struct {
+	Service   string              `json:"service"`
+	Operation string              `json:"operation"`
+	Model     string              `json:"model"`
+	Spans     uint64              `json:"spans"`
+	Target    *latencyPercentiles `json:"target,omitempty"`
+	Achieved  latencyPercentiles  `json:"achieved"`
+}
+
+type latencyPercentiles struct {
+	P50Ms float64 `json:"p50_ms"`
+	P95Ms float64 `json:"p95_ms"`
+	P99Ms float64 `json:"p99_ms"`
+}
+
+// operationLatencies samples span durations per service and operation so the
+// report can compare achieved percentiles with the latency model targets.
+type operationLatencies struct {
+	cfg config.Config
+	rng *rand.Rand
+	ops map[operationKey]*operationSamples
+}
+
+type operationKey struct {
+	service   string
+	operation string
+}
+
+type operationSamples struct {
+	report  operationReport
+	samples []time.Duration
+}
+
+func newOperationLatencies(cfg config.Config) *operationLatencies {
+	return &operationLatencies{
+		cfg: cfg,
+		rng: rand.New(rand.NewSource(cfg.Seed)),
+		ops: map[operationKey]*operationSamples{},
+	}
+}
+
+func (o *operationLatencies) observe(span model.Span) {
+	service, _ := span.Attributes["service.name"].(string)
+	key := operationKey{service: service, operation: span.Name}
+	op, ok := o.ops[key]
+	if !ok {
+		if len(o.ops) >= maxReportedOperations {
+			return
+		}
+		op = &operationSamples{report: operationReport{Service: service, Operation: span.Name, Model: unmodelledOperation}}
+		if target, ok := generator.LatencyTarget(o.cfg, span); ok {
+			op.report.Model = target.Kind
+			op.report.Target = &latencyPercentiles{P50Ms: millis(target.P50), P95Ms: millis(target.P95), P99Ms: millis(target.P99)}
+		}
+		o.ops[key] = op
+	}
+	op.report.Spans++
+	if len(op.samples) < latencyReservoirSize {
+		op.samples = append(op.samples, span.Duration)
+	} else if i := o.rng.Int63n(int64(op.report.Spans)); i < latencyReservoirSize {
+		op.samples[i] = span.Duration
+	}
+}
+
+func (o *operationLatencies) snapshot() []operationReport {
+	reports := make([]operationReport, 0, len(o.ops))
+	for _, op := range o.ops {
+		sorted := append([]time.Duration(nil), op.samples...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		report := op.report
+		report.Achieved = latencyPercentiles{
+			P50Ms: millis(percentile(sorted, 0.50)),
+			P95Ms: millis(percentile(sorted, 0.95)),
+			P99Ms: millis(percentile(sorted, 0.99)),
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Service != reports[j].Service {
+			return reports[i].Service < reports[j].Service
+		}
+		return reports[i].Operation < reports[j].Operation
+	})
+	return reports
+}
+
+// percentile returns the nearest-rank percentile of sorted durations.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(q*float64(len(sorted))+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/generator"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+// traceGenerator makes the traces for one worker.
+type traceGenerator interface {
+	GenerateTrace(start time.Time) model.Trace
+	TakeLinked() []model.Trace
+	FlushLinked() []model.Trace
+}
+
+// newTraceGenerator returns a generator for cfg's profile, or one that mixes
+// the profiles in --profile-mix. Each profile draws its sessions from its
+// population in users.
+func newTraceGenerator(cfg config.Config, seed int64, users map[string]*generator.Population) traceGenerator {
+	if len(cfg.ProfileMix) == 0 {
+		g := generator.New(withSeed(cfg, seed))
+		g.SetPopulation(users[cfg.Profile])
+		return g
+	}
+	return newMixedGenerator(cfg, seed, users)
+}
+
+// newPopulations returns the --users population of each profile in cfg,
+// shared by every worker of the run.
+func newPopulations(cfg config.Config) map[string]*generator.Population {
+	users := map[string]*generator.Population{}
+	for _, profile := range cfg.Profiles() {
+		users[profile] = generator.NewPopulation(cfg.Users)
+	}
+	return users
+}
+
+// mixedGenerator holds a generator per profile in the mix and picks one for
+// each trace by weight, so every profile shares the run's rate.
+type mixedGenerator struct {
+	rng        *rand.Rand
+	cumulative []float64
+	generators []*generator.Generator
+}
+
+func newMixedGenerator(cfg config.Config, seed int64, users map[string]*generator.Population) *mixedGenerator {
+	m := &mixedGenerator{rng: rand.New(rand.NewSource(seed))}
+	total := 0.0
+	for i, share := range cfg.ProfileMix {
+		profileCfg := cfg
+		profileCfg.Profile = share.Profile
+		profileCfg.ProfileMix = nil
+		// Keep each profile's seed clear of the other workers' seeds so no
+		// two generators produce the same trace IDs.
+		profileCfg.Seed = seed + int64(i+1)<<32
+		total += share.Weight
+		m.cumulative = append(m.cumulative, total)
+		g := generator.New(profileCfg)
+		g.SetPopulation(users[share.Profile])
+		m.generators = append(m.generators, g)
+	}
+	return m
+}
+
+func (m *mixedGenerator) GenerateTrace(start time.Time) model.Trace {
+	pick := m.rng.Float64() * m.cumulative[len(m.cumulative)-1]
+	for i, bound := range m.cumulative {
+		if pick < bound {
+			return m.generators[i].GenerateTrace(start)
+		}
+	}
+	return m.generators[len(m.generators)-1].GenerateTrace(start)
+}
+
+func (m *mixedGenerator) TakeLinked() []model.Trace {
+	var out []model.Trace
+	for _, g := range m.generators {
+		out = append(out, g.TakeLinked()...)
+	}
+	return out
+}
+
+func (m *mixedGenerator) FlushLinked() []model.Trace {
+	var out []model.Trace
+	for _, g := range m.generators {
+		out = append(out, g.FlushLinked()...)
+	}
+	return out
+}
+
+// trackProfiles makes the manifest count traces by profile, in mix order.
+func (m *reportManifest) trackProfiles(mix config.ProfileMix) {
+	m.profiles = map[string]*shareTally{}
+	for i, share := range mix {
+		m.profiles[share.Profile] = newShareTally(share.Profile, mix.Share(i))
+	}
+	m.profileOrder = mix.Profiles()
+}
+
+func (m *reportManifest) profileReports() []shareReport {
+	if m.profiles == nil {
+		return nil
+	}
+	out := make([]shareReport, 0, len(m.profileOrder))
+	for _, name := range m.profileOrder {
+		out = append(out, m.profiles[name].snapshot())
+	}
+	return out
+}
+
+// reportProfile is the profile the run report names: the profile, or the
+// mix when there is one.
+func reportProfile(cfg config.Config) string {
+	if len(cfg.ProfileMix) > 0 {
+		return cfg.ProfileMix.String()
+	}
+	return cfg.Profile
+}
+
+// traceProfile returns the profile that generated trace, read from its
+// spanforge.profile attribute.
+func traceProfile(trace model.Trace) string {
+	return traceAttribute(trace, "spanforge.profile")
+}
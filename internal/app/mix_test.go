@@ -0,0 +1,103 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+)
+
+func TestRunProfileMixReportsEachProfile(t *testing.T) {
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	cfg := reportTestConfig(reportPath)
+	cfg.RateValue = 2000
+	cfg.Count = 400
+	cfg.Workers = 2
+	cfg.ProfileMix = config.ProfileMix{{Profile: "web", Weight: 60}, {Profile: "grpc", Weight: 25}, {Profile: "queue", Weight: 10}, {Profile: "batch", Weight: 5}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := Run(cfg, bytes.NewBuffer(nil)); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var report runReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if report.Profile != "web=60,grpc=25,queue=10,batch=5" {
+		t.Fatalf("profile=%q", report.Profile)
+	}
+	if len(report.Profiles) != 4 {
+		t.Fatalf("profiles=%+v want 4", report.Profiles)
+	}
+	var traces uint64
+	for i, p := range report.Profiles {
+		if p.Name != cfg.ProfileMix[i].Profile || p.Share != cfg.ProfileMix.Share(i) {
+			t.Fatalf("profile %d=%+v", i, p)
+		}
+		if p.TracesSent == 0 || len(p.Services) == 0 || len(p.SampleTraceIDs) == 0 {
+			t.Fatalf("profile %s sent nothing: %+v", p.Name, p)
+		}
+		traces += p.TracesSent
+	}
+	if traces != report.EmittedTraces {
+		t.Fatalf("profile traces=%d emitted=%d", traces, report.EmittedTraces)
+	}
+	if web, batch := report.Profiles[0].TracesSent, report.Profiles[3].TracesSent; web < 4*batch {
+		t.Fatalf("web=%d batch=%d want web about 12x batch", web, batch)
+	}
+}
+
+func TestMixedGeneratorSeedsProfilesApart(t *testing.T) {
+	cfg := reportTestConfig("")
+	cfg.ProfileMix = config.ProfileMix{{Profile: "web", Weight: 1}, {Profile: "grpc", Weight: 1}}
+	g := newMixedGenerator(cfg, cfg.Seed, newPopulations(cfg))
+	seen := map[string]bool{}
+	profiles := map[string]int{}
+	for i := 0; i < 200; i++ {
+		trace := g.GenerateTrace(time.Now().UTC())
+		id := fmtTraceID(trace.TraceID)
+		if seen[id] {
+			t.Fatalf("trace ID %s repeated", id)
+		}
+		seen[id] = true
+		profiles[traceProfile(trace)]++
+	}
+	if profiles["web"] < 60 || profiles["grpc"] < 60 {
+		t.Fatalf("profiles=%v want both near 100", profiles)
+	}
+}
+
+func TestDeliveryHoldsOnlyRealtimeProfilesInMix(t *testing.T) {
+	cfg := reportTestConfig("")
+	cfg.Depth = 3
+	cfg.Fanout = 2
+	cfg.WorkflowLifetime = 50 * time.Millisecond
+	cfg.ProfileMix = config.ProfileMix{{Profile: "web", Weight: 1}, {Profile: "workflow", Weight: 1}}
+	if !deliveryEnabled(cfg) {
+		t.Fatalf("delivery disabled for a mix with workflow")
+	}
+
+	s := newDeliveryScheduler(cfg, newEmitterStats())
+	g := newMixedGenerator(cfg, cfg.Seed, newPopulations(cfg))
+	pieces := map[string]int{}
+	for i := 0; i < 20; i++ {
+		trace := g.GenerateTrace(time.Now().UTC())
+		before := s.queue.Len()
+		s.schedule(trace, time.Now())
+		if profile := traceProfile(trace); s.queue.Len()-before > 1 {
+			pieces[profile]++
+		}
+	}
+	if pieces["web"] != 0 || pieces["workflow"] == 0 {
+		t.Fatalf("traces split into spans by profile=%v, want workflow only", pieces)
+	}
+}
@@ -0,0 +1,200 @@
+package app
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+func writePhaseFile(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "phases.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write phase file: %v", err)
+	}
+	return path
+}
+
+func TestPhaseFileOverridesConfigPerPhase(t *testing.T) {
+	plan, err := loadPhaseFile(writePhaseFile(t, `
+repeat: 2
+phases:
+  - name: web
+    duration: 1s
+  - name: payments
+    duration: 1s
+    ramp: exponential
+    rate: 400
+    profile: payment-system
+    depth: 5
+    weird: [future-timestamp]
+    headers: ["x-scenario=payments"]
+`))
+	if err != nil {
+		t.Fatalf("loadPhaseFile: %v", err)
+	}
+	if len(plan.Phases) != 4 || plan.Forever || plan.Phases[2].Name != "web" {
+		t.Fatalf("plan=%+v want the two phases twice", plan)
+	}
+	cfg := reportTestConfig("")
+	web, err := plan.Phases[0].configure(cfg)
+	if err != nil || web.Profile != "web" || web.Depth != cfg.Depth || len(web.Headers) != 0 {
+		t.Fatalf("web phase profile=%s depth=%d headers=%v err=%v", web.Profile, web.Depth, web.Headers, err)
+	}
+	payments, err := plan.Phases[1].configure(cfg)
+	if err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	if payments.Profile != "payment-system" || payments.Depth != 5 || payments.Weird[0] != "future-timestamp" || payments.Headers["x-scenario"] != "payments" {
+		t.Fatalf("payments phase=%+v", payments)
+	}
+	if plan.Phases[1].Ramp != rampExponential || payments.RateValue != 400 {
+		t.Fatalf("ramp=%q rate=%.0f", plan.Phases[1].Ramp, payments.RateValue)
+	}
+}
+
+func TestPhaseFileErrorsGiveLines(t *testing.T) {
+	cases := map[string]string{
+		"phases:\n  - name: a\n    duration: 1s\n  - name: b\n    duration: 1s\n    colour: red\n":   "line 6: unknown setting \"colour\"",
+		"phases:\n  - name: a\n    duration: 1s\n  - name: b\n    duration: 1s\n    format: jsonl\n": "line 6: format cannot change during a run",
+		"phases:\n  - name: a\n    duration: soon\n":                                                 "line 2: invalid duration",
+		"phases:\n  - name: a\n    duration: 1s\n    ramp: cubic\n":                                  "line 2: invalid ramp",
+		"loop: sometimes\nphases:\n  - name: a\n    duration: 1s\n":                                  "loop must be forever",
+	}
+	for body, want := range cases {
+		_, err := loadPhaseFile(writePhaseFile(t, body))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("err=%v want %q for\n%s", err, want, body)
+		}
+	}
+
+	path := writePhaseFile(t, "phases:\n  - name: a\n    duration: 1s\n  - name: b\n    duration: 1s\n    depth: 0\n")
+	cfg := reportTestConfig("")
+	cfg.PhaseFile = path
+	if _, err := loadPhases(cfg); err == nil || !strings.Contains(err.Error(), "line 4: invalid phase \"b\": depth must be > 0") {
+		t.Fatalf("err=%v want the invalid phase's line", err)
+	}
+}
+
+func TestLoopForeverCannotUseCount(t *testing.T) {
+	cfg := reportTestConfig("")
+	cfg.PhaseFile = writePhaseFile(t, "loop: forever\nphases:\n  - name: a\n    duration: 1s\n")
+	if _, err := loadPhases(cfg); err == nil {
+		t.Fatal("loop forever with count loaded, want error")
+	}
+	cfg.Count = 0
+	plan, err := loadPhases(cfg)
+	if err != nil || !plan.Forever {
+		t.Fatalf("plan=%+v err=%v want a forever loop", plan, err)
+	}
+}
+
+func TestRampConfigInterpolates(t *testing.T) {
+	from := reportTestConfig("")
+	from.RateValue, from.Errors, from.P95 = 100, 0, 40*time.Millisecond
+	to := from
+	to.RateValue, to.Errors, to.P95 = 400, 0.1, 160*time.Millisecond
+
+	linear := rampConfig(from, to, rampLinear, 0.5)
+	if linear.RateValue != 250 || math.Abs(linear.Errors-0.05) > 1e-12 || linear.P95 != 100*time.Millisecond {
+		t.Fatalf("linear rate=%.0f errors=%.3f p95=%s", linear.RateValue, linear.Errors, linear.P95)
+	}
+	exponential := rampConfig(from, to, rampExponential, 0.5)
+	if math.Abs(exponential.RateValue-200) > 1e-9 || math.Abs(exponential.Errors-0.05) > 1e-12 || exponential.P95 != 80*time.Millisecond {
+		t.Fatalf("exponential rate=%.1f errors=%.3f (linear from zero) p95=%s", exponential.RateValue, exponential.Errors, exponential.P95)
+	}
+
+	to.RateUnit = config.RateUnitSpans
+	if got := rampConfig(from, to, rampLinear, 0.5).RateValue; got != 400 {
+		t.Fatalf("rate across units=%.0f want the phase's own rate", got)
+	}
+}
+
+func TestRampSplitsCountByTraffic(t *testing.T) {
+	from := reportTestConfig("")
+	from.RateValue = 1000
+	to := from
+	to.RateValue = 3000
+	to.Duration = time.Second
+	to.Count = 40
+	seeds := 0
+	traceCh := make(chan model.Trace, 64)
+	if err := produceRamp(context.Background(), from, to, rampLinear, func() int64 { seeds++; return int64(100 + seeds) }, traceCh); err != nil {
+		t.Fatalf("produceRamp: %v", err)
+	}
+	close(traceCh)
+	traces := 0
+	for range traceCh {
+		traces++
+	}
+	if traces != 40 {
+		t.Fatalf("traces=%d want the phase count", traces)
+	}
+	if seeds != 9 {
+		t.Fatalf("new seeds=%d want one for each of the 9 later steps", seeds)
+	}
+}
+
+func TestPhaseHeadersReachTheSink(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	var mu sync.Mutex
+	scenarios := map[string]int{}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		scenarios[r.Header.Get("x-scenario")+"/"+r.Header.Get("authorization")]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.Listener = lis
+	srv.Start()
+	defer srv.Close()
+
+	cfg := reportTestConfig("")
+	cfg.Count = 4
+	cfg.Output = "otlp"
+	cfg.OTLPEndpoint = srv.URL
+	cfg.Headers = map[string]string{"authorization": "Bearer t", "x-scenario": "baseline"}
+	cfg.PhaseFile = writePhaseFile(t, `
+phases:
+  - name: baseline
+    duration: 1s
+  - name: tenant
+    duration: 1s
+    headers: ["x-scenario=tenant"]
+`)
+	if err := Run(cfg, &strings.Builder{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if scenarios["baseline/Bearer t"] == 0 || scenarios["tenant/Bearer t"] == 0 || len(scenarios) != 2 {
+		t.Fatalf("requests by headers=%v want batches for each phase", scenarios)
+	}
+}
+
+func TestExamplePhaseFilesLoad(t *testing.T) {
+	paths, err := filepath.Glob("../../examples/phases/*.yaml")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("example phase files=%v err=%v", paths, err)
+	}
+	for _, path := range paths {
+		cfg := reportTestConfig("")
+		cfg.Count = 0
+		cfg.PhaseFile = path
+		if _, err := loadPhases(cfg); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	}
+}
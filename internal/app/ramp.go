@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+// Phase ramps for the phase file ramp key.
+const (
+	rampLinear      = "linear"
+	rampExponential = "exponential"
+)
+
+// produceRamp runs the phase to in short steps whose rate, error rate, retry
+// rate, fanout and latency move from from's values to to's. Every step after
+// the first takes a new seed from nextSeed. With a count, each step gets its
+// share of to.Count by the traffic it carries.
+func produceRamp(ctx context.Context, from, to config.Config, ramp string, nextSeed func() int64, traceCh chan<- model.Trace) error {
+	step := stepLength(to.Duration)
+	var steps []config.Config
+	var weights []float64
+	total := 0.0
+	for at := time.Duration(0); at < to.Duration; at += step {
+		length := min(step, to.Duration-at)
+		progress := (float64(at) + float64(length)/2) / float64(to.Duration)
+		stepCfg := rampConfig(from, to, ramp, progress)
+		stepCfg.Duration = length
+		steps = append(steps, stepCfg)
+		weights = append(weights, stepCfg.RateValue*float64(length))
+		total += stepCfg.RateValue * float64(length)
+	}
+	sent := 0
+	cumulative := 0.0
+	for i, stepCfg := range steps {
+		if i > 0 {
+			stepCfg.Seed = nextSeed()
+		}
+		if to.Count > 0 {
+			cumulative += weights[i]
+			target := to.Count
+			if i < len(steps)-1 && total > 0 {
+				target = int(math.Round(float64(to.Count) * cumulative / total))
+			}
+			stepCfg.Count = target - sent
+			sent = target
+			if stepCfg.Count <= 0 {
+				continue
+			}
+		}
+		if err := produceTraceSteady(ctx, stepCfg, traceCh); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// rampConfig returns to with its ramped values progress of the way from
+// from's. Exponential ramps move by equal ratios rather than equal steps,
+// falling back to linear when a value is zero. The rate only ramps when both
+// phases use the same rate unit.
+func rampConfig(from, to config.Config, ramp string, progress float64) config.Config {
+	cfg := to
+	interpolate := func(a, b float64) float64 {
+		if ramp == rampExponential && a > 0 && b > 0 {
+			return a * math.Pow(b/a, progress)
+		}
+		return a + (b-a)*progress
+	}
+	between := func(a, b time.Duration) time.Duration {
+		return time.Duration(interpolate(float64(a), float64(b)))
+	}
+	if from.RateUnit == to.RateUnit {
+		cfg.RateValue = interpolate(from.RateValue, to.RateValue)
+	}
+	cfg.Errors = interpolate(from.Errors, to.Errors)
+	cfg.Retries = interpolate(from.Retries, to.Retries)
+	cfg.Fanout = interpolate(from.Fanout, to.Fanout)
+	cfg.P50 = between(from.P50, to.P50)
+	cfg.P95 = between(from.P95, to.P95)
+	cfg.P99 = between(from.P99, to.P99)
+	return cfg
+}
@@ -79,6 +79,43 @@ func TestRunWritesReportFile(t *testing.T) {
 	}
 }
 
+func TestRunReportComparesOperationLatencyWithTargets(t *testing.T) {
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	cfg := reportTestConfig(reportPath)
+	cfg.Count = 200
+	cfg.RateValue = 100000
+	cfg.LatencyModels = []config.LatencyModel{{Operation: "GET /cart", Kind: config.LatencyFixed, P50: 40 * time.Millisecond, P95: 49 * time.Millisecond, P99: 49800 * time.Microsecond}}
+	if err := Run(cfg, bytes.NewBuffer(nil)); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	operations, ok := readReport(t, reportPath)["operations"].([]any)
+	if !ok || len(operations) == 0 {
+		t.Fatal("report has no operations")
+	}
+	found := false
+	for _, raw := range operations {
+		op := raw.(map[string]any)
+		if op["operation"] != "GET /cart" || op["service"] == "api-gateway" {
+			if _, ok := op["target"]; op["operation"] != "GET /cart" && (ok || op["model"] != "none") {
+				t.Fatalf("operation=%v want no target for an unmodelled operation", op)
+			}
+			continue
+		}
+		found = true
+		target := op["target"].(map[string]any)
+		achieved := op["achieved"].(map[string]any)
+		if op["model"] != "fixed" || target["p99_ms"] != 49.8 {
+			t.Fatalf("operation=%v want the fixed model target", op)
+		}
+		if p50 := achieved["p50_ms"].(float64); p50 < 35 || p50 > 45 {
+			t.Fatalf("achieved p50=%vms want about 40ms", p50)
+		}
+	}
+	if !found {
+		t.Fatalf("no GET /cart client operation in %v", operations)
+	}
+}
+
 func TestRunPhaseFileAddsPhaseReport(t *testing.T) {
 	tmp := t.TempDir()
 	phasePath := filepath.Join(tmp, "phases.yaml")
@@ -123,6 +160,123 @@ phases:
 	}
 }
 
+func TestPhaseFileDeploymentSettlesServiceVersions(t *testing.T) {
+	phasePath := filepath.Join(t.TempDir(), "phases.yaml")
+	if err := os.WriteFile(phasePath, []byte(`
+phases:
+  - name: before
+    duration: 1s
+  - name: canary
+    duration: 1s
+    deployment:
+      service: checkout-api
+      from: v1
+      to: v2
+      canary_start: 5%
+      latency: 1.5
+      errors: 10%
+  - name: after
+    duration: 1s
+`), 0o644); err != nil {
+		t.Fatalf("write phase file: %v", err)
+	}
+	plan, err := loadPhaseFile(phasePath)
+	if err != nil {
+		t.Fatalf("loadPhaseFile: %v", err)
+	}
+	phases := plan.Phases
+	rollout := phases[1].Deployment
+	if rollout == nil {
+		t.Fatal("expected deployment on canary phase")
+	}
+	if rollout.CanaryStart != 0.05 || rollout.CanaryEnd != 1 || rollout.Latency != 1.5 || rollout.Errors != 0.1 {
+		t.Fatalf("rollout=%+v", *rollout)
+	}
+	versions := initialServiceVersions(phases)
+	if versions["checkout-api"] != "v1" {
+		t.Fatalf("initial version=%q want v1", versions["checkout-api"])
+	}
+	if got := settleRollout(versions, *rollout)["checkout-api"]; got != "v2" {
+		t.Fatalf("settled version=%q want v2", got)
+	}
+	rollout.CanaryEnd = 0.5
+	if got := settleRollout(versions, *rollout)["checkout-api"]; got != "v1" {
+		t.Fatalf("partial rollout settled at %q want rollback to v1", got)
+	}
+}
+
+func TestPhaseFileFaultsApplyOnlyToTheirPhase(t *testing.T) {
+	phasePath := filepath.Join(t.TempDir(), "phases.yaml")
+	if err := os.WriteFile(phasePath, []byte(`
+phases:
+  - name: baseline
+    duration: 1s
+  - name: brownout
+    duration: 1s
+    faults:
+      - service: payment-service
+        operation: POST /charge
+        rate: 30%
+        status: 503
+        latency: +800ms
+      - service: ledger-service
+        timeout: 5s
+`), 0o644); err != nil {
+		t.Fatalf("write phase file: %v", err)
+	}
+	plan, err := loadPhaseFile(phasePath)
+	if err != nil {
+		t.Fatalf("loadPhaseFile: %v", err)
+	}
+	phases := plan.Phases
+	cfg := reportTestConfig("")
+	cfg.Faults = []config.Fault{{Service: "cart-service", Rate: 1}}
+	if got := phases[0].apply(cfg).Faults; len(got) != 1 {
+		t.Fatalf("baseline faults=%+v want only the CLI fault", got)
+	}
+	got := phases[1].apply(cfg).Faults
+	want := []config.Fault{
+		{Service: "cart-service", Rate: 1},
+		{Service: "payment-service", Operation: "POST /charge", Rate: 0.3, Status: 503, Latency: 800 * time.Millisecond},
+		{Service: "ledger-service", Rate: 1, Timeout: 5 * time.Second},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("brownout faults=%+v want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("brownout fault %d=%+v want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPhaseFileRetryStormAppliesOnlyToItsPhase(t *testing.T) {
+	phasePath := filepath.Join(t.TempDir(), "phases.yaml")
+	if err := os.WriteFile(phasePath, []byte(`
+phases:
+  - name: baseline
+    duration: 1s
+  - name: brownout
+    duration: 1s
+    errors: 20%
+    retry_storm: true
+`), 0o644); err != nil {
+		t.Fatalf("write phase file: %v", err)
+	}
+	plan, err := loadPhaseFile(phasePath)
+	if err != nil {
+		t.Fatalf("loadPhaseFile: %v", err)
+	}
+	phases := plan.Phases
+	cfg := reportTestConfig("")
+	if phases[0].apply(cfg).RetryStorm {
+		t.Fatal("baseline phase has a retry storm")
+	}
+	if !phases[1].apply(cfg).RetryStorm {
+		t.Fatal("brownout phase has no retry storm")
+	}
+}
+
 func TestRunBuiltInLoadAddsPhaseReport(t *testing.T) {
 	tmp := t.TempDir()
 	reportPath := filepath.Join(tmp, "report.json")
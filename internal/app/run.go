@@ -11,17 +11,15 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/robmcelhinney/spanforge/internal/config"
-	jsonlenc "github.com/robmcelhinney/spanforge/internal/encode/jsonl"
 	prettyenc "github.com/robmcelhinney/spanforge/internal/encode/pretty"
-	"github.com/robmcelhinney/spanforge/internal/generator"
 	"github.com/robmcelhinney/spanforge/internal/model"
-	"github.com/robmcelhinney/spanforge/internal/sink/otlpgrpc"
-	"github.com/robmcelhinney/spanforge/internal/sink/otlphttp"
-	"github.com/robmcelhinney/spanforge/internal/sink/zipkin"
+	"github.com/robmcelhinney/spanforge/internal/sink"
 	"gopkg.in/yaml.v3"
 )
 
@@ -47,6 +45,54 @@ type runReport struct {
 	Services        []string      `json:"services"`
 	SampleTraceIDs  []string      `json:"sample_trace_ids"`
 	Phases          []phaseReport `json:"phases,omitempty"`
+	DelayedSpans    uint64        `json:"delayed_spans,omitempty"`
+	// Profiles breaks the run down by profile when it uses --profile-mix.
+	Profiles []shareReport `json:"profiles,omitempty"`
+	// Tenants breaks the run down by tenant when it uses --tenant.
+	Tenants []tenantReport `json:"tenants,omitempty"`
+	// Sinks breaks the run down by sink when it uses --sink.
+	Sinks []sinkReport `json:"sinks,omitempty"`
+	// Operations compares achieved span latency with the latency model targets.
+	Operations []operationReport `json:"operations,omitempty"`
+	// Compression sizes the request bodies of a run sending to an endpoint.
+	Compression *compressionReport `json:"compression,omitempty"`
+	// Endpoints breaks a network run's requests down by endpoint address.
+	Endpoints []endpointReport `json:"endpoints,omitempty"`
+	// Send totals a network run's requests and their latency.
+	Send *sendReport `json:"send,omitempty"`
+	// Adaptive is how the rate moved under --rate-control=adaptive.
+	Adaptive *adaptiveReport `json:"adaptive,omitempty"`
+}
+
+// compressionReport totals the request bodies a sink sent. Ratio is
+// uncompressed over compressed bytes, so 1 means no saving.
+type compressionReport struct {
+	Algorithm         string  `json:"algorithm"`
+	Batches           uint64  `json:"batches"`
+	UncompressedBytes uint64  `json:"uncompressed_bytes"`
+	CompressedBytes   uint64  `json:"compressed_bytes"`
+	Ratio             float64 `json:"ratio"`
+}
+
+// newCompressionReport reports the requests in snapshot, or returns nil
+// when none were sent.
+func newCompressionReport(algorithm string, snapshot statsSnapshot) *compressionReport {
+	if snapshot.SentBatches == 0 {
+		return nil
+	}
+	if algorithm == "" {
+		algorithm = "none"
+	}
+	r := &compressionReport{
+		Algorithm:         algorithm,
+		Batches:           snapshot.SentBatches,
+		UncompressedBytes: snapshot.UncompressedBytes,
+		CompressedBytes:   snapshot.CompressedBytes,
+	}
+	if r.CompressedBytes > 0 {
+		r.Ratio = float64(r.UncompressedBytes) / float64(r.CompressedBytes)
+	}
+	return r
 }
 
 type phaseReport struct {
@@ -61,6 +107,14 @@ type reportManifest struct {
 	seenTraceIDs   map[string]struct{}
 	phases         map[string]*phaseReport
 	phaseOrder     []string
+	// profiles is nil unless the run uses --profile-mix.
+	profiles     map[string]*shareTally
+	profileOrder []string
+	// tenants is nil unless the run uses --tenant.
+	tenants     map[string]*shareTally
+	tenantOrder []tenantReport
+	// latencies is nil unless the run writes a report file.
+	latencies *operationLatencies
 }
 
 func newReportManifest() *reportManifest {
@@ -80,6 +134,9 @@ func (m *reportManifest) observe(trace model.Trace) {
 		}
 	}
 	for _, span := range trace.Spans {
+		if m.latencies != nil {
+			m.latencies.observe(span)
+		}
 		if service, ok := span.Attributes["service.name"].(string); ok && service != "" {
 			m.services[service] = struct{}{}
 		}
@@ -94,18 +151,32 @@ func (m *reportManifest) observe(trace model.Trace) {
 			p = &phaseReport{Name: phase}
 			m.phases[phase] = p
 		}
-		p.TracesSent++
+		p.TracesSent += uint64(traceCount(trace))
 		p.SpansSent += uint64(len(trace.Spans))
 	}
+	if m.profiles != nil {
+		if p, ok := m.profiles[traceProfile(trace)]; ok {
+			p.observe(trace, traceID)
+		}
+	}
+	if t, ok := m.tenants[trace.Tenant]; ok {
+		t.observe(trace, traceID)
+	}
 }
 
 func tracePhase(trace model.Trace) string {
+	return traceAttribute(trace, "spanforge.phase")
+}
+
+// traceAttribute returns the first non-empty string value of key on a span
+// or its resource.
+func traceAttribute(trace model.Trace, key string) string {
 	for _, span := range trace.Spans {
-		if phase, ok := span.Attributes["spanforge.phase"].(string); ok && phase != "" {
-			return phase
+		if value, ok := span.Attributes[key].(string); ok && value != "" {
+			return value
 		}
-		if phase, ok := span.Resource.Attributes["spanforge.phase"].(string); ok && phase != "" {
-			return phase
+		if value, ok := span.Resource.Attributes[key].(string); ok && value != "" {
+			return value
 		}
 	}
 	return ""
@@ -121,10 +192,17 @@ func (m *reportManifest) snapshot() reportManifestSnapshot {
 	for _, name := range m.phaseOrder {
 		phases = append(phases, *m.phases[name])
 	}
+	var operations []operationReport
+	if m.latencies != nil {
+		operations = m.latencies.snapshot()
+	}
 	return reportManifestSnapshot{
 		Services:       services,
 		SampleTraceIDs: append([]string(nil), m.sampleTraceIDs...),
 		Phases:         phases,
+		Profiles:       m.profileReports(),
+		Tenants:        m.tenantReports(),
+		Operations:     operations,
 	}
 }
 
@@ -132,30 +210,69 @@ type reportManifestSnapshot struct {
 	Services       []string
 	SampleTraceIDs []string
 	Phases         []phaseReport
+	Profiles       []shareReport
+	Tenants        []tenantReport
+	Operations     []operationReport
 }
 
 func Run(cfg config.Config, out io.Writer) error {
+	// An adaptive run backs off when the sink throttles, so batches that
+	// run out of retries meanwhile are counted rather than ending the run.
+	var failures *sinkFailures
+	if cfg.Adaptive() {
+		failures = &sinkFailures{}
+	}
+	_, err := run(cfg, out, failures)
+	return err
+}
+
+// run runs cfg and returns its report. With failures set, batches the run's
+// sink fails to send are counted there instead of failing the run.
+func run(cfg config.Config, out io.Writer, failures *sinkFailures) (runReport, error) {
 	cfg.RunID = effectiveRunID(cfg)
 	stats := newEmitterStats()
 	manifest := newReportManifest()
+	if cfg.ReportFile != "" {
+		manifest.latencies = newOperationLatencies(cfg)
+	}
+	if len(cfg.ProfileMix) > 0 {
+		manifest.trackProfiles(cfg.ProfileMix)
+	}
+	if len(cfg.Tenants) > 0 {
+		manifest.trackTenants(cfg)
+	}
+	var control *rateController
+	if cfg.Adaptive() {
+		control = newRateController(cfg)
+		stats.control = control
+	}
 	runStarted := time.Now().UTC()
 	debugf(cfg, "starting run format=%s output=%s rate=%.2f/%s duration=%s count=%d workers=%d", cfg.Format, cfg.Output, cfg.RateValue, cfg.RateUnit, cfg.Duration, cfg.Count, cfg.Workers)
 
-	writer := out
-	if cfg.Output == "file" {
-		if cfg.File == "" {
-			return fmt.Errorf("--file is required when --output=file")
+	var fan *fanOut
+	if len(cfg.Sinks) > 0 {
+		var err error
+		if fan, err = openFanOut(cfg, out); err != nil {
+			return runReport{}, err
 		}
-		f, err := os.Create(cfg.File)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		writer = f
+		defer func() { _ = fan.close() }()
 	}
 
-	buf := bufio.NewWriter(writer)
+	buf := bufio.NewWriter(out)
 	defer buf.Flush()
+	var stream streamWriter = buf
+	var outFile streamFile
+	if cfg.Output == "file" && fan == nil {
+		if cfg.File == "" {
+			return runReport{}, fmt.Errorf("--file is required when --output=file")
+		}
+		var err error
+		if outFile, err = openStreamFile(cfg, cfg.File); err != nil {
+			return runReport{}, err
+		}
+		defer outFile.Close()
+		stream = outFile
+	}
 
 	traceCh := make(chan model.Trace, cfg.BatchSize)
 	errCh := make(chan error, 1)
@@ -179,10 +296,28 @@ func Run(cfg config.Config, out io.Writer) error {
 		}()
 	}
 
+	sinkCh := (<-chan model.Trace)(traceCh)
+	if deliveryEnabled(cfg) {
+		deliveredCh := make(chan model.Trace, cfg.BatchSize)
+		sinkCh = deliveredCh
+		sinkWG.Add(1)
+		go func() {
+			defer sinkWG.Done()
+			defer close(deliveredCh)
+			_ = runDelivery(ctx, cfg, traceCh, deliveredCh, stats)
+		}()
+	}
+
 	sinkWG.Add(1)
 	go func() {
 		defer sinkWG.Done()
-		if err := consumeTraces(ctx, buf, cfg, traceCh, stats, manifest); err != nil {
+		var err error
+		if fan != nil {
+			err = fan.run(ctx, sinkCh, stats, manifest)
+		} else {
+			err = consumeTraces(ctx, stream, cfg, sinkCh, stats, manifest, failures)
+		}
+		if err != nil {
 			select {
 			case errCh <- err:
 			default:
@@ -191,25 +326,52 @@ func Run(cfg config.Config, out io.Writer) error {
 		}
 	}()
 
-	if err := produceTraces(ctx, cfg, traceCh); err != nil {
+	produce := produceTraces
+	if control != nil {
+		produce = func(ctx context.Context, cfg config.Config, traceCh chan<- model.Trace) error {
+			return produceTraceAdaptive(ctx, cfg, traceCh, control)
+		}
+	}
+	if err := produce(ctx, cfg, traceCh); err != nil {
 		cancel()
 		sinkWG.Wait()
 		adminWG.Wait()
-		return err
+		return runReport{}, err
 	}
 	close(traceCh)
 	sinkWG.Wait()
 	cancel()
 	adminWG.Wait()
+	// Complete the output files before the report, so a failure to
+	// finish one fails the run.
+	if outFile != nil {
+		if err := outFile.Close(); err != nil {
+			return runReport{}, err
+		}
+	}
+	if fan != nil {
+		if err := fan.close(); err != nil {
+			return runReport{}, err
+		}
+	}
 
 	select {
 	case err := <-errCh:
-		return err
+		return runReport{}, err
 	default:
 		finishedAt := time.Now().UTC()
 		snapshot := stats.snapshot()
 		report := buildRunReport(runStarted, finishedAt, cfg, snapshot, manifest.snapshot())
-		if cfg.Output == "noop" {
+		if fan != nil {
+			fan.describe(&report)
+		}
+		if control != nil {
+			report.Adaptive = control.report(snapshot)
+			if failures != nil {
+				report.Adaptive.FailedSpans = atomic.LoadUint64(&failures.spans)
+			}
+		}
+		if cfg.Output == "noop" && fan == nil {
 			if _, err := fmt.Fprintf(out,
 				"benchmark summary: traces=%d spans=%d duration=%.2fs traces/sec=%.2f spans/sec=%.2f\n",
 				report.EmittedTraces,
@@ -218,15 +380,15 @@ func Run(cfg config.Config, out io.Writer) error {
 				report.TracesPerSecond,
 				report.SpansPerSecond,
 			); err != nil {
-				return err
+				return runReport{}, err
 			}
 		}
 		if cfg.ReportFile != "" {
 			if err := writeRunReport(cfg.ReportFile, report); err != nil {
-				return err
+				return runReport{}, err
 			}
 		}
-		return nil
+		return report, nil
 	}
 }
 
@@ -240,7 +402,7 @@ func buildRunReport(startedAt, finishedAt time.Time, cfg config.Config, snapshot
 		FinishedAt:      finishedAt,
 		DurationSeconds: duration,
 		RunID:           cfg.RunID,
-		Profile:         cfg.Profile,
+		Profile:         reportProfile(cfg),
 		Format:          cfg.Format,
 		Output:          cfg.Output,
 		EmittedTraces:   snapshot.EmittedTraces,
@@ -250,6 +412,13 @@ func buildRunReport(startedAt, finishedAt time.Time, cfg config.Config, snapshot
 		Services:        manifest.Services,
 		SampleTraceIDs:  manifest.SampleTraceIDs,
 		Phases:          manifest.Phases,
+		Profiles:        manifest.Profiles,
+		Tenants:         manifest.Tenants,
+		DelayedSpans:    snapshot.DelayedSpans,
+		Operations:      manifest.Operations,
+		Compression:     newCompressionReport(cfg.Compress, snapshot),
+		Endpoints:       snapshot.Endpoints,
+		Send:            snapshot.Send,
 	}
 }
 
@@ -281,73 +450,149 @@ func writeRunReport(path string, report runReport) error {
 }
 
 func produceTraces(ctx context.Context, cfg config.Config, traceCh chan<- model.Trace) error {
-	phases, err := loadPhases(cfg)
+	if cfg.Curve != nil {
+		return produceTraceCurve(ctx, cfg, traceCh)
+	}
+	plan, err := loadPhases(cfg)
 	if err != nil {
 		return err
 	}
-	if len(phases) > 0 {
-		return produceTracePhases(ctx, cfg, phases, traceCh)
+	if len(plan.Phases) > 0 {
+		return produceTracePhases(ctx, cfg, plan, traceCh)
 	}
 	return produceTraceSteady(ctx, cfg, traceCh)
 }
 
-func produceTracePhases(ctx context.Context, cfg config.Config, phases []loadPhase, traceCh chan<- model.Trace) error {
+func produceTracePhases(ctx context.Context, cfg config.Config, plan phasePlan, traceCh chan<- model.Trace) error {
+	phases := plan.Phases
 	totalDuration := time.Duration(0)
 	for _, phase := range phases {
 		totalDuration += phase.Duration
 	}
 	remainingCount := cfg.Count
-	for i, phase := range phases {
-		phaseCfg := phase.apply(cfg)
-		phaseCfg.Seed = cfg.Seed + int64(i*maxInt(1, cfg.Workers))
-		if err := phaseCfg.Validate(); err != nil {
-			return fmt.Errorf("invalid phase %q: %w", phase.Name, err)
-		}
-		if cfg.Count > 0 {
-			phaseCfg.Count = phaseCount(cfg.Count, remainingCount, phase.Duration, totalDuration, i == len(phases)-1)
-			remainingCount -= phaseCfg.Count
-			if phaseCfg.Count <= 0 {
-				continue
+	versions := initialServiceVersions(phases)
+	runs := 0
+	nextSeed := func() int64 {
+		runs++
+		return cfg.Seed + int64((runs-1)*maxInt(1, cfg.Workers))
+	}
+	prevCfg := cfg
+	for {
+		for i, phase := range phases {
+			phaseCfg, err := phase.configure(cfg)
+			if err != nil {
+				return fmt.Errorf("invalid phase %q: %w", phase.Name, err)
+			}
+			phaseCfg.Seed = nextSeed()
+			phaseCfg.ServiceVersions = versions
+			if phaseCfg.Rollout != nil {
+				phaseCfg.Rollout.Start = time.Now().UTC()
+				phaseCfg.Rollout.Window = phase.Duration
+			}
+			if err := phaseCfg.Validate(); err != nil {
+				return fmt.Errorf("invalid phase %q: %w", phase.Name, err)
+			}
+			if cfg.Count > 0 {
+				phaseCfg.Count = phaseCount(cfg.Count, remainingCount, phase.Duration, totalDuration, i == len(phases)-1)
+				remainingCount -= phaseCfg.Count
+				if phaseCfg.Count <= 0 {
+					continue
+				}
+			}
+			debugf(phaseCfg, "starting phase name=%s rate=%.2f/%s duration=%s count=%d errors=%.4f retries=%.4f p95=%s ramp=%s", phase.Name, phaseCfg.RateValue, phaseCfg.RateUnit, phaseCfg.Duration, phaseCfg.Count, phaseCfg.Errors, phaseCfg.Retries, phaseCfg.P95, phase.Ramp)
+			if phase.Ramp != "" {
+				err = produceRamp(ctx, prevCfg, phaseCfg, phase.Ramp, nextSeed, traceCh)
+			} else {
+				err = produceTraceSteady(ctx, phaseCfg, traceCh)
+			}
+			if err != nil {
+				return err
+			}
+			prevCfg = phaseCfg
+			if phase.Deployment != nil {
+				versions = settleRollout(versions, *phase.Deployment)
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			default:
 			}
 		}
-		debugf(phaseCfg, "starting phase name=%s rate=%.2f/%s duration=%s count=%d errors=%.4f retries=%.4f p95=%s", phase.Name, phaseCfg.RateValue, phaseCfg.RateUnit, phaseCfg.Duration, phaseCfg.Count, phaseCfg.Errors, phaseCfg.Retries, phaseCfg.P95)
-		if err := produceTraceSteady(ctx, phaseCfg, traceCh); err != nil {
-			return err
-		}
-		select {
-		case <-ctx.Done():
+		if !plan.Forever {
 			return nil
-		default:
 		}
 	}
-	return nil
 }
 
-func produceTraceSteady(ctx context.Context, cfg config.Config, traceCh chan<- model.Trace) error {
-	jobs := make(chan time.Time, cfg.Workers*2)
+// initialServiceVersions starts every service named in a deployment at the
+// version it rolls from, so its spans carry service.version before the rollout.
+func initialServiceVersions(phases []loadPhase) map[string]string {
+	versions := map[string]string{}
+	for _, phase := range phases {
+		if d := phase.Deployment; d != nil {
+			if _, ok := versions[d.Service]; !ok {
+				versions[d.Service] = d.From
+			}
+		}
+	}
+	return versions
+}
+
+// settleRollout records the version a service runs after its rollout phase.
+// A rollout that finishes below 100% is treated as rolled back.
+func settleRollout(versions map[string]string, rollout config.Rollout) map[string]string {
+	settled := make(map[string]string, len(versions))
+	for service, version := range versions {
+		settled[service] = version
+	}
+	settled[rollout.Service] = rollout.From
+	if rollout.CanaryEnd >= 1 {
+		settled[rollout.Service] = rollout.To
+	}
+	return settled
+}
+
+// startTraceWorkers starts cfg.Workers generators, each turning the start
+// times sent on jobs into traces. Close jobs, then call wait for the
+// generators to finish. blocked, when set, is called each time a generator
+// waits for the sink to take a trace.
+func startTraceWorkers(ctx context.Context, cfg config.Config, traceCh chan<- model.Trace, blocked func()) (jobs chan<- time.Time, wait func()) {
+	ch := make(chan time.Time, cfg.Workers*2)
+	users := newPopulations(cfg)
 	var workersWG sync.WaitGroup
 
 	for i := 0; i < cfg.Workers; i++ {
 		workersWG.Add(1)
 		go func(workerID int) {
 			defer workersWG.Done()
-			g := generator.New(withSeed(cfg, cfg.Seed+int64(workerID)))
-			for start := range jobs {
+			g := newTraceGenerator(cfg, cfg.Seed+int64(workerID), users)
+			tenants := newTenantPicker(cfg, cfg.Seed+int64(workerID))
+			for start := range ch {
 				trace := g.GenerateTrace(start)
-				select {
-				case traceCh <- trace:
-				case <-ctx.Done():
+				if !sendTraces(ctx, traceCh, cfg.Headers, tenants, append([]model.Trace{trace}, g.TakeLinked()...), blocked) {
 					return
 				}
 			}
+			sendTraces(ctx, traceCh, cfg.Headers, tenants, g.FlushLinked(), blocked)
 		}(i)
 	}
+	return ch, workersWG.Wait
+}
+
+func produceTraceSteady(ctx context.Context, cfg config.Config, traceCh chan<- model.Trace) error {
+	jobs, wait := startTraceWorkers(ctx, cfg, traceCh, nil)
 
 	targetTracesPerInterval := effectiveTracesPerInterval(cfg)
 	ratePerSecond := targetTracesPerInterval / cfg.RateInterval.Seconds()
 	if ratePerSecond <= 0 {
 		close(jobs)
-		workersWG.Wait()
+		wait()
+		return nil
+	}
+	if arrivals := newArrivalProcess(cfg, ratePerSecond); arrivals != nil {
+		produceArrivals(ctx, cfg, arrivals, jobs)
+		close(jobs)
+		wait()
 		return nil
 	}
 	tickInterval := 10 * time.Millisecond
@@ -358,7 +603,9 @@ func produceTraceSteady(ctx context.Context, cfg config.Config, traceCh chan<- m
 	if capacity < 1 {
 		capacity = 1
 	}
-	tokens := capacity
+	// Start with one token, not a full bucket, so phases and curve steps
+	// do not each begin with a burst above the rate.
+	tokens := 1.0
 	lastRefill := time.Now()
 	start := lastRefill.UTC()
 	hasDurationLimit := cfg.Count <= 0 && cfg.Duration > 0
@@ -401,7 +648,7 @@ func produceTraceSteady(ctx context.Context, cfg config.Config, traceCh chan<- m
 				dispatched = true
 			case <-ctx.Done():
 				close(jobs)
-				workersWG.Wait()
+				wait()
 				return nil
 			default:
 				tokens = 0
@@ -415,17 +662,41 @@ func produceTraceSteady(ctx context.Context, cfg config.Config, traceCh chan<- m
 		select {
 		case <-ctx.Done():
 			close(jobs)
-			workersWG.Wait()
+			wait()
 			return nil
 		case <-ticker.C:
 		}
 	}
 
 	close(jobs)
-	workersWG.Wait()
+	wait()
 	return nil
 }
 
+// sendTraces hands traces to the sink, to go out with headers and to a
+// tenant from tenants, and reports false once ctx is cancelled. blocked,
+// when set, is called before waiting on a full traceCh.
+func sendTraces(ctx context.Context, traceCh chan<- model.Trace, headers map[string]string, tenants *tenantPicker, traces []model.Trace, blocked func()) bool {
+	for _, trace := range traces {
+		trace.Headers = headers
+		tenants.assign(&trace)
+		if blocked != nil {
+			select {
+			case traceCh <- trace:
+				continue
+			default:
+				blocked()
+			}
+		}
+		select {
+		case traceCh <- trace:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
 type loadPhase struct {
 	Name     string
 	Duration time.Duration
@@ -436,6 +707,19 @@ type loadPhase struct {
 	P50      *time.Duration
 	P95      *time.Duration
 	P99      *time.Duration
+	// Deployment rolls one service to a new version during the phase.
+	Deployment *config.Rollout
+	// Faults apply during the phase on top of any --fault flags.
+	Faults []config.Fault
+	// RetryStorm makes every caller retry failed calls during the phase.
+	RetryStorm *bool
+	// Ramp moves the rate, error rate, retry rate and latency from the
+	// previous phase's values to this phase's across the phase.
+	Ramp string
+	// Overrides set any other config for the phase.
+	Overrides config.Overrides
+	// Line is where the phase starts in its phase file.
+	Line int
 }
 
 func (p loadPhase) apply(cfg config.Config) config.Config {
@@ -462,16 +746,43 @@ func (p loadPhase) apply(cfg config.Config) config.Config {
 	if p.P99 != nil {
 		cfg.P99 = *p.P99
 	}
+	if p.Deployment != nil {
+		rollout := *p.Deployment
+		cfg.Rollout = &rollout
+	}
+	if len(p.Faults) > 0 {
+		cfg.Faults = append(append([]config.Fault(nil), cfg.Faults...), p.Faults...)
+	}
+	if p.RetryStorm != nil {
+		cfg.RetryStorm = *p.RetryStorm
+	}
 	return cfg
 }
 
+// configure returns cfg as it runs during the phase, with the phase's
+// overrides set.
+func (p loadPhase) configure(cfg config.Config) (config.Config, error) {
+	return p.Overrides.Apply(p.apply(cfg))
+}
+
 type phaseFile struct {
-	Phases []phaseFileItem `yaml:"phases"`
+	Phases []yaml.Node `yaml:"phases"`
+	// Repeat plays the phases this many times in a row.
+	Repeat int `yaml:"repeat"`
+	// Loop "forever" plays the phases again until the run is stopped.
+	Loop string `yaml:"loop"`
+}
+
+// phasePlan is the phases of a run in the order they play.
+type phasePlan struct {
+	Phases  []loadPhase
+	Forever bool
 }
 
 type phaseFileItem struct {
 	Name     string   `yaml:"name"`
 	Duration string   `yaml:"duration"`
+	Ramp     string   `yaml:"ramp"`
 	Rate     *float64 `yaml:"rate"`
 	RateUnit *string  `yaml:"rate_unit"`
 	Errors   *string  `yaml:"errors"`
@@ -479,39 +790,128 @@ type phaseFileItem struct {
 	P50      *string  `yaml:"p50"`
 	P95      *string  `yaml:"p95"`
 	P99      *string  `yaml:"p99"`
+	// Deployment describes a version rollout of one service during the phase.
+	Deployment *phaseFileDeployment `yaml:"deployment"`
+	Faults     []phaseFileFault     `yaml:"faults"`
+	RetryStorm *bool                `yaml:"retry_storm"`
 }
 
-func loadPhases(cfg config.Config) ([]loadPhase, error) {
-	if cfg.PhaseFile != "" {
-		return loadPhaseFile(cfg.PhaseFile)
+type phaseFileFault struct {
+	Service   string  `yaml:"service"`
+	Operation string  `yaml:"operation"`
+	Rate      *string `yaml:"rate"`
+	Status    int     `yaml:"status"`
+	Latency   *string `yaml:"latency"`
+	Timeout   *string `yaml:"timeout"`
+}
+
+type phaseFileDeployment struct {
+	Service     string   `yaml:"service"`
+	From        string   `yaml:"from"`
+	To          string   `yaml:"to"`
+	CanaryStart *string  `yaml:"canary_start"`
+	CanaryEnd   *string  `yaml:"canary_end"`
+	Latency     *float64 `yaml:"latency"`
+	Errors      *string  `yaml:"errors"`
+}
+
+func loadPhases(cfg config.Config) (phasePlan, error) {
+	var plan phasePlan
+	var err error
+	switch {
+	case cfg.PhaseFile != "":
+		plan, err = loadPhaseFile(cfg.PhaseFile)
+	case cfg.Load != "":
+		plan.Phases, err = builtInLoad(cfg)
+	}
+	if err != nil {
+		return phasePlan{}, err
+	}
+	if plan.Forever && cfg.Count > 0 {
+		return phasePlan{}, fmt.Errorf("phase file loops forever and cannot be combined with count")
 	}
-	if cfg.Load != "" {
-		return builtInLoad(cfg)
+	for _, phase := range plan.Phases {
+		phaseCfg, err := phase.configure(cfg)
+		if err == nil {
+			err = phaseCfg.Validate()
+		}
+		if err != nil {
+			if phase.Line > 0 {
+				return phasePlan{}, fmt.Errorf("phase file %s line %d: invalid phase %q: %w", cfg.PhaseFile, phase.Line, phase.Name, err)
+			}
+			return phasePlan{}, fmt.Errorf("invalid phase %q: %w", phase.Name, err)
+		}
 	}
-	return nil, nil
+	return plan, nil
 }
 
-func loadPhaseFile(path string) ([]loadPhase, error) {
+func loadPhaseFile(path string) (phasePlan, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("read phase file: %w", err)
+		return phasePlan{}, fmt.Errorf("read phase file: %w", err)
 	}
 	var file phaseFile
 	if err := yaml.Unmarshal(data, &file); err != nil {
-		return nil, fmt.Errorf("parse phase file: %w", err)
+		return phasePlan{}, fmt.Errorf("parse phase file: %w", err)
 	}
 	if len(file.Phases) == 0 {
-		return nil, fmt.Errorf("phase file must contain at least one phase")
+		return phasePlan{}, fmt.Errorf("phase file must contain at least one phase")
+	}
+	if file.Repeat < 0 {
+		return phasePlan{}, fmt.Errorf("phase file %s: repeat must be >= 0", path)
+	}
+	if file.Loop != "" && file.Loop != "forever" {
+		return phasePlan{}, fmt.Errorf("phase file %s: loop must be forever", path)
+	}
+	if file.Loop != "" && file.Repeat > 1 {
+		return phasePlan{}, fmt.Errorf("phase file %s: set repeat or loop, not both", path)
 	}
 	phases := make([]loadPhase, 0, len(file.Phases))
-	for _, item := range file.Phases {
-		phase, err := parsePhaseFileItem(item)
+	for i := range file.Phases {
+		phase, err := parsePhaseNode(&file.Phases[i])
 		if err != nil {
-			return nil, err
+			return phasePlan{}, fmt.Errorf("phase file %s: %w", path, err)
 		}
 		phases = append(phases, phase)
 	}
-	return phases, nil
+	plan := phasePlan{Forever: file.Loop == "forever"}
+	for i := 0; i < max(1, file.Repeat); i++ {
+		plan.Phases = append(plan.Phases, phases...)
+	}
+	return plan, nil
+}
+
+// phaseKeys are the phase file keys that describe the phase itself. Every
+// other key overrides the config setting of the same name.
+var phaseKeys = map[string]bool{
+	"name": true, "duration": true, "ramp": true, "rate": true, "rate_unit": true,
+	"errors": true, "retries": true, "p50": true, "p95": true, "p99": true,
+	"deployment": true, "faults": true, "retry_storm": true,
+}
+
+func parsePhaseNode(node *yaml.Node) (loadPhase, error) {
+	if node.Kind != yaml.MappingNode {
+		return loadPhase{}, fmt.Errorf("line %d: phase must be a mapping", node.Line)
+	}
+	var item phaseFileItem
+	if err := node.Decode(&item); err != nil {
+		return loadPhase{}, err
+	}
+	phase, err := parsePhaseFileItem(item)
+	if err != nil {
+		return loadPhase{}, fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	settings := &yaml.Node{Kind: yaml.MappingNode, Line: node.Line}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !phaseKeys[node.Content[i].Value] {
+			settings.Content = append(settings.Content, node.Content[i], node.Content[i+1])
+		}
+	}
+	if phase.Overrides, err = config.ParseOverrides(settings); err != nil {
+		return loadPhase{}, fmt.Errorf("%w (phase %q)", err, item.Name)
+	}
+	phase.Line = node.Line
+	return phase, nil
 }
 
 func parsePhaseFileItem(item phaseFileItem) (loadPhase, error) {
@@ -522,7 +922,13 @@ func parsePhaseFileItem(item phaseFileItem) (loadPhase, error) {
 	if err != nil || duration <= 0 {
 		return loadPhase{}, fmt.Errorf("invalid duration for phase %q", item.Name)
 	}
-	phase := loadPhase{Name: item.Name, Duration: duration, Rate: item.Rate}
+	phase := loadPhase{Name: item.Name, Duration: duration, Rate: item.Rate, RetryStorm: item.RetryStorm}
+	switch item.Ramp {
+	case "", rampLinear, rampExponential:
+		phase.Ramp = item.Ramp
+	default:
+		return loadPhase{}, fmt.Errorf("invalid ramp for phase %q (must be linear or exponential)", item.Name)
+	}
 	if item.RateUnit != nil {
 		unit, err := config.ParseRateUnit(*item.RateUnit)
 		if err != nil {
@@ -551,9 +957,79 @@ func parsePhaseFileItem(item phaseFileItem) (loadPhase, error) {
 	if parseErr != nil {
 		return loadPhase{}, parseErr
 	}
+	if item.Deployment != nil {
+		phase.Deployment, parseErr = parsePhaseDeployment(*item.Deployment, item.Name)
+		if parseErr != nil {
+			return loadPhase{}, parseErr
+		}
+	}
+	for _, raw := range item.Faults {
+		fault, err := parsePhaseFault(raw, item.Name)
+		if err != nil {
+			return loadPhase{}, err
+		}
+		phase.Faults = append(phase.Faults, fault)
+	}
 	return phase, nil
 }
 
+func parsePhaseFault(item phaseFileFault, phase string) (config.Fault, error) {
+	fault := config.Fault{Service: item.Service, Operation: item.Operation, Rate: 1, Status: item.Status}
+	if v, err := optionalPercent(item.Rate, "fault rate", phase); err != nil {
+		return config.Fault{}, err
+	} else if v != nil {
+		fault.Rate = *v
+	}
+	if item.Latency != nil {
+		d, err := time.ParseDuration(strings.TrimPrefix(strings.TrimSpace(*item.Latency), "+"))
+		if err != nil || d < 0 {
+			return config.Fault{}, fmt.Errorf("invalid fault latency for phase %q", phase)
+		}
+		fault.Latency = d
+	}
+	if v, err := optionalDuration(item.Timeout, "fault timeout", phase); err != nil {
+		return config.Fault{}, err
+	} else if v != nil {
+		fault.Timeout = *v
+	}
+	if err := fault.Validate(); err != nil {
+		return config.Fault{}, fmt.Errorf("invalid fault for phase %q: %w", phase, err)
+	}
+	return fault, nil
+}
+
+func parsePhaseDeployment(item phaseFileDeployment, phase string) (*config.Rollout, error) {
+	rollout := config.Rollout{
+		Service:   item.Service,
+		From:      item.From,
+		To:        item.To,
+		CanaryEnd: 1,
+		Latency:   1,
+	}
+	if v, err := optionalPercent(item.CanaryStart, "deployment canary_start", phase); err != nil {
+		return nil, err
+	} else if v != nil {
+		rollout.CanaryStart = *v
+	}
+	if v, err := optionalPercent(item.CanaryEnd, "deployment canary_end", phase); err != nil {
+		return nil, err
+	} else if v != nil {
+		rollout.CanaryEnd = *v
+	}
+	if v, err := optionalPercent(item.Errors, "deployment errors", phase); err != nil {
+		return nil, err
+	} else if v != nil {
+		rollout.Errors = *v
+	}
+	if item.Latency != nil {
+		rollout.Latency = *item.Latency
+	}
+	if err := rollout.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid deployment for phase %q: %w", phase, err)
+	}
+	return &rollout, nil
+}
+
 func optionalPercent(raw *string, field, phase string) (*float64, error) {
 	if raw == nil {
 		return nil, nil
@@ -693,26 +1169,26 @@ func estimateSpansPerTrace(depth int, fanout float64) float64 {
 	return total
 }
 
-func consumeTraces(ctx context.Context, out *bufio.Writer, cfg config.Config, traceCh <-chan model.Trace, stats *emitterStats, manifest *reportManifest) error {
+// consumeTraces writes the traces from traceCh to cfg's output. A nil
+// manifest skips the report manifest. Failed sends stop it unless failures
+// is set, in which case they are counted there instead.
+func consumeTraces(ctx context.Context, out streamWriter, cfg config.Config, traceCh <-chan model.Trace, stats *emitterStats, manifest *reportManifest, failures *sinkFailures) error {
 	flushTicker := time.NewTicker(cfg.FlushInterval)
 	defer flushTicker.Stop()
 
-	var otlpHTTPClient *otlphttp.Client
-	var otlpGRPCClient *otlpgrpc.Client
-	var zipkinClient *zipkin.Client
-	if cfg.Format == "otlp-http" {
-		otlpHTTPClient = otlphttp.New(cfg.OTLPEndpoint, cfg.Headers, cfg.Compress == "gzip", cfg.SinkTimeout)
-	}
-	if cfg.Format == "otlp-grpc" {
-		otlpGRPCClient = otlpgrpc.New(cfg.OTLPEndpoint, cfg.Headers, cfg.OTLPInsecure, cfg.SinkTimeout)
-		defer otlpGRPCClient.Close()
-	}
-	if cfg.Format == "zipkin-json" {
-		zipkinClient = zipkin.New(cfg.ZipkinEndpoint, cfg.Headers, cfg.SinkTimeout)
+	clients, err := newSinkClients(ctx, cfg, stats)
+	if err != nil {
+		return err
 	}
+	defer clients.close()
+	network := cfg.Output == "otlp" || cfg.Output == "zipkin"
+	encode := newStreamEncoder(cfg.Format)
 
 	var spanBatch []model.Span
 	pendingTraceCount := 0
+	// batches hold the spans for network sinks. Each batch holds traces
+	// with one set of headers going to one tenant.
+	batches := map[string]*sinkBatch{}
 
 	networkSem := make(chan struct{}, cfg.SinkMaxInFlight)
 	var networkWG sync.WaitGroup
@@ -738,7 +1214,7 @@ func consumeTraces(ctx context.Context, out *bufio.Writer, cfg config.Config, tr
 		}
 	}
 
-	dispatchNetwork := func(send func(context.Context) error, batchTraces, batchSpans int) error {
+	dispatchNetwork := func(send func(context.Context) error, size *sink.PayloadSize, batchTraces, batchSpans int) error {
 		select {
 		case networkSem <- struct{}{}:
 		case <-ctx.Done():
@@ -751,23 +1227,28 @@ func consumeTraces(ctx context.Context, out *bufio.Writer, cfg config.Config, tr
 			debugf(cfg, "sending batch output=%s format=%s traces=%d spans=%d", cfg.Output, cfg.Format, batchTraces, batchSpans)
 			if err := sendWithRetry(ctx, cfg.SinkRetries, cfg.SinkRetryBackoff, cfg.SinkTimeout, send); err != nil {
 				debugf(cfg, "send failed output=%s format=%s traces=%d spans=%d err=%v", cfg.Output, cfg.Format, batchTraces, batchSpans, err)
+				if failures != nil {
+					failures.add(err, batchTraces, batchSpans)
+					return
+				}
 				reportNetworkErr(err)
 				return
 			}
 			stats.add(batchTraces, batchSpans)
+			stats.addPayload(*size)
 			debugf(cfg, "send complete output=%s format=%s traces=%d spans=%d", cfg.Output, cfg.Format, batchTraces, batchSpans)
 		}()
 		return nil
 	}
 
-	flushJSONL := func() error {
+	flushStream := func() error {
 		if len(spanBatch) == 0 {
 			return nil
 		}
 		batchSpans := len(spanBatch)
 		batchTraces := pendingTraceCount
 		if hasMode(cfg.Invalid, "bad-encoded-payload") {
-			if _, err := out.WriteString("{\"broken\":\n"); err != nil {
+			if err := writeBrokenBatch(out, cfg.Format); err != nil {
 				return err
 			}
 			spanBatch = spanBatch[:0]
@@ -778,7 +1259,7 @@ func consumeTraces(ctx context.Context, out *bufio.Writer, cfg config.Config, tr
 			stats.add(batchTraces, batchSpans)
 			return nil
 		}
-		if err := jsonlenc.WriteTrace(out, model.Trace{Spans: spanBatch}); err != nil {
+		if err := encode(out, spanBatch); err != nil {
 			return err
 		}
 		spanBatch = spanBatch[:0]
@@ -790,75 +1271,47 @@ func consumeTraces(ctx context.Context, out *bufio.Writer, cfg config.Config, tr
 		debugf(cfg, "wrote batch output=%s format=%s traces=%d spans=%d", cfg.Output, cfg.Format, batchTraces, batchSpans)
 		return nil
 	}
-	flushOTLP := func() error {
-		if len(spanBatch) == 0 {
-			return nil
-		}
-		batch := append([]model.Span(nil), spanBatch...)
-		batchSpans := len(batch)
-		batchTraces := pendingTraceCount
-		spanBatch = spanBatch[:0]
-		pendingTraceCount = 0
-		return dispatchNetwork(func(reqCtx context.Context) error {
-			if hasMode(cfg.Invalid, "bad-encoded-payload") {
-				return otlpHTTPClient.SendRaw(reqCtx, []byte{0x00, 0x01, 0x02, 0x03})
-			}
-			return otlpHTTPClient.SendSpans(reqCtx, batch)
-		}, batchTraces, batchSpans)
-	}
-	flushOTLPGRPC := func() error {
-		if len(spanBatch) == 0 {
+	flushBatch := func(key string) error {
+		b := batches[key]
+		if b == nil {
 			return nil
 		}
-		batch := append([]model.Span(nil), spanBatch...)
-		batchSpans := len(batch)
-		batchTraces := pendingTraceCount
-		spanBatch = spanBatch[:0]
-		pendingTraceCount = 0
+		delete(batches, key)
+		size := &sink.PayloadSize{}
 		return dispatchNetwork(func(reqCtx context.Context) error {
-			return otlpGRPCClient.SendSpans(reqCtx, batch)
-		}, batchTraces, batchSpans)
-	}
-	flushZipkin := func() error {
-		if len(spanBatch) == 0 {
-			return nil
+			reqCtx = sink.WithPayloadSize(sink.WithHeaders(reqCtx, b.headers), size)
+			return clients.send(reqCtx, b.tenant, b.spans)
+		}, size, b.traces, len(b.spans))
+	}
+	flushNetwork := func() error {
+		keys := make([]string, 0, len(batches))
+		for key := range batches {
+			keys = append(keys, key)
 		}
-		batch := append([]model.Span(nil), spanBatch...)
-		batchSpans := len(batch)
-		batchTraces := pendingTraceCount
-		spanBatch = spanBatch[:0]
-		pendingTraceCount = 0
-		return dispatchNetwork(func(reqCtx context.Context) error {
-			if hasMode(cfg.Invalid, "bad-encoded-payload") {
-				return zipkinClient.SendRaw(reqCtx, []byte("{\"broken\":"))
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := flushBatch(key); err != nil {
+				return err
 			}
-			return zipkinClient.SendSpans(reqCtx, batch)
-		}, batchTraces, batchSpans)
+		}
+		return nil
 	}
 
 	finalize := func() error {
 		if cfg.Output == "noop" {
 			return waitNetwork()
 		}
-		switch cfg.Format {
-		case "jsonl":
-			if err := flushJSONL(); err != nil {
-				return err
-			}
-		case "otlp-http":
-			if err := flushOTLP(); err != nil {
+		switch {
+		case network:
+			if err := flushNetwork(); err != nil {
 				return err
 			}
-		case "otlp-grpc":
-			if err := flushOTLPGRPC(); err != nil {
-				return err
-			}
-		case "zipkin-json":
-			if err := flushZipkin(); err != nil {
+		case cfg.Format == "pretty":
+			if err := out.Flush(); err != nil {
 				return err
 			}
 		default:
-			if err := out.Flush(); err != nil {
+			if err := flushStream(); err != nil {
 				return err
 			}
 		}
@@ -875,72 +1328,54 @@ func consumeTraces(ctx context.Context, out *bufio.Writer, cfg config.Config, tr
 			if !ok {
 				return finalize()
 			}
-			manifest.observe(trace)
+			if manifest != nil {
+				manifest.observe(trace)
+			}
 			if cfg.Output == "noop" {
-				stats.add(1, len(trace.Spans))
+				stats.add(traceCount(trace), len(trace.Spans))
 				continue
 			}
-			switch cfg.Format {
-			case "jsonl":
-				spanBatch = append(spanBatch, trace.Spans...)
-				pendingTraceCount++
-				if len(spanBatch) >= cfg.BatchSize {
-					if err := flushJSONL(); err != nil {
-						return err
-					}
-				}
-			case "otlp-http":
-				spanBatch = append(spanBatch, trace.Spans...)
-				pendingTraceCount++
-				if len(spanBatch) >= cfg.BatchSize {
-					if err := flushOTLP(); err != nil {
-						return err
-					}
+			switch {
+			case network:
+				key := sinkBatchKey(trace)
+				b := batches[key]
+				if b == nil {
+					b = &sinkBatch{headers: trace.Headers, tenant: trace.Tenant}
+					batches[key] = b
 				}
-			case "otlp-grpc":
-				spanBatch = append(spanBatch, trace.Spans...)
-				pendingTraceCount++
-				if len(spanBatch) >= cfg.BatchSize {
-					if err := flushOTLPGRPC(); err != nil {
+				b.spans = append(b.spans, trace.Spans...)
+				b.traces += traceCount(trace)
+				if len(b.spans) >= cfg.BatchSize {
+					if err := flushBatch(key); err != nil {
 						return err
 					}
 				}
-			case "zipkin-json":
-				spanBatch = append(spanBatch, trace.Spans...)
-				pendingTraceCount++
-				if len(spanBatch) >= cfg.BatchSize {
-					if err := flushZipkin(); err != nil {
-						return err
-					}
-				}
-			case "pretty":
+			case cfg.Format == "pretty":
 				if _, err := out.WriteString(prettyenc.RenderTrace(trace)); err != nil {
 					return err
 				}
 				if err := out.Flush(); err != nil {
 					return err
 				}
-				stats.add(1, len(trace.Spans))
+				stats.add(traceCount(trace), len(trace.Spans))
 				debugf(cfg, "wrote trace output=%s format=%s traces=1 spans=%d", cfg.Output, cfg.Format, len(trace.Spans))
 			default:
-				return fmt.Errorf("unsupported format %q in this stage", cfg.Format)
+				spanBatch = append(spanBatch, trace.Spans...)
+				pendingTraceCount += traceCount(trace)
+				if len(spanBatch) >= cfg.BatchSize {
+					if err := flushStream(); err != nil {
+						return err
+					}
+				}
 			}
 		case <-flushTicker.C:
-			switch cfg.Format {
-			case "jsonl":
-				if err := flushJSONL(); err != nil {
-					return err
-				}
-			case "otlp-http":
-				if err := flushOTLP(); err != nil {
-					return err
-				}
-			case "otlp-grpc":
-				if err := flushOTLPGRPC(); err != nil {
+			switch {
+			case network:
+				if err := flushNetwork(); err != nil {
 					return err
 				}
-			case "zipkin-json":
-				if err := flushZipkin(); err != nil {
+			case cfg.Format != "pretty":
+				if err := flushStream(); err != nil {
 					return err
 				}
 			}
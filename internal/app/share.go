@@ -0,0 +1,67 @@
+package app
+
+import (
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+// shareSamples is how many trace IDs the report keeps for each profile of a
+// mix or each tenant. A trace is sampled only when it has a service the
+// earlier samples lack, so validation can look for all of the services.
+const shareSamples = 10
+
+// shareReport is what one profile of a mix, or one tenant, sent.
+type shareReport struct {
+	Name           string   `json:"name"`
+	Share          float64  `json:"share"`
+	TracesSent     uint64   `json:"traces_sent"`
+	SpansSent      uint64   `json:"spans_sent"`
+	Services       []string `json:"services"`
+	SampleTraceIDs []string `json:"sample_trace_ids"`
+}
+
+// shareTally counts the traces of one profile of a mix or one tenant.
+type shareTally struct {
+	report   shareReport
+	services map[string]struct{}
+}
+
+func newShareTally(name string, share float64) *shareTally {
+	return &shareTally{
+		report:   shareReport{Name: name, Share: math.Round(share*1000) / 1000},
+		services: map[string]struct{}{},
+	}
+}
+
+func (t *shareTally) observe(trace model.Trace, traceID string) {
+	t.report.TracesSent += uint64(traceCount(trace))
+	t.report.SpansSent += uint64(len(trace.Spans))
+	sample := false
+	for _, span := range trace.Spans {
+		for _, attrs := range []model.Attrs{span.Attributes, span.Resource.Attributes} {
+			if service, ok := attrs["service.name"].(string); ok && service != "" {
+				if _, seen := t.services[service]; !seen {
+					t.services[service] = struct{}{}
+					sample = true
+				}
+			}
+		}
+	}
+	if sample && len(t.report.SampleTraceIDs) < shareSamples && !slices.Contains(t.report.SampleTraceIDs, traceID) {
+		t.report.SampleTraceIDs = append(t.report.SampleTraceIDs, traceID)
+	}
+}
+
+func (t *shareTally) snapshot() shareReport {
+	report := t.report
+	report.Services = make([]string, 0, len(t.services))
+	for service := range t.services {
+		report.Services = append(report.Services, service)
+	}
+	sort.Strings(report.Services)
+	report.SampleTraceIDs = append([]string{}, t.report.SampleTraceIDs...)
+	return report
+}
@@ -0,0 +1,198 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/auth"
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
+	"github.com/robmcelhinney/spanforge/internal/sink"
+	"github.com/robmcelhinney/spanforge/internal/sink/otlpgrpc"
+	"github.com/robmcelhinney/spanforge/internal/sink/otlphttp"
+	"github.com/robmcelhinney/spanforge/internal/sink/zipkin"
+	"github.com/robmcelhinney/spanforge/internal/tlsconfig"
+)
+
+// sinkBatch is the spans for one network request.
+type sinkBatch struct {
+	spans   []model.Span
+	traces  int
+	headers map[string]string
+	tenant  string
+}
+
+// sinkBatchKey groups traces that can share a request: those going to the
+// same tenant with the same headers.
+func sinkBatchKey(trace model.Trace) string {
+	keys := make([]string, 0, len(trace.Headers))
+	for k := range trace.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(trace.Tenant)
+	for _, k := range keys {
+		b.WriteString("\n" + k + ":" + trace.Headers[k])
+	}
+	return b.String()
+}
+
+// sinkClients holds the network sink clients for a run: a pool of
+// backends for the run's endpoints, under the empty tenant name, and one for
+// each tenant with its own endpoint. Runs writing to stdout, a file or noop
+// have none.
+type sinkClients struct {
+	cfg   config.Config
+	stats *emitterStats
+	pools map[string]*backendPool
+}
+
+func newSinkClients(ctx context.Context, cfg config.Config, stats *emitterStats) (*sinkClients, error) {
+	c := &sinkClients{cfg: cfg, stats: stats, pools: map[string]*backendPool{}}
+	if cfg.Output != "otlp" && cfg.Output != "zipkin" {
+		return c, nil
+	}
+	// The loader stays nil without TLS options, leaving each client on
+	// Go's defaults.
+	var certs *tlsconfig.Loader
+	if cfg.UsesTLS() {
+		var err error
+		if certs, err = tlsconfig.NewLoader(cfg.TLS()); err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+	}
+	// One provider serves every client, so they share a cached token.
+	provider := cfg.Auth.Provider(certs)
+	endpoints := tenantEndpoints(cfg)
+	switch cfg.Format {
+	case "otlp-http", "otlp-grpc":
+		endpoints[""] = cfg.OTLPEndpoint
+	case "zipkin-json":
+		endpoints[""] = cfg.ZipkinEndpoint
+	}
+	for tenant, raw := range endpoints {
+		pool := &backendPool{policy: cfg.LB}
+		for _, endpoint := range config.Endpoints(raw) {
+			addresses := []string{""}
+			if cfg.LBResolve {
+				resolveCtx, cancel := context.WithTimeout(ctx, cfg.SinkTimeout)
+				resolved, err := resolveEndpoint(resolveCtx, endpoint)
+				cancel()
+				if err != nil {
+					c.close()
+					return nil, err
+				}
+				addresses = resolved
+			}
+			for _, address := range addresses {
+				pool.backends = append(pool.backends, &backend{
+					endpoint: endpoint,
+					address:  address,
+					client:   newNetworkClient(cfg, endpoint, address, certs.Config(tlsconfig.Host(endpoint)), provider),
+				})
+			}
+		}
+		c.pools[tenant] = pool
+	}
+	return c, nil
+}
+
+// newNetworkClient returns a client for cfg's format sending to endpoint,
+// connecting to address when it is set.
+func newNetworkClient(cfg config.Config, endpoint, address string, tlsConfig *tls.Config, provider auth.Provider) networkClient {
+	httpOpts := sink.HTTPOptions{
+		MaxConns:        cfg.Connections,
+		MaxIdleConns:    cfg.HTTPMaxIdleConns,
+		IdleConnTimeout: cfg.HTTPIdleTimeout,
+		DialAddress:     address,
+	}
+	if cfg.HTTPVersion != sink.HTTPVersionAuto {
+		httpOpts.Version = cfg.HTTPVersion
+	}
+	switch cfg.Format {
+	case "otlp-http":
+		return otlphttp.New(endpoint, otlphttp.Options{
+			Headers:     cfg.Headers,
+			Compression: cfg.Compress,
+			Timeout:     cfg.SinkTimeout,
+			TLS:         tlsConfig,
+			Auth:        provider,
+			HTTP:        httpOpts,
+		})
+	case "otlp-grpc":
+		return otlpgrpc.New(endpoint, otlpgrpc.Options{
+			Headers:          cfg.Headers,
+			Insecure:         cfg.OTLPInsecure,
+			Compression:      cfg.Compress,
+			Timeout:          cfg.SinkTimeout,
+			TLS:              tlsConfig,
+			Auth:             provider,
+			Connections:      cfg.Connections,
+			KeepaliveTime:    cfg.GRPCKeepalive,
+			KeepaliveTimeout: cfg.GRPCKeepaliveWait,
+			MaxMessageSize:   int(cfg.GRPCMaxMessage),
+			DialAddress:      address,
+		})
+	default:
+		return zipkin.New(endpoint, zipkin.Options{
+			Headers:     cfg.Headers,
+			Compression: cfg.Compress,
+			Timeout:     cfg.SinkTimeout,
+			TLS:         tlsConfig,
+			Auth:        provider,
+			HTTP:        httpOpts,
+		})
+	}
+}
+
+// send sends spans to one of tenant's backends, or the run's when the
+// tenant has no endpoint of its own, and counts the request against it.
+func (c *sinkClients) send(ctx context.Context, tenant string, spans []model.Span) error {
+	b, done := clientFor(c.pools, tenant).acquire()
+	defer done()
+	size := &sink.PayloadSize{}
+	reqCtx := sink.WithPayloadSize(ctx, size)
+	start := time.Now()
+	var err error
+	if raw, ok := b.client.(rawSender); ok && hasMode(c.cfg.Invalid, "bad-encoded-payload") {
+		err = raw.SendRaw(reqCtx, brokenPayload(c.cfg.Format))
+	} else {
+		err = b.client.SendSpans(reqCtx, spans)
+	}
+	c.stats.addRequest(b.endpoint, b.address, len(spans), *size, time.Since(start), err)
+	if err == nil {
+		sink.RecordPayloadSize(ctx, size.Uncompressed, size.Compressed)
+	}
+	return err
+}
+
+// brokenPayload is the body bad-encoded-payload sends in place of spans.
+func brokenPayload(format string) []byte {
+	if format == "zipkin-json" {
+		return []byte("{\"broken\":")
+	}
+	return []byte{0x00, 0x01, 0x02, 0x03}
+}
+
+func (c *sinkClients) close() {
+	for _, pool := range c.pools {
+		for _, b := range pool.backends {
+			if closer, ok := b.client.(io.Closer); ok {
+				_ = closer.Close()
+			}
+		}
+	}
+}
+
+func clientFor[C any](clients map[string]C, tenant string) C {
+	if client, ok := clients[tenant]; ok {
+		return client
+	}
+	return clients[""]
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	jsonlenc "github.com/robmcelhinney/spanforge/internal/encode/jsonl"
+	otlpenc "github.com/robmcelhinney/spanforge/internal/encode/otlp"
+	zipkinenc "github.com/robmcelhinney/spanforge/internal/encode/zipkin"
+	"github.com/robmcelhinney/spanforge/internal/model"
+	"github.com/robmcelhinney/spanforge/internal/sink/file"
+)
+
+// streamWriter is a stdout or file output. Flush ends a batch.
+type streamWriter interface {
+	io.Writer
+	io.StringWriter
+	Flush() error
+}
+
+// streamFile is a file output. Close flushes and completes it.
+type streamFile interface {
+	streamWriter
+	io.Closer
+}
+
+// openStreamFile creates path for a file output. With --file-rotate-size,
+// --file-rotate-interval, --file-compress or --file-max-files it writes
+// through a rotating file writer.
+func openStreamFile(cfg config.Config, path string) (streamFile, error) {
+	if cfg.FileRotates() {
+		return file.New(path, file.Options{
+			MaxSize:  cfg.FileRotateSize,
+			MaxAge:   cfg.FileRotateEvery,
+			Compress: cfg.FileCompress,
+			MaxFiles: cfg.FileMaxFiles,
+		})
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &plainFile{Writer: bufio.NewWriter(f), file: f}, nil
+}
+
+// plainFile is a file output written in place.
+type plainFile struct {
+	*bufio.Writer
+	file   *os.File
+	closed bool
+}
+
+func (f *plainFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	if err := f.Writer.Flush(); err != nil {
+		_ = f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
+
+// streamEncoder writes one batch of spans to a stdout or file output.
+type streamEncoder func(w io.Writer, spans []model.Span) error
+
+// newStreamEncoder returns the encoder for format. OTLP formats write
+// length-delimited ExportTraceServiceRequests, otlp-json and zipkin-json
+// write one JSON document per line, and jsonl writes one span per line.
+// pretty renders whole traces, so it has no batch encoder.
+func newStreamEncoder(format string) streamEncoder {
+	switch format {
+	case "otlp-http", "otlp-grpc", "otlp-proto":
+		return func(w io.Writer, spans []model.Span) error {
+			req, err := otlpenc.EncodeSpans(spans)
+			if err != nil {
+				return err
+			}
+			return otlpenc.WriteDelimited(w, req)
+		}
+	case "otlp-json":
+		return func(w io.Writer, spans []model.Span) error {
+			req, err := otlpenc.EncodeSpans(spans)
+			if err != nil {
+				return err
+			}
+			data, err := otlpenc.MarshalJSON(req)
+			if err != nil {
+				return err
+			}
+			return writeLine(w, data)
+		}
+	case "zipkin-json":
+		return func(w io.Writer, spans []model.Span) error {
+			data, err := zipkinenc.EncodeSpans(spans)
+			if err != nil {
+				return err
+			}
+			return writeLine(w, data)
+		}
+	default:
+		return func(w io.Writer, spans []model.Span) error {
+			return jsonlenc.WriteTrace(w, model.Trace{Spans: spans})
+		}
+	}
+}
+
+// writeBrokenBatch writes a batch that does not decode, for
+// --invalid bad-encoded-payload. OTLP formats keep the length prefix so
+// readers find the bad message instead of losing the framing.
+func writeBrokenBatch(w io.Writer, format string) error {
+	switch format {
+	case "otlp-http", "otlp-grpc", "otlp-proto":
+		return otlpenc.WriteFrame(w, []byte{0x00, 0x01, 0x02, 0x03})
+	default:
+		_, err := io.WriteString(w, "{\"broken\":\n")
+		return err
+	}
+}
+
+func writeLine(w io.Writer, data []byte) error {
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte{'\n'})
+	return err
+}
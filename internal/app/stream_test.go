@@ -0,0 +1,171 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	collectortracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRunWritesEveryFormatToFile(t *testing.T) {
+	// countSpans decodes a file of each format and counts its spans.
+	countSpans := map[string]func(t *testing.T, data []byte) int{
+		"otlp-proto": func(t *testing.T, data []byte) int {
+			spans := 0
+			for len(data) > 0 {
+				size := binary.BigEndian.Uint32(data[:4])
+				var req collectortracev1.ExportTraceServiceRequest
+				if err := proto.Unmarshal(data[4:4+size], &req); err != nil {
+					t.Fatalf("decode frame: %v", err)
+				}
+				for _, rs := range req.ResourceSpans {
+					for _, ss := range rs.ScopeSpans {
+						spans += len(ss.Spans)
+					}
+				}
+				data = data[4+size:]
+			}
+			return spans
+		},
+		"otlp-json": func(t *testing.T, data []byte) int {
+			spans := 0
+			scanner := bufio.NewScanner(bytes.NewReader(data))
+			scanner.Buffer(nil, 1<<20)
+			for scanner.Scan() {
+				var req struct {
+					ResourceSpans []struct {
+						ScopeSpans []struct {
+							Spans []struct {
+								TraceID string `json:"traceId"`
+							} `json:"spans"`
+						} `json:"scopeSpans"`
+					} `json:"resourceSpans"`
+				}
+				if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+					t.Fatalf("decode line: %v", err)
+				}
+				for _, rs := range req.ResourceSpans {
+					for _, ss := range rs.ScopeSpans {
+						for _, span := range ss.Spans {
+							if len(span.TraceID) != 32 {
+								t.Fatalf("trace id %q is not hex", span.TraceID)
+							}
+							spans++
+						}
+					}
+				}
+			}
+			return spans
+		},
+		"zipkin-json": func(t *testing.T, data []byte) int {
+			spans := 0
+			scanner := bufio.NewScanner(bytes.NewReader(data))
+			scanner.Buffer(nil, 1<<20)
+			for scanner.Scan() {
+				var batch []map[string]any
+				if err := json.Unmarshal(scanner.Bytes(), &batch); err != nil {
+					t.Fatalf("decode line: %v", err)
+				}
+				spans += len(batch)
+			}
+			return spans
+		},
+	}
+	countSpans["otlp-http"] = countSpans["otlp-proto"]
+
+	for format, count := range countSpans {
+		t.Run(format, func(t *testing.T) {
+			tmp := t.TempDir()
+			reportPath := filepath.Join(tmp, "report.json")
+			cfg := reportTestConfig(reportPath)
+			cfg.Count = 10
+			cfg.BatchSize = 8
+			cfg.Format = format
+			cfg.Output = "file"
+			cfg.File = filepath.Join(tmp, "traces.out")
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			if err := Run(cfg, &bytes.Buffer{}); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			data, err := os.ReadFile(cfg.File)
+			if err != nil {
+				t.Fatalf("read output: %v", err)
+			}
+			report, err := os.ReadFile(reportPath)
+			if err != nil {
+				t.Fatalf("read report: %v", err)
+			}
+			var rep runReport
+			if err := json.Unmarshal(report, &rep); err != nil {
+				t.Fatalf("decode report: %v", err)
+			}
+			if got := count(t, data); got == 0 || uint64(got) != rep.EmittedSpans {
+				t.Fatalf("file has %d spans, report says %d", got, rep.EmittedSpans)
+			}
+		})
+	}
+}
+
+func TestRunRotatesAndCompressesFileOutput(t *testing.T) {
+	tmp := t.TempDir()
+	cfg := reportTestConfig(filepath.Join(tmp, "report.json"))
+	cfg.Count = 20
+	cfg.BatchSize = 4
+	cfg.Format = "jsonl"
+	cfg.Output = "file"
+	cfg.File = filepath.Join(tmp, "traces.jsonl")
+	cfg.FileRotateSize = 2000
+	cfg.FileCompress = "gzip"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := Run(cfg, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(tmp, "traces-*"))
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	if len(files) < 2 {
+		t.Fatalf("files=%v want several", files)
+	}
+	lines := 0
+	for _, name := range files {
+		if filepath.Ext(name) != ".gz" {
+			t.Fatalf("%s is not a completed gzip file", name)
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("gzip %s: %v", name, err)
+		}
+		scanner := bufio.NewScanner(zr)
+		for scanner.Scan() {
+			lines++
+		}
+		f.Close()
+	}
+	report, err := os.ReadFile(cfg.ReportFile)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var rep runReport
+	if err := json.Unmarshal(report, &rep); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if uint64(lines) != rep.EmittedSpans {
+		t.Fatalf("files hold %d spans, report says %d", lines, rep.EmittedSpans)
+	}
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+)
+
+// SweepResult compares the combinations of a sweep matrix, each run against
+// the same sink.
+type SweepResult struct {
+	Keys         []string           `json:"keys"`
+	Combinations []SweepCombination `json:"combinations"`
+}
+
+// SweepCombination is one combination's run. Bytes are the request bodies
+// accepted by the sink; requests and errors count every attempt, retries
+// included, and failed spans are the ones dropped once retries ran out.
+type SweepCombination struct {
+	Settings          map[string]string  `json:"settings"`
+	DurationSeconds   float64            `json:"duration_seconds"`
+	EmittedTraces     uint64             `json:"emitted_traces"`
+	EmittedSpans      uint64             `json:"emitted_spans"`
+	TracesPerSecond   float64            `json:"traces_per_second"`
+	SpansPerSecond    float64            `json:"spans_per_second"`
+	Batches           uint64             `json:"batches"`
+	UncompressedBytes uint64             `json:"uncompressed_bytes"`
+	CompressedBytes   uint64             `json:"compressed_bytes"`
+	BytesPerSecond    float64            `json:"bytes_per_second"`
+	Requests          uint64             `json:"requests"`
+	Errors            uint64             `json:"errors"`
+	ErrorRate         float64            `json:"error_rate"`
+	FailedSpans       uint64             `json:"failed_spans"`
+	Latency           latencyPercentiles `json:"latency"`
+	// Error is why the combination could not run, if it could not.
+	Error string `json:"error,omitempty"`
+
+	values []string
+}
+
+// Sweep runs each of runs in turn, pausing cooldown between them, and
+// reports every combination. A combination that fails to run is reported
+// with its error rather than ending the sweep.
+func Sweep(keys []string, runs []config.SweepRun, cooldown time.Duration, progress io.Writer) SweepResult {
+	result := SweepResult{Keys: keys}
+	for i, r := range runs {
+		if i > 0 && cooldown > 0 {
+			time.Sleep(cooldown)
+		}
+		c := sweepCombination(keys, r)
+		result.Combinations = append(result.Combinations, c)
+		if progress != nil {
+			status := fmt.Sprintf("spans/sec=%.2f error_rate=%.4f p99=%.1fms", c.SpansPerSecond, c.ErrorRate, c.Latency.P99Ms)
+			if c.Error != "" {
+				status = "error: " + c.Error
+			}
+			fmt.Fprintf(progress, "sweep %d/%d %s: %s\n", i+1, len(runs), settingsText(keys, c.values), status)
+		}
+	}
+	return result
+}
+
+func sweepCombination(keys []string, r config.SweepRun) SweepCombination {
+	c := SweepCombination{Settings: map[string]string{}, values: r.Values}
+	for i, key := range keys {
+		c.Settings[key] = r.Values[i]
+	}
+	cfg := r.Config
+	cfg.ReportFile = ""
+	failures := &sinkFailures{}
+	report, err := run(cfg, io.Discard, failures)
+	if err != nil {
+		c.Error = err.Error()
+		return c
+	}
+	c.DurationSeconds = report.DurationSeconds
+	c.EmittedTraces = report.EmittedTraces
+	c.EmittedSpans = report.EmittedSpans
+	c.TracesPerSecond = report.TracesPerSecond
+	c.SpansPerSecond = report.SpansPerSecond
+	c.FailedSpans = atomic.LoadUint64(&failures.spans)
+	if report.Compression != nil {
+		c.Batches = report.Compression.Batches
+		c.UncompressedBytes = report.Compression.UncompressedBytes
+		c.CompressedBytes = report.Compression.CompressedBytes
+		c.BytesPerSecond = float64(c.CompressedBytes) / report.DurationSeconds
+	}
+	if report.Send != nil {
+		c.Requests = report.Send.Requests
+		c.Errors = report.Send.Errors
+		c.ErrorRate = report.Send.ErrorRate
+		c.Latency = report.Send.Latency
+	}
+	return c
+}
+
+func settingsText(keys, values []string) string {
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + values[i]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// WriteSweepJSON writes result as indented JSON.
+func WriteSweepJSON(w io.Writer, result SweepResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+// WriteSweepText writes result as a table with a column per matrix key.
+func WriteSweepText(w io.Writer, result SweepResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, key := range result.Keys {
+		fmt.Fprintf(tw, "%s\t", key)
+	}
+	fmt.Fprintln(tw, "spans/sec\ttraces/sec\tsent bytes\tratio\terror rate\tp50 ms\tp95 ms\tp99 ms\tfailed spans")
+	for _, c := range result.Combinations {
+		for _, key := range result.Keys {
+			fmt.Fprintf(tw, "%s\t", c.Settings[key])
+		}
+		if c.Error != "" {
+			fmt.Fprintf(tw, "error: %s\n", c.Error)
+			continue
+		}
+		ratio := 0.0
+		if c.CompressedBytes > 0 {
+			ratio = float64(c.UncompressedBytes) / float64(c.CompressedBytes)
+		}
+		fmt.Fprintf(tw, "%.2f\t%.2f\t%d\t%.2f\t%.4f\t%.1f\t%.1f\t%.1f\t%d\n",
+			c.SpansPerSecond, c.TracesPerSecond, c.CompressedBytes, ratio, c.ErrorRate,
+			c.Latency.P50Ms, c.Latency.P95Ms, c.Latency.P99Ms, c.FailedSpans)
+	}
+	return tw.Flush()
+}
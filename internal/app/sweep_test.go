@@ -0,0 +1,86 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+)
+
+func TestSweepReportsEachCombination(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.Listener = lis
+	srv.Start()
+	defer srv.Close()
+
+	keys := []string{"batch_size", "compress"}
+	var runs []config.SweepRun
+	for _, batch := range []int{1, 8} {
+		for _, compress := range []string{"none", "gzip"} {
+			cfg := reportTestConfig("")
+			cfg.Output = "otlp"
+			cfg.OTLPEndpoint = srv.URL
+			cfg.Count = 16
+			cfg.BatchSize = batch
+			cfg.Compress = compress
+			runs = append(runs, config.SweepRun{Values: []string{strconv.Itoa(batch), compress}, Config: cfg})
+		}
+	}
+	// A combination that cannot run is reported, and the sweep goes on.
+	broken := reportTestConfig("")
+	broken.Output = "file"
+	runs = append(runs, config.SweepRun{Values: []string{"1", "none"}, Config: broken})
+
+	progress := &bytes.Buffer{}
+	result := Sweep(keys, runs, time.Millisecond, progress)
+	if len(result.Combinations) != 5 || strings.Count(progress.String(), "sweep ") != 5 {
+		t.Fatalf("combinations=%d progress=%q", len(result.Combinations), progress.String())
+	}
+	for i, c := range result.Combinations[:4] {
+		if c.Error != "" || c.EmittedSpans == 0 || c.Requests == 0 || c.Errors != 0 || c.CompressedBytes == 0 || c.Latency.P99Ms <= 0 {
+			t.Fatalf("combination %d=%+v", i, c)
+		}
+	}
+	none1, gzip1, none8 := result.Combinations[0], result.Combinations[1], result.Combinations[2]
+	if none1.Settings["batch_size"] != "1" || none1.Settings["compress"] != "none" || none1.CompressedBytes != none1.UncompressedBytes {
+		t.Fatalf("uncompressed combination=%+v", none1)
+	}
+	if gzip1.CompressedBytes >= gzip1.UncompressedBytes {
+		t.Fatalf("gzip combination sent %d of %d bytes", gzip1.CompressedBytes, gzip1.UncompressedBytes)
+	}
+	if none8.Batches >= none1.Batches {
+		t.Fatalf("batch size 8 sent %d batches, batch size 1 sent %d", none8.Batches, none1.Batches)
+	}
+	if result.Combinations[4].Error == "" {
+		t.Fatalf("broken combination=%+v", result.Combinations[4])
+	}
+
+	var text bytes.Buffer
+	if err := WriteSweepText(&text, result); err != nil {
+		t.Fatalf("text: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(text.String()), "\n"); len(lines) != 6 || !strings.HasPrefix(lines[0], "batch_size") || !strings.Contains(lines[5], "error:") {
+		t.Fatalf("text=%q", text.String())
+	}
+	var js bytes.Buffer
+	if err := WriteSweepJSON(&js, result); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	var decoded SweepResult
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil || len(decoded.Combinations) != 5 || decoded.Combinations[1].Settings["compress"] != "gzip" {
+		t.Fatalf("decode json: %v %s", err, js.String())
+	}
+}
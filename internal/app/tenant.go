@@ -0,0 +1,180 @@
+package app
+
+import (
+	"math/rand"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+// tenantSeedOffset keeps tenant picks independent of the profile picks of a
+// --profile-mix, which use the worker's seed.
+const tenantSeedOffset = 1 << 40
+
+// tenantPicker sends each trace to one of the run's tenants, picked by
+// weight. Linked consumer traces go to their producer's tenant.
+type tenantPicker struct {
+	rng        *rand.Rand
+	cumulative []float64
+	tenants    []config.Tenant
+	headers    []map[string]string
+	// producers holds the tenant of each trace with linked messages not yet
+	// consumed, while linked is set.
+	linked    bool
+	producers map[model.TraceID]*producerTenant
+}
+
+// producerTenant is a producer trace's tenant and how many of its messages
+// are still to be consumed.
+type producerTenant struct {
+	tenant  int
+	pending int
+}
+
+// newTenantPicker returns a picker for cfg's tenants, or nil when the run has
+// none.
+func newTenantPicker(cfg config.Config, seed int64) *tenantPicker {
+	if len(cfg.Tenants) == 0 {
+		return nil
+	}
+	p := &tenantPicker{
+		rng:       rand.New(rand.NewSource(seed + tenantSeedOffset)),
+		tenants:   cfg.Tenants,
+		linked:    cfg.MessagingMode == "linked",
+		producers: map[model.TraceID]*producerTenant{},
+	}
+	total := 0.0
+	for _, t := range cfg.Tenants {
+		total += t.Weight
+		p.cumulative = append(p.cumulative, total)
+		p.headers = append(p.headers, t.RequestHeaders(cfg.TenantHeader))
+	}
+	return p
+}
+
+// assign picks trace's tenant, adds the tenant's headers to the ones the
+// trace already has and labels every span's resource with the tenant. A
+// linked consumer trace takes the tenant of the producer it links to first.
+func (p *tenantPicker) assign(trace *model.Trace) {
+	if p == nil {
+		return
+	}
+	i, ok := p.producerTenant(*trace)
+	if !ok {
+		i = p.pick()
+	}
+	p.rememberProducer(*trace, i)
+	tenant := p.tenants[i]
+	headers := make(map[string]string, len(trace.Headers)+len(p.headers[i]))
+	for k, v := range trace.Headers {
+		headers[k] = v
+	}
+	for k, v := range p.headers[i] {
+		headers[k] = v
+	}
+	trace.Headers = headers
+	trace.Tenant = tenant.Name
+	for s := range trace.Spans {
+		span := &trace.Spans[s]
+		if span.Resource.Attributes == nil {
+			span.Resource.Attributes = model.Attrs{}
+		}
+		span.Resource.Attributes["spanforge.tenant"] = tenant.Name
+		for k, v := range tenant.Attributes {
+			span.Resource.Attributes[k] = v
+		}
+	}
+}
+
+func (p *tenantPicker) pick() int {
+	pick := p.rng.Float64() * p.cumulative[len(p.cumulative)-1]
+	for i, bound := range p.cumulative {
+		if pick < bound {
+			return i
+		}
+	}
+	return len(p.tenants) - 1
+}
+
+// producerTenant returns the tenant of the first producer trace is linked
+// to, and counts each linked message as consumed.
+func (p *tenantPicker) producerTenant(trace model.Trace) (int, bool) {
+	tenant, found := 0, false
+	for _, span := range trace.Spans {
+		for _, link := range span.Links {
+			producer, ok := p.producers[link.TraceID]
+			if !ok {
+				continue
+			}
+			if !found {
+				tenant, found = producer.tenant, true
+			}
+			if producer.pending--; producer.pending <= 0 {
+				delete(p.producers, link.TraceID)
+			}
+		}
+	}
+	return tenant, found
+}
+
+// rememberProducer keeps trace's tenant until each message it published
+// for a linked consumer has been consumed.
+func (p *tenantPicker) rememberProducer(trace model.Trace, tenant int) {
+	if !p.linked {
+		return
+	}
+	pending := 0
+	for _, span := range trace.Spans {
+		if span.Kind == "PRODUCER" {
+			pending++
+		}
+	}
+	if pending > 0 {
+		p.producers[trace.TraceID] = &producerTenant{tenant: tenant, pending: pending}
+	}
+}
+
+// tenantEndpoints maps each tenant with its own endpoint to that endpoint.
+func tenantEndpoints(cfg config.Config) map[string]string {
+	endpoints := map[string]string{}
+	for _, t := range cfg.Tenants {
+		if t.Endpoint != "" {
+			endpoints[t.Name] = t.Endpoint
+		}
+	}
+	return endpoints
+}
+
+// tenantReport is what one tenant was sent. Headers are the tenant's
+// request headers without credentials, for validation to query it with.
+type tenantReport struct {
+	shareReport
+	Endpoint string            `json:"endpoint,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+}
+
+// trackTenants makes the manifest count traces by tenant, in the order cfg
+// gives the tenants.
+func (m *reportManifest) trackTenants(cfg config.Config) {
+	m.tenants = map[string]*shareTally{}
+	for i, t := range cfg.Tenants {
+		m.tenants[t.Name] = newShareTally(t.Name, cfg.TenantShare(i))
+		m.tenantOrder = append(m.tenantOrder, tenantReport{
+			shareReport: shareReport{Name: t.Name},
+			Endpoint:    t.Endpoint,
+			Headers:     t.QueryHeaders(cfg.TenantHeader),
+		})
+	}
+}
+
+func (m *reportManifest) tenantReports() []tenantReport {
+	if m.tenants == nil {
+		return nil
+	}
+	out := make([]tenantReport, 0, len(m.tenantOrder))
+	for _, t := range m.tenantOrder {
+		t.shareReport = m.tenants[t.Name].snapshot()
+		out = append(out, t)
+	}
+	return out
+}
@@ -0,0 +1,155 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	collectortracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/generator"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+func TestTenantsBatchAndRouteSeparately(t *testing.T) {
+	var mu sync.Mutex
+	// requests counts requests by the server that got them, the tenant
+	// header and the tenants the spans were labelled with.
+	requests := map[string]int{}
+	server := func(name string) *httptest.Server {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Skipf("listen unavailable in this environment: %v", err)
+		}
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			var req collectortracev1.ExportTraceServiceRequest
+			if err := proto.Unmarshal(body, &req); err != nil {
+				t.Errorf("decode request: %v", err)
+			}
+			labels := map[string]bool{}
+			for _, rs := range req.ResourceSpans {
+				for _, attr := range rs.Resource.Attributes {
+					if attr.Key == "spanforge.tenant" {
+						labels[attr.Value.GetStringValue()] = true
+					}
+				}
+			}
+			var tenants []string
+			for label := range labels {
+				tenants = append(tenants, label)
+			}
+			mu.Lock()
+			requests[name+"/"+r.Header.Get("X-Scope-OrgID")+"/"+strings.Join(tenants, ",")+"/"+r.Header.Get("Authorization")]++
+			mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		}))
+		srv.Listener = lis
+		srv.Start()
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	shared, own := server("shared"), server("own")
+
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	cfg := reportTestConfig(reportPath)
+	cfg.RateValue = 1000
+	cfg.Count = 60
+	cfg.Output = "otlp"
+	cfg.OTLPEndpoint = shared.URL
+	cfg.TenantHeader = "X-Scope-OrgID"
+	for _, raw := range []string{"acme,weight=2", "globex,header=Authorization=Bearer g,endpoint=" + own.URL} {
+		tenant, err := config.ParseTenant(raw)
+		if err != nil {
+			t.Fatalf("ParseTenant: %v", err)
+		}
+		cfg.Tenants = append(cfg.Tenants, tenant)
+	}
+	if err := Run(cfg, &strings.Builder{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if requests["shared/acme/acme/"] == 0 || requests["own/globex/globex/Bearer g"] == 0 || len(requests) != 2 {
+		t.Fatalf("requests=%v want each tenant batched alone to its endpoint", requests)
+	}
+
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var report runReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if len(report.Tenants) != 2 {
+		t.Fatalf("tenants=%+v want 2", report.Tenants)
+	}
+	acme, globex := report.Tenants[0], report.Tenants[1]
+	if acme.Name != "acme" || acme.Share != 0.667 || acme.Headers["X-Scope-OrgID"] != "acme" || acme.Endpoint != "" {
+		t.Fatalf("acme=%+v", acme)
+	}
+	if globex.Endpoint != own.URL || globex.Headers["X-Scope-OrgID"] != "globex" || globex.Headers["Authorization"] != "" {
+		t.Fatalf("globex=%+v want endpoint and no credentials", globex)
+	}
+	if acme.TracesSent+globex.TracesSent != 60 || len(acme.SampleTraceIDs) == 0 || len(globex.SampleTraceIDs) == 0 {
+		t.Fatalf("acme=%+v globex=%+v want 60 traces between them", acme, globex)
+	}
+}
+
+func TestLinkedConsumersFollowTheirProducersTenant(t *testing.T) {
+	cfg := reportTestConfig("")
+	cfg.Profile = "queue"
+	cfg.MessagingMode = "linked"
+	cfg.MessagingBatch = 3
+	for _, raw := range []string{"acme", "globex"} {
+		tenant, err := config.ParseTenant(raw)
+		if err != nil {
+			t.Fatalf("ParseTenant: %v", err)
+		}
+		cfg.Tenants = append(cfg.Tenants, tenant)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	g := generator.New(cfg)
+	tenants := newTenantPicker(cfg, cfg.Seed)
+	producers := map[model.TraceID]string{}
+	consumers := 0
+	check := func(traces []model.Trace) {
+		for _, trace := range traces {
+			tenants.assign(&trace)
+			links := trace.Spans[0].Links
+			if len(links) == 0 {
+				producers[trace.TraceID] = trace.Tenant
+				continue
+			}
+			consumers++
+			if want := producers[links[0].TraceID]; trace.Tenant != want {
+				t.Fatalf("consumer tenant=%q want producer's %q", trace.Tenant, want)
+			}
+			if trace.Spans[0].Resource.Attributes["spanforge.tenant"] != trace.Tenant {
+				t.Fatalf("consumer labelled %v want %q", trace.Spans[0].Resource.Attributes["spanforge.tenant"], trace.Tenant)
+			}
+		}
+	}
+	for i := 0; i < 40; i++ {
+		check(append([]model.Trace{g.GenerateTrace(time.Now().UTC())}, g.TakeLinked()...))
+	}
+	check(g.FlushLinked())
+	if consumers == 0 {
+		t.Fatal("expected linked consumer traces")
+	}
+	if len(tenants.producers) != 0 {
+		t.Fatalf("producers=%d still remembered after every message was consumed", len(tenants.producers))
+	}
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/pem"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	collectortracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// startTLSServer starts an HTTPS server on 127.0.0.1 counting the requests
+// it gets, and writes its certificate to a CA file for the client.
+func startTLSServer(t *testing.T) (*httptest.Server, *atomic.Int64, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	var requests atomic.Int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.Listener = lis
+	// Failed handshakes are part of the test; keep them out of the log.
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatalf("write CA file: %v", err)
+	}
+	return srv, &requests, caFile
+}
+
+func TestRunSendsOverTLSWithCAFile(t *testing.T) {
+	for _, format := range []string{"otlp-http", "zipkin-json"} {
+		t.Run(format, func(t *testing.T) {
+			srv, requests, caFile := startTLSServer(t)
+			cfg := reportTestConfig("")
+			cfg.Format = format
+			cfg.Output = "otlp"
+			cfg.OTLPEndpoint = srv.URL
+			if format == "zipkin-json" {
+				cfg.Output = "zipkin"
+				cfg.ZipkinEndpoint = srv.URL
+			}
+
+			// The server's certificate is not in the system roots.
+			if err := Run(cfg, &bytes.Buffer{}); err == nil {
+				t.Fatalf("run without a CA file succeeded")
+			}
+
+			requests.Store(0)
+			cfg.TLSCAFile = caFile
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			if err := Run(cfg, &bytes.Buffer{}); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if requests.Load() == 0 {
+				t.Fatalf("server got no requests")
+			}
+		})
+	}
+}
+
+func TestRunOTLPGRPCOverTLS(t *testing.T) {
+	// Borrow the httptest server's certificate, which covers 127.0.0.1.
+	certSrv, _, caFile := startTLSServer(t)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	svc := &testTraceSvc{}
+	creds := credentials.NewTLS(&tls.Config{Certificates: certSrv.TLS.Certificates})
+	srv := grpc.NewServer(grpc.Creds(creds))
+	collectortracev1.RegisterTraceServiceServer(srv, svc)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	cfg := reportTestConfig("")
+	cfg.Format = "otlp-grpc"
+	cfg.Output = "otlp"
+	cfg.OTLPEndpoint = lis.Addr().String()
+	// --otlp-insecure stays at its default: the CA file turns TLS on.
+	cfg.OTLPInsecure = true
+	cfg.TLSCAFile = caFile
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := Run(cfg, &bytes.Buffer{}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if svc.spans == 0 {
+		t.Fatalf("server got no spans")
+	}
+}
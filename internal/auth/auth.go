@@ -0,0 +1,165 @@
+// Package auth supplies the Authorization header for sink and validate
+// requests: a bearer token from a file, basic auth, or OAuth2 client
+// credentials. Credentials are read when needed rather than once, so tokens
+// and secrets rotated during a long run are picked up.
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Provider returns the Authorization header value for a request.
+type Provider interface {
+	Authorization(ctx context.Context) (string, error)
+}
+
+// invalidator is a provider holding a credential the server can reject,
+// such as a cached OAuth2 token. Invalidate drops it so the next request
+// fetches a new one.
+type invalidator interface {
+	Invalidate()
+}
+
+// Rejected tells p that a server refused its credential.
+func Rejected(p Provider) {
+	if inv, ok := p.(invalidator); ok {
+		inv.Invalidate()
+	}
+}
+
+// Secret returns a credential, such as a token or password.
+type Secret func() (string, error)
+
+// FileSecret reads a secret from path, trimming surrounding whitespace. The
+// file is read again when its size or modification time changes; a failed
+// or empty read keeps the last good secret.
+func FileSecret(path string) Secret {
+	f := &fileSecret{path: path}
+	return f.get
+}
+
+// EnvSecret reads a secret from the environment variable name.
+func EnvSecret(name string) Secret {
+	return func() (string, error) {
+		value, ok := os.LookupEnv(name)
+		if !ok || strings.TrimSpace(value) == "" {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return strings.TrimSpace(value), nil
+	}
+}
+
+type fileSecret struct {
+	path string
+
+	mu    sync.Mutex
+	stamp string
+	value string
+}
+
+func (f *fileSecret) get() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, err := os.Stat(f.path)
+	if err == nil {
+		stamp := fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+		if stamp == f.stamp {
+			return f.value, nil
+		}
+		var data []byte
+		if data, err = os.ReadFile(f.path); err == nil {
+			if value := strings.TrimSpace(string(data)); value != "" {
+				f.stamp, f.value = stamp, value
+				return value, nil
+			}
+			err = fmt.Errorf("%s is empty", f.path)
+		}
+	}
+	if f.value != "" {
+		return f.value, nil
+	}
+	return "", err
+}
+
+// Bearer sends token as a bearer token.
+func Bearer(token Secret) Provider {
+	return bearer{token: token}
+}
+
+type bearer struct {
+	token Secret
+}
+
+func (b bearer) Authorization(context.Context) (string, error) {
+	token, err := b.token()
+	if err != nil {
+		return "", fmt.Errorf("bearer token: %w", err)
+	}
+	return "Bearer " + token, nil
+}
+
+// Basic sends username and password as HTTP basic auth.
+func Basic(username string, password Secret) Provider {
+	return basic{username: username, password: password}
+}
+
+type basic struct {
+	username string
+	password Secret
+}
+
+func (b basic) Authorization(context.Context) (string, error) {
+	password, err := b.password()
+	if err != nil {
+		return "", fmt.Errorf("basic auth password: %w", err)
+	}
+	return "Basic " + basicCredentials(b.username, password), nil
+}
+
+func basicCredentials(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+// WrapClient returns a copy of client that sets the Authorization header
+// from p on requests that do not already carry one, and invalidates p's
+// credential when a server answers 401. A nil p returns client unchanged.
+func WrapClient(client *http.Client, p Provider) *http.Client {
+	if p == nil {
+		return client
+	}
+	wrapped := *client
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wrapped.Transport = &transport{base: base, provider: p}
+	return &wrapped
+}
+
+type transport struct {
+	base     http.RoundTripper
+	provider Provider
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") == "" {
+		value, err := t.provider.Authorization(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		// RoundTrippers must not change the caller's request.
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", value)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		Rejected(t.provider)
+	}
+	return resp, err
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeSecret(t *testing.T, path, value string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(value), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+}
+
+func TestBearerReloadsTokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	start := time.Now()
+	writeSecret(t, path, "first\n", start)
+	p := Bearer(FileSecret(path))
+	for _, want := range []string{"Bearer first", "Bearer first"} {
+		if got, err := p.Authorization(context.Background()); err != nil || got != want {
+			t.Fatalf("Authorization()=%q, %v want %q", got, err, want)
+		}
+	}
+
+	writeSecret(t, path, "second", start.Add(time.Minute))
+	if got, _ := p.Authorization(context.Background()); got != "Bearer second" {
+		t.Fatalf("after rotation got %q", got)
+	}
+	// A token file caught empty mid-rotation keeps the last token.
+	writeSecret(t, path, "", start.Add(2*time.Minute))
+	if got, err := p.Authorization(context.Background()); err != nil || got != "Bearer second" {
+		t.Fatalf("after empty rewrite got %q, %v", got, err)
+	}
+
+	missing := Bearer(FileSecret(filepath.Join(t.TempDir(), "missing")))
+	if _, err := missing.Authorization(context.Background()); err == nil {
+		t.Fatalf("missing token file gave a token")
+	}
+}
+
+func TestBasicFromEnv(t *testing.T) {
+	t.Setenv("SPANFORGE_TEST_PASSWORD", "s3cret")
+	got, err := Basic("spanforge", EnvSecret("SPANFORGE_TEST_PASSWORD")).Authorization(context.Background())
+	if err != nil || got != "Basic c3BhbmZvcmdlOnMzY3JldA==" {
+		t.Fatalf("Authorization()=%q, %v", got, err)
+	}
+	if _, err := Basic("spanforge", EnvSecret("SPANFORGE_TEST_UNSET")).Authorization(context.Background()); err == nil {
+		t.Fatalf("unset password env gave credentials")
+	}
+}
+
+func TestWrapClientKeepsExplicitAuthorization(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	var got []string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"))
+	}))
+	srv.Listener = lis
+	srv.Start()
+	defer srv.Close()
+
+	client := WrapClient(&http.Client{}, Bearer(func() (string, error) { return "run", nil }))
+	for _, explicit := range []string{"", "Bearer tenant"} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		if explicit != "" {
+			req.Header.Set("Authorization", explicit)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if len(got) != 2 || got[0] != "Bearer run" || got[1] != "Bearer tenant" {
+		t.Fatalf("authorization headers=%q", got)
+	}
+	if WrapClient(http.DefaultClient, nil) != http.DefaultClient {
+		t.Fatalf("nil provider wrapped the client")
+	}
+}
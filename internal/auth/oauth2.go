@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClientCredentials configures the OAuth2 client credentials grant.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret Secret
+	Scopes       []string
+	// HTTPClient calls the token URL. Nil uses a client with a 10s timeout.
+	HTTPClient *http.Client
+}
+
+// refreshMargin is how long before expiry a cached token is replaced, so a
+// request never leaves with a token about to lapse. Short-lived tokens are
+// refreshed once half their lifetime has passed instead.
+const refreshMargin = 30 * time.Second
+
+// tokenSource fetches tokens from a token URL and caches them until they
+// are close to expiry or a server rejects them.
+type tokenSource struct {
+	cfg ClientCredentials
+	now func() time.Time
+
+	mu      sync.Mutex
+	token   string
+	refresh time.Time
+}
+
+// OAuth2 returns a provider sending bearer tokens from the client
+// credentials grant. Tokens are fetched on first use and cached; one
+// request fetches a new token while the others wait for it.
+func OAuth2(cfg ClientCredentials) Provider {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &tokenSource{cfg: cfg, now: time.Now}
+}
+
+func (s *tokenSource) Authorization(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && (s.refresh.IsZero() || s.now().Before(s.refresh)) {
+		return "Bearer " + s.token, nil
+	}
+	token, lifetime, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+	s.refresh = time.Time{}
+	if lifetime > 0 {
+		margin := refreshMargin
+		if margin > lifetime/2 {
+			margin = lifetime / 2
+		}
+		s.refresh = s.now().Add(lifetime - margin)
+	}
+	return "Bearer " + token, nil
+}
+
+func (s *tokenSource) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = ""
+}
+
+// tokenResponse is the token endpoint's answer, RFC 6749 section 5.1.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (s *tokenSource) fetch(ctx context.Context) (string, time.Duration, error) {
+	secret, err := s.cfg.ClientSecret()
+	if err != nil {
+		return "", 0, fmt.Errorf("oauth2 client secret: %w", err)
+	}
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(secret))
+	resp, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("oauth2 token request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", 0, fmt.Errorf("oauth2 token response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", 0, fmt.Errorf("oauth2 token endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var tok tokenResponse
+	if err := json.Unmarshal(data, &tok); err != nil {
+		return "", 0, fmt.Errorf("oauth2 token response: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", 0, fmt.Errorf("oauth2 token response has no access_token")
+	}
+	if tok.TokenType != "" && !strings.EqualFold(tok.TokenType, "bearer") {
+		return "", 0, fmt.Errorf("oauth2 token type %q is not bearer", tok.TokenType)
+	}
+	return tok.AccessToken, time.Duration(tok.ExpiresIn) * time.Second, nil
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startTokenServer starts a fake OAuth2 token endpoint issuing token-1,
+// token-2 and so on, each valid for expiresIn seconds.
+func startTokenServer(t *testing.T, expiresIn int) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	var issued atomic.Int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "spanforge" || secret != "s3cret" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" || r.PostForm.Get("scope") != "traces.write audit" {
+			http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+			return
+		}
+		n := issued.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": fmt.Sprintf("token-%d", n),
+			"token_type":   "Bearer",
+			"expires_in":   expiresIn,
+		})
+	}))
+	srv.Listener = lis
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv, &issued
+}
+
+func testCredentials(tokenURL string) ClientCredentials {
+	return ClientCredentials{
+		TokenURL:     tokenURL,
+		ClientID:     "spanforge",
+		ClientSecret: func() (string, error) { return "s3cret", nil },
+		Scopes:       []string{"traces.write", "audit"},
+	}
+}
+
+func TestOAuth2CachesAndRefreshesTokens(t *testing.T) {
+	srv, issued := startTokenServer(t, 300)
+	p := OAuth2(testCredentials(srv.URL)).(*tokenSource)
+	clock := time.Now()
+	p.now = func() time.Time { return clock }
+
+	for i := 0; i < 3; i++ {
+		if got, err := p.Authorization(context.Background()); err != nil || got != "Bearer token-1" {
+			t.Fatalf("Authorization()=%q, %v want cached token-1", got, err)
+		}
+	}
+	// The token is replaced shortly before it expires.
+	clock = clock.Add(280 * time.Second)
+	if got, _ := p.Authorization(context.Background()); got != "Bearer token-2" {
+		t.Fatalf("near expiry got %q want token-2", got)
+	}
+	p.Invalidate()
+	if got, _ := p.Authorization(context.Background()); got != "Bearer token-3" {
+		t.Fatalf("after invalidation got %q want token-3", got)
+	}
+	if issued.Load() != 3 {
+		t.Fatalf("token endpoint called %d times, want 3", issued.Load())
+	}
+}
+
+func TestOAuth2RefetchesAfterRejection(t *testing.T) {
+	tokens, issued := startTokenServer(t, 3600)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	// The gateway has revoked token-1.
+	gateway := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	gateway.Listener = lis
+	gateway.Start()
+	defer gateway.Close()
+
+	client := WrapClient(&http.Client{}, OAuth2(testCredentials(tokens.URL)))
+	var statuses []int
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(gateway.URL)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+	}
+	if fmt.Sprint(statuses) != "[401 200 200]" || issued.Load() != 2 {
+		t.Fatalf("statuses=%v tokens issued=%d", statuses, issued.Load())
+	}
+}
+
+func TestOAuth2TokenEndpointErrors(t *testing.T) {
+	srv, _ := startTokenServer(t, 60)
+	cfg := testCredentials(srv.URL)
+	cfg.ClientSecret = func() (string, error) { return "wrong", nil }
+	if _, err := OAuth2(cfg).Authorization(context.Background()); err == nil {
+		t.Fatalf("rejected client credentials gave a token")
+	}
+}
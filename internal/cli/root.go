@@ -3,12 +3,15 @@ package cli
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/robmcelhinney/spanforge/internal/app"
 	"github.com/robmcelhinney/spanforge/internal/config"
 	"github.com/robmcelhinney/spanforge/internal/generator"
+	"github.com/robmcelhinney/spanforge/internal/tlsconfig"
 	"github.com/robmcelhinney/spanforge/internal/validate"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -42,6 +45,8 @@ func NewRootCmd(version string) *cobra.Command {
 	config.AddFlags(cmd.Flags(), &flags)
 	cmd.AddCommand(newProfilesCmd())
 	cmd.AddCommand(newValidateCmd())
+	cmd.AddCommand(newBenchCmd())
+	cmd.AddCommand(newSweepCmd())
 
 	return cmd
 }
@@ -81,6 +86,140 @@ func newProfilesCmd() *cobra.Command {
 	return cmd
 }
 
+func newBenchCmd() *cobra.Command {
+	var flags config.FlagValues
+	var opts app.BenchOptions
+	var maxErrorRate, minThroughput, precision string
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Find the highest rate a sink sustains within error and latency SLOs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format = strings.ToLower(strings.TrimSpace(format))
+			if format != "text" && format != "json" {
+				return fmt.Errorf("bench-format must be text or json")
+			}
+			overrides := make(map[string]bool)
+			cmd.Flags().Visit(func(f *pflag.Flag) {
+				overrides[f.Name] = true
+			})
+			cfg, err := config.FromFlagsWithOverrides(flags, overrides)
+			if err != nil {
+				return err
+			}
+			opts.Search = strings.ToLower(strings.TrimSpace(opts.Search))
+			if opts.StartRate == 0 {
+				opts.StartRate = cfg.RateValue
+			}
+			if opts.MaxErrorRate, err = config.ParsePercent(maxErrorRate); err != nil {
+				return fmt.Errorf("max-error-rate: %w", err)
+			}
+			if opts.MinThroughput, err = config.ParsePercent(minThroughput); err != nil {
+				return fmt.Errorf("min-throughput: %w", err)
+			}
+			if opts.Precision, err = config.ParsePercent(precision); err != nil {
+				return fmt.Errorf("precision: %w", err)
+			}
+			result, err := app.Bench(cfg, opts, cmd.ErrOrStderr())
+			if err != nil {
+				return err
+			}
+			if cfg.ReportFile != "" {
+				if err := writeReportFile(cfg.ReportFile, func(w io.Writer) error { return app.WriteBenchJSON(w, result) }); err != nil {
+					return fmt.Errorf("write bench report: %w", err)
+				}
+			}
+			if format == "json" {
+				return app.WriteBenchJSON(cmd.OutOrStdout(), result)
+			}
+			return app.WriteBenchText(cmd.OutOrStdout(), result)
+		},
+	}
+	config.AddFlags(cmd.Flags(), &flags)
+	cmd.Flags().StringVar(&opts.Search, "search", app.BenchSearchBinary, "How to pick rates: binary doubles until a step fails then bisects, step adds --rate-step each step")
+	cmd.Flags().Float64Var(&opts.StartRate, "start-rate", 0, "First rate tried, in --rate-unit per --rate-interval (0 uses --rate)")
+	cmd.Flags().Float64Var(&opts.MaxRate, "max-rate", 0, "Highest rate tried (0 is no limit for binary search)")
+	cmd.Flags().Float64Var(&opts.StepRate, "rate-step", 0, "Rate added each step by step search (0 adds --start-rate)")
+	cmd.Flags().DurationVar(&opts.StepDuration, "step-duration", 30*time.Second, "How long each rate is held")
+	cmd.Flags().DurationVar(&opts.Cooldown, "cooldown", 0, "Pause between steps so the sink can drain")
+	cmd.Flags().StringVar(&maxErrorRate, "max-error-rate", "1%", "Highest share of failed requests, retries included, a passing step may have")
+	cmd.Flags().DurationVar(&opts.MaxP99Latency, "max-p99-latency", 0, "Highest p99 send latency a passing step may have (0 does not check)")
+	cmd.Flags().StringVar(&minThroughput, "min-throughput", "90%", "Lowest share of the rate a passing step must achieve")
+	cmd.Flags().StringVar(&precision, "precision", "5%", "Binary search stops once the failing rate is within this share of the passing rate")
+	cmd.Flags().IntVar(&opts.MaxSteps, "max-steps", 12, "Most steps to run")
+	cmd.Flags().StringVar(&format, "bench-format", "text", "Bench output format: text or json")
+	return cmd
+}
+
+func newSweepCmd() *cobra.Command {
+	var flags config.FlagValues
+	var matrixFile string
+	var vary []string
+	var cooldown time.Duration
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "sweep",
+		Short: "Run every combination of a matrix of settings and compare them",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format = strings.ToLower(strings.TrimSpace(format))
+			if format != "text" && format != "json" {
+				return fmt.Errorf("sweep-format must be text or json")
+			}
+			var matrix config.Matrix
+			if matrixFile != "" {
+				var err error
+				if matrix, err = config.LoadMatrix(matrixFile); err != nil {
+					return err
+				}
+			}
+			for _, raw := range vary {
+				if err := matrix.AddVary(raw); err != nil {
+					return err
+				}
+			}
+			overrides := make(map[string]bool)
+			cmd.Flags().Visit(func(f *pflag.Flag) {
+				overrides[f.Name] = true
+			})
+			runs, err := config.SweepRuns(flags, overrides, matrix)
+			if err != nil {
+				return err
+			}
+			result := app.Sweep(matrix.Keys, runs, cooldown, cmd.ErrOrStderr())
+			if reportFile := runs[0].Config.ReportFile; reportFile != "" {
+				if err := writeReportFile(reportFile, func(w io.Writer) error { return app.WriteSweepJSON(w, result) }); err != nil {
+					return fmt.Errorf("write sweep report: %w", err)
+				}
+			}
+			if format == "json" {
+				return app.WriteSweepJSON(cmd.OutOrStdout(), result)
+			}
+			return app.WriteSweepText(cmd.OutOrStdout(), result)
+		},
+	}
+	config.AddFlags(cmd.Flags(), &flags)
+	cmd.Flags().StringVar(&matrixFile, "matrix", "", "YAML file mapping config file settings to the values to try, e.g. batch_size: [128, 512, 2048]")
+	cmd.Flags().StringArrayVar(&vary, "vary", nil, "Setting and values to try (repeat), e.g. compress=none,gzip")
+	cmd.Flags().DurationVar(&cooldown, "cooldown", 0, "Pause between combinations so the sink can drain")
+	cmd.Flags().StringVar(&format, "sweep-format", "text", "Sweep output format: text or json")
+	return cmd
+}
+
+// writeReportFile creates path and writes a report to it with write.
+func writeReportFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func newValidateCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "validate",
@@ -97,6 +236,9 @@ func newValidateBackendCmd(backend string) *cobra.Command {
 	var wait time.Duration
 	var pollInterval time.Duration
 	var output string
+	var headers []string
+	var tlsOpts tlsconfig.Options
+	var authSpec string
 
 	cmd := &cobra.Command{
 		Use:   backend,
@@ -106,12 +248,31 @@ func newValidateBackendCmd(backend string) *cobra.Command {
 			if output != "text" && output != "json" {
 				return fmt.Errorf("output must be text or json")
 			}
+			queryHeaders, err := config.ParseHeaders(headers)
+			if err != nil {
+				return err
+			}
+			var certs *tlsconfig.Loader
+			if !tlsOpts.IsZero() {
+				if certs, err = tlsconfig.NewLoader(tlsOpts); err != nil {
+					return fmt.Errorf("tls: %w", err)
+				}
+			}
+			var authMethod *config.Auth
+			if strings.TrimSpace(authSpec) != "" {
+				if authMethod, err = config.ParseAuth(authSpec); err != nil {
+					return err
+				}
+			}
 			result, err := validate.Run(context.Background(), validate.Options{
 				Backend:      backend,
 				Endpoint:     endpoint,
 				ReportFile:   reportFile,
 				Wait:         wait,
 				PollInterval: pollInterval,
+				Headers:      queryHeaders,
+				TLS:          certs.Config(tlsconfig.Host(endpoint)),
+				Auth:         authMethod.Provider(certs),
 			})
 			if err != nil {
 				return err
@@ -136,6 +297,14 @@ func newValidateBackendCmd(backend string) *cobra.Command {
 	cmd.Flags().DurationVar(&wait, "wait", 30*time.Second, "Maximum time to wait for sampled traces")
 	cmd.Flags().DurationVar(&pollInterval, "poll-interval", 2*time.Second, "Polling interval while waiting")
 	cmd.Flags().StringVar(&output, "output", "text", "Validation output format: text or json")
+	cmd.Flags().StringArrayVar(&headers, "header", nil, "Header for every backend query (repeat k=v), e.g. Authorization=Bearer abc")
+	cmd.Flags().StringVar(&tlsOpts.CAFile, "tls-ca-file", "", "PEM CA bundle to verify the backend with instead of the system roots")
+	cmd.Flags().StringVar(&tlsOpts.CertFile, "tls-cert-file", "", "PEM client certificate for mutual TLS (with --tls-key-file)")
+	cmd.Flags().StringVar(&tlsOpts.KeyFile, "tls-key-file", "", "PEM client key for mutual TLS (with --tls-cert-file)")
+	cmd.Flags().StringVar(&tlsOpts.ServerName, "tls-server-name", "", "Server name to verify instead of the endpoint host")
+	cmd.Flags().StringVar(&tlsOpts.MinVersion, "tls-min-version", "", "Minimum TLS version: 1.2|1.3 (default 1.2)")
+	cmd.Flags().BoolVar(&tlsOpts.InsecureSkipVerify, "tls-insecure-skip-verify", false, "Accept any backend certificate (testing only)")
+	cmd.Flags().StringVar(&authSpec, "auth", "", "Authenticate backend queries, as for the run's --auth, e.g. bearer,token-file=/run/secrets/token")
 	_ = cmd.MarkFlagRequired("report-file")
 	return cmd
 }
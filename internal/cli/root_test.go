@@ -83,3 +83,81 @@ func TestValidateRejectsBadOutputFormat(t *testing.T) {
 		t.Fatalf("execute err=%v output=%s", err, buf.String())
 	}
 }
+
+func TestBenchNoop(t *testing.T) {
+	reportPath := filepath.Join(t.TempDir(), "bench.json")
+	buf := new(bytes.Buffer)
+	cmd := NewRootCmd("test")
+	cmd.SetOut(buf)
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{
+		"bench",
+		"--output", "noop",
+		"--rate", "50",
+		"--rate-unit", "traces",
+		"--max-rate", "50",
+		"--step-duration", "200ms",
+		"--min-throughput", "50%",
+		"--report-file", reportPath,
+	})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got := buf.String(); !strings.Contains(got, "max sustainable:") || !strings.Contains(got, "pass") {
+		t.Fatalf("bench output=%q", got)
+	}
+	data, err := os.ReadFile(reportPath)
+	if err != nil || !strings.Contains(string(data), `"max_sustainable_rate": 50`) {
+		t.Fatalf("bench report=%s err=%v", data, err)
+	}
+
+	cmd = NewRootCmd("test")
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"bench", "--bench-format", "xml"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "bench-format must be text or json") {
+		t.Fatalf("execute err=%v", err)
+	}
+}
+
+func TestSweepNoop(t *testing.T) {
+	dir := t.TempDir()
+	matrixPath := filepath.Join(dir, "matrix.yaml")
+	if err := os.WriteFile(matrixPath, []byte("batch_size: [16, 32]\n"), 0o644); err != nil {
+		t.Fatalf("write matrix: %v", err)
+	}
+	reportPath := filepath.Join(dir, "sweep.json")
+	buf := new(bytes.Buffer)
+	cmd := NewRootCmd("test")
+	cmd.SetOut(buf)
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{
+		"sweep",
+		"--output", "noop",
+		"--count", "5",
+		"--matrix", matrixPath,
+		"--vary", "workers=1,2",
+		"--report-file", reportPath,
+	})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "batch_size") || !strings.Contains(lines[0], "workers") {
+		t.Fatalf("sweep output=%q", buf.String())
+	}
+	data, err := os.ReadFile(reportPath)
+	if err != nil || strings.Count(string(data), `"settings"`) != 4 {
+		t.Fatalf("sweep report=%s err=%v", data, err)
+	}
+
+	cmd = NewRootCmd("test")
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"sweep", "--output", "noop"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "matrix") {
+		t.Fatalf("sweep without a matrix err=%v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rate control modes for --rate-control.
+const (
+	// RateControlFixed holds --rate, whatever the sink does.
+	RateControlFixed = "fixed"
+	// RateControlAdaptive starts at --rate and adjusts it to the sink:
+	// additive increase while the sink keeps up, multiplicative decrease
+	// when it throttles, times out or slows down.
+	RateControlAdaptive = "adaptive"
+)
+
+// Adaptive reports whether the run adjusts its rate to sink backpressure.
+func (c Config) Adaptive() bool {
+	return c.RateControl == RateControlAdaptive
+}
+
+func validateAdaptive(c Config) error {
+	switch c.RateControl {
+	case "", RateControlFixed:
+		return nil
+	case RateControlAdaptive:
+	default:
+		return fmt.Errorf("rate-control must be fixed or adaptive")
+	}
+	if strings.TrimSpace(c.PhaseFile) != "" || strings.TrimSpace(c.Load) != "" || c.Curve != nil {
+		return fmt.Errorf("rate-control=adaptive cannot be combined with phase-file, load or curve")
+	}
+	if c.Arrival != "" && c.Arrival != ArrivalUniform {
+		return fmt.Errorf("rate-control=adaptive needs arrival=uniform")
+	}
+	if c.RateValue <= 0 {
+		return fmt.Errorf("rate-control=adaptive needs rate > 0 to start from")
+	}
+	if c.AdaptiveWindow <= 0 {
+		return fmt.Errorf("adaptive-window must be > 0")
+	}
+	if c.AdaptiveStep <= 0 {
+		return fmt.Errorf("adaptive-step must be > 0")
+	}
+	if c.AdaptiveBackoff <= 0 || c.AdaptiveBackoff >= 1 {
+		return fmt.Errorf("adaptive-backoff must be in (0,1)")
+	}
+	if c.AdaptiveMinRate < 0 || c.AdaptiveMaxRate < 0 {
+		return fmt.Errorf("adaptive-min-rate and adaptive-max-rate must be >= 0")
+	}
+	if c.AdaptiveMaxRate > 0 && c.AdaptiveMaxRate < c.RateValue {
+		return fmt.Errorf("adaptive-max-rate must be >= rate")
+	}
+	if c.AdaptiveMinRate > c.RateValue {
+		return fmt.Errorf("adaptive-min-rate must be <= rate")
+	}
+	if c.AdaptiveMaxLatency < 0 {
+		return fmt.Errorf("adaptive-max-latency must be >= 0")
+	}
+	return nil
+}
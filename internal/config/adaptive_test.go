@@ -0,0 +1,73 @@
+package config
+
+import "testing"
+
+func TestFromFlagsAdaptive(t *testing.T) {
+	v := FlagValues{
+		Rate:             200,
+		RateUnit:         "traces",
+		RateInterval:     1,
+		Duration:         1,
+		Workers:          1,
+		Profile:          "web",
+		Routes:           8,
+		Services:         5,
+		Depth:            4,
+		Fanout:           2,
+		ServicePrefix:    "svc-",
+		P50:              1,
+		P95:              2,
+		P99:              3,
+		Errors:           "0.5%",
+		Retries:          "1%",
+		DBHeavy:          "20%",
+		CacheHitRate:     "85%",
+		Variety:          "medium",
+		Format:           "otlp-http",
+		Output:           "noop",
+		BatchSize:        512,
+		FlushInterval:    1,
+		SinkRetryBackoff: 1,
+		SinkTimeout:      1,
+		SinkMaxInFlight:  2,
+		Arrival:          "uniform",
+		RateControl:      "Adaptive",
+		AdaptiveWindow:   1,
+		AdaptiveStep:     0.1,
+		AdaptiveBackoff:  0.5,
+		AdaptiveMaxRate:  400,
+	}
+	cfg, err := FromFlags(v)
+	if err != nil {
+		t.Fatalf("FromFlags: %v", err)
+	}
+	if !cfg.Adaptive() || cfg.AdaptiveMaxRate != 400 {
+		t.Fatalf("rate-control=%q max-rate=%v", cfg.RateControl, cfg.AdaptiveMaxRate)
+	}
+
+	for name, mutate := range map[string]func(*FlagValues){
+		"mode":     func(v *FlagValues) { v.RateControl = "pid" },
+		"load":     func(v *FlagValues) { v.Load = "spike" },
+		"curve":    func(v *FlagValues) { v.Curve = "sine" },
+		"arrival":  func(v *FlagValues) { v.Arrival = "poisson" },
+		"rate":     func(v *FlagValues) { v.Rate = 0 },
+		"window":   func(v *FlagValues) { v.AdaptiveWindow = 0 },
+		"backoff":  func(v *FlagValues) { v.AdaptiveBackoff = 1 },
+		"step":     func(v *FlagValues) { v.AdaptiveStep = 0 },
+		"max rate": func(v *FlagValues) { v.AdaptiveMaxRate = 100 },
+		"min rate": func(v *FlagValues) { v.AdaptiveMinRate = 300 },
+	} {
+		bad := v
+		mutate(&bad)
+		if _, err := FromFlags(bad); err == nil {
+			t.Fatalf("%s: bad value validated", name)
+		}
+	}
+	// A fixed rate ignores the adaptive settings.
+	fixed := v
+	fixed.RateControl = "fixed"
+	fixed.AdaptiveBackoff = 0
+	if _, err := FromFlags(fixed); err != nil {
+		t.Fatalf("fixed rate: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/auth"
+	"github.com/robmcelhinney/spanforge/internal/tlsconfig"
+)
+
+// Authentication methods for --auth.
+const (
+	AuthBearer = "bearer"
+	AuthBasic  = "basic"
+	AuthOAuth2 = "oauth2"
+)
+
+// Auth is how network sinks authenticate. Secrets are named by file or
+// environment variable rather than given inline, so they stay out of shell
+// history, and files are read again when they change.
+type Auth struct {
+	Method string
+	// TokenFile holds the bearer token.
+	TokenFile string
+	// Username and a password from PasswordFile or PasswordEnv are for
+	// basic auth.
+	Username     string
+	PasswordFile string
+	PasswordEnv  string
+	// TokenURL, ClientID and a secret from ClientSecretFile or
+	// ClientSecretEnv are for the OAuth2 client credentials grant.
+	TokenURL         string
+	ClientID         string
+	ClientSecretFile string
+	ClientSecretEnv  string
+	Scopes           []string
+}
+
+// ParseAuth parses a CLI auth method such as "bearer,token-file=/run/secrets/token",
+// "basic,username=spanforge,password-env=GATEWAY_PASSWORD" or
+// "oauth2,token-url=https://idp/token,client-id=spanforge,client-secret-file=/run/secrets/secret,scope=traces.write".
+// The first item names the method. scope can repeat.
+func ParseAuth(raw string) (*Auth, error) {
+	parts := strings.Split(raw, ",")
+	a := &Auth{Method: strings.ToLower(strings.TrimSpace(parts[0]))}
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid auth %q: expected key=value, got %q", raw, part)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "token-file":
+			a.TokenFile = value
+		case "username":
+			a.Username = value
+		case "password-file":
+			a.PasswordFile = value
+		case "password-env":
+			a.PasswordEnv = value
+		case "token-url":
+			a.TokenURL = value
+		case "client-id":
+			a.ClientID = value
+		case "client-secret-file":
+			a.ClientSecretFile = value
+		case "client-secret-env":
+			a.ClientSecretEnv = value
+		case "scope":
+			a.Scopes = append(a.Scopes, value)
+		default:
+			return nil, fmt.Errorf("invalid auth %q: unknown key %q", raw, key)
+		}
+	}
+	if err := a.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid auth %q: %w", raw, err)
+	}
+	return a, nil
+}
+
+func (a Auth) Validate() error {
+	switch a.Method {
+	case AuthBearer:
+		if a.TokenFile == "" {
+			return fmt.Errorf("bearer needs token-file")
+		}
+	case AuthBasic:
+		if a.Username == "" {
+			return fmt.Errorf("basic needs username")
+		}
+		if (a.PasswordFile == "") == (a.PasswordEnv == "") {
+			return fmt.Errorf("basic needs one of password-file or password-env")
+		}
+	case AuthOAuth2:
+		if a.TokenURL == "" || a.ClientID == "" {
+			return fmt.Errorf("oauth2 needs token-url and client-id")
+		}
+		if (a.ClientSecretFile == "") == (a.ClientSecretEnv == "") {
+			return fmt.Errorf("oauth2 needs one of client-secret-file or client-secret-env")
+		}
+	default:
+		return fmt.Errorf("method must be bearer, basic or oauth2")
+	}
+	return nil
+}
+
+// Provider returns the provider that sets each request's Authorization
+// header. A nil Auth returns nil. OAuth2 token requests use certs for TLS,
+// the same as the sinks; a nil certs leaves them on Go's defaults.
+func (a *Auth) Provider(certs *tlsconfig.Loader) auth.Provider {
+	if a == nil {
+		return nil
+	}
+	secret := func(file, env string) auth.Secret {
+		if file != "" {
+			return auth.FileSecret(file)
+		}
+		return auth.EnvSecret(env)
+	}
+	switch a.Method {
+	case AuthBearer:
+		return auth.Bearer(auth.FileSecret(a.TokenFile))
+	case AuthBasic:
+		return auth.Basic(a.Username, secret(a.PasswordFile, a.PasswordEnv))
+	case AuthOAuth2:
+		return auth.OAuth2(auth.ClientCredentials{
+			TokenURL:     a.TokenURL,
+			ClientID:     a.ClientID,
+			ClientSecret: secret(a.ClientSecretFile, a.ClientSecretEnv),
+			Scopes:       a.Scopes,
+			HTTPClient:   tlsconfig.HTTPClient(10*time.Second, certs.Config(tlsconfig.Host(a.TokenURL))),
+		})
+	}
+	return nil
+}
+
+// validateAuth rejects --auth alongside an Authorization header in
+// --headers, since only one of them could be sent.
+func validateAuth(c Config) error {
+	if c.Auth == nil {
+		return nil
+	}
+	if err := c.Auth.Validate(); err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	for k := range c.Headers {
+		if http.CanonicalHeaderKey(k) == "Authorization" {
+			return fmt.Errorf("auth and an Authorization header in headers cannot both be set")
+		}
+	}
+	return nil
+}
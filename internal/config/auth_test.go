@@ -0,0 +1,89 @@
+package config
+
+import (
+	"context"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robmcelhinney/spanforge/internal/tlsconfig"
+)
+
+func TestParseAuth(t *testing.T) {
+	a, err := ParseAuth("oauth2,token-url=https://idp/token,client-id=spanforge,client-secret-env=IDP_SECRET,scope=traces.write,scope=audit")
+	if err != nil {
+		t.Fatalf("ParseAuth: %v", err)
+	}
+	if a.Method != AuthOAuth2 || a.TokenURL != "https://idp/token" || a.ClientSecretEnv != "IDP_SECRET" || strings.Join(a.Scopes, " ") != "traces.write audit" {
+		t.Fatalf("auth=%+v", a)
+	}
+	for raw, want := range map[string]string{
+		"bearer":           "bearer needs token-file",
+		"basic,username=u": "one of password-file or password-env",
+		"basic,username=u,password-file=p,password-env=P": "one of password-file or password-env",
+		"oauth2,client-id=x,client-secret-env=S":          "needs token-url and client-id",
+		"digest,token-file=t":                             "method must be",
+		"bearer,token=abc":                                "unknown key",
+	} {
+		if _, err := ParseAuth(raw); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("ParseAuth(%q) err=%v want %q", raw, err, want)
+		}
+	}
+}
+
+func TestValidateAuthRejectsAuthorizationHeader(t *testing.T) {
+	a, err := ParseAuth("bearer,token-file=/run/secrets/token")
+	if err != nil {
+		t.Fatalf("ParseAuth: %v", err)
+	}
+	cfg := Config{Auth: a, Headers: map[string]string{"authorization": "Bearer abc"}}
+	if err := validateAuth(cfg); err == nil {
+		t.Fatalf("auth with an Authorization header validated")
+	}
+	cfg.Headers = map[string]string{"X-Scope-OrgID": "acme"}
+	if err := validateAuth(cfg); err != nil {
+		t.Fatalf("validateAuth: %v", err)
+	}
+}
+
+func TestOAuth2TokenRequestsUseSinkTLS(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"abc","token_type":"Bearer","expires_in":300}`))
+	}))
+	srv.Listener = lis
+	srv.StartTLS()
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("IDP_SECRET", "s3cret")
+	a, err := ParseAuth("oauth2,token-url=" + srv.URL + ",client-id=spanforge,client-secret-env=IDP_SECRET")
+	if err != nil {
+		t.Fatalf("ParseAuth: %v", err)
+	}
+
+	if _, err := a.Provider(nil).Authorization(context.Background()); err == nil {
+		t.Fatal("token request trusted the test server without its CA")
+	}
+	certs, err := tlsconfig.NewLoader(tlsconfig.Options{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+	header, err := a.Provider(certs).Authorization(context.Background())
+	if err != nil || header != "Bearer abc" {
+		t.Fatalf("Authorization=%q err=%v", header, err)
+	}
+}
@@ -14,6 +14,14 @@ const (
 	RateUnitTraces RateUnit = "traces"
 )
 
+// Trace arrival processes for --arrival.
+const (
+	ArrivalUniform = "uniform"
+	ArrivalPoisson = "poisson"
+	ArrivalBursty  = "bursty"
+	ArrivalOnOff   = "on-off"
+)
+
 type Config struct {
 	RateValue        float64
 	RateUnit         RateUnit
@@ -27,6 +35,7 @@ type Config struct {
 	Phase            string
 	Workers          int
 	Profile          string
+	ProfileMix       ProfileMix
 	Routes           int
 	Services         int
 	Depth            int
@@ -60,6 +69,68 @@ type Config struct {
 	ReportFile       string
 	HTTPListen       string
 	Debug            bool
+
+	DeliverySplit      float64
+	DeliveryShuffle    float64
+	DeliveryDelay      float64
+	DeliveryDelayDist  Distribution
+	WorkflowLifetime   time.Duration
+	MessagingMode      string
+	MessagingLag       Distribution
+	MessagingBatch     int
+	Users              int
+	ThinkTime          Distribution
+	Rollout            *Rollout
+	ServiceVersions    map[string]string
+	Faults             []Fault
+	ErrorPropagation   float64
+	ErrorShortCircuit  float64
+	RetryMaxAttempts   int
+	RetryBackoff       time.Duration
+	RetryJitter        float64
+	RetryPolicies      []RetryPolicy
+	RetryStorm         bool
+	Deadline           time.Duration
+	DeadlinePolicies   []DeadlinePolicy
+	DeadlineCancel     float64
+	LatencyModels      []LatencyModel
+	ProfileLatency     bool
+	Arrival            string
+	BurstSize          int
+	BurstInterval      time.Duration
+	Curve              *Curve
+	CurvePosition      string
+	CurveLoad          float64
+	Tenants            []Tenant
+	TenantHeader       string
+	Sinks              []Sink
+	FileRotateSize     int64
+	FileRotateEvery    time.Duration
+	FileCompress       string
+	FileMaxFiles       int
+	TLSCAFile          string
+	TLSCertFile        string
+	TLSKeyFile         string
+	TLSServerName      string
+	TLSMinVersion      string
+	TLSSkipVerify      bool
+	Auth               *Auth
+	LB                 string
+	LBResolve          bool
+	Connections        int
+	HTTPVersion        string
+	HTTPMaxIdleConns   int
+	HTTPIdleTimeout    time.Duration
+	GRPCKeepalive      time.Duration
+	GRPCKeepaliveWait  time.Duration
+	GRPCMaxMessage     int64
+	RateControl        string
+	AdaptiveWindow     time.Duration
+	AdaptiveStep       float64
+	AdaptiveBackoff    float64
+	AdaptiveMinRate    float64
+	AdaptiveMaxRate    float64
+	AdaptiveMaxLatency time.Duration
 }
 
 func ParseRateUnit(raw string) (RateUnit, error) {
@@ -88,6 +159,14 @@ func ParsePercent(raw string) (float64, error) {
 	return v / 100.0, nil
 }
 
+// parseOptionalPercent treats an empty value as 0% for options that are off by default.
+func parseOptionalPercent(raw string) (float64, error) {
+	if strings.TrimSpace(raw) == "" {
+		return 0, nil
+	}
+	return ParsePercent(raw)
+}
+
 func ParseHeaders(items []string) (map[string]string, error) {
 	out := make(map[string]string, len(items))
 	for _, item := range items {
@@ -158,6 +237,17 @@ func (c Config) Validate() error {
 	if strings.TrimSpace(c.PhaseFile) != "" && strings.TrimSpace(c.Load) != "" {
 		return fmt.Errorf("phase-file and load cannot both be set")
 	}
+	if c.Curve != nil {
+		if strings.TrimSpace(c.PhaseFile) != "" || strings.TrimSpace(c.Load) != "" {
+			return fmt.Errorf("curve cannot be combined with phase-file or load")
+		}
+		if c.Count > 0 {
+			return fmt.Errorf("curve runs for duration and cannot be combined with count")
+		}
+		if err := c.Curve.Validate(); err != nil {
+			return err
+		}
+	}
 	if c.Workers <= 0 {
 		return fmt.Errorf("workers must be > 0")
 	}
@@ -183,10 +273,84 @@ func (c Config) Validate() error {
 		return fmt.Errorf("errors/retries/db-heavy/cache-hit-rate must be in [0,1]")
 	}
 
-	switch c.Profile {
-	case "web", "grpc", "queue", "batch", "payment-system", "api-gateway":
+	if err := validateProfile(c.Profile); err != nil {
+		return err
+	}
+	if c.ProfileMix != nil {
+		if err := c.ProfileMix.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := validateTenants(c.Tenants); err != nil {
+		return err
+	}
+	if c.WorkflowLifetime < 0 {
+		return fmt.Errorf("workflow-lifetime must be >= 0")
+	}
+	switch c.MessagingMode {
+	case "", "same-trace", "linked":
 	default:
-		return fmt.Errorf("profile must be one of web, grpc, queue, batch, payment-system, api-gateway")
+		return fmt.Errorf("messaging-mode must be one of same-trace, linked")
+	}
+	if c.MessagingBatch < 0 {
+		return fmt.Errorf("messaging-batch must be >= 0")
+	}
+	if c.ErrorPropagation < 0 || c.ErrorPropagation > 1 || c.ErrorShortCircuit < 0 || c.ErrorShortCircuit > 1 {
+		return fmt.Errorf("error-propagation/error-short-circuit must be in [0,1]")
+	}
+	for _, f := range c.Faults {
+		if err := f.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := c.DefaultRetryPolicy().Validate(); err != nil {
+		return err
+	}
+	for _, p := range c.RetryPolicies {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Deadline < 0 {
+		return fmt.Errorf("deadline must be >= 0")
+	}
+	if c.DeadlineCancel < 0 || c.DeadlineCancel > 1 {
+		return fmt.Errorf("deadline-cancel must be in [0,1]")
+	}
+	for _, p := range c.DeadlinePolicies {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	switch c.Arrival {
+	case "", ArrivalUniform, ArrivalPoisson, ArrivalBursty, ArrivalOnOff:
+	default:
+		return fmt.Errorf("arrival must be one of uniform, poisson, bursty, on-off")
+	}
+	if c.BurstSize < 0 || c.BurstInterval < 0 {
+		return fmt.Errorf("burst-size and burst-interval must be >= 0")
+	}
+	for _, m := range c.LatencyModels {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Rollout != nil {
+		if err := c.Rollout.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Users < 0 {
+		return fmt.Errorf("users must be >= 0")
+	}
+	if c.Users > 0 {
+		for _, profile := range c.Profiles() {
+			switch profile {
+			case "web", "grpc", "payment-system", "api-gateway":
+			default:
+				return fmt.Errorf("users requires profile web, grpc, payment-system, or api-gateway")
+			}
+		}
 	}
 
 	switch strings.ToLower(strings.TrimSpace(c.Variety)) {
@@ -204,76 +368,58 @@ func (c Config) Validate() error {
 	if err := validateModes("invalid", c.Invalid, []string{"duplicate-span-id", "negative-duration", "bad-encoded-payload", "empty-required-fields"}); err != nil {
 		return err
 	}
-	if len(c.Invalid) > 0 && c.Output == "noop" {
+	outputs := c.Outputs()
+	if len(c.Invalid) > 0 && allNoop(outputs) {
 		return fmt.Errorf("invalid telemetry modes require a real output sink")
 	}
-	if containsMode(c.Invalid, "bad-encoded-payload") {
-		switch c.Format {
-		case "jsonl", "otlp-http", "zipkin-json":
-		default:
-			return fmt.Errorf("bad-encoded-payload only supports jsonl, otlp-http, and zipkin-json formats")
+	for _, s := range outputs {
+		if containsMode(c.Invalid, "bad-encoded-payload") {
+			switch s.Format {
+			case "jsonl", "otlp-http", "otlp-proto", "otlp-json", "zipkin-json":
+			case "otlp-grpc":
+				if s.Output == "otlp" {
+					return fmt.Errorf("bad-encoded-payload does not support otlp-grpc sent over gRPC")
+				}
+			default:
+				return fmt.Errorf("bad-encoded-payload only supports jsonl, otlp-http, otlp-proto, otlp-json, and zipkin-json formats")
+			}
+		}
+		if err := s.Validate(); err != nil {
+			if len(c.Sinks) > 0 {
+				return fmt.Errorf("sink %s: %w", s.Name, err)
+			}
+			return err
 		}
 	}
-
-	needsOTLPEndpoint := c.Output == "otlp" || ((c.Format == "otlp-http" || c.Format == "otlp-grpc") && c.Output != "noop")
-	if needsOTLPEndpoint && strings.TrimSpace(c.OTLPEndpoint) == "" {
-		return fmt.Errorf("otlp endpoint required for output=%q format=%q", c.Output, c.Format)
-	}
-	needsZipkinEndpoint := c.Output == "zipkin" || (c.Format == "zipkin-json" && c.Output != "noop")
-	if needsZipkinEndpoint && strings.TrimSpace(c.ZipkinEndpoint) == "" {
-		return fmt.Errorf("zipkin endpoint required for output=%q format=%q", c.Output, c.Format)
-	}
-
-	if c.BatchSize <= 0 {
-		return fmt.Errorf("batch-size must be > 0")
-	}
-	if c.FlushInterval <= 0 {
-		return fmt.Errorf("flush-interval must be > 0")
+	if err := validateSinks(c); err != nil {
+		return err
 	}
-	if c.SinkRetries < 0 {
-		return fmt.Errorf("sink-retries must be >= 0")
+	if err := validateFileOutput(c); err != nil {
+		return err
 	}
-	if c.SinkRetryBackoff <= 0 {
-		return fmt.Errorf("sink-retry-backoff must be > 0")
+	if err := c.TLS().Validate(); err != nil {
+		return err
 	}
-	if c.SinkTimeout <= 0 {
-		return fmt.Errorf("sink-timeout must be > 0")
+	if err := validateAuth(c); err != nil {
+		return err
 	}
-	if c.SinkMaxInFlight <= 0 {
-		return fmt.Errorf("sink-max-in-flight must be > 0")
+	if err := validateTransport(c); err != nil {
+		return err
 	}
-	switch c.Format {
-	case "jsonl":
-		if c.Output != "stdout" && c.Output != "file" && c.Output != "noop" {
-			return fmt.Errorf("jsonl format requires output stdout, file, or noop")
-		}
-	case "pretty":
-		if c.Output != "stdout" && c.Output != "noop" {
-			return fmt.Errorf("pretty format requires output stdout or noop")
-		}
-	case "otlp-http":
-		if c.Output != "otlp" && c.Output != "noop" {
-			return fmt.Errorf("otlp-http format requires output otlp or noop")
-		}
-	case "otlp-grpc":
-		if c.Output != "otlp" && c.Output != "noop" {
-			return fmt.Errorf("otlp-grpc format requires output otlp or noop")
-		}
-	case "zipkin-json":
-		if c.Output != "zipkin" && c.Output != "noop" {
-			return fmt.Errorf("zipkin-json format requires output zipkin or noop")
-		}
-	default:
-		return fmt.Errorf("unsupported format %q", c.Format)
+	if err := validateAdaptive(c); err != nil {
+		return err
 	}
-	switch c.Output {
-	case "stdout", "file", "otlp", "zipkin", "noop":
-	default:
-		return fmt.Errorf("unsupported output %q", c.Output)
+	if c.DeliverySplit < 0 || c.DeliverySplit > 1 || c.DeliveryShuffle < 0 || c.DeliveryShuffle > 1 || c.DeliveryDelay < 0 || c.DeliveryDelay > 1 {
+		return fmt.Errorf("delivery-split/delivery-shuffle/delivery-delay must be in [0,1]")
 	}
-	if c.Output == "file" && strings.TrimSpace(c.File) == "" {
-		return fmt.Errorf("file output requires --file")
+	if c.DeliveryDelay > 0 && c.DeliveryDelayDist.IsZero() {
+		return fmt.Errorf("delivery-delay requires delivery-delay-dist")
 	}
 
 	return nil
 }
+
+// DefaultRetryPolicy is the policy for calls no --retry-policy matches.
+func (c Config) DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: c.RetryMaxAttempts, Backoff: c.RetryBackoff, Jitter: c.RetryJitter}
+}
@@ -84,7 +84,8 @@ func TestValidateFormatOutputPairing(t *testing.T) {
 		Retries:          0,
 		CacheHitRate:     1,
 		Format:           "pretty",
-		Output:           "file",
+		Output:           "otlp",
+		OTLPEndpoint:     "http://collector:4318",
 		BatchSize:        1,
 		FlushInterval:    1,
 		SinkRetries:      0,
@@ -93,7 +94,24 @@ func TestValidateFormatOutputPairing(t *testing.T) {
 		SinkMaxInFlight:  1,
 	}
 	if err := cfg.Validate(); err == nil {
-		t.Fatal("expected validation error for pretty+file")
+		t.Fatal("expected validation error for pretty+otlp")
+	}
+
+	// Every format can be written to a file.
+	cfg.Output = "file"
+	cfg.File = "out"
+	for _, format := range []string{"jsonl", "pretty", "otlp-http", "otlp-grpc", "otlp-proto", "otlp-json", "zipkin-json"} {
+		cfg.Format = format
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("%s+file: %v", format, err)
+		}
+	}
+	for _, format := range []string{"otlp-proto", "otlp-json"} {
+		cfg.Format = format
+		cfg.Output = "otlp"
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("expected validation error for %s+otlp", format)
+		}
 	}
 }
 
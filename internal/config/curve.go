@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	// Embed the timezone database so curve timezones resolve in minimal images.
+	_ "time/tzdata"
+)
+
+// Load curve shapes for --curve.
+const (
+	CurveDiurnal = "diurnal"
+	CurveWeekly  = "weekly"
+	CurveCSV     = "csv"
+)
+
+// Curve varies load continuously over a run. Diurnal curves follow a sine
+// over the day from Trough (a share of the peak) up to the full rate at Peak,
+// a time of day in Location. Weekly curves also scale Saturdays and Sundays by
+// Weekend. CSV curves read a rate series from Path. The simulated clock moves
+// Speed times faster than the wall clock and, when Start is set, begins at
+// that time of day rather than now. Follow is the share of the error rate and
+// latency that falls with the load off-peak.
+type Curve struct {
+	Kind     string
+	Path     string
+	Peak     time.Duration
+	Trough   float64
+	Location *time.Location
+	Weekend  float64
+	Speed    float64
+	Follow   float64
+	Start    *time.Duration
+}
+
+// ParseCurve parses a CLI curve such as
+// "diurnal,peak=14:00,trough=20%,timezone=Europe/Dublin,speed=24" or
+// "csv:rates.csv,speed=60". The first item names the shape.
+func ParseCurve(raw string) (*Curve, error) {
+	parts := strings.Split(raw, ",")
+	kind := strings.TrimSpace(parts[0])
+	c := &Curve{
+		Peak:     14 * time.Hour,
+		Trough:   0.25,
+		Location: time.UTC,
+		Weekend:  0.5,
+		Speed:    1,
+	}
+	switch lower := strings.ToLower(kind); {
+	case lower == CurveDiurnal, lower == CurveWeekly:
+		c.Kind = lower
+	case strings.HasPrefix(lower, CurveCSV+":"):
+		c.Kind = CurveCSV
+		c.Path = strings.TrimSpace(kind[len(CurveCSV)+1:])
+	default:
+		return nil, fmt.Errorf("invalid curve %q: shape must be diurnal, weekly or csv:<path>", raw)
+	}
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid curve %q: expected key=value, got %q", raw, part)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		var err error
+		switch key {
+		case "peak":
+			c.Peak, err = parseTimeOfDay(value)
+		case "trough":
+			c.Trough, err = ParsePercent(value)
+		case "timezone", "tz":
+			c.Location, err = time.LoadLocation(value)
+		case "weekend":
+			c.Weekend, err = ParsePercent(value)
+		case "speed":
+			c.Speed, err = strconv.ParseFloat(value, 64)
+		case "follow":
+			c.Follow, err = ParsePercent(value)
+		case "start":
+			var start time.Duration
+			start, err = parseTimeOfDay(value)
+			c.Start = &start
+		default:
+			return nil, fmt.Errorf("invalid curve %q: unknown key %q (must be peak, trough, timezone, weekend, speed, follow, or start)", raw, key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid curve %q: bad %s %q", raw, key, value)
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid curve %q: %w", raw, err)
+	}
+	return c, nil
+}
+
+// parseTimeOfDay parses "HH:MM" into a duration since midnight.
+func parseTimeOfDay(raw string) (time.Duration, error) {
+	t, err := time.Parse("15:04", raw)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (c Curve) Validate() error {
+	switch c.Kind {
+	case CurveDiurnal, CurveWeekly:
+	case CurveCSV:
+		if c.Path == "" {
+			return fmt.Errorf("csv curve needs a path")
+		}
+	default:
+		return fmt.Errorf("curve shape must be diurnal, weekly or csv")
+	}
+	if c.Trough < 0 || c.Trough > 1 || c.Weekend < 0 || c.Weekend > 1 || c.Follow < 0 || c.Follow > 1 {
+		return fmt.Errorf("curve trough, weekend and follow must be in [0,1]")
+	}
+	if c.Speed <= 0 {
+		return fmt.Errorf("curve speed must be > 0")
+	}
+	if c.Peak < 0 || c.Peak >= 24*time.Hour {
+		return fmt.Errorf("curve peak must be a time of day")
+	}
+	return nil
+}
+
+// Load returns the diurnal or weekly load at simulated time at, as a share of
+// the peak rate.
+func (c Curve) Load(at time.Time) float64 {
+	loc := c.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	at = at.In(loc)
+	midnight := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, loc)
+	sincePeak := at.Sub(midnight) - c.Peak
+	load := c.Trough + (1-c.Trough)*(1+math.Cos(2*math.Pi*sincePeak.Hours()/24))/2
+	if c.Kind == CurveWeekly && (at.Weekday() == time.Saturday || at.Weekday() == time.Sunday) {
+		load *= c.Weekend
+	}
+	return load
+}
+
+// Scale returns the factor applied to the error rate and latency at load.
+func (c Curve) Scale(load float64) float64 {
+	return 1 - c.Follow + c.Follow*load
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseCurve(t *testing.T) {
+	got, err := ParseCurve("diurnal, peak=09:30, trough=10%, timezone=Europe/Dublin, speed=24, follow=50%, start=03:00")
+	if err != nil {
+		t.Fatalf("ParseCurve: %v", err)
+	}
+	if got.Kind != CurveDiurnal || got.Peak != 9*time.Hour+30*time.Minute || got.Trough != 0.1 || got.Speed != 24 || got.Follow != 0.5 {
+		t.Fatalf("curve=%+v", got)
+	}
+	if got.Location.String() != "Europe/Dublin" || got.Start == nil || *got.Start != 3*time.Hour {
+		t.Fatalf("location=%s start=%v", got.Location, got.Start)
+	}
+	csv, err := ParseCurve("csv:rates.csv,speed=60")
+	if err != nil || csv.Kind != CurveCSV || csv.Path != "rates.csv" || csv.Speed != 60 {
+		t.Fatalf("csv curve=%+v err=%v", csv, err)
+	}
+
+	for _, raw := range []string{"sine", "csv:", "diurnal,peak=25:00", "diurnal,trough=150%", "weekly,timezone=Mars/Olympus", "diurnal,speed=0", "diurnal,colour=red", "diurnal,peak"} {
+		if _, err := ParseCurve(raw); err == nil {
+			t.Fatalf("ParseCurve(%q) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestCurveLoadFollowsDayAndWeek(t *testing.T) {
+	c, err := ParseCurve("weekly,peak=14:00,trough=20%,weekend=50%,timezone=America/New_York")
+	if err != nil {
+		t.Fatalf("ParseCurve: %v", err)
+	}
+	ny := c.Location
+	cases := []struct {
+		at   time.Time
+		want float64
+	}{
+		{time.Date(2026, 10, 14, 14, 0, 0, 0, ny), 1},   // Wednesday peak
+		{time.Date(2026, 10, 14, 2, 0, 0, 0, ny), 0.2},  // Wednesday trough
+		{time.Date(2026, 10, 14, 8, 0, 0, 0, ny), 0.6},  // halfway up
+		{time.Date(2026, 10, 17, 14, 0, 0, 0, ny), 0.5}, // Saturday peak
+		{time.Date(2026, 10, 14, 18, 0, 0, 0, time.UTC), 1},
+	}
+	for _, tc := range cases {
+		if got := c.Load(tc.at); math.Abs(got-tc.want) > 1e-9 {
+			t.Fatalf("Load(%s)=%.3f want %.3f", tc.at, got, tc.want)
+		}
+	}
+
+	c.Kind = CurveDiurnal
+	if got := c.Load(time.Date(2026, 10, 17, 14, 0, 0, 0, ny)); got != 1 {
+		t.Fatalf("diurnal Saturday peak=%.3f want 1", got)
+	}
+}
+
+func TestCurveCannotCombineWithPhasesOrCount(t *testing.T) {
+	curve, err := ParseCurve("diurnal")
+	if err != nil {
+		t.Fatalf("ParseCurve: %v", err)
+	}
+	base := Config{
+		RateValue:        1,
+		RateUnit:         RateUnitTraces,
+		RateInterval:     1,
+		Workers:          1,
+		Profile:          "web",
+		Routes:           1,
+		Services:         1,
+		Depth:            1,
+		Fanout:           1,
+		P50:              1,
+		P95:              2,
+		P99:              3,
+		CacheHitRate:     1,
+		Variety:          "medium",
+		Format:           "jsonl",
+		Output:           "stdout",
+		BatchSize:        1,
+		FlushInterval:    1,
+		SinkRetryBackoff: 1,
+		SinkTimeout:      1,
+		SinkMaxInFlight:  1,
+		Curve:            curve,
+	}
+	if err := base.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	for name, mutate := range map[string]func(*Config){
+		"phase-file": func(c *Config) { c.PhaseFile = "phases.yaml" },
+		"load":       func(c *Config) { c.Load = "warmup-spike-recovery" },
+		"count":      func(c *Config) { c.Count = 10 },
+	} {
+		cfg := base
+		mutate(&cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("curve with %s validated, want error", name)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DeadlinePolicy sets how long Caller waits for calls into Service. An empty
+// Caller or Service matches any service, but a policy needs at least one.
+type DeadlinePolicy struct {
+	Caller   string
+	Service  string
+	Deadline time.Duration
+}
+
+// ParseDeadlinePolicy parses a CLI deadline such as
+// "caller=checkout-api,service=payment-service,deadline=800ms".
+func ParseDeadlinePolicy(raw string) (DeadlinePolicy, error) {
+	var p DeadlinePolicy
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return DeadlinePolicy{}, fmt.Errorf("invalid deadline policy %q: expected key=value, got %q", raw, part)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "caller":
+			p.Caller = value
+		case "service":
+			p.Service = value
+		case "deadline", "timeout":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return DeadlinePolicy{}, fmt.Errorf("invalid deadline policy %q: bad %s %q", raw, key, value)
+			}
+			p.Deadline = d
+		default:
+			return DeadlinePolicy{}, fmt.Errorf("invalid deadline policy %q: unknown key %q (must be caller, service, or deadline)", raw, key)
+		}
+	}
+	if err := p.Validate(); err != nil {
+		return DeadlinePolicy{}, fmt.Errorf("invalid deadline policy %q: %w", raw, err)
+	}
+	return p, nil
+}
+
+func (p DeadlinePolicy) Validate() error {
+	if p.Caller == "" && p.Service == "" {
+		return fmt.Errorf("deadline policy needs a caller or service")
+	}
+	if p.Deadline <= 0 {
+		return fmt.Errorf("deadline policy needs a deadline > 0")
+	}
+	return nil
+}
+
+// Matches reports whether the policy covers calls from caller into service.
+func (p DeadlinePolicy) Matches(caller, service string) bool {
+	return (p.Caller == "" || p.Caller == caller) && (p.Service == "" || p.Service == service)
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDeadlinePolicy(t *testing.T) {
+	got, err := ParseDeadlinePolicy("caller=checkout-api, service=payment-service, deadline=800ms")
+	if err != nil {
+		t.Fatalf("ParseDeadlinePolicy: %v", err)
+	}
+	want := DeadlinePolicy{Caller: "checkout-api", Service: "payment-service", Deadline: 800 * time.Millisecond}
+	if got != want {
+		t.Fatalf("policy=%+v want %+v", got, want)
+	}
+	if !got.Matches("checkout-api", "payment-service") || got.Matches("edge-gateway", "payment-service") {
+		t.Fatalf("policy %+v matched the wrong edge", got)
+	}
+
+	for _, raw := range []string{"deadline=1s", "service=a", "service=a,deadline=soon", "service=a,deadline=1s,colour=red"} {
+		if _, err := ParseDeadlinePolicy(raw); err == nil {
+			t.Fatalf("ParseDeadlinePolicy(%q) succeeded, want error", raw)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+type DistributionKind string
+
+const (
+	DistributionFixed       DistributionKind = "fixed"
+	DistributionUniform     DistributionKind = "uniform"
+	DistributionExponential DistributionKind = "exponential"
+	DistributionLognormal   DistributionKind = "lognormal"
+)
+
+// Distribution describes a random duration such as a delivery delay or a think time.
+//
+// Specs use the form kind:args, for example fixed:30s, uniform:1s-5s,
+// exponential:2s (mean) or lognormal:1s-10s (p50-p95). A bare duration is fixed.
+type Distribution struct {
+	Kind DistributionKind
+	A    time.Duration
+	B    time.Duration
+}
+
+func ParseDistribution(raw string) (Distribution, error) {
+	s := strings.ToLower(strings.TrimSpace(raw))
+	if s == "" {
+		return Distribution{}, nil
+	}
+	kind, args, ok := strings.Cut(s, ":")
+	if !ok {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return Distribution{}, fmt.Errorf("invalid distribution %q", raw)
+		}
+		return Distribution{Kind: DistributionFixed, A: d}, nil
+	}
+	switch DistributionKind(kind) {
+	case DistributionFixed, DistributionExponential:
+		d, err := time.ParseDuration(args)
+		if err != nil || d < 0 {
+			return Distribution{}, fmt.Errorf("invalid %s distribution %q", kind, raw)
+		}
+		return Distribution{Kind: DistributionKind(kind), A: d}, nil
+	case DistributionUniform, DistributionLognormal:
+		lo, hi, ok := strings.Cut(args, "-")
+		if !ok {
+			return Distribution{}, fmt.Errorf("invalid %s distribution %q (expected %s:<a>-<b>)", kind, raw, kind)
+		}
+		a, errA := time.ParseDuration(strings.TrimSpace(lo))
+		b, errB := time.ParseDuration(strings.TrimSpace(hi))
+		if errA != nil || errB != nil || a < 0 || b < a {
+			return Distribution{}, fmt.Errorf("invalid %s distribution %q", kind, raw)
+		}
+		if DistributionKind(kind) == DistributionLognormal && a <= 0 {
+			return Distribution{}, fmt.Errorf("lognormal distribution %q needs a positive p50", raw)
+		}
+		return Distribution{Kind: DistributionKind(kind), A: a, B: b}, nil
+	default:
+		return Distribution{}, fmt.Errorf("unknown distribution kind %q (must be fixed, uniform, exponential, or lognormal)", kind)
+	}
+}
+
+func (d Distribution) IsZero() bool {
+	return d.Kind == ""
+}
+
+func (d Distribution) String() string {
+	switch d.Kind {
+	case "":
+		return ""
+	case DistributionUniform, DistributionLognormal:
+		return fmt.Sprintf("%s:%s-%s", d.Kind, d.A, d.B)
+	default:
+		return fmt.Sprintf("%s:%s", d.Kind, d.A)
+	}
+}
+
+// Sample draws a duration using uniform as the source of [0,1) values.
+func (d Distribution) Sample(uniform func() float64) time.Duration {
+	switch d.Kind {
+	case DistributionFixed:
+		return d.A
+	case DistributionUniform:
+		return d.A + time.Duration(uniform()*float64(d.B-d.A))
+	case DistributionExponential:
+		u := uniform()
+		if u < 1e-9 {
+			u = 1e-9
+		}
+		return time.Duration(-math.Log(u) * float64(d.A))
+	case DistributionLognormal:
+		u1 := uniform()
+		if u1 < 1e-9 {
+			u1 = 1e-9
+		}
+		u2 := uniform()
+		z := math.Sqrt(-2.0*math.Log(u1)) * math.Cos(2.0*math.Pi*u2)
+		mu := math.Log(float64(d.A))
+		sigma := 0.0
+		if d.B > d.A {
+			sigma = (math.Log(float64(d.B)) - mu) / 1.6448536269514722
+		}
+		return time.Duration(math.Exp(mu + sigma*z))
+	default:
+		return 0
+	}
+}
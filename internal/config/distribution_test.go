@@ -0,0 +1,45 @@
+package config
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestParseDistribution(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Distribution
+	}{
+		{in: "45s", want: Distribution{Kind: DistributionFixed, A: 45 * time.Second}},
+		{in: "fixed:2s", want: Distribution{Kind: DistributionFixed, A: 2 * time.Second}},
+		{in: "uniform:1s-5s", want: Distribution{Kind: DistributionUniform, A: time.Second, B: 5 * time.Second}},
+		{in: "exponential:500ms", want: Distribution{Kind: DistributionExponential, A: 500 * time.Millisecond}},
+		{in: "lognormal:1s-10s", want: Distribution{Kind: DistributionLognormal, A: time.Second, B: 10 * time.Second}},
+	}
+	for _, tc := range tests {
+		got, err := ParseDistribution(tc.in)
+		if err != nil {
+			t.Fatalf("ParseDistribution(%q): %v", tc.in, err)
+		}
+		if got != tc.want {
+			t.Fatalf("ParseDistribution(%q)=%+v want %+v", tc.in, got, tc.want)
+		}
+	}
+	for _, bad := range []string{"uniform:5s-1s", "poisson:1s", "exponential:soon", "lognormal:0s-1s"} {
+		if _, err := ParseDistribution(bad); err == nil {
+			t.Fatalf("ParseDistribution(%q) expected error", bad)
+		}
+	}
+}
+
+func TestDistributionSampleStaysInRange(t *testing.T) {
+	d := Distribution{Kind: DistributionUniform, A: time.Second, B: 2 * time.Second}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		v := d.Sample(rng.Float64)
+		if v < time.Second || v > 2*time.Second {
+			t.Fatalf("uniform sample %s out of range", v)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fault degrades spans of one service or operation. A matching span is hit
+// with probability Rate. A hit span fails with Status, runs until Timeout and
+// fails, or gets Latency added. A fault that sets none of these fails the span
+// with a generic error.
+type Fault struct {
+	Service   string
+	Operation string
+	Rate      float64
+	Status    int
+	Latency   time.Duration
+	Timeout   time.Duration
+}
+
+// ParseFault parses a CLI fault such as
+// "service=payment-service,operation=POST /charge,rate=30%,status=503,latency=800ms".
+func ParseFault(raw string) (Fault, error) {
+	f := Fault{Rate: 1}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Fault{}, fmt.Errorf("invalid fault %q: expected key=value, got %q", raw, part)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		var err error
+		switch key {
+		case "service":
+			f.Service = value
+		case "operation", "op":
+			f.Operation = value
+		case "rate":
+			f.Rate, err = ParsePercent(value)
+		case "status":
+			f.Status, err = strconv.Atoi(value)
+		case "latency":
+			f.Latency, err = time.ParseDuration(strings.TrimPrefix(value, "+"))
+		case "timeout":
+			f.Timeout, err = time.ParseDuration(value)
+		default:
+			return Fault{}, fmt.Errorf("invalid fault %q: unknown key %q (must be service, operation, rate, status, latency, or timeout)", raw, key)
+		}
+		if err != nil {
+			return Fault{}, fmt.Errorf("invalid fault %q: bad %s %q", raw, key, value)
+		}
+	}
+	if err := f.Validate(); err != nil {
+		return Fault{}, fmt.Errorf("invalid fault %q: %w", raw, err)
+	}
+	return f, nil
+}
+
+func (f Fault) Validate() error {
+	if f.Service == "" && f.Operation == "" {
+		return fmt.Errorf("fault needs a service or operation")
+	}
+	if f.Rate < 0 || f.Rate > 1 {
+		return fmt.Errorf("fault rate must be in [0,1]")
+	}
+	if f.Status != 0 && (f.Status < 100 || f.Status > 599) {
+		return fmt.Errorf("fault status must be an HTTP status code")
+	}
+	if f.Latency < 0 || f.Timeout < 0 {
+		return fmt.Errorf("fault latency and timeout must be >= 0")
+	}
+	return nil
+}
+
+// Label names the fault target, for example "payment-service POST /charge".
+func (f Fault) Label() string {
+	return strings.TrimSpace(f.Service + " " + f.Operation)
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFault(t *testing.T) {
+	got, err := ParseFault("service=payment-service, operation=POST /charge, rate=30%, status=503, latency=+800ms")
+	if err != nil {
+		t.Fatalf("ParseFault: %v", err)
+	}
+	want := Fault{Service: "payment-service", Operation: "POST /charge", Rate: 0.3, Status: 503, Latency: 800 * time.Millisecond}
+	if got != want {
+		t.Fatalf("fault=%+v want %+v", got, want)
+	}
+
+	got, err = ParseFault("service=ledger-service,timeout=5s")
+	if err != nil {
+		t.Fatalf("ParseFault timeout: %v", err)
+	}
+	if got.Rate != 1 || got.Timeout != 5*time.Second {
+		t.Fatalf("fault=%+v want rate 1 and 5s timeout", got)
+	}
+
+	for _, raw := range []string{"rate=10%", "service=a,status=42", "service=a,colour=red", "service"} {
+		if _, err := ParseFault(raw); err == nil {
+			t.Fatalf("ParseFault(%q) succeeded, want error", raw)
+		}
+	}
+}
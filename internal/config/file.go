@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// byteUnits are the suffixes ParseByteSize accepts. KB, MB and GB are
+// powers of 1000; KiB, MiB and GiB are powers of 1024.
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"kib", 1 << 10}, {"mib", 1 << 20}, {"gib", 1 << 30},
+	{"kb", 1000}, {"mb", 1000 * 1000}, {"gb", 1000 * 1000 * 1000},
+	{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+	{"b", 1},
+}
+
+// ParseByteSize parses a size such as "512", "64KB", "100MB" or "1GiB".
+// An empty string is zero.
+func ParseByteSize(raw string) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(raw))
+	if s == "" {
+		return 0, nil
+	}
+	unit := int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			unit = u.size
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", raw)
+	}
+	return int64(v * float64(unit)), nil
+}
+
+// FileRotates reports whether file outputs use the rotating writer: any of
+// rotation, compression or retention is set.
+func (c Config) FileRotates() bool {
+	return c.FileRotateSize > 0 || c.FileRotateEvery > 0 || c.FileCompress != "" || c.FileMaxFiles > 0
+}
+
+func validateFileOutput(c Config) error {
+	if c.FileRotateSize < 0 {
+		return fmt.Errorf("file-rotate-size must be >= 0")
+	}
+	if c.FileRotateEvery < 0 {
+		return fmt.Errorf("file-rotate-interval must be >= 0")
+	}
+	if c.FileMaxFiles < 0 {
+		return fmt.Errorf("file-max-files must be >= 0")
+	}
+	switch c.FileCompress {
+	case "", "gzip", "zstd":
+	default:
+		return fmt.Errorf("file-compress must be gzip or zstd")
+	}
+	if !c.FileRotates() {
+		return nil
+	}
+	for _, s := range c.Outputs() {
+		if s.Output == "file" {
+			return nil
+		}
+	}
+	return fmt.Errorf("file-rotate-size, file-rotate-interval, file-compress and file-max-files need a file output")
+}
@@ -0,0 +1,38 @@
+package config
+
+import "testing"
+
+func TestParseByteSize(t *testing.T) {
+	for raw, want := range map[string]int64{"": 0, "0": 0, "512": 512, "64KB": 64000, "100MB": 100000000, "1GiB": 1 << 30, "1.5 kib": 1536, "2m": 2000000, "10b": 10} {
+		got, err := ParseByteSize(raw)
+		if err != nil || got != want {
+			t.Fatalf("ParseByteSize(%q)=%d, %v want %d", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"big", "-1MB", "10TB"} {
+		if _, err := ParseByteSize(raw); err == nil {
+			t.Fatalf("ParseByteSize(%q) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestValidateFileOutput(t *testing.T) {
+	cfg := Config{Format: "jsonl", Output: "stdout", FileCompress: "gzip"}
+	if err := validateFileOutput(cfg); err == nil {
+		t.Fatalf("compression without a file output validated")
+	}
+	cfg.Output = "file"
+	cfg.File = "traces.jsonl"
+	if err := validateFileOutput(cfg); err != nil {
+		t.Fatalf("gzip file output: %v", err)
+	}
+	cfg.FileCompress = "lz4"
+	if err := validateFileOutput(cfg); err == nil {
+		t.Fatalf("lz4 validated")
+	}
+	cfg.FileCompress = ""
+	cfg.FileMaxFiles = -1
+	if err := validateFileOutput(cfg); err == nil {
+		t.Fatalf("negative file-max-files validated")
+	}
+}
@@ -24,6 +24,7 @@ type FlagValues struct {
 	Load             string
 	Workers          int
 	Profile          string
+	ProfileMix       string
 	Routes           int
 	Services         int
 	Depth            int
@@ -57,6 +58,64 @@ type FlagValues struct {
 	ReportFile       string
 	HTTPListen       string
 	Debug            bool
+
+	DeliverySplit      string
+	DeliveryShuffle    string
+	DeliveryDelay      string
+	DeliveryDelayDist  string
+	WorkflowLifetime   time.Duration
+	MessagingMode      string
+	MessagingLag       string
+	MessagingBatch     int
+	Users              int
+	ThinkTime          string
+	Faults             []string
+	ErrorPropagation   string
+	ErrorShortCircuit  string
+	RetryMaxAttempts   int
+	RetryBackoff       time.Duration
+	RetryJitter        string
+	RetryPolicies      []string
+	RetryStorm         bool
+	Deadline           time.Duration
+	DeadlinePolicies   []string
+	DeadlineCancel     string
+	LatencyModels      []string
+	ProfileLatency     bool
+	Arrival            string
+	BurstSize          int
+	BurstInterval      time.Duration
+	Curve              string
+	Tenants            []string
+	TenantHeader       string
+	Sinks              []string
+	FileRotateSize     string
+	FileRotateEvery    time.Duration
+	FileCompress       string
+	FileMaxFiles       int
+	TLSCAFile          string
+	TLSCertFile        string
+	TLSKeyFile         string
+	TLSServerName      string
+	TLSMinVersion      string
+	TLSSkipVerify      bool
+	Auth               string
+	LB                 string
+	LBResolve          bool
+	Connections        int
+	HTTPVersion        string
+	HTTPMaxIdleConns   int
+	HTTPIdleTimeout    time.Duration
+	GRPCKeepalive      time.Duration
+	GRPCKeepaliveWait  time.Duration
+	GRPCMaxMessage     string
+	RateControl        string
+	AdaptiveWindow     time.Duration
+	AdaptiveStep       float64
+	AdaptiveBackoff    float64
+	AdaptiveMinRate    float64
+	AdaptiveMaxRate    float64
+	AdaptiveMaxLatency time.Duration
 }
 
 type yamlFlagValues struct {
@@ -71,6 +130,7 @@ type yamlFlagValues struct {
 	Load             *string  `yaml:"load"`
 	Workers          *int     `yaml:"workers"`
 	Profile          *string  `yaml:"profile"`
+	ProfileMix       *string  `yaml:"profile_mix"`
 	Routes           *int     `yaml:"routes"`
 	Services         *int     `yaml:"services"`
 	Depth            *int     `yaml:"depth"`
@@ -104,6 +164,64 @@ type yamlFlagValues struct {
 	ReportFile       *string  `yaml:"report_file"`
 	HTTPListen       *string  `yaml:"http_listen"`
 	Debug            *bool    `yaml:"debug"`
+
+	DeliverySplit      *string  `yaml:"delivery_split"`
+	DeliveryShuffle    *string  `yaml:"delivery_shuffle"`
+	DeliveryDelay      *string  `yaml:"delivery_delay"`
+	DeliveryDelayDist  *string  `yaml:"delivery_delay_dist"`
+	WorkflowLifetime   *string  `yaml:"workflow_lifetime"`
+	MessagingMode      *string  `yaml:"messaging_mode"`
+	MessagingLag       *string  `yaml:"messaging_lag"`
+	MessagingBatch     *int     `yaml:"messaging_batch"`
+	Users              *int     `yaml:"users"`
+	ThinkTime          *string  `yaml:"think_time"`
+	Faults             []string `yaml:"faults"`
+	ErrorPropagation   *string  `yaml:"error_propagation"`
+	ErrorShortCircuit  *string  `yaml:"error_short_circuit"`
+	RetryMaxAttempts   *int     `yaml:"retry_max_attempts"`
+	RetryBackoff       *string  `yaml:"retry_backoff"`
+	RetryJitter        *string  `yaml:"retry_jitter"`
+	RetryPolicies      []string `yaml:"retry_policies"`
+	RetryStorm         *bool    `yaml:"retry_storm"`
+	Deadline           *string  `yaml:"deadline"`
+	DeadlinePolicies   []string `yaml:"deadline_policies"`
+	DeadlineCancel     *string  `yaml:"deadline_cancel"`
+	LatencyModels      []string `yaml:"latency_models"`
+	ProfileLatency     *bool    `yaml:"profile_latency_models"`
+	Arrival            *string  `yaml:"arrival"`
+	BurstSize          *int     `yaml:"burst_size"`
+	BurstInterval      *string  `yaml:"burst_interval"`
+	Curve              *string  `yaml:"curve"`
+	Tenants            []string `yaml:"tenants"`
+	TenantHeader       *string  `yaml:"tenant_header"`
+	Sinks              []string `yaml:"sinks"`
+	FileRotateSize     *string  `yaml:"file_rotate_size"`
+	FileRotateEvery    *string  `yaml:"file_rotate_interval"`
+	FileCompress       *string  `yaml:"file_compress"`
+	FileMaxFiles       *int     `yaml:"file_max_files"`
+	TLSCAFile          *string  `yaml:"tls_ca_file"`
+	TLSCertFile        *string  `yaml:"tls_cert_file"`
+	TLSKeyFile         *string  `yaml:"tls_key_file"`
+	TLSServerName      *string  `yaml:"tls_server_name"`
+	TLSMinVersion      *string  `yaml:"tls_min_version"`
+	TLSSkipVerify      *bool    `yaml:"tls_insecure_skip_verify"`
+	Auth               *string  `yaml:"auth"`
+	LB                 *string  `yaml:"lb"`
+	LBResolve          *bool    `yaml:"lb_resolve"`
+	Connections        *int     `yaml:"connections"`
+	HTTPVersion        *string  `yaml:"http_version"`
+	HTTPMaxIdleConns   *int     `yaml:"http_max_idle_conns"`
+	HTTPIdleTimeout    *string  `yaml:"http_idle_conn_timeout"`
+	GRPCKeepalive      *string  `yaml:"grpc_keepalive_time"`
+	GRPCKeepaliveWait  *string  `yaml:"grpc_keepalive_timeout"`
+	GRPCMaxMessage     *string  `yaml:"grpc_max_message_size"`
+	RateControl        *string  `yaml:"rate_control"`
+	AdaptiveWindow     *string  `yaml:"adaptive_window"`
+	AdaptiveStep       *float64 `yaml:"adaptive_step"`
+	AdaptiveBackoff    *float64 `yaml:"adaptive_backoff"`
+	AdaptiveMinRate    *float64 `yaml:"adaptive_min_rate"`
+	AdaptiveMaxRate    *float64 `yaml:"adaptive_max_rate"`
+	AdaptiveMaxLatency *string  `yaml:"adaptive_max_latency"`
 }
 
 func AddFlags(fs *pflag.FlagSet, v *FlagValues) {
@@ -119,6 +237,7 @@ func AddFlags(fs *pflag.FlagSet, v *FlagValues) {
 	fs.StringVar(&v.Load, "load", "", "Built-in load preset")
 	fs.IntVar(&v.Workers, "workers", 1, "Concurrent generator workers")
 	fs.StringVar(&v.Profile, "profile", "web", "Generation profile")
+	fs.StringVar(&v.ProfileMix, "profile-mix", "", "Run several profiles at once by weight, e.g. web=60,grpc=25,queue=10,batch=5 (replaces --profile)")
 	fs.IntVar(&v.Routes, "routes", 8, "Number of named routes/methods per profile")
 	fs.IntVar(&v.Services, "services", 8, "Number of services")
 	fs.IntVar(&v.Depth, "depth", 4, "Max trace depth")
@@ -135,14 +254,34 @@ func AddFlags(fs *pflag.FlagSet, v *FlagValues) {
 	fs.BoolVar(&v.HighCardinality, "high-cardinality", false, "Enable high-cardinality attributes (request IDs, message IDs)")
 	fs.StringSliceVar(&v.Weird, "weird", nil, "Valid but awkward telemetry modes (repeat or comma-separate)")
 	fs.StringSliceVar(&v.Invalid, "invalid", nil, "Intentionally invalid telemetry modes (repeat or comma-separate)")
-	fs.StringVar(&v.Format, "format", "jsonl", "Output format")
+	fs.StringVar(&v.Format, "format", "jsonl", "Output format: jsonl|pretty|otlp-http|otlp-grpc|otlp-proto|otlp-json|zipkin-json")
 	fs.StringVar(&v.Output, "output", "stdout", "Output sink")
 	fs.StringVar(&v.File, "file", "", "Output file path")
-	fs.StringVar(&v.OTLPEndpoint, "otlp-endpoint", "", "OTLP endpoint")
-	fs.StringVar(&v.ZipkinEndpoint, "zipkin-endpoint", "", "Zipkin endpoint")
-	fs.BoolVar(&v.OTLPInsecure, "otlp-insecure", true, "Use insecure OTLP gRPC transport")
+	fs.StringVar(&v.FileRotateSize, "file-rotate-size", "0", "Start a new output file once this much is written, before compression, e.g. 100MB (0 disables)")
+	fs.DurationVar(&v.FileRotateEvery, "file-rotate-interval", 0, "Start a new output file after this long (0 disables)")
+	fs.StringVar(&v.FileCompress, "file-compress", "", "Compress output files: gzip|zstd")
+	fs.IntVar(&v.FileMaxFiles, "file-max-files", 0, "Keep only this many completed output files, deleting the oldest (0 keeps all)")
+	fs.StringVar(&v.OTLPEndpoint, "otlp-endpoint", "", "OTLP endpoint, or several separated by commas to balance across")
+	fs.StringVar(&v.ZipkinEndpoint, "zipkin-endpoint", "", "Zipkin endpoint, or several separated by commas to balance across")
+	fs.StringVar(&v.LB, "lb", "round-robin", "Balance requests across endpoints: round-robin|least-loaded")
+	fs.BoolVar(&v.LBResolve, "lb-resolve", false, "Resolve each endpoint's host to all its addresses and balance across them")
+	fs.IntVar(&v.Connections, "connections", 0, "Connections per endpoint: OTLP gRPC opens this many (0 opens 1), HTTP opens at most this many (0 is no limit)")
+	fs.StringVar(&v.HTTPVersion, "http-version", "auto", "HTTP version for OTLP HTTP and Zipkin: auto|1.1|2 (2 uses h2c for http:// endpoints)")
+	fs.IntVar(&v.HTTPMaxIdleConns, "http-max-idle-conns", 0, "Idle HTTP connections kept per endpoint (0 keeps Go's default of 2)")
+	fs.DurationVar(&v.HTTPIdleTimeout, "http-idle-conn-timeout", 90*time.Second, "Close HTTP connections idle this long")
+	fs.DurationVar(&v.GRPCKeepalive, "grpc-keepalive-time", 0, "Ping OTLP gRPC connections idle this long (0 disables)")
+	fs.DurationVar(&v.GRPCKeepaliveWait, "grpc-keepalive-timeout", 20*time.Second, "Close an OTLP gRPC connection when a keepalive ping goes unanswered this long")
+	fs.StringVar(&v.GRPCMaxMessage, "grpc-max-message-size", "0", "Largest OTLP gRPC message sent or received, e.g. 16MiB (0 keeps gRPC's limits)")
+	fs.BoolVar(&v.OTLPInsecure, "otlp-insecure", true, "Use insecure OTLP gRPC transport (any --tls-* flag turns TLS on)")
+	fs.StringVar(&v.TLSCAFile, "tls-ca-file", "", "PEM CA bundle to verify OTLP and Zipkin servers with instead of the system roots")
+	fs.StringVar(&v.TLSCertFile, "tls-cert-file", "", "PEM client certificate for mutual TLS (with --tls-key-file)")
+	fs.StringVar(&v.TLSKeyFile, "tls-key-file", "", "PEM client key for mutual TLS (with --tls-cert-file)")
+	fs.StringVar(&v.TLSServerName, "tls-server-name", "", "Server name to verify instead of the endpoint host")
+	fs.StringVar(&v.TLSMinVersion, "tls-min-version", "1.2", "Minimum TLS version: 1.2|1.3")
+	fs.BoolVar(&v.TLSSkipVerify, "tls-insecure-skip-verify", false, "Accept any server certificate (testing only)")
+	fs.StringVar(&v.Auth, "auth", "", "Authenticate OTLP and Zipkin requests: bearer,token-file=<path> | basic,username=<u>,password-file=<path>|password-env=<var> | oauth2,token-url=<url>,client-id=<id>,client-secret-file=<path>|client-secret-env=<var>,scope=<s>")
 	fs.StringSliceVar(&v.Headers, "headers", nil, "Additional headers (repeat k=v)")
-	fs.StringVar(&v.Compress, "compress", "", "Compression for OTLP HTTP (gzip)")
+	fs.StringVar(&v.Compress, "compress", "", "Compress OTLP and Zipkin requests: gzip|zstd|snappy|none (Zipkin takes gzip only)")
 	fs.IntVar(&v.BatchSize, "batch-size", 512, "Spans per batch")
 	fs.DurationVar(&v.FlushInterval, "flush-interval", 200*time.Millisecond, "Sink flush interval")
 	fs.IntVar(&v.SinkRetries, "sink-retries", 2, "Retry attempts for sink requests")
@@ -152,6 +291,43 @@ func AddFlags(fs *pflag.FlagSet, v *FlagValues) {
 	fs.StringVar(&v.ReportFile, "report-file", "", "Write run summary as JSON to this path")
 	fs.StringVar(&v.HTTPListen, "http-listen", "127.0.0.1:8080", "Admin HTTP listen address for /healthz and /stats")
 	fs.BoolVar(&v.Debug, "debug", false, "Enable debug logs for trace emission and sink sends")
+	fs.StringVar(&v.DeliverySplit, "delivery-split", "0%", "Share of traces whose spans are split across batches")
+	fs.StringVar(&v.DeliveryShuffle, "delivery-shuffle", "0%", "Share of traces whose spans are delivered out of order")
+	fs.StringVar(&v.DeliveryDelay, "delivery-delay", "0%", "Share of spans delivered late")
+	fs.StringVar(&v.DeliveryDelayDist, "delivery-delay-dist", "exponential:5s", "Late span delay distribution (fixed:30s, uniform:1s-5s, exponential:5s, lognormal:1s-10s)")
+	fs.DurationVar(&v.WorkflowLifetime, "workflow-lifetime", 10*time.Minute, "Total lifetime of each trace in the workflow profile; spans are sent as they end, and any still open when the run ends are sent then")
+	fs.StringVar(&v.MessagingMode, "messaging-mode", "same-trace", "Queue profile consumer placement: same-trace|linked")
+	fs.StringVar(&v.MessagingLag, "messaging-lag", "exponential:2s", "Consumer lag distribution for --messaging-mode linked")
+	fs.IntVar(&v.MessagingBatch, "messaging-batch", 1, "Messages per consumer receive for --messaging-mode linked")
+	fs.IntVar(&v.Users, "users", 0, "Simulated user population; 0 makes every trace independent")
+	fs.StringVar(&v.ThinkTime, "think-time", "exponential:5s", "Pause between requests in one user session")
+	fs.StringArrayVar(&v.Faults, "fault", nil, "Targeted fault (repeat), e.g. service=payment-service,operation=POST /charge,rate=30%,status=503,latency=800ms")
+	fs.StringVar(&v.ErrorPropagation, "error-propagation", "0%", "Chance a caller fails when a call it makes fails")
+	fs.StringVar(&v.ErrorShortCircuit, "error-short-circuit", "100%", "Chance a caller skips its remaining calls once a failure surfaces")
+	fs.IntVar(&v.RetryMaxAttempts, "retry-max-attempts", 3, "Attempts per retried call, including the first")
+	fs.DurationVar(&v.RetryBackoff, "retry-backoff", 100*time.Millisecond, "Wait before the first retry; doubles for each later retry")
+	fs.StringVar(&v.RetryJitter, "retry-jitter", "50%", "Largest share of each retry backoff removed at random")
+	fs.StringArrayVar(&v.RetryPolicies, "retry-policy", nil, "Retry policy for one service or profile (repeat), e.g. service=payment-service,attempts=5,backoff=200ms,jitter=20%")
+	fs.BoolVar(&v.RetryStorm, "retry-storm", false, "Retry every failed call; retries fail at least half the time")
+	fs.DurationVar(&v.Deadline, "deadline", 0, "How long a caller waits for each call (0 disables)")
+	fs.StringArrayVar(&v.DeadlinePolicies, "deadline-policy", nil, "Deadline for calls between two services (repeat), e.g. caller=checkout-api,service=payment-service,deadline=800ms")
+	fs.StringVar(&v.DeadlineCancel, "deadline-cancel", "0%", "Chance that work behind a timed-out call is cancelled instead of continuing")
+	fs.StringVar(&v.Arrival, "arrival", "uniform", "Trace arrival process: uniform|poisson|bursty|on-off")
+	fs.StringVar(&v.RateControl, "rate-control", "fixed", "Rate control: fixed holds --rate, adaptive starts there and follows sink backpressure")
+	fs.DurationVar(&v.AdaptiveWindow, "adaptive-window", 2*time.Second, "How often adaptive rate control adjusts the rate")
+	fs.Float64Var(&v.AdaptiveStep, "adaptive-step", 0.1, "Fraction of --rate adaptive rate control adds after each healthy window")
+	fs.Float64Var(&v.AdaptiveBackoff, "adaptive-backoff", 0.5, "Factor adaptive rate control multiplies the rate by after a throttled, timed out or slow window")
+	fs.Float64Var(&v.AdaptiveMinRate, "adaptive-min-rate", 0, "Lowest rate adaptive rate control backs off to, in --rate units (0 is 1% of --rate)")
+	fs.Float64Var(&v.AdaptiveMaxRate, "adaptive-max-rate", 0, "Highest rate adaptive rate control probes up to, in --rate units (0 is no limit)")
+	fs.DurationVar(&v.AdaptiveMaxLatency, "adaptive-max-latency", 0, "Mean send latency above which adaptive rate control backs off (0 is twice the lowest window mean plus 50ms)")
+	fs.IntVar(&v.BurstSize, "burst-size", 20, "Traces per burst for --arrival bursty")
+	fs.DurationVar(&v.BurstInterval, "burst-interval", 0, "Time between bursts for --arrival bursty (0 spaces them at random), or mean on/off period for --arrival on-off (default 1s)")
+	fs.StringVar(&v.Curve, "curve", "", "Continuous load curve: diurnal|weekly|csv:<path> with options, e.g. diurnal,peak=14:00,trough=20%,timezone=Europe/Dublin,speed=24")
+	fs.StringArrayVar(&v.Tenants, "tenant", nil, "Tenant to send a weighted share of traces to (repeat), e.g. acme,weight=3,header=Authorization=Bearer abc,attr=cloud.account.id=1234,endpoint=http://tempo-acme:4318")
+	fs.StringVar(&v.TenantHeader, "tenant-header", "X-Scope-OrgID", "Header carrying the tenant name on each tenant's requests (empty disables)")
+	fs.StringArrayVar(&v.Sinks, "sink", nil, "Output to fan every trace out to (repeat; replaces --format/--output), e.g. name=tempo,format=otlp-http,endpoint=http://tempo:4318,batch-size=1000,on-error=continue")
+	fs.StringArrayVar(&v.LatencyModels, "latency-model", nil, "Latency model for one service or operation (repeat), e.g. operation=authorize payment,model=pareto,p50=80ms,p95=400ms,p99=2s")
+	fs.BoolVar(&v.ProfileLatency, "profile-latency-models", false, "Use the profile's built-in per-operation latency models (payment-system, api-gateway); modelled spans skip the profile and --variety slow multipliers")
 }
 
 func FromFlags(v FlagValues) (Config, error) {
@@ -159,6 +335,16 @@ func FromFlags(v FlagValues) (Config, error) {
 }
 
 func FromFlagsWithOverrides(v FlagValues, cliOverrides map[string]bool) (Config, error) {
+	merged, err := mergeValues(v, cliOverrides)
+	if err != nil {
+		return Config{}, err
+	}
+	return fromValues(merged)
+}
+
+// mergeValues sets the config file's values, then the environment's, over
+// the flags not given on the command line.
+func mergeValues(v FlagValues, cliOverrides map[string]bool) (FlagValues, error) {
 	if (cliOverrides == nil || !cliOverrides["config"]) && v.ConfigFile == "" {
 		if raw, ok := os.LookupEnv("SPANFORGE_CONFIG"); ok {
 			v.ConfigFile = strings.TrimSpace(raw)
@@ -168,17 +354,16 @@ func FromFlagsWithOverrides(v FlagValues, cliOverrides map[string]bool) (Config,
 	if v.ConfigFile != "" {
 		merged, err := mergeFromYAML(v, cliOverrides)
 		if err != nil {
-			return Config{}, err
+			return FlagValues{}, err
 		}
 		v = merged
 	}
+	return mergeFromEnv(v, cliOverrides)
+}
 
-	mergedEnv, err := mergeFromEnv(v, cliOverrides)
-	if err != nil {
-		return Config{}, err
-	}
-	v = mergedEnv
-
+// fromValues parses and validates flag values once the config file and
+// environment are merged in.
+func fromValues(v FlagValues) (Config, error) {
 	rateUnit, err := ParseRateUnit(v.RateUnit)
 	if err != nil {
 		return Config{}, err
@@ -203,6 +388,116 @@ func FromFlagsWithOverrides(v FlagValues, cliOverrides map[string]bool) (Config,
 	if err != nil {
 		return Config{}, err
 	}
+	deliverySplit, err := parseOptionalPercent(v.DeliverySplit)
+	if err != nil {
+		return Config{}, err
+	}
+	deliveryShuffle, err := parseOptionalPercent(v.DeliveryShuffle)
+	if err != nil {
+		return Config{}, err
+	}
+	deliveryDelay, err := parseOptionalPercent(v.DeliveryDelay)
+	if err != nil {
+		return Config{}, err
+	}
+	deliveryDelayDist, err := ParseDistribution(v.DeliveryDelayDist)
+	if err != nil {
+		return Config{}, err
+	}
+	messagingLag, err := ParseDistribution(v.MessagingLag)
+	if err != nil {
+		return Config{}, fmt.Errorf("messaging-lag: %w", err)
+	}
+	thinkTime, err := ParseDistribution(v.ThinkTime)
+	if err != nil {
+		return Config{}, fmt.Errorf("think-time: %w", err)
+	}
+	errorPropagation, err := parseOptionalPercent(v.ErrorPropagation)
+	if err != nil {
+		return Config{}, err
+	}
+	errorShortCircuit, err := parseOptionalPercent(v.ErrorShortCircuit)
+	if err != nil {
+		return Config{}, err
+	}
+	retryJitter, err := parseOptionalPercent(v.RetryJitter)
+	if err != nil {
+		return Config{}, err
+	}
+	retryDefaults := RetryPolicy{MaxAttempts: v.RetryMaxAttempts, Backoff: v.RetryBackoff, Jitter: retryJitter}
+	retryPolicies := make([]RetryPolicy, 0, len(v.RetryPolicies))
+	for _, raw := range v.RetryPolicies {
+		policy, err := ParseRetryPolicy(raw, retryDefaults)
+		if err != nil {
+			return Config{}, err
+		}
+		retryPolicies = append(retryPolicies, policy)
+	}
+	deadlineCancel, err := parseOptionalPercent(v.DeadlineCancel)
+	if err != nil {
+		return Config{}, err
+	}
+	deadlinePolicies := make([]DeadlinePolicy, 0, len(v.DeadlinePolicies))
+	for _, raw := range v.DeadlinePolicies {
+		policy, err := ParseDeadlinePolicy(raw)
+		if err != nil {
+			return Config{}, err
+		}
+		deadlinePolicies = append(deadlinePolicies, policy)
+	}
+	latencyModels := make([]LatencyModel, 0, len(v.LatencyModels))
+	for _, raw := range v.LatencyModels {
+		model, err := ParseLatencyModel(raw)
+		if err != nil {
+			return Config{}, err
+		}
+		latencyModels = append(latencyModels, model)
+	}
+	faults := make([]Fault, 0, len(v.Faults))
+	for _, raw := range v.Faults {
+		fault, err := ParseFault(raw)
+		if err != nil {
+			return Config{}, err
+		}
+		faults = append(faults, fault)
+	}
+	var profileMix ProfileMix
+	if strings.TrimSpace(v.ProfileMix) != "" {
+		profileMix, err = ParseProfileMix(v.ProfileMix)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	tenants := make([]Tenant, 0, len(v.Tenants))
+	for _, raw := range v.Tenants {
+		tenant, err := ParseTenant(raw)
+		if err != nil {
+			return Config{}, err
+		}
+		tenants = append(tenants, tenant)
+	}
+	var authMethod *Auth
+	if strings.TrimSpace(v.Auth) != "" {
+		authMethod, err = ParseAuth(v.Auth)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	fileRotateSize, err := ParseByteSize(v.FileRotateSize)
+	if err != nil {
+		return Config{}, fmt.Errorf("file-rotate-size: %w", err)
+	}
+	grpcMaxMessage, err := ParseByteSize(v.GRPCMaxMessage)
+	if err != nil {
+		return Config{}, fmt.Errorf("grpc-max-message-size: %w", err)
+	}
+	var curve *Curve
+	if strings.TrimSpace(v.Curve) != "" {
+		curve, err = ParseCurve(v.Curve)
+		if err != nil {
+			return Config{}, err
+		}
+	}
 
 	cfg := Config{
 		RateValue:        v.Rate,
@@ -216,6 +511,7 @@ func FromFlagsWithOverrides(v FlagValues, cliOverrides map[string]bool) (Config,
 		Load:             v.Load,
 		Workers:          v.Workers,
 		Profile:          v.Profile,
+		ProfileMix:       profileMix,
 		Routes:           v.Routes,
 		Services:         v.Services,
 		Depth:            v.Depth,
@@ -239,7 +535,7 @@ func FromFlagsWithOverrides(v FlagValues, cliOverrides map[string]bool) (Config,
 		ZipkinEndpoint:   v.ZipkinEndpoint,
 		OTLPInsecure:     v.OTLPInsecure,
 		Headers:          headers,
-		Compress:         v.Compress,
+		Compress:         strings.ToLower(strings.TrimSpace(v.Compress)),
 		BatchSize:        v.BatchSize,
 		FlushInterval:    v.FlushInterval,
 		SinkRetries:      v.SinkRetries,
@@ -249,6 +545,72 @@ func FromFlagsWithOverrides(v FlagValues, cliOverrides map[string]bool) (Config,
 		ReportFile:       v.ReportFile,
 		HTTPListen:       v.HTTPListen,
 		Debug:            v.Debug,
+
+		DeliverySplit:      deliverySplit,
+		DeliveryShuffle:    deliveryShuffle,
+		DeliveryDelay:      deliveryDelay,
+		DeliveryDelayDist:  deliveryDelayDist,
+		WorkflowLifetime:   v.WorkflowLifetime,
+		MessagingMode:      strings.ToLower(strings.TrimSpace(v.MessagingMode)),
+		MessagingLag:       messagingLag,
+		MessagingBatch:     v.MessagingBatch,
+		Users:              v.Users,
+		ThinkTime:          thinkTime,
+		Faults:             faults,
+		ErrorPropagation:   errorPropagation,
+		ErrorShortCircuit:  errorShortCircuit,
+		RetryMaxAttempts:   v.RetryMaxAttempts,
+		RetryBackoff:       v.RetryBackoff,
+		RetryJitter:        retryJitter,
+		RetryPolicies:      retryPolicies,
+		RetryStorm:         v.RetryStorm,
+		Deadline:           v.Deadline,
+		DeadlinePolicies:   deadlinePolicies,
+		DeadlineCancel:     deadlineCancel,
+		LatencyModels:      latencyModels,
+		ProfileLatency:     v.ProfileLatency,
+		Arrival:            strings.ToLower(strings.TrimSpace(v.Arrival)),
+		BurstSize:          v.BurstSize,
+		BurstInterval:      v.BurstInterval,
+		Curve:              curve,
+		Tenants:            tenants,
+		TenantHeader:       strings.TrimSpace(v.TenantHeader),
+		FileRotateSize:     fileRotateSize,
+		FileRotateEvery:    v.FileRotateEvery,
+		FileCompress:       strings.ToLower(strings.TrimSpace(v.FileCompress)),
+		FileMaxFiles:       v.FileMaxFiles,
+		TLSCAFile:          strings.TrimSpace(v.TLSCAFile),
+		TLSCertFile:        strings.TrimSpace(v.TLSCertFile),
+		TLSKeyFile:         strings.TrimSpace(v.TLSKeyFile),
+		TLSServerName:      strings.TrimSpace(v.TLSServerName),
+		TLSMinVersion:      strings.TrimSpace(v.TLSMinVersion),
+		TLSSkipVerify:      v.TLSSkipVerify,
+		Auth:               authMethod,
+		LB:                 strings.ToLower(strings.TrimSpace(v.LB)),
+		LBResolve:          v.LBResolve,
+		Connections:        v.Connections,
+		HTTPVersion:        strings.ToLower(strings.TrimSpace(v.HTTPVersion)),
+		HTTPMaxIdleConns:   v.HTTPMaxIdleConns,
+		HTTPIdleTimeout:    v.HTTPIdleTimeout,
+		GRPCKeepalive:      v.GRPCKeepalive,
+		GRPCKeepaliveWait:  v.GRPCKeepaliveWait,
+		GRPCMaxMessage:     grpcMaxMessage,
+		RateControl:        strings.ToLower(strings.TrimSpace(v.RateControl)),
+		AdaptiveWindow:     v.AdaptiveWindow,
+		AdaptiveStep:       v.AdaptiveStep,
+		AdaptiveBackoff:    v.AdaptiveBackoff,
+		AdaptiveMinRate:    v.AdaptiveMinRate,
+		AdaptiveMaxRate:    v.AdaptiveMaxRate,
+		AdaptiveMaxLatency: v.AdaptiveMaxLatency,
+	}
+	// Sinks take their defaults from the run's sink flags, so they are
+	// parsed once those are in place.
+	for _, raw := range v.Sinks {
+		sink, err := ParseSink(raw, cfg)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.Sinks = append(cfg.Sinks, sink)
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -267,6 +629,12 @@ func mergeFromYAML(v FlagValues, cliOverrides map[string]bool) (FlagValues, erro
 	if err := yaml.Unmarshal(data, &y); err != nil {
 		return FlagValues{}, fmt.Errorf("parse config yaml: %w", err)
 	}
+	return mergeYAMLValues(v, y, cliOverrides)
+}
+
+// mergeYAMLValues sets the values y has over v, except those set on the
+// command line.
+func mergeYAMLValues(v FlagValues, y yamlFlagValues, cliOverrides map[string]bool) (FlagValues, error) {
 	overridden := func(name string) bool {
 		if cliOverrides == nil {
 			return false
@@ -325,6 +693,7 @@ func mergeFromYAML(v FlagValues, cliOverrides map[string]bool) (FlagValues, erro
 	setString("load", y.Load, &v.Load)
 	setInt("workers", y.Workers, &v.Workers)
 	setString("profile", y.Profile, &v.Profile)
+	setString("profile-mix", y.ProfileMix, &v.ProfileMix)
 	setInt("routes", y.Routes, &v.Routes)
 	setInt("services", y.Services, &v.Services)
 	setInt("depth", y.Depth, &v.Depth)
@@ -376,6 +745,95 @@ func mergeFromYAML(v FlagValues, cliOverrides map[string]bool) (FlagValues, erro
 	setString("report-file", y.ReportFile, &v.ReportFile)
 	setString("http-listen", y.HTTPListen, &v.HTTPListen)
 	setBool("debug", y.Debug, &v.Debug)
+	setString("delivery-split", y.DeliverySplit, &v.DeliverySplit)
+	setString("delivery-shuffle", y.DeliveryShuffle, &v.DeliveryShuffle)
+	setString("delivery-delay", y.DeliveryDelay, &v.DeliveryDelay)
+	setString("delivery-delay-dist", y.DeliveryDelayDist, &v.DeliveryDelayDist)
+	if err := setDuration("workflow-lifetime", y.WorkflowLifetime, &v.WorkflowLifetime); err != nil {
+		return FlagValues{}, err
+	}
+	setString("messaging-mode", y.MessagingMode, &v.MessagingMode)
+	setString("messaging-lag", y.MessagingLag, &v.MessagingLag)
+	setInt("messaging-batch", y.MessagingBatch, &v.MessagingBatch)
+	setInt("users", y.Users, &v.Users)
+	setString("think-time", y.ThinkTime, &v.ThinkTime)
+	if len(y.Faults) > 0 && !overridden("fault") {
+		v.Faults = append([]string(nil), y.Faults...)
+	}
+	setString("error-propagation", y.ErrorPropagation, &v.ErrorPropagation)
+	setString("error-short-circuit", y.ErrorShortCircuit, &v.ErrorShortCircuit)
+	setInt("retry-max-attempts", y.RetryMaxAttempts, &v.RetryMaxAttempts)
+	if err := setDuration("retry-backoff", y.RetryBackoff, &v.RetryBackoff); err != nil {
+		return FlagValues{}, err
+	}
+	setString("retry-jitter", y.RetryJitter, &v.RetryJitter)
+	if len(y.RetryPolicies) > 0 && !overridden("retry-policy") {
+		v.RetryPolicies = append([]string(nil), y.RetryPolicies...)
+	}
+	setBool("retry-storm", y.RetryStorm, &v.RetryStorm)
+	if err := setDuration("deadline", y.Deadline, &v.Deadline); err != nil {
+		return FlagValues{}, err
+	}
+	if len(y.DeadlinePolicies) > 0 && !overridden("deadline-policy") {
+		v.DeadlinePolicies = append([]string(nil), y.DeadlinePolicies...)
+	}
+	setString("deadline-cancel", y.DeadlineCancel, &v.DeadlineCancel)
+	setString("arrival", y.Arrival, &v.Arrival)
+	setInt("burst-size", y.BurstSize, &v.BurstSize)
+	if err := setDuration("burst-interval", y.BurstInterval, &v.BurstInterval); err != nil {
+		return FlagValues{}, err
+	}
+	setString("curve", y.Curve, &v.Curve)
+	if len(y.Tenants) > 0 && !overridden("tenant") {
+		v.Tenants = append([]string(nil), y.Tenants...)
+	}
+	setString("tenant-header", y.TenantHeader, &v.TenantHeader)
+	if len(y.Sinks) > 0 && !overridden("sink") {
+		v.Sinks = append([]string(nil), y.Sinks...)
+	}
+	setString("file-rotate-size", y.FileRotateSize, &v.FileRotateSize)
+	if err := setDuration("file-rotate-interval", y.FileRotateEvery, &v.FileRotateEvery); err != nil {
+		return FlagValues{}, err
+	}
+	setString("file-compress", y.FileCompress, &v.FileCompress)
+	setInt("file-max-files", y.FileMaxFiles, &v.FileMaxFiles)
+	setString("tls-ca-file", y.TLSCAFile, &v.TLSCAFile)
+	setString("tls-cert-file", y.TLSCertFile, &v.TLSCertFile)
+	setString("tls-key-file", y.TLSKeyFile, &v.TLSKeyFile)
+	setString("tls-server-name", y.TLSServerName, &v.TLSServerName)
+	setString("tls-min-version", y.TLSMinVersion, &v.TLSMinVersion)
+	setBool("tls-insecure-skip-verify", y.TLSSkipVerify, &v.TLSSkipVerify)
+	setString("auth", y.Auth, &v.Auth)
+	setString("lb", y.LB, &v.LB)
+	setBool("lb-resolve", y.LBResolve, &v.LBResolve)
+	setInt("connections", y.Connections, &v.Connections)
+	setString("http-version", y.HTTPVersion, &v.HTTPVersion)
+	setInt("http-max-idle-conns", y.HTTPMaxIdleConns, &v.HTTPMaxIdleConns)
+	if err := setDuration("http-idle-conn-timeout", y.HTTPIdleTimeout, &v.HTTPIdleTimeout); err != nil {
+		return FlagValues{}, err
+	}
+	if err := setDuration("grpc-keepalive-time", y.GRPCKeepalive, &v.GRPCKeepalive); err != nil {
+		return FlagValues{}, err
+	}
+	if err := setDuration("grpc-keepalive-timeout", y.GRPCKeepaliveWait, &v.GRPCKeepaliveWait); err != nil {
+		return FlagValues{}, err
+	}
+	setString("grpc-max-message-size", y.GRPCMaxMessage, &v.GRPCMaxMessage)
+	setString("rate-control", y.RateControl, &v.RateControl)
+	if err := setDuration("adaptive-window", y.AdaptiveWindow, &v.AdaptiveWindow); err != nil {
+		return FlagValues{}, err
+	}
+	setFloat("adaptive-step", y.AdaptiveStep, &v.AdaptiveStep)
+	setFloat("adaptive-backoff", y.AdaptiveBackoff, &v.AdaptiveBackoff)
+	setFloat("adaptive-min-rate", y.AdaptiveMinRate, &v.AdaptiveMinRate)
+	setFloat("adaptive-max-rate", y.AdaptiveMaxRate, &v.AdaptiveMaxRate)
+	if err := setDuration("adaptive-max-latency", y.AdaptiveMaxLatency, &v.AdaptiveMaxLatency); err != nil {
+		return FlagValues{}, err
+	}
+	if len(y.LatencyModels) > 0 && !overridden("latency-model") {
+		v.LatencyModels = append([]string(nil), y.LatencyModels...)
+	}
+	setBool("profile-latency-models", y.ProfileLatency, &v.ProfileLatency)
 
 	return v, nil
 }
@@ -495,6 +953,7 @@ func mergeFromEnv(v FlagValues, cliOverrides map[string]bool) (FlagValues, error
 		return FlagValues{}, err
 	}
 	setString("profile", "SPANFORGE_PROFILE", &v.Profile)
+	setString("profile-mix", "SPANFORGE_PROFILE_MIX", &v.ProfileMix)
 	if err := setInt("routes", "SPANFORGE_ROUTES", &v.Routes); err != nil {
 		return FlagValues{}, err
 	}
@@ -575,6 +1034,155 @@ func mergeFromEnv(v FlagValues, cliOverrides map[string]bool) (FlagValues, error
 	if err := setBool("debug", "SPANFORGE_DEBUG", &v.Debug); err != nil {
 		return FlagValues{}, err
 	}
+	setString("delivery-split", "SPANFORGE_DELIVERY_SPLIT", &v.DeliverySplit)
+	setString("delivery-shuffle", "SPANFORGE_DELIVERY_SHUFFLE", &v.DeliveryShuffle)
+	setString("delivery-delay", "SPANFORGE_DELIVERY_DELAY", &v.DeliveryDelay)
+	setString("delivery-delay-dist", "SPANFORGE_DELIVERY_DELAY_DIST", &v.DeliveryDelayDist)
+	if err := setDuration("workflow-lifetime", "SPANFORGE_WORKFLOW_LIFETIME", &v.WorkflowLifetime); err != nil {
+		return FlagValues{}, err
+	}
+	setString("messaging-mode", "SPANFORGE_MESSAGING_MODE", &v.MessagingMode)
+	setString("messaging-lag", "SPANFORGE_MESSAGING_LAG", &v.MessagingLag)
+	if err := setInt("messaging-batch", "SPANFORGE_MESSAGING_BATCH", &v.MessagingBatch); err != nil {
+		return FlagValues{}, err
+	}
+	if err := setInt("users", "SPANFORGE_USERS", &v.Users); err != nil {
+		return FlagValues{}, err
+	}
+	setString("think-time", "SPANFORGE_THINK_TIME", &v.ThinkTime)
+	setString("error-propagation", "SPANFORGE_ERROR_PROPAGATION", &v.ErrorPropagation)
+	setString("error-short-circuit", "SPANFORGE_ERROR_SHORT_CIRCUIT", &v.ErrorShortCircuit)
+	if err := setInt("retry-max-attempts", "SPANFORGE_RETRY_MAX_ATTEMPTS", &v.RetryMaxAttempts); err != nil {
+		return FlagValues{}, err
+	}
+	if err := setDuration("retry-backoff", "SPANFORGE_RETRY_BACKOFF", &v.RetryBackoff); err != nil {
+		return FlagValues{}, err
+	}
+	setString("retry-jitter", "SPANFORGE_RETRY_JITTER", &v.RetryJitter)
+	if err := setBool("retry-storm", "SPANFORGE_RETRY_STORM", &v.RetryStorm); err != nil {
+		return FlagValues{}, err
+	}
+	if raw, ok := os.LookupEnv("SPANFORGE_RETRY_POLICIES"); ok && strings.TrimSpace(raw) != "" && !overridden("retry-policy") {
+		v.RetryPolicies = v.RetryPolicies[:0]
+		for _, policy := range strings.Split(raw, ";") {
+			if trimmed := strings.TrimSpace(policy); trimmed != "" {
+				v.RetryPolicies = append(v.RetryPolicies, trimmed)
+			}
+		}
+	}
+	if err := setDuration("deadline", "SPANFORGE_DEADLINE", &v.Deadline); err != nil {
+		return FlagValues{}, err
+	}
+	setString("deadline-cancel", "SPANFORGE_DEADLINE_CANCEL", &v.DeadlineCancel)
+	if raw, ok := os.LookupEnv("SPANFORGE_DEADLINE_POLICIES"); ok && strings.TrimSpace(raw) != "" && !overridden("deadline-policy") {
+		v.DeadlinePolicies = v.DeadlinePolicies[:0]
+		for _, policy := range strings.Split(raw, ";") {
+			if trimmed := strings.TrimSpace(policy); trimmed != "" {
+				v.DeadlinePolicies = append(v.DeadlinePolicies, trimmed)
+			}
+		}
+	}
+	setString("arrival", "SPANFORGE_ARRIVAL", &v.Arrival)
+	if err := setInt("burst-size", "SPANFORGE_BURST_SIZE", &v.BurstSize); err != nil {
+		return FlagValues{}, err
+	}
+	if err := setDuration("burst-interval", "SPANFORGE_BURST_INTERVAL", &v.BurstInterval); err != nil {
+		return FlagValues{}, err
+	}
+	setString("curve", "SPANFORGE_CURVE", &v.Curve)
+	if raw, ok := os.LookupEnv("SPANFORGE_TENANTS"); ok && strings.TrimSpace(raw) != "" && !overridden("tenant") {
+		v.Tenants = v.Tenants[:0]
+		for _, tenant := range strings.Split(raw, ";") {
+			if trimmed := strings.TrimSpace(tenant); trimmed != "" {
+				v.Tenants = append(v.Tenants, trimmed)
+			}
+		}
+	}
+	setString("tenant-header", "SPANFORGE_TENANT_HEADER", &v.TenantHeader)
+	if raw, ok := os.LookupEnv("SPANFORGE_SINKS"); ok && strings.TrimSpace(raw) != "" && !overridden("sink") {
+		v.Sinks = v.Sinks[:0]
+		for _, sink := range strings.Split(raw, ";") {
+			if trimmed := strings.TrimSpace(sink); trimmed != "" {
+				v.Sinks = append(v.Sinks, trimmed)
+			}
+		}
+	}
+	setString("file-rotate-size", "SPANFORGE_FILE_ROTATE_SIZE", &v.FileRotateSize)
+	if err := setDuration("file-rotate-interval", "SPANFORGE_FILE_ROTATE_INTERVAL", &v.FileRotateEvery); err != nil {
+		return FlagValues{}, err
+	}
+	setString("file-compress", "SPANFORGE_FILE_COMPRESS", &v.FileCompress)
+	if err := setInt("file-max-files", "SPANFORGE_FILE_MAX_FILES", &v.FileMaxFiles); err != nil {
+		return FlagValues{}, err
+	}
+	setString("tls-ca-file", "SPANFORGE_TLS_CA_FILE", &v.TLSCAFile)
+	setString("tls-cert-file", "SPANFORGE_TLS_CERT_FILE", &v.TLSCertFile)
+	setString("tls-key-file", "SPANFORGE_TLS_KEY_FILE", &v.TLSKeyFile)
+	setString("tls-server-name", "SPANFORGE_TLS_SERVER_NAME", &v.TLSServerName)
+	setString("tls-min-version", "SPANFORGE_TLS_MIN_VERSION", &v.TLSMinVersion)
+	if err := setBool("tls-insecure-skip-verify", "SPANFORGE_TLS_INSECURE_SKIP_VERIFY", &v.TLSSkipVerify); err != nil {
+		return FlagValues{}, err
+	}
+	setString("auth", "SPANFORGE_AUTH", &v.Auth)
+	setString("lb", "SPANFORGE_LB", &v.LB)
+	if err := setBool("lb-resolve", "SPANFORGE_LB_RESOLVE", &v.LBResolve); err != nil {
+		return FlagValues{}, err
+	}
+	if err := setInt("connections", "SPANFORGE_CONNECTIONS", &v.Connections); err != nil {
+		return FlagValues{}, err
+	}
+	setString("http-version", "SPANFORGE_HTTP_VERSION", &v.HTTPVersion)
+	if err := setInt("http-max-idle-conns", "SPANFORGE_HTTP_MAX_IDLE_CONNS", &v.HTTPMaxIdleConns); err != nil {
+		return FlagValues{}, err
+	}
+	if err := setDuration("http-idle-conn-timeout", "SPANFORGE_HTTP_IDLE_CONN_TIMEOUT", &v.HTTPIdleTimeout); err != nil {
+		return FlagValues{}, err
+	}
+	if err := setDuration("grpc-keepalive-time", "SPANFORGE_GRPC_KEEPALIVE_TIME", &v.GRPCKeepalive); err != nil {
+		return FlagValues{}, err
+	}
+	if err := setDuration("grpc-keepalive-timeout", "SPANFORGE_GRPC_KEEPALIVE_TIMEOUT", &v.GRPCKeepaliveWait); err != nil {
+		return FlagValues{}, err
+	}
+	setString("grpc-max-message-size", "SPANFORGE_GRPC_MAX_MESSAGE_SIZE", &v.GRPCMaxMessage)
+	setString("rate-control", "SPANFORGE_RATE_CONTROL", &v.RateControl)
+	if err := setDuration("adaptive-window", "SPANFORGE_ADAPTIVE_WINDOW", &v.AdaptiveWindow); err != nil {
+		return FlagValues{}, err
+	}
+	if err := setFloat("adaptive-step", "SPANFORGE_ADAPTIVE_STEP", &v.AdaptiveStep); err != nil {
+		return FlagValues{}, err
+	}
+	if err := setFloat("adaptive-backoff", "SPANFORGE_ADAPTIVE_BACKOFF", &v.AdaptiveBackoff); err != nil {
+		return FlagValues{}, err
+	}
+	if err := setFloat("adaptive-min-rate", "SPANFORGE_ADAPTIVE_MIN_RATE", &v.AdaptiveMinRate); err != nil {
+		return FlagValues{}, err
+	}
+	if err := setFloat("adaptive-max-rate", "SPANFORGE_ADAPTIVE_MAX_RATE", &v.AdaptiveMaxRate); err != nil {
+		return FlagValues{}, err
+	}
+	if err := setDuration("adaptive-max-latency", "SPANFORGE_ADAPTIVE_MAX_LATENCY", &v.AdaptiveMaxLatency); err != nil {
+		return FlagValues{}, err
+	}
+	if raw, ok := os.LookupEnv("SPANFORGE_LATENCY_MODELS"); ok && strings.TrimSpace(raw) != "" && !overridden("latency-model") {
+		v.LatencyModels = v.LatencyModels[:0]
+		for _, model := range strings.Split(raw, ";") {
+			if trimmed := strings.TrimSpace(model); trimmed != "" {
+				v.LatencyModels = append(v.LatencyModels, trimmed)
+			}
+		}
+	}
+	if err := setBool("profile-latency-models", "SPANFORGE_PROFILE_LATENCY_MODELS", &v.ProfileLatency); err != nil {
+		return FlagValues{}, err
+	}
+	if raw, ok := os.LookupEnv("SPANFORGE_FAULTS"); ok && strings.TrimSpace(raw) != "" && !overridden("fault") {
+		v.Faults = v.Faults[:0]
+		for _, fault := range strings.Split(raw, ";") {
+			if trimmed := strings.TrimSpace(fault); trimmed != "" {
+				v.Faults = append(v.Faults, trimmed)
+			}
+		}
+	}
 
 	return v, nil
 }
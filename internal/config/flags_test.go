@@ -297,3 +297,47 @@ func TestFromFlagsEnvDebug(t *testing.T) {
 		t.Fatal("expected debug=true from env")
 	}
 }
+
+func TestFromFlagsEnvFaults(t *testing.T) {
+	t.Setenv("SPANFORGE_FAULTS", "service=payment-service,status=503;service=ledger-service,timeout=5s")
+	flags := FlagValues{
+		Rate:             200,
+		RateUnit:         "spans",
+		RateInterval:     time.Second,
+		Duration:         30 * time.Second,
+		Workers:          1,
+		Profile:          "web",
+		Routes:           8,
+		Services:         5,
+		Depth:            4,
+		Fanout:           2,
+		ServicePrefix:    "svc-",
+		P50:              30 * time.Millisecond,
+		P95:              120 * time.Millisecond,
+		P99:              350 * time.Millisecond,
+		Errors:           "0.5%",
+		Retries:          "1%",
+		DBHeavy:          "20%",
+		CacheHitRate:     "85%",
+		Variety:          "medium",
+		Format:           "jsonl",
+		Output:           "stdout",
+		BatchSize:        512,
+		FlushInterval:    200 * time.Millisecond,
+		SinkRetries:      2,
+		SinkRetryBackoff: 300 * time.Millisecond,
+		SinkTimeout:      10 * time.Second,
+		SinkMaxInFlight:  2,
+	}
+
+	cfg, err := FromFlagsWithOverrides(flags, nil)
+	if err != nil {
+		t.Fatalf("FromFlagsWithOverrides: %v", err)
+	}
+	if len(cfg.Faults) != 2 {
+		t.Fatalf("faults=%+v want 2", cfg.Faults)
+	}
+	if cfg.Faults[0].Status != 503 || cfg.Faults[1].Timeout != 5*time.Second {
+		t.Fatalf("faults=%+v", cfg.Faults)
+	}
+}
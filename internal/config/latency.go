@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Latency model kinds.
+const (
+	LatencyLognormal = "lognormal"
+	LatencyBimodal   = "bimodal"
+	LatencyPareto    = "pareto"
+	LatencyFixed     = "fixed"
+)
+
+// LatencyModel sets the latency distribution of one service or operation.
+// Percentiles left at zero keep the shape of --p50, --p95 and --p99, scaled
+// to the percentiles that are set.
+type LatencyModel struct {
+	Service   string
+	Operation string
+	Kind      string
+	P50       time.Duration
+	P95       time.Duration
+	P99       time.Duration
+}
+
+// ParseLatencyModel parses a CLI latency model such as
+// "operation=authorize payment,model=pareto,p50=80ms,p95=400ms,p99=2s".
+func ParseLatencyModel(raw string) (LatencyModel, error) {
+	m := LatencyModel{Kind: LatencyLognormal}
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return LatencyModel{}, fmt.Errorf("invalid latency model %q: expected key=value, got %q", raw, part)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		var err error
+		switch key {
+		case "service":
+			m.Service = value
+		case "operation", "op":
+			m.Operation = value
+		case "model", "kind":
+			m.Kind = strings.ToLower(value)
+		case "p50":
+			m.P50, err = time.ParseDuration(value)
+		case "p95":
+			m.P95, err = time.ParseDuration(value)
+		case "p99":
+			m.P99, err = time.ParseDuration(value)
+		default:
+			return LatencyModel{}, fmt.Errorf("invalid latency model %q: unknown key %q (must be service, operation, model, p50, p95, or p99)", raw, key)
+		}
+		if err != nil {
+			return LatencyModel{}, fmt.Errorf("invalid latency model %q: bad %s %q", raw, key, value)
+		}
+	}
+	if err := m.Validate(); err != nil {
+		return LatencyModel{}, fmt.Errorf("invalid latency model %q: %w", raw, err)
+	}
+	return m, nil
+}
+
+func (m LatencyModel) Validate() error {
+	if m.Service == "" && m.Operation == "" {
+		return fmt.Errorf("latency model needs a service or operation")
+	}
+	switch m.Kind {
+	case LatencyLognormal, LatencyBimodal, LatencyPareto, LatencyFixed:
+	default:
+		return fmt.Errorf("latency model must be one of lognormal, bimodal, pareto, fixed")
+	}
+	if m.P50 < 0 || m.P95 < 0 || m.P99 < 0 {
+		return fmt.Errorf("latency percentiles must be >= 0")
+	}
+	if (m.P50 > 0 && m.P95 > 0 && m.P50 > m.P95) || (m.P95 > 0 && m.P99 > 0 && m.P95 > m.P99) || (m.P50 > 0 && m.P99 > 0 && m.P50 > m.P99) {
+		return fmt.Errorf("latency percentiles must satisfy p50 <= p95 <= p99")
+	}
+	return nil
+}
+
+// Fill returns m with missing percentiles taken from the p50, p95 and p99
+// shape, scaled by the first percentile m sets.
+func (m LatencyModel) Fill(p50, p95, p99 time.Duration) LatencyModel {
+	scale := 1.0
+	switch {
+	case m.P50 > 0 && p50 > 0:
+		scale = float64(m.P50) / float64(p50)
+	case m.P95 > 0 && p95 > 0:
+		scale = float64(m.P95) / float64(p95)
+	case m.P99 > 0 && p99 > 0:
+		scale = float64(m.P99) / float64(p99)
+	}
+	if m.P50 == 0 {
+		m.P50 = time.Duration(float64(p50) * scale)
+	}
+	if m.P95 == 0 {
+		m.P95 = maxDuration(m.P50, time.Duration(float64(p95)*scale))
+	}
+	if m.P99 == 0 {
+		m.P99 = maxDuration(m.P95, time.Duration(float64(p99)*scale))
+	}
+	return m
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
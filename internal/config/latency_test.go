@@ -0,0 +1,31 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLatencyModel(t *testing.T) {
+	got, err := ParseLatencyModel("operation=authorize payment, model=pareto, p50=80ms, p95=400ms, p99=2s")
+	if err != nil {
+		t.Fatalf("ParseLatencyModel: %v", err)
+	}
+	want := LatencyModel{Operation: "authorize payment", Kind: LatencyPareto, P50: 80 * time.Millisecond, P95: 400 * time.Millisecond, P99: 2 * time.Second}
+	if got != want {
+		t.Fatalf("model=%+v want %+v", got, want)
+	}
+
+	for _, raw := range []string{"model=fixed", "service=a,model=gamma", "service=a,p50=1s,p95=100ms", "service=a,colour=red"} {
+		if _, err := ParseLatencyModel(raw); err == nil {
+			t.Fatalf("ParseLatencyModel(%q) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestLatencyModelFillKeepsShape(t *testing.T) {
+	m := LatencyModel{Service: "a", Kind: LatencyLognormal, P50: 60 * time.Millisecond}
+	got := m.Fill(30*time.Millisecond, 120*time.Millisecond, 350*time.Millisecond)
+	if got.P95 != 240*time.Millisecond || got.P99 != 700*time.Millisecond {
+		t.Fatalf("filled=%+v want p95 240ms and p99 700ms", got)
+	}
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// profiles lists the generation profiles in the order help text gives them.
+var profiles = []string{"web", "grpc", "queue", "batch", "payment-system", "api-gateway", "workflow"}
+
+// ProfileShare is one profile in a --profile-mix. Weights are relative, so
+// web=3,grpc=1 and web=75,grpc=25 give the same mix.
+type ProfileShare struct {
+	Profile string
+	Weight  float64
+}
+
+// ProfileMix runs several profiles at once, picking each trace's profile by
+// weight.
+type ProfileMix []ProfileShare
+
+// ParseProfileMix parses a CLI mix such as "web=60,grpc=25,queue=10,batch=5".
+func ParseProfileMix(raw string) (ProfileMix, error) {
+	var mix ProfileMix
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid profile mix %q: expected profile=weight, got %q", raw, part)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid profile mix %q: bad weight %q for %s", raw, value, strings.TrimSpace(name))
+		}
+		mix = append(mix, ProfileShare{Profile: strings.ToLower(strings.TrimSpace(name)), Weight: weight})
+	}
+	if err := mix.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid profile mix %q: %w", raw, err)
+	}
+	return mix, nil
+}
+
+func (m ProfileMix) Validate() error {
+	if len(m) == 0 {
+		return fmt.Errorf("profile mix needs at least one profile")
+	}
+	seen := map[string]bool{}
+	for _, share := range m {
+		if err := validateProfile(share.Profile); err != nil {
+			return err
+		}
+		if seen[share.Profile] {
+			return fmt.Errorf("profile %s appears twice in the mix", share.Profile)
+		}
+		seen[share.Profile] = true
+		if share.Weight <= 0 {
+			return fmt.Errorf("profile %s needs a weight > 0", share.Profile)
+		}
+	}
+	return nil
+}
+
+// Share returns profile i's share of traces, between 0 and 1.
+func (m ProfileMix) Share(i int) float64 {
+	total := 0.0
+	for _, share := range m {
+		total += share.Weight
+	}
+	return m[i].Weight / total
+}
+
+// String formats the mix as ParseProfileMix accepts it.
+func (m ProfileMix) String() string {
+	parts := make([]string, len(m))
+	for i, share := range m {
+		parts[i] = share.Profile + "=" + strconv.FormatFloat(share.Weight, 'f', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Profiles returns the profile names in mix order.
+func (m ProfileMix) Profiles() []string {
+	out := make([]string, len(m))
+	for i, share := range m {
+		out[i] = share.Profile
+	}
+	return out
+}
+
+// Profiles returns the profiles a run generates: every profile in the mix,
+// or Profile alone.
+func (c Config) Profiles() []string {
+	if len(c.ProfileMix) == 0 {
+		return []string{c.Profile}
+	}
+	return c.ProfileMix.Profiles()
+}
+
+func validateProfile(profile string) error {
+	for _, p := range profiles {
+		if p == profile {
+			return nil
+		}
+	}
+	return fmt.Errorf("profile must be one of %s", strings.Join(profiles, ", "))
+}
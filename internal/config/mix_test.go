@@ -0,0 +1,93 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseProfileMix(t *testing.T) {
+	mix, err := ParseProfileMix("web=60, grpc=25, Queue=10, batch=5%")
+	if err != nil {
+		t.Fatalf("ParseProfileMix: %v", err)
+	}
+	if len(mix) != 4 || mix[2].Profile != "queue" || mix[3].Weight != 5 {
+		t.Fatalf("mix=%+v", mix)
+	}
+	if got := mix.Share(0); got != 0.6 {
+		t.Fatalf("web share=%.3f want 0.6", got)
+	}
+	if got := mix.String(); got != "web=60,grpc=25,queue=10,batch=5" {
+		t.Fatalf("String()=%q", got)
+	}
+
+	for _, raw := range []string{"", "web", "web=0", "web=-1", "web=x", "web=1,web=2", "mainframe=10"} {
+		if _, err := ParseProfileMix(raw); err == nil {
+			t.Fatalf("ParseProfileMix(%q) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestUsersNeedsSessionProfilesInMix(t *testing.T) {
+	cfg := Config{
+		RateValue:        1,
+		RateUnit:         RateUnitSpans,
+		RateInterval:     1,
+		Duration:         1,
+		Workers:          1,
+		Profile:          "web",
+		ProfileMix:       ProfileMix{{Profile: "web", Weight: 1}, {Profile: "grpc", Weight: 1}},
+		Routes:           1,
+		Services:         1,
+		Depth:            1,
+		Fanout:           1,
+		P50:              1,
+		P95:              2,
+		P99:              3,
+		CacheHitRate:     1,
+		Variety:          "medium",
+		Format:           "jsonl",
+		Output:           "stdout",
+		BatchSize:        1,
+		FlushInterval:    1,
+		SinkRetryBackoff: 1,
+		SinkTimeout:      1,
+		SinkMaxInFlight:  1,
+		Users:            10,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	cfg.ProfileMix = append(cfg.ProfileMix, ProfileShare{Profile: "batch", Weight: 1})
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("Validate succeeded with users and a batch profile in the mix")
+	}
+}
+
+func TestOverrideProfileReplacesMix(t *testing.T) {
+	cfg := Config{Profile: "web", ProfileMix: ProfileMix{{Profile: "web", Weight: 1}, {Profile: "grpc", Weight: 1}}}
+	for _, tc := range []struct {
+		src  string
+		want string
+	}{
+		{"profile: batch", ""},
+		{"profile_mix: queue=1,batch=3", "queue=1,batch=3"},
+		{"profile_mix: ''", ""},
+	} {
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(tc.src), &node); err != nil {
+			t.Fatal(err)
+		}
+		o, err := ParseOverrides(node.Content[0])
+		if err != nil {
+			t.Fatalf("ParseOverrides(%q): %v", tc.src, err)
+		}
+		got, err := o.Apply(cfg)
+		if err != nil {
+			t.Fatalf("Apply(%q): %v", tc.src, err)
+		}
+		if got.ProfileMix.String() != tc.want {
+			t.Fatalf("%q: mix=%q want %q", tc.src, got.ProfileMix.String(), tc.want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// runSettings are YAML config keys that hold for the whole run, because
+// they pick the run's length, output or delivery. Overrides cannot set them.
+var runSettings = map[string]bool{
+	"phase_file": true, "load": true, "curve": true, "duration": true, "count": true,
+	"seed": true, "run_id": true, "format": true, "output": true, "file": true,
+	"otlp_endpoint": true, "zipkin_endpoint": true, "otlp_insecure": true, "compress": true,
+	"batch_size": true, "flush_interval": true, "sink_retries": true, "sink_retry_backoff": true,
+	"sink_timeout": true, "sink_max_in_flight": true, "report_file": true, "http_listen": true,
+	"debug": true, "delivery_split": true, "delivery_shuffle": true, "delivery_delay": true,
+	"delivery_delay_dist": true, "tenants": true, "tenant_header": true, "sinks": true,
+	"file_rotate_size": true, "file_rotate_interval": true, "file_compress": true, "file_max_files": true,
+	"tls_ca_file": true, "tls_cert_file": true, "tls_key_file": true, "tls_server_name": true,
+	"tls_min_version": true, "tls_insecure_skip_verify": true, "auth": true,
+	"lb": true, "lb_resolve": true, "connections": true, "http_version": true, "http_max_idle_conns": true,
+	"http_idle_conn_timeout": true, "grpc_keepalive_time": true, "grpc_keepalive_timeout": true,
+	"grpc_max_message_size": true, "rate_control": true, "adaptive_window": true, "adaptive_step": true,
+	"adaptive_backoff": true, "adaptive_min_rate": true, "adaptive_max_rate": true, "adaptive_max_latency": true,
+}
+
+// Overrides replace config settings for part of a run, such as one load
+// phase. Keys are the same as in the YAML config file. Headers add to the
+// run's headers; every other setting replaces the run's value.
+type Overrides struct {
+	values yamlFlagValues
+}
+
+// ParseOverrides reads overrides from a YAML mapping. Errors give the line
+// of the bad entry.
+func ParseOverrides(node *yaml.Node) (Overrides, error) {
+	if node == nil || len(node.Content) == 0 {
+		return Overrides{}, nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return Overrides{}, fmt.Errorf("line %d: settings must be a mapping", node.Line)
+	}
+	known := yamlKeys()
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch {
+		case runSettings[key.Value]:
+			return Overrides{}, fmt.Errorf("line %d: %s cannot change during a run", key.Line, key.Value)
+		case !known[key.Value]:
+			return Overrides{}, fmt.Errorf("line %d: unknown setting %q", key.Line, key.Value)
+		}
+		var one yamlFlagValues
+		entry := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{key, value}}
+		if err := entry.Decode(&one); err != nil {
+			return Overrides{}, fmt.Errorf("line %d: invalid %s: %w", key.Line, key.Value, err)
+		}
+		if _, err := (Overrides{values: one}).Apply(Config{}); err != nil {
+			return Overrides{}, fmt.Errorf("line %d: %w", key.Line, err)
+		}
+	}
+	var o Overrides
+	if err := node.Decode(&o.values); err != nil {
+		return Overrides{}, err
+	}
+	return o, nil
+}
+
+// yamlKeys returns every key the YAML config file accepts.
+func yamlKeys() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(yamlFlagValues{})
+	for i := 0; i < t.NumField(); i++ {
+		keys[t.Field(i).Tag.Get("yaml")] = true
+	}
+	return keys
+}
+
+// Apply returns cfg with the overrides set. It parses values as FromFlags
+// does, so scalar settings apply before the policies built from them.
+func (o Overrides) Apply(cfg Config) (Config, error) {
+	y := o.values
+	var err error
+	percent := func(name string, src *string, dst *float64) {
+		if src != nil && err == nil {
+			if *dst, err = parseOptionalPercent(*src); err != nil {
+				err = fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+	}
+	duration := func(name string, src *string, dst *time.Duration) {
+		if src != nil && err == nil {
+			if *dst, err = time.ParseDuration(*src); err != nil {
+				err = fmt.Errorf("invalid %s duration %q", name, *src)
+			}
+		}
+	}
+	distribution := func(name string, src *string, dst *Distribution) {
+		if src != nil && err == nil {
+			if *dst, err = ParseDistribution(*src); err != nil {
+				err = fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	setInt := func(src *int, dst *int) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	setString := func(src *string, dst *string) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+
+	if y.Rate != nil {
+		cfg.RateValue = *y.Rate
+	}
+	if y.RateUnit != nil && err == nil {
+		cfg.RateUnit, err = ParseRateUnit(*y.RateUnit)
+	}
+	duration("rate_interval", y.RateInterval, &cfg.RateInterval)
+	setInt(y.Workers, &cfg.Workers)
+	if y.Profile != nil {
+		// A profile replaces the run's mix unless profile_mix is also set.
+		cfg.Profile = *y.Profile
+		cfg.ProfileMix = nil
+	}
+	if y.ProfileMix != nil && err == nil {
+		cfg.ProfileMix = nil
+		if strings.TrimSpace(*y.ProfileMix) != "" {
+			cfg.ProfileMix, err = ParseProfileMix(*y.ProfileMix)
+		}
+	}
+	setInt(y.Routes, &cfg.Routes)
+	setInt(y.Services, &cfg.Services)
+	setInt(y.Depth, &cfg.Depth)
+	if y.Fanout != nil {
+		cfg.Fanout = *y.Fanout
+	}
+	setString(y.ServicePrefix, &cfg.ServicePrefix)
+	duration("p50", y.P50, &cfg.P50)
+	duration("p95", y.P95, &cfg.P95)
+	duration("p99", y.P99, &cfg.P99)
+	percent("errors", y.Errors, &cfg.Errors)
+	percent("retries", y.Retries, &cfg.Retries)
+	percent("db_heavy", y.DBHeavy, &cfg.DBHeavy)
+	percent("cache_hit_rate", y.CacheHitRate, &cfg.CacheHitRate)
+	setString(y.Variety, &cfg.Variety)
+	if y.HighCardinality != nil {
+		cfg.HighCardinality = *y.HighCardinality
+	}
+	if y.Weird != nil {
+		cfg.Weird = normalizeModes(y.Weird)
+	}
+	if y.Invalid != nil {
+		cfg.Invalid = normalizeModes(y.Invalid)
+	}
+	if len(y.Headers) > 0 && err == nil {
+		var headers map[string]string
+		if headers, err = ParseHeaders(y.Headers); err == nil {
+			merged := make(map[string]string, len(cfg.Headers)+len(headers))
+			for k, v := range cfg.Headers {
+				merged[k] = v
+			}
+			for k, v := range headers {
+				merged[k] = v
+			}
+			cfg.Headers = merged
+		}
+	}
+	duration("workflow_lifetime", y.WorkflowLifetime, &cfg.WorkflowLifetime)
+	if y.MessagingMode != nil {
+		cfg.MessagingMode = strings.ToLower(strings.TrimSpace(*y.MessagingMode))
+	}
+	distribution("messaging_lag", y.MessagingLag, &cfg.MessagingLag)
+	setInt(y.MessagingBatch, &cfg.MessagingBatch)
+	setInt(y.Users, &cfg.Users)
+	distribution("think_time", y.ThinkTime, &cfg.ThinkTime)
+	percent("error_propagation", y.ErrorPropagation, &cfg.ErrorPropagation)
+	percent("error_short_circuit", y.ErrorShortCircuit, &cfg.ErrorShortCircuit)
+	setInt(y.RetryMaxAttempts, &cfg.RetryMaxAttempts)
+	duration("retry_backoff", y.RetryBackoff, &cfg.RetryBackoff)
+	percent("retry_jitter", y.RetryJitter, &cfg.RetryJitter)
+	if y.RetryStorm != nil {
+		cfg.RetryStorm = *y.RetryStorm
+	}
+	duration("deadline", y.Deadline, &cfg.Deadline)
+	percent("deadline_cancel", y.DeadlineCancel, &cfg.DeadlineCancel)
+	if y.Arrival != nil {
+		cfg.Arrival = strings.ToLower(strings.TrimSpace(*y.Arrival))
+	}
+	setInt(y.BurstSize, &cfg.BurstSize)
+	duration("burst_interval", y.BurstInterval, &cfg.BurstInterval)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if y.Faults != nil {
+		cfg.Faults = make([]Fault, 0, len(y.Faults))
+		for _, raw := range y.Faults {
+			fault, err := ParseFault(raw)
+			if err != nil {
+				return Config{}, err
+			}
+			cfg.Faults = append(cfg.Faults, fault)
+		}
+	}
+	if y.RetryPolicies != nil {
+		cfg.RetryPolicies = make([]RetryPolicy, 0, len(y.RetryPolicies))
+		for _, raw := range y.RetryPolicies {
+			policy, err := ParseRetryPolicy(raw, cfg.DefaultRetryPolicy())
+			if err != nil {
+				return Config{}, err
+			}
+			cfg.RetryPolicies = append(cfg.RetryPolicies, policy)
+		}
+	}
+	if y.DeadlinePolicies != nil {
+		cfg.DeadlinePolicies = make([]DeadlinePolicy, 0, len(y.DeadlinePolicies))
+		for _, raw := range y.DeadlinePolicies {
+			policy, err := ParseDeadlinePolicy(raw)
+			if err != nil {
+				return Config{}, err
+			}
+			cfg.DeadlinePolicies = append(cfg.DeadlinePolicies, policy)
+		}
+	}
+	if y.ProfileLatency != nil {
+		cfg.ProfileLatency = *y.ProfileLatency
+	}
+	if y.LatencyModels != nil {
+		cfg.LatencyModels = make([]LatencyModel, 0, len(y.LatencyModels))
+		for _, raw := range y.LatencyModels {
+			model, err := ParseLatencyModel(raw)
+			if err != nil {
+				return Config{}, err
+			}
+			cfg.LatencyModels = append(cfg.LatencyModels, model)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func parseOverridesYAML(t *testing.T, text string) (Overrides, error) {
+	t.Helper()
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return ParseOverrides(doc.Content[0])
+}
+
+func TestOverridesApplyConfigSettings(t *testing.T) {
+	o, err := parseOverridesYAML(t, `
+profile: payment-system
+depth: 6
+fanout: 3.5
+services: 12
+weird: [future-timestamp]
+invalid: []
+headers: ["x-tenant=beta"]
+retry_max_attempts: 5
+retry_policies: ["service=payment-service,backoff=1s"]
+`)
+	if err != nil {
+		t.Fatalf("ParseOverrides: %v", err)
+	}
+	base := Config{
+		Profile:          "web",
+		Depth:            4,
+		Invalid:          []string{"negative-duration"},
+		Headers:          map[string]string{"authorization": "Bearer a", "x-tenant": "alpha"},
+		RetryMaxAttempts: 3,
+	}
+	got, err := o.Apply(base)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got.Profile != "payment-system" || got.Depth != 6 || got.Fanout != 3.5 || got.Services != 12 {
+		t.Fatalf("profile=%s depth=%d fanout=%.1f services=%d", got.Profile, got.Depth, got.Fanout, got.Services)
+	}
+	if len(got.Weird) != 1 || got.Weird[0] != "future-timestamp" || len(got.Invalid) != 0 {
+		t.Fatalf("weird=%v invalid=%v", got.Weird, got.Invalid)
+	}
+	if got.Headers["authorization"] != "Bearer a" || got.Headers["x-tenant"] != "beta" || base.Headers["x-tenant"] != "alpha" {
+		t.Fatalf("headers=%v base=%v want beta merged over a copy", got.Headers, base.Headers)
+	}
+	if len(got.RetryPolicies) != 1 || got.RetryPolicies[0].MaxAttempts != 5 {
+		t.Fatalf("retry policies=%+v want the phase's default attempts", got.RetryPolicies)
+	}
+}
+
+func TestParseOverridesReportsLines(t *testing.T) {
+	cases := map[string]string{
+		"profile: web\ncolour: red\n":       "line 2: unknown setting \"colour\"",
+		"depth: 3\n\noutput: otlp\n":        "line 3: output cannot change during a run",
+		"errors: 5%\ndb_heavy: lots\n":      "line 2: invalid db_heavy",
+		"depth: deep\n":                     "line 1: invalid depth",
+		"latency_models: [\"model=odd\"]\n": "line 1:",
+	}
+	for text, want := range cases {
+		_, err := parseOverridesYAML(t, text)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("ParseOverrides(%q) err=%v want %q", text, err, want)
+		}
+	}
+}
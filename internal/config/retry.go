@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how callers retry a failed call. MaxAttempts counts
+// the first call, so 1 disables retries. Each retry waits Backoff doubled per
+// attempt, shortened by up to Jitter of itself. A policy with a Service
+// applies to calls into that service; one with a Profile applies while that
+// profile runs. Policies without either are ignored.
+type RetryPolicy struct {
+	Service     string
+	Profile     string
+	MaxAttempts int
+	Backoff     time.Duration
+	Jitter      float64
+}
+
+// ParseRetryPolicy parses a CLI retry policy such as
+// "service=payment-service,attempts=5,backoff=200ms,jitter=20%". Keys left
+// out keep their value from defaults.
+func ParseRetryPolicy(raw string, defaults RetryPolicy) (RetryPolicy, error) {
+	p := defaults
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return RetryPolicy{}, fmt.Errorf("invalid retry policy %q: expected key=value, got %q", raw, part)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		var err error
+		switch key {
+		case "service":
+			p.Service = value
+		case "profile":
+			p.Profile = strings.ToLower(value)
+		case "attempts", "max_attempts":
+			p.MaxAttempts, err = strconv.Atoi(value)
+		case "backoff":
+			p.Backoff, err = time.ParseDuration(value)
+		case "jitter":
+			p.Jitter, err = ParsePercent(value)
+		default:
+			return RetryPolicy{}, fmt.Errorf("invalid retry policy %q: unknown key %q (must be service, profile, attempts, backoff, or jitter)", raw, key)
+		}
+		if err != nil {
+			return RetryPolicy{}, fmt.Errorf("invalid retry policy %q: bad %s %q", raw, key, value)
+		}
+	}
+	if p.Service == "" && p.Profile == "" {
+		return RetryPolicy{}, fmt.Errorf("invalid retry policy %q: needs a service or profile", raw)
+	}
+	if err := p.Validate(); err != nil {
+		return RetryPolicy{}, fmt.Errorf("invalid retry policy %q: %w", raw, err)
+	}
+	return p, nil
+}
+
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 {
+		return fmt.Errorf("retry attempts must be >= 0")
+	}
+	if p.Backoff < 0 {
+		return fmt.Errorf("retry backoff must be >= 0")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("retry jitter must be in [0,1]")
+	}
+	return nil
+}
+
+// Delay returns the wait before retry number attempt (1 for the first retry).
+// uniform must return values in [0,1).
+func (p RetryPolicy) Delay(attempt int, uniform func() float64) time.Duration {
+	d := float64(p.Backoff)
+	for i := 1; i < attempt; i++ {
+		d *= 2
+	}
+	return time.Duration(d * (1 - p.Jitter*uniform()))
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRetryPolicy(t *testing.T) {
+	defaults := RetryPolicy{MaxAttempts: 3, Backoff: 100 * time.Millisecond, Jitter: 0.5}
+	got, err := ParseRetryPolicy("service=payment-service, attempts=5, jitter=20%", defaults)
+	if err != nil {
+		t.Fatalf("ParseRetryPolicy: %v", err)
+	}
+	want := RetryPolicy{Service: "payment-service", MaxAttempts: 5, Backoff: 100 * time.Millisecond, Jitter: 0.2}
+	if got != want {
+		t.Fatalf("policy=%+v want %+v", got, want)
+	}
+
+	for _, raw := range []string{"attempts=2", "service=a,attempts=-1", "service=a,jitter=150%", "service=a,colour=red"} {
+		if _, err := ParseRetryPolicy(raw, defaults); err == nil {
+			t.Fatalf("ParseRetryPolicy(%q) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestRetryPolicyDelayDoublesWithinJitter(t *testing.T) {
+	p := RetryPolicy{Backoff: 100 * time.Millisecond, Jitter: 0.5}
+	for attempt, base := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond} {
+		if got := p.Delay(attempt, func() float64 { return 0 }); got != base {
+			t.Fatalf("attempt %d delay=%s want %s", attempt, got, base)
+		}
+		if got := p.Delay(attempt, func() float64 { return 0.999 }); got < base/2 || got >= base {
+			t.Fatalf("attempt %d jittered delay=%s want in [%s,%s)", attempt, got, base/2, base)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Rollout moves one service from version From to version To during a load
+// phase. The share of that service's spans served by To ramps linearly from
+// CanaryStart to CanaryEnd across the phase window. Spans served by To are
+// scaled by Latency and fail at the Errors rate.
+type Rollout struct {
+	Service     string
+	From        string
+	To          string
+	CanaryStart float64
+	CanaryEnd   float64
+	Latency     float64
+	Errors      float64
+	Start       time.Time
+	Window      time.Duration
+}
+
+func (r Rollout) Validate() error {
+	if r.Service == "" || r.From == "" || r.To == "" {
+		return fmt.Errorf("deployment needs service, from and to")
+	}
+	if r.From == r.To {
+		return fmt.Errorf("deployment from and to must differ")
+	}
+	if r.CanaryStart < 0 || r.CanaryStart > 1 || r.CanaryEnd < 0 || r.CanaryEnd > 1 {
+		return fmt.Errorf("deployment canary_start/canary_end must be in [0,1]")
+	}
+	if r.Latency < 0 {
+		return fmt.Errorf("deployment latency must be >= 0")
+	}
+	if r.Errors < 0 || r.Errors > 1 {
+		return fmt.Errorf("deployment errors must be in [0,1]")
+	}
+	return nil
+}
+
+// CanaryShare returns the share of spans served by the new version at at.
+func (r Rollout) CanaryShare(at time.Time) float64 {
+	if r.Window <= 0 {
+		return r.CanaryEnd
+	}
+	progress := float64(at.Sub(r.Start)) / float64(r.Window)
+	if progress < 0 {
+		progress = 0
+	}
+	if progress > 1 {
+		progress = 1
+	}
+	return r.CanaryStart + (r.CanaryEnd-r.CanaryStart)*progress
+}
@@ -0,0 +1,308 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sink error policies for --sink on-error.
+const (
+	// SinkOnErrorFail stops the run at the sink's first failed write or send.
+	SinkOnErrorFail = "fail"
+	// SinkOnErrorContinue counts the sink's failures and keeps the run going.
+	SinkOnErrorContinue = "continue"
+)
+
+// Sink is one output of a run: a format written to an output. Every sink
+// gets every trace and batches, retries and sends it on its own. Endpoint is
+// the OTLP or Zipkin endpoint, by Format, or several separated by commas.
+type Sink struct {
+	Name          string
+	Format        string
+	Output        string
+	File          string
+	Endpoint      string
+	BatchSize     int
+	FlushInterval time.Duration
+	Retries       int
+	RetryBackoff  time.Duration
+	Timeout       time.Duration
+	MaxInFlight   int
+	OnError       string
+	Compress      string
+}
+
+// ParseSink parses a CLI sink such as
+// "name=tempo,format=otlp-http,endpoint=http://tempo:4318,batch-size=1000,on-error=continue".
+// Keys left out keep the run's value: its batching, sink and compress flags, and its
+// --otlp-endpoint or --zipkin-endpoint for the sink's format. The output
+// follows from the format, or is file when file is set. The name defaults to
+// the format.
+func ParseSink(raw string, run Config) (Sink, error) {
+	s := Sink{
+		BatchSize:     run.BatchSize,
+		FlushInterval: run.FlushInterval,
+		Retries:       run.SinkRetries,
+		RetryBackoff:  run.SinkRetryBackoff,
+		Timeout:       run.SinkTimeout,
+		MaxInFlight:   run.SinkMaxInFlight,
+		OnError:       SinkOnErrorFail,
+		Compress:      run.Compress,
+	}
+	endpointSet := false
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Sink{}, fmt.Errorf("invalid sink %q: expected key=value, got %q", raw, part)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		var err error
+		switch key {
+		case "name":
+			s.Name = value
+		case "format":
+			s.Format = strings.ToLower(value)
+		case "output":
+			s.Output = strings.ToLower(value)
+		case "file":
+			s.File = value
+		case "endpoint":
+			// endpoint can repeat to balance across several.
+			if endpointSet && value != "" {
+				s.Endpoint += "," + value
+			} else {
+				s.Endpoint = value
+			}
+			endpointSet = true
+		case "batch-size":
+			s.BatchSize, err = strconv.Atoi(value)
+		case "flush-interval":
+			s.FlushInterval, err = time.ParseDuration(value)
+		case "retries":
+			s.Retries, err = strconv.Atoi(value)
+		case "retry-backoff":
+			s.RetryBackoff, err = time.ParseDuration(value)
+		case "timeout":
+			s.Timeout, err = time.ParseDuration(value)
+		case "max-in-flight":
+			s.MaxInFlight, err = strconv.Atoi(value)
+		case "on-error":
+			s.OnError = strings.ToLower(value)
+		case "compress":
+			s.Compress = strings.ToLower(value)
+		default:
+			return Sink{}, fmt.Errorf("invalid sink %q: unknown key %q (must be name, format, output, file, endpoint, batch-size, flush-interval, retries, retry-backoff, timeout, max-in-flight, on-error, or compress)", raw, key)
+		}
+		if err != nil {
+			return Sink{}, fmt.Errorf("invalid sink %q: bad %s %q", raw, key, value)
+		}
+	}
+	if s.Format == "" {
+		return Sink{}, fmt.Errorf("invalid sink %q: needs a format", raw)
+	}
+	if s.Output == "" {
+		s.Output = defaultSinkOutput(s.Format, s.File)
+	}
+	if !endpointSet {
+		s.Endpoint = run.endpointFor(s.Format)
+	}
+	if s.Name == "" {
+		s.Name = s.Format
+	}
+	if err := s.Validate(); err != nil {
+		return Sink{}, fmt.Errorf("invalid sink %q: %w", raw, err)
+	}
+	return s, nil
+}
+
+func defaultSinkOutput(format, file string) string {
+	switch {
+	case file != "":
+		return "file"
+	case format == "otlp-http" || format == "otlp-grpc":
+		return "otlp"
+	case format == "zipkin-json":
+		return "zipkin"
+	default:
+		return "stdout"
+	}
+}
+
+func (s Sink) Validate() error {
+	switch s.Format {
+	case "jsonl", "pretty", "otlp-proto", "otlp-json":
+		if s.Output != "stdout" && s.Output != "file" && s.Output != "noop" {
+			return fmt.Errorf("%s format requires output stdout, file, or noop", s.Format)
+		}
+	case "otlp-http", "otlp-grpc":
+		if s.Output != "otlp" && s.Output != "stdout" && s.Output != "file" && s.Output != "noop" {
+			return fmt.Errorf("%s format requires output otlp, stdout, file, or noop", s.Format)
+		}
+	case "zipkin-json":
+		if s.Output != "zipkin" && s.Output != "stdout" && s.Output != "file" && s.Output != "noop" {
+			return fmt.Errorf("zipkin-json format requires output zipkin, stdout, file, or noop")
+		}
+	default:
+		return fmt.Errorf("unsupported format %q", s.Format)
+	}
+	switch s.Output {
+	case "stdout", "file", "noop":
+	case "otlp", "zipkin":
+		if len(Endpoints(s.Endpoint)) == 0 {
+			return fmt.Errorf("%s endpoint required for output=%q format=%q", s.Output, s.Output, s.Format)
+		}
+	default:
+		return fmt.Errorf("unsupported output %q", s.Output)
+	}
+	if s.Output == "file" && strings.TrimSpace(s.File) == "" {
+		return fmt.Errorf("file output requires --file")
+	}
+	if s.BatchSize <= 0 {
+		return fmt.Errorf("batch-size must be > 0")
+	}
+	if s.FlushInterval <= 0 {
+		return fmt.Errorf("flush-interval must be > 0")
+	}
+	if s.Retries < 0 {
+		return fmt.Errorf("sink-retries must be >= 0")
+	}
+	if s.RetryBackoff <= 0 {
+		return fmt.Errorf("sink-retry-backoff must be > 0")
+	}
+	if s.Timeout <= 0 {
+		return fmt.Errorf("sink-timeout must be > 0")
+	}
+	if s.MaxInFlight <= 0 {
+		return fmt.Errorf("sink-max-in-flight must be > 0")
+	}
+	switch s.OnError {
+	case SinkOnErrorFail, SinkOnErrorContinue:
+	default:
+		return fmt.Errorf("sink on-error must be fail or continue")
+	}
+	switch s.Compress {
+	case "", "none", "gzip":
+	case "zstd", "snappy":
+		if s.Output == "zipkin" {
+			return fmt.Errorf("zipkin supports only gzip compression")
+		}
+	default:
+		return fmt.Errorf("compress must be gzip, zstd, snappy or none")
+	}
+	return nil
+}
+
+// network reports whether the sink sends requests to an endpoint.
+func (s Sink) network() bool {
+	return s.Output == "otlp" || s.Output == "zipkin"
+}
+
+// Outputs returns the sinks a run writes to: every --sink, or the one sink
+// --format and --output describe.
+func (c Config) Outputs() []Sink {
+	if len(c.Sinks) > 0 {
+		return c.Sinks
+	}
+	return []Sink{{
+		Name:          c.Format,
+		Format:        c.Format,
+		Output:        c.Output,
+		File:          c.File,
+		Endpoint:      c.endpointFor(c.Format),
+		BatchSize:     c.BatchSize,
+		FlushInterval: c.FlushInterval,
+		Retries:       c.SinkRetries,
+		RetryBackoff:  c.SinkRetryBackoff,
+		Timeout:       c.SinkTimeout,
+		MaxInFlight:   c.SinkMaxInFlight,
+		OnError:       SinkOnErrorFail,
+		Compress:      c.Compress,
+	}}
+}
+
+// WithSink returns c with its format, output and sink settings taken from s,
+// so code written for a single output can drive one sink of several.
+func (c Config) WithSink(s Sink) Config {
+	c.Format = s.Format
+	c.Output = s.Output
+	c.File = s.File
+	switch s.Format {
+	case "otlp-http", "otlp-grpc":
+		c.OTLPEndpoint = s.Endpoint
+	case "zipkin-json":
+		c.ZipkinEndpoint = s.Endpoint
+	}
+	c.BatchSize = s.BatchSize
+	c.FlushInterval = s.FlushInterval
+	c.SinkRetries = s.Retries
+	c.SinkRetryBackoff = s.RetryBackoff
+	c.SinkTimeout = s.Timeout
+	c.SinkMaxInFlight = s.MaxInFlight
+	c.Compress = s.Compress
+	c.Sinks = nil
+	return c
+}
+
+func (c Config) endpointFor(format string) string {
+	switch format {
+	case "otlp-http", "otlp-grpc":
+		return c.OTLPEndpoint
+	case "zipkin-json":
+		return c.ZipkinEndpoint
+	}
+	return ""
+}
+
+// validateSinks checks the sinks against each other and against the rest of
+// the run.
+func validateSinks(c Config) error {
+	names := map[string]bool{}
+	files := map[string]string{}
+	stdout := ""
+	network := 0
+	for _, s := range c.Sinks {
+		if names[s.Name] {
+			return fmt.Errorf("sink %s appears twice; give each sink its own name=", s.Name)
+		}
+		names[s.Name] = true
+		switch s.Output {
+		case "stdout":
+			if stdout != "" {
+				return fmt.Errorf("sinks %s and %s both write to stdout", stdout, s.Name)
+			}
+			stdout = s.Name
+		case "file":
+			if other, ok := files[s.File]; ok {
+				return fmt.Errorf("sinks %s and %s both write to %s", other, s.Name, s.File)
+			}
+			files[s.File] = s.Name
+		}
+		if s.network() {
+			network++
+		}
+	}
+	if network > 1 {
+		for _, t := range c.Tenants {
+			if t.Endpoint != "" {
+				return fmt.Errorf("tenant %s sets an endpoint, which needs a single network sink", t.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func allNoop(sinks []Sink) bool {
+	for _, s := range sinks {
+		if s.Output != "noop" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSink(t *testing.T) {
+	run := Config{
+		OTLPEndpoint:     "http://collector:4318",
+		ZipkinEndpoint:   "http://zipkin:9411/api/v2/spans",
+		BatchSize:        512,
+		FlushInterval:    200 * time.Millisecond,
+		SinkRetries:      2,
+		SinkRetryBackoff: 300 * time.Millisecond,
+		SinkTimeout:      10 * time.Second,
+		SinkMaxInFlight:  2,
+	}
+	got, err := ParseSink("name=tempo, format=otlp-http, endpoint=http://tempo:4318, batch-size=1000, flush-interval=1s, retries=5, retry-backoff=1s, timeout=3s, max-in-flight=8, on-error=continue", run)
+	if err != nil {
+		t.Fatalf("ParseSink: %v", err)
+	}
+	want := Sink{Name: "tempo", Format: "otlp-http", Output: "otlp", Endpoint: "http://tempo:4318", BatchSize: 1000, FlushInterval: time.Second, Retries: 5, RetryBackoff: time.Second, Timeout: 3 * time.Second, MaxInFlight: 8, OnError: SinkOnErrorContinue}
+	if got != want {
+		t.Fatalf("sink=%+v want %+v", got, want)
+	}
+
+	zipkin, err := ParseSink("format=zipkin-json", run)
+	if err != nil {
+		t.Fatalf("ParseSink zipkin: %v", err)
+	}
+	if zipkin.Name != "zipkin-json" || zipkin.Output != "zipkin" || zipkin.Endpoint != run.ZipkinEndpoint || zipkin.BatchSize != 512 || zipkin.OnError != SinkOnErrorFail {
+		t.Fatalf("zipkin sink=%+v want run defaults", zipkin)
+	}
+	file, err := ParseSink("format=jsonl,file=out.jsonl", run)
+	if err != nil || file.Output != "file" || file.File != "out.jsonl" {
+		t.Fatalf("file sink=%+v err=%v", file, err)
+	}
+
+	run.Compress = "zstd"
+	compressed, err := ParseSink("format=otlp-grpc", run)
+	if err != nil || compressed.Compress != "zstd" {
+		t.Fatalf("sink=%+v err=%v want the run's compress", compressed, err)
+	}
+	if _, err := ParseSink("format=zipkin-json", run); err == nil || !strings.Contains(err.Error(), "only gzip") {
+		t.Fatalf("zipkin sink with zstd: err=%v", err)
+	}
+	zipkin, err = ParseSink("format=zipkin-json,compress=gzip", run)
+	if err != nil || zipkin.Compress != "gzip" {
+		t.Fatalf("zipkin sink=%+v err=%v", zipkin, err)
+	}
+	run.Compress = ""
+
+	for _, raw := range []string{"", "output=stdout", "format=xml", "format=otlp-http,compress=brotli", "format=jsonl,output=otlp", "format=otlp-http,endpoint=", "format=jsonl,output=file", "format=jsonl,batch-size=0", "format=jsonl,timeout=soon", "format=jsonl,on-error=ignore", "format=jsonl,colour=red", "format"} {
+		if _, err := ParseSink(raw, run); err == nil {
+			t.Fatalf("ParseSink(%q) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestValidateSinks(t *testing.T) {
+	base := Config{BatchSize: 1, FlushInterval: 1, SinkRetryBackoff: 1, SinkTimeout: 1, SinkMaxInFlight: 1}
+	sink := func(raw string) Sink {
+		s, err := ParseSink(raw, base)
+		if err != nil {
+			t.Fatalf("ParseSink(%q): %v", raw, err)
+		}
+		return s
+	}
+	tests := []struct {
+		name  string
+		sinks []Sink
+		want  string
+	}{
+		{"same name", []Sink{sink("format=jsonl"), sink("format=jsonl,file=a.jsonl")}, "appears twice"},
+		{"two stdout", []Sink{sink("name=a,format=jsonl"), sink("name=b,format=pretty")}, "both write to stdout"},
+		{"same file", []Sink{sink("name=a,format=jsonl,file=x"), sink("name=b,format=jsonl,file=x")}, "both write to x"},
+		{"tenant endpoint", []Sink{sink("format=otlp-http,endpoint=http://a"), sink("format=zipkin-json,endpoint=http://b")}, "single network sink"},
+	}
+	for _, tt := range tests {
+		cfg := base
+		cfg.Sinks = tt.sinks
+		cfg.Tenants = []Tenant{{Name: "acme", Weight: 1, Endpoint: "http://acme"}}
+		if err := validateSinks(cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("%s: err=%v want %q", tt.name, err, tt.want)
+		}
+	}
+	cfg := base
+	cfg.Sinks = []Sink{sink("format=otlp-http,endpoint=http://a"), sink("format=jsonl,file=a.jsonl")}
+	if err := validateSinks(cfg); err != nil {
+		t.Fatalf("otlp and file sinks: %v", err)
+	}
+}
+
+func TestFromFlagsSinks(t *testing.T) {
+	flags := FlagValues{
+		Rate:             200,
+		RateUnit:         "spans",
+		RateInterval:     1,
+		Duration:         1,
+		Workers:          1,
+		Profile:          "web",
+		Routes:           8,
+		Services:         5,
+		Depth:            4,
+		Fanout:           2,
+		ServicePrefix:    "svc-",
+		P50:              1,
+		P95:              2,
+		P99:              3,
+		Errors:           "0.5%",
+		Retries:          "1%",
+		DBHeavy:          "20%",
+		CacheHitRate:     "85%",
+		Variety:          "medium",
+		Format:           "jsonl",
+		Output:           "stdout",
+		OTLPEndpoint:     "http://collector:4318",
+		BatchSize:        512,
+		FlushInterval:    1,
+		SinkRetryBackoff: 1,
+		SinkTimeout:      1,
+		SinkMaxInFlight:  2,
+		Sinks:            []string{"format=otlp-http,batch-size=100", "name=copy,format=jsonl,file=copy.jsonl"},
+	}
+	cfg, err := FromFlagsWithOverrides(flags, nil)
+	if err != nil {
+		t.Fatalf("FromFlagsWithOverrides: %v", err)
+	}
+	outputs := cfg.Outputs()
+	if len(outputs) != 2 || outputs[0].Endpoint != "http://collector:4318" || outputs[0].BatchSize != 100 || outputs[1].Name != "copy" || outputs[1].BatchSize != 512 {
+		t.Fatalf("outputs=%+v", outputs)
+	}
+	sinkCfg := cfg.WithSink(outputs[1])
+	if sinkCfg.Format != "jsonl" || sinkCfg.Output != "file" || sinkCfg.File != "copy.jsonl" || len(sinkCfg.Sinks) != 0 {
+		t.Fatalf("sink config format=%s output=%s file=%s", sinkCfg.Format, sinkCfg.Output, sinkCfg.File)
+	}
+
+	t.Setenv("SPANFORGE_SINKS", "format=jsonl;format=pretty")
+	if _, err := FromFlagsWithOverrides(flags, nil); err == nil || !strings.Contains(err.Error(), "both write to stdout") {
+		t.Fatalf("err=%v want two stdout sinks rejected", err)
+	}
+
+	flags.Sinks = nil
+	t.Setenv("SPANFORGE_SINKS", "")
+	single, err := FromFlagsWithOverrides(flags, nil)
+	if err != nil {
+		t.Fatalf("FromFlagsWithOverrides without sinks: %v", err)
+	}
+	if outputs := single.Outputs(); len(outputs) != 1 || outputs[0].Format != "jsonl" || outputs[0].Output != "stdout" {
+		t.Fatalf("outputs=%+v want the --format/--output sink", outputs)
+	}
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sweepExcluded are YAML config keys a sweep cannot vary, because the
+// sweep runs and reports each combination itself.
+var sweepExcluded = map[string]bool{
+	"report_file": true, "http_listen": true, "phase_file": true, "load": true, "curve": true,
+}
+
+// Matrix is the config values a sweep tries. Each key is a YAML config key
+// with the values to try for it, in order.
+type Matrix struct {
+	Keys   []string
+	Values [][]*yaml.Node
+}
+
+// SweepRun is one combination of matrix values and the config it makes.
+type SweepRun struct {
+	// Values are the combination's values for the matrix keys, in order.
+	Values []string
+	Config Config
+}
+
+// LoadMatrix reads a matrix from a YAML file such as
+//
+//	batch_size: [128, 512, 2048]
+//	compress: [none, gzip]
+func LoadMatrix(path string) (Matrix, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Matrix{}, fmt.Errorf("read matrix file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return Matrix{}, fmt.Errorf("parse matrix yaml: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return Matrix{}, fmt.Errorf("matrix file %s is empty", path)
+	}
+	node := doc.Content[0]
+	if node.Kind != yaml.MappingNode {
+		return Matrix{}, fmt.Errorf("line %d: matrix must be a mapping of settings to lists of values", node.Line)
+	}
+	var m Matrix
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		values := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			values = value.Content
+		}
+		if err := m.add(key.Value, values); err != nil {
+			return Matrix{}, fmt.Errorf("line %d: %w", key.Line, err)
+		}
+	}
+	return m, nil
+}
+
+// AddVary adds a CLI matrix entry such as "batch_size=128,512,2048" to m.
+func (m *Matrix) AddVary(raw string) error {
+	key, list, ok := strings.Cut(raw, "=")
+	if !ok {
+		return fmt.Errorf("invalid vary %q: expected key=value,value", raw)
+	}
+	var values []*yaml.Node
+	for _, value := range strings.Split(list, ",") {
+		values = append(values, &yaml.Node{Kind: yaml.ScalarNode, Value: strings.TrimSpace(value)})
+	}
+	if err := m.add(strings.TrimSpace(key), values); err != nil {
+		return fmt.Errorf("invalid vary %q: %w", raw, err)
+	}
+	return nil
+}
+
+func (m *Matrix) add(key string, values []*yaml.Node) error {
+	switch {
+	case !yamlKeys()[key]:
+		return fmt.Errorf("unknown setting %q", key)
+	case sweepExcluded[key]:
+		return fmt.Errorf("%s cannot be varied in a sweep", key)
+	case len(values) == 0:
+		return fmt.Errorf("%s needs at least one value", key)
+	}
+	for _, k := range m.Keys {
+		if k == key {
+			return fmt.Errorf("%s is given twice", key)
+		}
+	}
+	m.Keys = append(m.Keys, key)
+	m.Values = append(m.Values, values)
+	return nil
+}
+
+// SweepRuns returns a config for every combination of m's values, with the
+// first key varying slowest. Each combination's values are set over the
+// flags, config file and environment, and the configs are validated before
+// any runs.
+func SweepRuns(v FlagValues, cliOverrides map[string]bool, m Matrix) ([]SweepRun, error) {
+	if len(m.Keys) == 0 {
+		return nil, fmt.Errorf("sweep needs a matrix file or at least one vary")
+	}
+	base, err := mergeValues(v, cliOverrides)
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []SweepRun
+	pick := make([]int, len(m.Keys))
+	for {
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		values := make([]string, len(m.Keys))
+		settings := make([]string, len(m.Keys))
+		for i, key := range m.Keys {
+			value := m.Values[i][pick[i]]
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+			values[i] = nodeText(value)
+			settings[i] = key + "=" + values[i]
+		}
+		var y yamlFlagValues
+		if err := node.Decode(&y); err != nil {
+			return nil, fmt.Errorf("sweep %s: %w", strings.Join(settings, ","), err)
+		}
+		merged, err := mergeYAMLValues(base, y, nil)
+		if err != nil {
+			return nil, fmt.Errorf("sweep %s: %w", strings.Join(settings, ","), err)
+		}
+		cfg, err := fromValues(merged)
+		if err != nil {
+			return nil, fmt.Errorf("sweep %s: %w", strings.Join(settings, ","), err)
+		}
+		runs = append(runs, SweepRun{Values: values, Config: cfg})
+
+		// Advance the last key fastest, like an odometer.
+		i := len(pick) - 1
+		for ; i >= 0; i-- {
+			if pick[i]++; pick[i] < len(m.Values[i]) {
+				break
+			}
+			pick[i] = 0
+		}
+		if i < 0 {
+			return runs, nil
+		}
+	}
+}
+
+// nodeText renders a matrix value for a report: scalars as written, other
+// values as flow YAML.
+func nodeText(node *yaml.Node) string {
+	if node.Kind == yaml.ScalarNode {
+		return node.Value
+	}
+	flow := *node
+	flow.Style = yaml.FlowStyle
+	data, err := yaml.Marshal(&flow)
+	if err != nil {
+		return node.Value
+	}
+	return strings.TrimSpace(string(data))
+}
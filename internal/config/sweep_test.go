@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSweepRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "matrix.yaml")
+	if err := os.WriteFile(path, []byte("batch_size: [128, 512, 2048]\ncompress: [none, gzip]\n"), 0o644); err != nil {
+		t.Fatalf("write matrix: %v", err)
+	}
+	m, err := LoadMatrix(path)
+	if err != nil {
+		t.Fatalf("LoadMatrix: %v", err)
+	}
+	if err := m.AddVary("sink_max_in_flight=1, 4"); err != nil {
+		t.Fatalf("AddVary: %v", err)
+	}
+	v := FlagValues{
+		Rate:             200,
+		RateUnit:         "traces",
+		RateInterval:     1,
+		Duration:         1,
+		Workers:          1,
+		Profile:          "web",
+		Routes:           8,
+		Services:         5,
+		Depth:            4,
+		Fanout:           2,
+		ServicePrefix:    "svc-",
+		P50:              1,
+		P95:              2,
+		P99:              3,
+		Errors:           "0.5%",
+		Retries:          "1%",
+		DBHeavy:          "20%",
+		CacheHitRate:     "85%",
+		Variety:          "medium",
+		Format:           "otlp-http",
+		Output:           "otlp",
+		OTLPEndpoint:     "http://collector:4318",
+		Compress:         "zstd",
+		BatchSize:        64,
+		FlushInterval:    1,
+		SinkRetryBackoff: 1,
+		SinkTimeout:      1,
+		SinkMaxInFlight:  2,
+	}
+	// Matrix values win over flags given on the command line.
+	runs, err := SweepRuns(v, map[string]bool{"compress": true, "batch-size": true}, m)
+	if err != nil {
+		t.Fatalf("SweepRuns: %v", err)
+	}
+	if len(runs) != 12 {
+		t.Fatalf("runs=%d want 12", len(runs))
+	}
+	if got := runs[3].Values; !reflect.DeepEqual(got, []string{"128", "gzip", "4"}) {
+		t.Fatalf("runs[3] values=%q", got)
+	}
+	last := runs[11].Config
+	if last.BatchSize != 2048 || last.Compress != "gzip" || last.SinkMaxInFlight != 4 || last.OTLPEndpoint != "http://collector:4318" {
+		t.Fatalf("last config batch=%d compress=%q in-flight=%d endpoint=%q", last.BatchSize, last.Compress, last.SinkMaxInFlight, last.OTLPEndpoint)
+	}
+
+	for name, vary := range map[string]string{
+		"unknown":  "batch=1,2",
+		"excluded": "report_file=a.json",
+		"repeated": "batch_size=1",
+		"no value": "workers",
+	} {
+		bad := m
+		if err := bad.AddVary(vary); err == nil {
+			t.Fatalf("%s: vary %q accepted", name, vary)
+		}
+	}
+	var invalid Matrix
+	if err := invalid.AddVary("compress=none,brotli"); err != nil {
+		t.Fatalf("AddVary: %v", err)
+	}
+	if _, err := SweepRuns(v, nil, invalid); err == nil {
+		t.Fatalf("combination with an invalid compress validated")
+	}
+	if _, err := SweepRuns(v, nil, Matrix{}); err == nil {
+		t.Fatalf("empty matrix accepted")
+	}
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Tenant is one tenant of a multi-tenant backend. Each trace goes to one
+// tenant, picked by Weight. The tenant's requests carry Headers, its spans'
+// resources carry Attributes, and Endpoint, when set, replaces the run's
+// endpoint for its requests.
+type Tenant struct {
+	Name       string
+	Weight     float64
+	Headers    map[string]string
+	Attributes map[string]string
+	Endpoint   string
+}
+
+// ParseTenant parses a CLI tenant such as
+// "acme,weight=3,header=Authorization=Bearer abc,attr=cloud.account.id=1234,endpoint=http://tempo-acme:4318".
+// The first item names the tenant. header and attr can repeat.
+func ParseTenant(raw string) (Tenant, error) {
+	parts := strings.Split(raw, ",")
+	t := Tenant{Name: strings.TrimSpace(parts[0]), Weight: 1}
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Tenant{}, fmt.Errorf("invalid tenant %q: expected key=value, got %q", raw, part)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "weight":
+			w, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return Tenant{}, fmt.Errorf("invalid tenant %q: bad weight %q", raw, value)
+			}
+			t.Weight = w
+		case "header", "attr":
+			name, v, ok := strings.Cut(value, "=")
+			name = strings.TrimSpace(name)
+			if !ok || name == "" {
+				return Tenant{}, fmt.Errorf("invalid tenant %q: %s needs name=value, got %q", raw, key, value)
+			}
+			if key == "header" {
+				if t.Headers == nil {
+					t.Headers = map[string]string{}
+				}
+				t.Headers[name] = strings.TrimSpace(v)
+			} else {
+				if t.Attributes == nil {
+					t.Attributes = map[string]string{}
+				}
+				t.Attributes[name] = strings.TrimSpace(v)
+			}
+		case "endpoint":
+			t.Endpoint = value
+		default:
+			return Tenant{}, fmt.Errorf("invalid tenant %q: unknown key %q (must be weight, header, attr, or endpoint)", raw, key)
+		}
+	}
+	if err := t.Validate(); err != nil {
+		return Tenant{}, fmt.Errorf("invalid tenant %q: %w", raw, err)
+	}
+	return t, nil
+}
+
+func (t Tenant) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("tenant needs a name")
+	}
+	if t.Weight <= 0 {
+		return fmt.Errorf("tenant %s needs a weight > 0", t.Name)
+	}
+	return nil
+}
+
+// RequestHeaders returns the headers the tenant's requests add to the run's:
+// tenantHeader set to the tenant's name, unless tenantHeader is empty, and
+// then the tenant's own headers.
+func (t Tenant) RequestHeaders(tenantHeader string) map[string]string {
+	headers := make(map[string]string, len(t.Headers)+1)
+	if tenantHeader != "" {
+		headers[tenantHeader] = t.Name
+	}
+	for k, v := range t.Headers {
+		headers[k] = v
+	}
+	return headers
+}
+
+// QueryHeaders returns the request headers that pick the tenant on a query,
+// leaving out credentials so they can be written to the run report.
+func (t Tenant) QueryHeaders(tenantHeader string) map[string]string {
+	headers := t.RequestHeaders(tenantHeader)
+	for k := range headers {
+		switch http.CanonicalHeaderKey(k) {
+		case "Authorization", "Proxy-Authorization", "Cookie":
+			delete(headers, k)
+		}
+	}
+	return headers
+}
+
+// TenantShare returns tenant i's share of traces, between 0 and 1.
+func (c Config) TenantShare(i int) float64 {
+	total := 0.0
+	for _, t := range c.Tenants {
+		total += t.Weight
+	}
+	return c.Tenants[i].Weight / total
+}
+
+func validateTenants(tenants []Tenant) error {
+	seen := map[string]bool{}
+	for _, t := range tenants {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("tenant %s appears twice", t.Name)
+		}
+		seen[t.Name] = true
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import "testing"
+
+func TestParseTenant(t *testing.T) {
+	got, err := ParseTenant("acme, weight=3, header=Authorization=Bearer a=b, header=X-Team=core, attr=cloud.account.id=1234, endpoint=http://tempo-acme:4318")
+	if err != nil {
+		t.Fatalf("ParseTenant: %v", err)
+	}
+	if got.Name != "acme" || got.Weight != 3 || got.Endpoint != "http://tempo-acme:4318" {
+		t.Fatalf("tenant=%+v", got)
+	}
+	if got.Headers["Authorization"] != "Bearer a=b" || got.Headers["X-Team"] != "core" || got.Attributes["cloud.account.id"] != "1234" {
+		t.Fatalf("headers=%v attributes=%v", got.Headers, got.Attributes)
+	}
+	request := got.RequestHeaders("X-Scope-OrgID")
+	if request["X-Scope-OrgID"] != "acme" || request["Authorization"] != "Bearer a=b" {
+		t.Fatalf("request headers=%v", request)
+	}
+	query := got.QueryHeaders("X-Scope-OrgID")
+	if query["X-Scope-OrgID"] != "acme" || query["X-Team"] != "core" || len(query) != 2 {
+		t.Fatalf("query headers=%v want no credentials", query)
+	}
+	if plain, err := ParseTenant("globex"); err != nil || plain.Weight != 1 || len(plain.RequestHeaders("")) != 0 {
+		t.Fatalf("plain tenant=%+v err=%v", plain, err)
+	}
+
+	for _, raw := range []string{"", ",weight=2", "acme,weight=0", "acme,weight=x", "acme,header=Authorization", "acme,attr==1", "acme,colour=red", "acme,endpoint"} {
+		if _, err := ParseTenant(raw); err == nil {
+			t.Fatalf("ParseTenant(%q) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestFromFlagsEnvTenants(t *testing.T) {
+	t.Setenv("SPANFORGE_TENANTS", "acme,weight=3;globex,endpoint=http://globex:4318")
+	t.Setenv("SPANFORGE_TENANT_HEADER", "X-Tenant")
+	flags := FlagValues{
+		Rate:             200,
+		RateUnit:         "spans",
+		RateInterval:     1,
+		Duration:         1,
+		Workers:          1,
+		Profile:          "web",
+		Routes:           8,
+		Services:         5,
+		Depth:            4,
+		Fanout:           2,
+		ServicePrefix:    "svc-",
+		P50:              1,
+		P95:              2,
+		P99:              3,
+		Errors:           "0.5%",
+		Retries:          "1%",
+		DBHeavy:          "20%",
+		CacheHitRate:     "85%",
+		Variety:          "medium",
+		Format:           "jsonl",
+		Output:           "stdout",
+		BatchSize:        512,
+		FlushInterval:    1,
+		SinkRetryBackoff: 1,
+		SinkTimeout:      1,
+		SinkMaxInFlight:  2,
+	}
+	cfg, err := FromFlagsWithOverrides(flags, nil)
+	if err != nil {
+		t.Fatalf("FromFlagsWithOverrides: %v", err)
+	}
+	if len(cfg.Tenants) != 2 || cfg.Tenants[1].Endpoint != "http://globex:4318" || cfg.TenantHeader != "X-Tenant" {
+		t.Fatalf("tenants=%+v header=%q", cfg.Tenants, cfg.TenantHeader)
+	}
+	if cfg.TenantShare(0) != 0.75 {
+		t.Fatalf("acme share=%.3f want 0.75", cfg.TenantShare(0))
+	}
+
+	t.Setenv("SPANFORGE_TENANTS", "acme;acme")
+	if _, err := FromFlagsWithOverrides(flags, nil); err == nil {
+		t.Fatalf("duplicate tenants validated")
+	}
+}
@@ -0,0 +1,26 @@
+package config
+
+import "github.com/robmcelhinney/spanforge/internal/tlsconfig"
+
+// TLS returns the TLS options for the run's network sinks.
+func (c Config) TLS() tlsconfig.Options {
+	return tlsconfig.Options{
+		CAFile:             c.TLSCAFile,
+		CertFile:           c.TLSCertFile,
+		KeyFile:            c.TLSKeyFile,
+		ServerName:         c.TLSServerName,
+		MinVersion:         c.TLSMinVersion,
+		InsecureSkipVerify: c.TLSSkipVerify,
+	}
+}
+
+// UsesTLS reports whether any TLS option is set. OTLP gRPC then uses TLS
+// whatever --otlp-insecure says.
+func (c Config) UsesTLS() bool {
+	opts := c.TLS()
+	// The default minimum version alone does not turn TLS on.
+	if opts.MinVersion == "1.2" {
+		opts.MinVersion = ""
+	}
+	return !opts.IsZero()
+}
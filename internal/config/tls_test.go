@@ -0,0 +1,61 @@
+package config
+
+import "testing"
+
+func TestFromFlagsTLS(t *testing.T) {
+	t.Setenv("SPANFORGE_TLS_CA_FILE", "/etc/spanforge/ca.pem")
+	t.Setenv("SPANFORGE_TLS_MIN_VERSION", "1.3")
+	v := FlagValues{
+		Rate:             200,
+		RateUnit:         "spans",
+		RateInterval:     1,
+		Duration:         1,
+		Workers:          1,
+		Profile:          "web",
+		Routes:           8,
+		Services:         5,
+		Depth:            4,
+		Fanout:           2,
+		ServicePrefix:    "svc-",
+		P50:              1,
+		P95:              2,
+		P99:              3,
+		Errors:           "0.5%",
+		Retries:          "1%",
+		DBHeavy:          "20%",
+		CacheHitRate:     "85%",
+		Variety:          "medium",
+		Format:           "otlp-http",
+		Output:           "otlp",
+		OTLPEndpoint:     "https://collector:4318",
+		BatchSize:        512,
+		FlushInterval:    1,
+		SinkRetryBackoff: 1,
+		SinkTimeout:      1,
+		SinkMaxInFlight:  2,
+		TLSCertFile:      "client.pem",
+		TLSKeyFile:       "client-key.pem",
+	}
+	cfg, err := FromFlags(v)
+	if err != nil {
+		t.Fatalf("FromFlags: %v", err)
+	}
+	opts := cfg.TLS()
+	if opts.CAFile != "/etc/spanforge/ca.pem" || opts.MinVersion != "1.3" || opts.CertFile != "client.pem" || !cfg.UsesTLS() {
+		t.Fatalf("tls=%+v", opts)
+	}
+
+	v.TLSKeyFile = ""
+	if _, err := FromFlags(v); err == nil {
+		t.Fatalf("client certificate without a key validated")
+	}
+}
+
+func TestUsesTLS(t *testing.T) {
+	if (Config{TLSMinVersion: "1.2"}).UsesTLS() {
+		t.Fatalf("the default minimum version turned TLS on")
+	}
+	if !(Config{TLSSkipVerify: true}).UsesTLS() {
+		t.Fatalf("tls-insecure-skip-verify did not turn TLS on")
+	}
+}
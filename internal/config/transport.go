@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Balancing policies for --lb.
+const (
+	// LBRoundRobin sends each request to the next endpoint in turn.
+	LBRoundRobin = "round-robin"
+	// LBLeastLoaded sends each request to the endpoint with the fewest
+	// requests in flight.
+	LBLeastLoaded = "least-loaded"
+)
+
+// Endpoints splits a comma-separated endpoint list, dropping empty entries.
+func Endpoints(raw string) []string {
+	var endpoints []string
+	for _, endpoint := range strings.Split(raw, ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+func validateTransport(c Config) error {
+	switch c.LB {
+	case "", LBRoundRobin, LBLeastLoaded:
+	default:
+		return fmt.Errorf("lb must be round-robin or least-loaded")
+	}
+	switch c.HTTPVersion {
+	case "", "auto", "1.1", "2":
+	default:
+		return fmt.Errorf("http-version must be auto, 1.1 or 2")
+	}
+	if c.Connections < 0 {
+		return fmt.Errorf("connections must be >= 0")
+	}
+	if c.HTTPMaxIdleConns < 0 {
+		return fmt.Errorf("http-max-idle-conns must be >= 0")
+	}
+	if c.HTTPIdleTimeout < 0 {
+		return fmt.Errorf("http-idle-conn-timeout must be >= 0")
+	}
+	if c.GRPCKeepalive < 0 || c.GRPCKeepaliveWait < 0 {
+		return fmt.Errorf("grpc-keepalive-time and grpc-keepalive-timeout must be >= 0")
+	}
+	if c.GRPCMaxMessage < 0 || c.GRPCMaxMessage > math.MaxInt32 {
+		return fmt.Errorf("grpc-max-message-size must be between 0 and 2GiB")
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFromFlagsTransport(t *testing.T) {
+	v := FlagValues{
+		Rate:             200,
+		RateUnit:         "spans",
+		RateInterval:     1,
+		Duration:         1,
+		Workers:          1,
+		Profile:          "web",
+		Routes:           8,
+		Services:         5,
+		Depth:            4,
+		Fanout:           2,
+		ServicePrefix:    "svc-",
+		P50:              1,
+		P95:              2,
+		P99:              3,
+		Errors:           "0.5%",
+		Retries:          "1%",
+		DBHeavy:          "20%",
+		CacheHitRate:     "85%",
+		Variety:          "medium",
+		Format:           "otlp-grpc",
+		Output:           "otlp",
+		OTLPEndpoint:     "collector-a:4317,collector-b:4317",
+		BatchSize:        512,
+		FlushInterval:    1,
+		SinkRetryBackoff: 1,
+		SinkTimeout:      1,
+		SinkMaxInFlight:  2,
+		LB:               "Least-Loaded",
+		Connections:      4,
+		HTTPVersion:      "2",
+		GRPCMaxMessage:   "16MiB",
+	}
+	cfg, err := FromFlags(v)
+	if err != nil {
+		t.Fatalf("FromFlags: %v", err)
+	}
+	if cfg.LB != LBLeastLoaded || cfg.GRPCMaxMessage != 16<<20 || cfg.Connections != 4 || cfg.HTTPVersion != "2" {
+		t.Fatalf("transport lb=%q max-message=%d connections=%d http-version=%q", cfg.LB, cfg.GRPCMaxMessage, cfg.Connections, cfg.HTTPVersion)
+	}
+
+	for name, mutate := range map[string]func(*FlagValues){
+		"lb":           func(v *FlagValues) { v.LB = "random" },
+		"http version": func(v *FlagValues) { v.HTTPVersion = "3" },
+		"connections":  func(v *FlagValues) { v.Connections = -1 },
+		"message size": func(v *FlagValues) { v.GRPCMaxMessage = "4GiB" },
+	} {
+		bad := v
+		mutate(&bad)
+		if _, err := FromFlags(bad); err == nil {
+			t.Fatalf("%s: bad value validated", name)
+		}
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	if got := Endpoints(" http://a:4318, ,http://b:4318 "); !reflect.DeepEqual(got, []string{"http://a:4318", "http://b:4318"}) {
+		t.Fatalf("Endpoints=%q", got)
+	}
+	s, err := ParseSink("format=otlp-http,endpoint=http://a:4318,endpoint=http://b:4318", Config{BatchSize: 1, FlushInterval: 1, SinkRetryBackoff: 1, SinkTimeout: 1, SinkMaxInFlight: 1})
+	if err != nil || s.Endpoint != "http://a:4318,http://b:4318" {
+		t.Fatalf("sink endpoint=%q err=%v", s.Endpoint, err)
+	}
+}
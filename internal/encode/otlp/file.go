@@ -0,0 +1,89 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	collectortracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// WriteDelimited writes req as protobuf behind a 4-byte big-endian length,
+// the layout the collector's file exporter uses with format: proto.
+func WriteDelimited(w io.Writer, req *collectortracev1.ExportTraceServiceRequest) error {
+	payload, err := proto.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshal otlp request: %w", err)
+	}
+	return WriteFrame(w, payload)
+}
+
+// WriteFrame writes payload behind a 4-byte big-endian length.
+func WriteFrame(w io.Writer, payload []byte) error {
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(payload)))
+	if _, err := w.Write(size[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// MarshalJSON encodes req as OTLP/JSON: lowerCamelCase fields, enums as
+// numbers and trace and span IDs in hex, as the collector's file exporter
+// writes it with format: json.
+func MarshalJSON(req *collectortracev1.ExportTraceServiceRequest) ([]byte, error) {
+	data, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal otlp json: %w", err)
+	}
+	// protojson writes bytes fields as base64, but OTLP/JSON wants IDs in
+	// hex.
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("marshal otlp json: %w", err)
+	}
+	if err := hexIDs(doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func hexIDs(node any) error {
+	switch v := node.(type) {
+	case map[string]any:
+		for key, value := range v {
+			switch key {
+			case "traceId", "spanId", "parentSpanId":
+				s, ok := value.(string)
+				if !ok {
+					continue
+				}
+				raw, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					return fmt.Errorf("marshal otlp json: bad %s %q", key, s)
+				}
+				v[key] = hex.EncodeToString(raw)
+			default:
+				if err := hexIDs(value); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err := hexIDs(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
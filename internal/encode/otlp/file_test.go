@@ -0,0 +1,88 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	collectortracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+func fileTestRequest(t *testing.T) *collectortracev1.ExportTraceServiceRequest {
+	t.Helper()
+	req, err := EncodeSpans([]model.Span{{
+		TraceID:      model.TraceID{0xab, 1},
+		SpanID:       model.SpanID{0xcd, 2},
+		ParentSpanID: model.SpanID{0xef, 3},
+		HasParent:    true,
+		Name:         "GET /cart",
+		Kind:         "SERVER",
+		StartTime:    time.Unix(1, 0).UTC(),
+		Duration:     time.Millisecond,
+		Attributes:   model.Attrs{"service.name": "api"},
+	}})
+	if err != nil {
+		t.Fatalf("EncodeSpans: %v", err)
+	}
+	return req
+}
+
+func TestWriteDelimited(t *testing.T) {
+	req := fileTestRequest(t)
+	var buf bytes.Buffer
+	for i := 0; i < 2; i++ {
+		if err := WriteDelimited(&buf, req); err != nil {
+			t.Fatalf("WriteDelimited: %v", err)
+		}
+	}
+	data := buf.Bytes()
+	for i := 0; i < 2; i++ {
+		size := binary.BigEndian.Uint32(data[:4])
+		var got collectortracev1.ExportTraceServiceRequest
+		if err := proto.Unmarshal(data[4:4+size], &got); err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !proto.Equal(&got, req) {
+			t.Fatalf("frame %d does not round-trip", i)
+		}
+		data = data[4+size:]
+	}
+	if len(data) != 0 {
+		t.Fatalf("%d trailing bytes", len(data))
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	data, err := MarshalJSON(fileTestRequest(t))
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	var doc struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID           string `json:"traceId"`
+					SpanID            string `json:"spanId"`
+					ParentSpanID      string `json:"parentSpanId"`
+					Kind              int    `json:"kind"`
+					StartTimeUnixNano string `json:"startTimeUnixNano"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	span := doc.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if span.TraceID != "ab010000000000000000000000000000" || span.SpanID != "cd02000000000000" || span.ParentSpanID != "ef03000000000000" {
+		t.Fatalf("ids trace=%s span=%s parent=%s want hex", span.TraceID, span.SpanID, span.ParentSpanID)
+	}
+	if span.Kind != 2 || span.StartTimeUnixNano != "1000000000" {
+		t.Fatalf("kind=%d start=%s", span.Kind, span.StartTimeUnixNano)
+	}
+}
@@ -3,6 +3,7 @@ package otlp
 import (
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	collectortracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
@@ -14,26 +15,38 @@ import (
 )
 
 func EncodeSpans(spans []model.Span) (*collectortracev1.ExportTraceServiceRequest, error) {
-	byService := map[string][]model.Span{}
+	type resourceGroup struct {
+		attrs model.Attrs
+		spans []model.Span
+	}
+	groups := map[string]*resourceGroup{}
 	for _, s := range spans {
 		service, _ := s.Attributes["service.name"].(string)
 		if service == "" {
 			service = "unknown-service"
 		}
-		byService[service] = append(byService[service], s)
+		attrs := s.Resource.Exported()
+		attrs["service.name"] = service
+		key := resourceKey(attrs)
+		group, ok := groups[key]
+		if !ok {
+			group = &resourceGroup{attrs: attrs}
+			groups[key] = group
+		}
+		group.spans = append(group.spans, s)
 	}
 
-	services := make([]string, 0, len(byService))
-	for k := range byService {
-		services = append(services, k)
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
 	}
-	sort.Strings(services)
+	sort.Strings(keys)
 
-	resourceSpans := make([]*tracev1.ResourceSpans, 0, len(services))
-	for _, service := range services {
-		spansForService := byService[service]
-		otelSpans := make([]*tracev1.Span, 0, len(spansForService))
-		for _, s := range spansForService {
+	resourceSpans := make([]*tracev1.ResourceSpans, 0, len(keys))
+	for _, key := range keys {
+		group := groups[key]
+		otelSpans := make([]*tracev1.Span, 0, len(group.spans))
+		for _, s := range group.spans {
 			span, err := toOTLPSpan(s)
 			if err != nil {
 				return nil, err
@@ -42,10 +55,7 @@ func EncodeSpans(spans []model.Span) (*collectortracev1.ExportTraceServiceReques
 		}
 
 		resourceSpans = append(resourceSpans, &tracev1.ResourceSpans{
-			Resource: &resourcev1.Resource{Attributes: []*commonv1.KeyValue{{
-				Key:   "service.name",
-				Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: service}},
-			}}},
+			Resource:   &resourcev1.Resource{Attributes: toAttrs(group.attrs)},
 			ScopeSpans: []*tracev1.ScopeSpans{{Spans: otelSpans}},
 		})
 	}
@@ -53,6 +63,23 @@ func EncodeSpans(spans []model.Span) (*collectortracev1.ExportTraceServiceReques
 	return &collectortracev1.ExportTraceServiceRequest{ResourceSpans: resourceSpans}, nil
 }
 
+// resourceKey identifies a resource by service name first, so resources sort by service.
+func resourceKey(attrs model.Attrs) string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		if k != "service.name" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	fmt.Fprint(&b, attrs["service.name"])
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\x00%s=%v", k, attrs[k])
+	}
+	return b.String()
+}
+
 func toOTLPSpan(s model.Span) (*tracev1.Span, error) {
 	start := s.StartTime.UTC()
 	end := s.StartTime.Add(s.Duration).UTC()
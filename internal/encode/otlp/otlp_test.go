@@ -39,3 +39,40 @@ func TestEncodeSpans(t *testing.T) {
 		t.Fatalf("spans = %d, want 1", len(req.ResourceSpans[0].ScopeSpans[0].Spans))
 	}
 }
+
+func TestEncodeSpansGroupsByResource(t *testing.T) {
+	span := func(id byte, version string) model.Span {
+		return model.Span{
+			SpanID:     model.SpanID{id},
+			Name:       "op",
+			StartTime:  time.Unix(1, 0).UTC(),
+			Duration:   time.Millisecond,
+			Attributes: model.Attrs{"service.name": "api"},
+			Resource:   model.Resource{Attributes: model.Attrs{"service.name": "api", "service.version": version, "spanforge.profile": "web"}},
+		}
+	}
+	// spanforge's own labels stay off the resource and do not split it.
+	blob := span(3, "v1")
+	blob.Resource.Attributes["spanforge.blob"] = "xxxx"
+	req, err := EncodeSpans([]model.Span{span(1, "v1"), span(2, "v2"), blob})
+	if err != nil {
+		t.Fatalf("EncodeSpans: %v", err)
+	}
+	if len(req.ResourceSpans) != 2 {
+		t.Fatalf("resource spans = %d, want 2", len(req.ResourceSpans))
+	}
+	for _, rs := range req.ResourceSpans {
+		versions := 0
+		for _, kv := range rs.Resource.Attributes {
+			if kv.Key == "service.version" {
+				versions++
+			}
+		}
+		if versions != 1 || len(rs.Resource.Attributes) != 2 {
+			t.Fatalf("resource attrs=%v want service.name and service.version", rs.Resource.Attributes)
+		}
+	}
+	if got := len(req.ResourceSpans[0].ScopeSpans[0].Spans); got != 2 {
+		t.Fatalf("v1 spans = %d, want 2", got)
+	}
+}
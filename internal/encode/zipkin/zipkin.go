@@ -37,6 +37,15 @@ func EncodeSpans(spans []model.Span) ([]byte, error) {
 			d = 1
 		}
 		tags := tagsFromAttrs(s.Attributes)
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		// Zipkin has no resource, so resource attributes such as service.version become tags.
+		for k, v := range tagsFromAttrs(s.Resource.Exported()) {
+			if _, ok := tags[k]; !ok && k != "service.name" {
+				tags[k] = v
+			}
+		}
 		if s.Status.Code == "ERROR" {
 			tags["error"] = s.Status.Message
 			if tags["error"] == "" {
@@ -29,7 +29,9 @@ func TestEncodeSpans(t *testing.T) {
 		},
 		Status: model.SpanStatus{Code: "OK"},
 		Resource: model.Resource{Attributes: model.Attrs{
-			"service.name": "svc-a",
+			"service.name":      "svc-a",
+			"service.version":   "v2",
+			"spanforge.profile": "web",
 		}},
 	}}
 
@@ -64,4 +66,7 @@ func TestEncodeSpans(t *testing.T) {
 	if tags["http.method"] != "GET" {
 		t.Fatalf("http.method=%v", tags["http.method"])
 	}
+	if tags["service.version"] != "v2" || tags["spanforge.profile"] != nil {
+		t.Fatalf("tags=%v want service.version and no spanforge.profile", tags)
+	}
 }
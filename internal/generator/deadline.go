@@ -0,0 +1,165 @@
+package generator
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+// gRPC status codes set on timed-out and cancelled gRPC calls.
+const (
+	grpcStatusCancelled        = 1
+	grpcStatusDeadlineExceeded = 4
+)
+
+// applyDeadlines ends every call that outlasts its deadline at the deadline
+// and fails it with DEADLINE_EXCEEDED. A call's deadline is the earlier of
+// its own --deadline or --deadline-policy and the deadline its caller was
+// given, so deadlines shrink down the call tree. Work behind a timed-out call
+// is cancelled with probability --deadline-cancel and otherwise keeps running
+// after the caller gave up. The caller skips the calls it had not made yet
+// unless it retries the timed-out call.
+func (g *Generator) applyDeadlines(trace *model.Trace) {
+	if (g.cfg.Deadline <= 0 && len(g.cfg.DeadlinePolicies) == 0) || len(trace.Spans) < 2 {
+		return
+	}
+	children := map[model.SpanID][]int{}
+	for i, span := range trace.Spans {
+		if span.HasParent {
+			children[span.ParentSpanID] = append(children[span.ParentSpanID], i)
+		}
+	}
+	removed := map[int]bool{}
+	var removeSubtree func(idx int)
+	removeSubtree = func(idx int) {
+		removed[idx] = true
+		for _, child := range children[trace.Spans[idx].SpanID] {
+			removeSubtree(child)
+		}
+	}
+	// cancel stops the work under the span at idx at the given time.
+	var cancel func(idx int, at time.Time)
+	cancel = func(idx int, at time.Time) {
+		for _, child := range children[trace.Spans[idx].SpanID] {
+			span := &trace.Spans[child]
+			switch {
+			case !span.StartTime.Before(at):
+				removeSubtree(child)
+			case span.StartTime.Add(span.Duration).After(at):
+				span.Duration = at.Sub(span.StartTime)
+				cancelSpan(span)
+				cancel(child, at)
+			}
+		}
+	}
+
+	// visit applies deadlines to the calls made by the span at idx, which
+	// must itself finish by deadline. A zero deadline means none.
+	var visit func(idx int, deadline time.Time)
+	visit = func(idx int, deadline time.Time) {
+		caller, _ := trace.Spans[idx].Attributes["service.name"].(string)
+		kids := children[trace.Spans[idx].SpanID]
+		for n, child := range kids {
+			if removed[child] {
+				continue
+			}
+			call := &trace.Spans[child]
+			callDeadline := deadline
+			// timeout is the call's own deadline when it is the one that applies.
+			var timeout time.Duration
+			if call.Kind == "CLIENT" {
+				service, _ := call.Attributes["service.name"].(string)
+				if d := g.deadlineFor(caller, service); d > 0 {
+					if own := call.StartTime.Add(d); callDeadline.IsZero() || own.Before(callDeadline) {
+						callDeadline = own
+						timeout = d
+					}
+				}
+			}
+			if call.Kind != "CLIENT" || callDeadline.IsZero() || !call.StartTime.Add(call.Duration).After(callDeadline) {
+				visit(child, callDeadline)
+				continue
+			}
+
+			call.Duration = callDeadline.Sub(call.StartTime)
+			if call.Duration < 0 {
+				call.Duration = 0
+			}
+			failSpan(call, http.StatusGatewayTimeout, "DeadlineExceeded", "DEADLINE_EXCEEDED", deadlineMessage(timeout, callDeadline.Sub(call.StartTime)))
+			if call.Attributes["rpc.system"] == "grpc" {
+				call.Attributes["rpc.grpc.status_code"] = grpcStatusDeadlineExceeded
+			}
+			if g.rng.Float64() < g.cfg.DeadlineCancel {
+				cancel(child, callDeadline)
+			} else {
+				visit(child, time.Time{})
+			}
+			if !retried(trace.Spans, kids[n+1:], *call) {
+				for _, sibling := range kids[n+1:] {
+					removeSubtree(sibling)
+				}
+				break
+			}
+		}
+	}
+	for i, span := range trace.Spans {
+		if !span.HasParent {
+			visit(i, time.Time{})
+		}
+	}
+	dropSpans(trace, removed)
+}
+
+// deadlineMessage describes the deadline a call exceeded: its own timeout,
+// or else the budget left on the deadline inherited from its caller.
+func deadlineMessage(timeout, remaining time.Duration) string {
+	switch {
+	case timeout > 0:
+		return fmt.Sprintf("deadline of %s exceeded", timeout)
+	case remaining > 0:
+		return fmt.Sprintf("caller deadline exceeded with %s of budget remaining at the call", remaining)
+	default:
+		return "caller deadline had already passed"
+	}
+}
+
+// deadlineFor returns how long caller waits for calls into service. The
+// first matching --deadline-policy wins over --deadline.
+func (g *Generator) deadlineFor(caller, service string) time.Duration {
+	for _, p := range g.cfg.DeadlinePolicies {
+		if p.Matches(caller, service) {
+			return p.Deadline
+		}
+	}
+	return g.cfg.Deadline
+}
+
+// cancelSpan marks span as cut short because its caller stopped waiting.
+func cancelSpan(span *model.Span) {
+	span.Status = model.SpanStatus{Code: "ERROR", Message: "cancelled"}
+	span.Attributes["error"] = true
+	if span.Attributes["rpc.system"] == "grpc" {
+		span.Attributes["rpc.grpc.status_code"] = grpcStatusCancelled
+	}
+	span.Events = append(span.Events, model.Event{
+		Name:       "cancelled",
+		Time:       span.StartTime.Add(span.Duration),
+		Attributes: model.Attrs{"cancellation.reason": "caller deadline exceeded"},
+	})
+}
+
+// dropSpans removes the spans at the indexes in removed, keeping span order.
+func dropSpans(trace *model.Trace, removed map[int]bool) {
+	if len(removed) == 0 {
+		return
+	}
+	kept := trace.Spans[:0]
+	for i, span := range trace.Spans {
+		if !removed[i] {
+			kept = append(kept, span)
+		}
+	}
+	trace.Spans = kept
+}
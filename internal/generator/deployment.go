@@ -0,0 +1,57 @@
+package generator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+// instancesPerVersion is how many instances serve each version of a deployed service.
+const instancesPerVersion = 3
+
+// applyDeployments stamps the version of the instance serving each span.
+// During a rollout each span of the rolling service picks the old or new
+// version by the current canary share, and new-version spans take on the
+// rollout's latency and error characteristics. Callers wait for slower
+// canary calls.
+func (g *Generator) applyDeployments(trace *model.Trace) {
+	rollout := g.cfg.Rollout
+	if rollout == nil && len(g.cfg.ServiceVersions) == 0 {
+		return
+	}
+	for i := range trace.Spans {
+		span := &trace.Spans[i]
+		service, _ := span.Attributes["service.name"].(string)
+		if rollout != nil && service == rollout.Service {
+			version, track := rollout.From, "stable"
+			if g.rng.Float64() < rollout.CanaryShare(span.StartTime) {
+				version, track = rollout.To, "canary"
+				if rollout.Latency > 0 {
+					span.Duration = time.Duration(float64(span.Duration) * rollout.Latency)
+					if span.HasParent {
+						extendAncestors(trace, span.ParentSpanID, span.StartTime.Add(span.Duration))
+					}
+				}
+				if span.Status.Code != "ERROR" && g.rng.Float64() < rollout.Errors {
+					markError(span)
+				}
+			}
+			g.stampVersion(span, service, version)
+			span.Resource.Attributes["deployment.name"] = track
+			continue
+		}
+		if version, ok := g.cfg.ServiceVersions[service]; ok {
+			g.stampVersion(span, service, version)
+		}
+	}
+}
+
+func (g *Generator) stampVersion(span *model.Span, service, version string) {
+	if span.Resource.Attributes == nil {
+		span.Resource.Attributes = model.Attrs{}
+	}
+	span.Resource.Attributes["service.version"] = version
+	span.Resource.Attributes["service.instance.id"] = fmt.Sprintf("%s-%s-%d", service, version, g.rng.Intn(instancesPerVersion))
+	span.Resource.Attributes["deployment.id"] = service + "-" + version
+}
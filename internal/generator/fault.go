@@ -0,0 +1,68 @@
+package generator
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+// applyFaults degrades the spans that match a configured fault. Faulted spans
+// carry spanforge.fault so a run can be checked against what was injected,
+// and their callers wait for the added latency.
+func (g *Generator) applyFaults(trace *model.Trace) {
+	if len(g.cfg.Faults) == 0 {
+		return
+	}
+	for i := range trace.Spans {
+		span := &trace.Spans[i]
+		for _, fault := range g.cfg.Faults {
+			if !spanMatches(fault.Service, fault.Operation, *span) || g.rng.Float64() >= fault.Rate {
+				continue
+			}
+			injectFault(span, fault)
+			if span.HasParent {
+				extendAncestors(trace, span.ParentSpanID, span.StartTime.Add(span.Duration))
+			}
+		}
+	}
+}
+
+// spanMatches reports whether span belongs to service and operation, where
+// empty matches anything. The operation matches the span name, HTTP route,
+// RPC method or messaging destination.
+func spanMatches(service, operation string, span model.Span) bool {
+	if service != "" && span.Attributes["service.name"] != service {
+		return false
+	}
+	if operation == "" || span.Name == operation {
+		return true
+	}
+	for _, key := range []string{"http.route", "rpc.method", "messaging.destination.name"} {
+		if v, _ := span.Attributes[key].(string); v == operation {
+			return true
+		}
+	}
+	return false
+}
+
+func injectFault(span *model.Span, fault config.Fault) {
+	span.Attributes["spanforge.fault"] = fault.Label()
+	span.Duration += fault.Latency
+	switch {
+	case fault.Timeout > 0:
+		if span.Duration < fault.Timeout {
+			span.Duration = fault.Timeout
+		}
+		failSpan(span, http.StatusGatewayTimeout, "TimeoutError", "timeout", fmt.Sprintf("no response after %s", fault.Timeout))
+	case fault.Status >= 400:
+		failSpan(span, fault.Status, "InjectedFault", http.StatusText(fault.Status), fmt.Sprintf("injected %d", fault.Status))
+	case fault.Status > 0:
+		if _, ok := span.Attributes["http.method"]; ok {
+			span.Attributes["http.status_code"] = fault.Status
+		}
+	case fault.Latency == 0:
+		failSpan(span, http.StatusInternalServerError, "InjectedFault", "injected fault", "injected fault")
+	}
+}
@@ -17,24 +17,22 @@ type Generator struct {
 	rng      *RNG
 	topology Topology
 	profile  profileModule
-	mu       float64
-	sigma    float64
+	latency  latencyModel
+	// operations holds the per-operation latency models.
+	operations []operationLatency
+	messaging  messagingState
+	users      *Population
 }
 
 func New(cfg config.Config) *Generator {
-	mu := math.Log(float64(cfg.P50))
-	sigma := (math.Log(float64(cfg.P95)) - mu) / z95
-	if sigma < 0.01 {
-		sigma = 0.01
-	}
-
 	return &Generator{
-		cfg:      cfg,
-		rng:      NewRNG(cfg.Seed),
-		topology: BuildTopology(cfg.ServicePrefix, cfg.Services),
-		profile:  moduleFor(cfg.Profile),
-		mu:       mu,
-		sigma:    sigma,
+		cfg:        cfg,
+		rng:        NewRNG(cfg.Seed),
+		topology:   BuildTopology(cfg.ServicePrefix, cfg.Services),
+		profile:    moduleFor(cfg.Profile),
+		latency:    fitLatencyModel(config.LatencyModel{Kind: config.LatencyLognormal, P50: cfg.P50, P95: cfg.P95, P99: cfg.P99}, cfg.CacheHitRate),
+		operations: operationLatencies(cfg),
+		users:      NewPopulation(cfg.Users),
 	}
 }
 
@@ -49,23 +47,51 @@ func (g *Generator) GenerateTrace(start time.Time) model.Trace {
 
 	rootID := g.newSpanID()
 	routeIdx := g.rng.Intn(max(1, g.cfg.Routes))
+	if g.cfg.Profile == "workflow" {
+		root := g.profile.buildRoot(g.topology.Frontdoor, routeIdx, start, rootID, traceID, g.workflowLifetime())
+		g.applyRunAttrs(&root)
+		g.applyCardinalityAttrs(&root)
+		trace.Spans = append(trace.Spans, root)
+		g.generateWorkflow(&trace, root)
+		g.finishTrace(&trace)
+		return trace
+	}
+	var session *userSession
+	if g.cfg.Users > 0 {
+		session = g.nextSession(start)
+	}
+	if session != nil {
+		routeIdx = journeySteps(g.cfg.Profile)[session.step].routeIdx
+	}
 	root := g.profile.buildRoot(g.topology.Frontdoor, routeIdx, start, rootID, traceID, g.sampleDurationForProfile())
+	if session != nil {
+		applyJourneyStep(&root, journeySteps(g.cfg.Profile)[session.step])
+	}
+	g.sampleOperationLatency(&root)
 	g.applyRunAttrs(&root)
 	g.applyCardinalityAttrs(&root)
 	g.maybeAddProfileEvent(&root)
-	retrySpan := g.maybeErrorAndRetry(&root)
+	g.maybeFail(&root)
 	trace.Spans = append(trace.Spans, root)
-	if retrySpan != nil {
-		g.applyRunAttrs(retrySpan)
-		g.applyCardinalityAttrs(retrySpan)
-		trace.Spans = append(trace.Spans, *retrySpan)
-	}
 
 	g.generateChildren(&trace, root, 1)
-	g.applyModes(&trace)
+	if session != nil {
+		applySession(&trace, session)
+		g.finishRequest(session, root.StartTime.Add(root.Duration))
+	}
+	g.finishTrace(&trace)
 	return trace
 }
 
+// finishTrace applies the whole-trace passes once every span exists.
+func (g *Generator) finishTrace(trace *model.Trace) {
+	g.applyDeployments(trace)
+	g.applyFaults(trace)
+	g.applyDeadlines(trace)
+	g.propagateErrors(trace)
+	g.applyModes(trace)
+}
+
 func (g *Generator) generateChildren(trace *model.Trace, parent model.Span, level int) {
 	if level >= g.cfg.Depth {
 		return
@@ -85,40 +111,42 @@ func (g *Generator) generateChildren(trace *model.Trace, parent model.Span, leve
 		routeIdx := g.rng.Intn(max(1, g.cfg.Routes))
 		if g.cfg.Profile == "queue" {
 			producer, consumer := g.profile.buildQueuePair(parent, service, routeIdx, start, g.newSpanID(), g.newSpanID(), trace.TraceID, g.sampleDurationForProfile())
+			if g.cfg.MessagingMode == "linked" {
+				g.publishLinked(trace, producer, consumer, level)
+				continue
+			}
+			g.sampleOperationLatency(&producer)
+			g.sampleOperationLatency(&consumer)
+			partition, offset := g.stampMessage(&producer)
+			consumer.Attributes["messaging.destination.partition.id"] = partition
+			consumer.Attributes["messaging.kafka.offset"] = offset
+			consumer.Attributes["messaging.consumer.group.name"] = consumerGroup(service)
 			g.applyRunAttrs(&producer)
 			g.applyRunAttrs(&consumer)
 			g.applyCardinalityAttrs(&producer)
 			g.applyCardinalityAttrs(&consumer)
 			g.maybeAddProfileEvent(&producer)
 			g.maybeAddProfileEvent(&consumer)
-			producerRetry := g.maybeErrorAndRetry(&producer)
-			consumerRetry := g.maybeErrorAndRetry(&consumer)
+			g.maybeFail(&producer)
+			g.maybeFail(&consumer)
 			trace.Spans = append(trace.Spans, producer, consumer)
-			if producerRetry != nil {
-				g.applyRunAttrs(producerRetry)
-				g.applyCardinalityAttrs(producerRetry)
-				trace.Spans = append(trace.Spans, *producerRetry)
-			}
-			if consumerRetry != nil {
-				g.applyRunAttrs(consumerRetry)
-				g.applyCardinalityAttrs(consumerRetry)
-				trace.Spans = append(trace.Spans, *consumerRetry)
-			}
 			g.generateChildren(trace, consumer, level+1)
 			continue
 		}
 		child := g.profile.buildChild(parent, service, routeIdx, start, g.newSpanID(), trace.TraceID, g.sampleDurationForProfile(), g.rng.Float64() < g.cfg.DBHeavy, g.rng.Float64() < g.cfg.CacheHitRate)
+		g.sampleOperationLatency(&child)
 		g.applyRunAttrs(&child)
 		g.applyCardinalityAttrs(&child)
 		g.maybeAddProfileEvent(&child)
-		retrySpan := g.maybeErrorAndRetry(&child)
+		template, failed := g.failCall(&child)
+		childIdx := len(trace.Spans)
 		trace.Spans = append(trace.Spans, child)
-		if retrySpan != nil {
-			g.applyRunAttrs(retrySpan)
-			g.applyCardinalityAttrs(retrySpan)
-			trace.Spans = append(trace.Spans, *retrySpan)
-		}
 		g.generateChildren(trace, child, level+1)
+		if failed {
+			g.retryCall(trace, template, childIdx, func(idx int) {
+				g.generateChildren(trace, trace.Spans[idx], level+1)
+			})
+		}
 	}
 }
 
@@ -134,6 +162,10 @@ func (g *Generator) applyRunAttrs(span *model.Span) {
 	if strings.TrimSpace(g.cfg.Phase) != "" {
 		span.Attributes["spanforge.phase"] = g.cfg.Phase
 	}
+	if g.cfg.CurvePosition != "" {
+		span.Attributes["spanforge.curve.position"] = g.cfg.CurvePosition
+		span.Attributes["spanforge.curve.load"] = math.Round(g.cfg.CurveLoad*1000) / 1000
+	}
 	if span.Resource.Attributes == nil {
 		span.Resource.Attributes = model.Attrs{}
 	}
@@ -170,57 +202,26 @@ func max(a, b int) int {
 	return b
 }
 
-func (g *Generator) maybeErrorAndRetry(span *model.Span) *model.Span {
-	if g.rng.Float64() >= g.errorProbability(span) {
-		return nil
-	}
-	span.Status = model.SpanStatus{Code: "ERROR", Message: "synthetic failure"}
+// markError fails span with a synthetic exception.
+func markError(span *model.Span) {
+	failSpan(span, 500, "SyntheticError", "synthetic failure", "generated error")
+}
+
+// failSpan sets an error status, an HTTP status for HTTP spans and an exception event.
+func failSpan(span *model.Span, httpStatus int, exceptionType, statusMessage, exceptionMessage string) {
+	span.Status = model.SpanStatus{Code: "ERROR", Message: statusMessage}
 	span.Attributes["error"] = true
 	if _, ok := span.Attributes["http.method"]; ok {
-		span.Attributes["http.status_code"] = 500
+		span.Attributes["http.status_code"] = httpStatus
 	}
 	span.Events = append(span.Events, model.Event{
 		Name: "exception",
 		Time: span.StartTime.Add(span.Duration / 2),
 		Attributes: model.Attrs{
-			"exception.type":    "SyntheticError",
-			"exception.message": "generated error",
+			"exception.type":    exceptionType,
+			"exception.message": exceptionMessage,
 		},
 	})
-
-	if g.rng.Float64() < g.cfg.Retries {
-		span.Events = append(span.Events, model.Event{
-			Name: "retry",
-			Time: span.StartTime.Add(span.Duration),
-			Attributes: model.Attrs{
-				"retry.attempt": 1,
-			},
-		})
-		retryDuration := g.sampleDuration() / 2
-		span.Duration += retryDuration
-		serviceName, _ := span.Attributes["service.name"].(string)
-		retrySpan := &model.Span{
-			TraceID:      span.TraceID,
-			SpanID:       g.newSpanID(),
-			ParentSpanID: span.SpanID,
-			HasParent:    true,
-			Name:         "retry attempt",
-			Kind:         "INTERNAL",
-			StartTime:    span.StartTime.Add(span.Duration - retryDuration),
-			Duration:     retryDuration,
-			Attributes: model.Attrs{
-				"service.name":  serviceName,
-				"retry.attempt": 1,
-			},
-			Status:   model.SpanStatus{Code: "OK"},
-			Resource: model.Resource{Attributes: model.Attrs{"service.name": serviceName}},
-		}
-		if _, ok := span.Attributes["http.method"]; ok {
-			retrySpan.Attributes["http.status_code"] = 200
-		}
-		return retrySpan
-	}
-	return nil
 }
 
 func (g *Generator) eventProbability() float64 {
@@ -356,7 +357,7 @@ func (g *Generator) sampleDuration() time.Duration {
 	}
 	u2 := g.rng.Float64()
 	z := math.Sqrt(-2.0*math.Log(u1)) * math.Cos(2.0*math.Pi*u2)
-	x := math.Exp(g.mu + g.sigma*z)
+	x := g.latency.lognormal(z)
 	if x < float64(time.Microsecond) {
 		x = float64(time.Microsecond)
 	}
@@ -1,11 +1,13 @@
 package generator
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
 )
 
 func baseConfig() config.Config {
@@ -142,3 +144,414 @@ func TestInvalidModesBreakTraceShape(t *testing.T) {
 		t.Fatal("expected empty required fields mutation")
 	}
 }
+
+func TestRolloutStampsVersionAndCanaryBehaviour(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Errors = 0
+	cfg.Retries = 0
+	now := time.Unix(1700000000, 0).UTC()
+	service := New(cfg).GenerateTrace(now).Spans[0].Attributes["service.name"].(string)
+
+	cfg.Rollout = &config.Rollout{Service: service, From: "v1", To: "v2", CanaryStart: 1, CanaryEnd: 1, Latency: 3, Errors: 1}
+	cfg.ServiceVersions = map[string]string{service: "v1"}
+	trace := New(cfg).GenerateTrace(now)
+	found := false
+	for _, span := range trace.Spans {
+		if span.Attributes["service.name"] != service {
+			if _, ok := span.Resource.Attributes["service.version"]; ok {
+				t.Fatalf("span of %v has service.version", span.Attributes["service.name"])
+			}
+			continue
+		}
+		found = true
+		if span.Resource.Attributes["service.version"] != "v2" || span.Resource.Attributes["deployment.name"] != "canary" {
+			t.Fatalf("resource=%v want canary v2", span.Resource.Attributes)
+		}
+		if span.Status.Code != "ERROR" {
+			t.Fatalf("canary span status=%s want ERROR", span.Status.Code)
+		}
+	}
+	if !found {
+		t.Fatalf("no spans for %s", service)
+	}
+
+	cfg.Rollout = nil
+	trace = New(cfg).GenerateTrace(now)
+	if got := trace.Spans[0].Resource.Attributes["service.version"]; got != "v1" {
+		t.Fatalf("settled version=%v want v1", got)
+	}
+}
+
+func TestParentsEncloseCanaryChildren(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Profile = "payment-system"
+	cfg.Depth = 4
+	cfg.Fanout = 3
+	cfg.Errors = 0
+	cfg.Retries = 0
+	cfg.Rollout = &config.Rollout{Service: "payment-service", From: "v1", To: "v2", CanaryStart: 1, CanaryEnd: 1, Latency: 20}
+	g := New(cfg)
+	canaries := 0
+	for i := 0; i < 20; i++ {
+		trace := g.GenerateTrace(time.Unix(1700000000, 0).UTC())
+		byID := map[model.SpanID]model.Span{}
+		for _, span := range trace.Spans {
+			byID[span.SpanID] = span
+		}
+		for _, span := range trace.Spans {
+			if span.Resource.Attributes["deployment.name"] != "canary" || !span.HasParent {
+				continue
+			}
+			canaries++
+			parent := byID[span.ParentSpanID]
+			if parent.StartTime.Add(parent.Duration).Before(span.StartTime.Add(span.Duration)) {
+				t.Fatalf("parent %q ends before its canary child %q", parent.Name, span.Name)
+			}
+		}
+	}
+	if canaries == 0 {
+		t.Fatal("expected canary child spans")
+	}
+}
+
+func TestFaultsHitOnlyMatchingSpans(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Profile = "payment-system"
+	cfg.Depth = 4
+	cfg.Fanout = 3
+	cfg.Errors = 0
+	cfg.Retries = 0
+	cfg.Faults = []config.Fault{
+		{Service: "payment-service", Rate: 1, Status: 503, Latency: 800 * time.Millisecond},
+		{Service: "ledger-service", Rate: 1, Timeout: 5 * time.Second},
+	}
+	g := New(cfg)
+	faulted := 0
+	for i := 0; i < 20; i++ {
+		trace := g.GenerateTrace(time.Unix(1700000000, 0).UTC())
+		for _, span := range trace.Spans {
+			switch span.Attributes["service.name"] {
+			case "payment-service":
+				faulted++
+				if span.Status.Code != "ERROR" || span.Attributes["http.status_code"] != 503 || span.Duration < 800*time.Millisecond {
+					t.Fatalf("payment span status=%s http=%v duration=%s", span.Status.Code, span.Attributes["http.status_code"], span.Duration)
+				}
+			case "ledger-service":
+				faulted++
+				if span.Status.Code != "ERROR" || span.Attributes["http.status_code"] != 504 || span.Duration < 5*time.Second {
+					t.Fatalf("ledger span status=%s http=%v duration=%s", span.Status.Code, span.Attributes["http.status_code"], span.Duration)
+				}
+			default:
+				if span.Attributes["spanforge.fault"] != nil {
+					t.Fatalf("span of %v faulted", span.Attributes["service.name"])
+				}
+			}
+		}
+	}
+	if faulted == 0 {
+		t.Fatal("expected faulted spans")
+	}
+}
+
+func TestParentsEncloseFaultedChildren(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Profile = "payment-system"
+	cfg.Depth = 4
+	cfg.Fanout = 3
+	cfg.Errors = 0
+	cfg.Retries = 0
+	cfg.Faults = []config.Fault{
+		{Service: "payment-service", Rate: 1, Latency: 2 * time.Second},
+		{Service: "ledger-service", Rate: 1, Timeout: 5 * time.Second},
+	}
+	g := New(cfg)
+	faulted := 0
+	for i := 0; i < 20; i++ {
+		trace := g.GenerateTrace(time.Unix(1700000000, 0).UTC())
+		byID := map[model.SpanID]model.Span{}
+		for _, span := range trace.Spans {
+			byID[span.SpanID] = span
+		}
+		for _, span := range trace.Spans {
+			if span.Attributes["spanforge.fault"] == nil || !span.HasParent {
+				continue
+			}
+			faulted++
+			parent := byID[span.ParentSpanID]
+			if parent.StartTime.Add(parent.Duration).Before(span.StartTime.Add(span.Duration)) {
+				t.Fatalf("parent %q ends before its faulted child %q", parent.Name, span.Name)
+			}
+		}
+	}
+	if faulted == 0 {
+		t.Fatal("expected faulted child spans")
+	}
+}
+
+func TestErrorPropagationSurfacesFailureToRoot(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Profile = "payment-system"
+	cfg.Depth = 3
+	cfg.Fanout = 3
+	cfg.Errors = 0
+	cfg.Retries = 0
+	cfg.Faults = []config.Fault{{Service: "fraud-service", Rate: 1, Status: 503}}
+	cfg.ErrorPropagation = 1
+	cfg.ErrorShortCircuit = 1
+	g := New(cfg)
+
+	checked, surfaced := 0, 0
+	for i := 0; i < 30; i++ {
+		trace := g.GenerateTrace(time.Unix(1700000000, 0).UTC())
+		hasFault := false
+		lastChildFailed := map[model.SpanID]bool{}
+		for _, span := range trace.Spans {
+			if span.Attributes["spanforge.fault"] != nil {
+				hasFault = true
+			}
+			if !span.HasParent || span.Attributes["retry.attempt"] != nil {
+				continue
+			}
+			if lastChildFailed[span.ParentSpanID] {
+				t.Fatalf("span %q was called after a sibling failed", span.Name)
+			}
+			if span.Status.Code == "ERROR" {
+				lastChildFailed[span.ParentSpanID] = true
+			}
+		}
+		if !hasFault {
+			continue
+		}
+		checked++
+		root := trace.Spans[0]
+		if root.Status.Code != "ERROR" {
+			t.Fatalf("root status=%s want ERROR", root.Status.Code)
+		}
+		if root.Status.Message == "downstream failure" {
+			surfaced++
+			if root.Attributes["http.status_code"] != 502 {
+				t.Fatalf("root http.status_code=%v want 502", root.Attributes["http.status_code"])
+			}
+		}
+	}
+	if checked == 0 || surfaced == 0 {
+		t.Fatalf("traces hitting the fault=%d surfaced at root=%d want both > 0", checked, surfaced)
+	}
+}
+
+func TestRetriesAreSiblingAttemptsSeparatedByBackoff(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Errors = 0.4
+	cfg.Retries = 1
+	cfg.RetryMaxAttempts = 4
+	cfg.RetryBackoff = 50 * time.Millisecond
+	cfg.RetryJitter = 0.5
+	g := New(cfg)
+
+	retries := 0
+	for i := 0; i < 50; i++ {
+		trace := g.GenerateTrace(time.Unix(1700000000, 0).UTC())
+		byID := map[model.SpanID]model.Span{}
+		for _, span := range trace.Spans {
+			byID[span.SpanID] = span
+		}
+		previous := map[string]model.Span{}
+		for _, span := range trace.Spans {
+			if !span.HasParent {
+				continue
+			}
+			key := string(span.ParentSpanID[:]) + span.Name
+			attempt, retried := span.Attributes["retry.attempt"].(int)
+			if !retried {
+				previous[key] = span
+				continue
+			}
+			retries++
+			prev, ok := previous[key]
+			if !ok || prev.Status.Code != "ERROR" {
+				t.Fatalf("attempt %d of %q does not follow a failed sibling", attempt, span.Name)
+			}
+			if span.Kind != prev.Kind || span.Attributes["http.resend_count"] != attempt {
+				t.Fatalf("attempt %d kind=%s resend_count=%v", attempt, span.Kind, span.Attributes["http.resend_count"])
+			}
+			minGap := cfg.RetryBackoff << (attempt - 1) / 2
+			if gap := span.StartTime.Sub(prev.StartTime.Add(prev.Duration)); gap < minGap {
+				t.Fatalf("attempt %d gap=%s want >= %s", attempt, gap, minGap)
+			}
+			if attempt >= cfg.RetryMaxAttempts {
+				t.Fatalf("attempt %d exceeds max attempts %d", attempt, cfg.RetryMaxAttempts)
+			}
+			parent := byID[span.ParentSpanID]
+			if parent.StartTime.Add(parent.Duration).Before(span.StartTime.Add(span.Duration)) {
+				t.Fatalf("parent %q ends before attempt %d", parent.Name, attempt)
+			}
+			previous[key] = span
+		}
+	}
+	if retries == 0 {
+		t.Fatal("expected retry attempts")
+	}
+}
+
+func TestRetryAttemptsHaveTheirOwnResource(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Errors = 0.4
+	cfg.Retries = 1
+	cfg.RetryMaxAttempts = 4
+	g := New(cfg)
+
+	retries := 0
+	for i := 0; i < 50; i++ {
+		trace := g.GenerateTrace(time.Unix(1700000000, 0).UTC())
+		seen := map[uintptr]model.SpanID{}
+		for _, span := range trace.Spans {
+			if span.Resource.Attributes == nil {
+				continue
+			}
+			if _, retried := span.Attributes["retry.attempt"]; retried {
+				retries++
+			}
+			ptr := reflect.ValueOf(span.Resource.Attributes).Pointer()
+			if other, ok := seen[ptr]; ok {
+				t.Fatalf("span %x shares resource attributes with span %x", span.SpanID, other)
+			}
+			seen[ptr] = span.SpanID
+		}
+	}
+	if retries == 0 {
+		t.Fatal("expected retry attempts")
+	}
+}
+
+func TestRetryStormMultipliesDownstreamCalls(t *testing.T) {
+	countSpans := func(cfg config.Config) int {
+		g := New(cfg)
+		total := 0
+		for i := 0; i < 50; i++ {
+			total += len(g.GenerateTrace(time.Unix(1700000000, 0).UTC()).Spans)
+		}
+		return total
+	}
+	cfg := baseConfig()
+	cfg.Errors = 0.3
+	cfg.Retries = 0
+	cfg.RetryMaxAttempts = 4
+	cfg.RetryBackoff = 10 * time.Millisecond
+	calm := countSpans(cfg)
+	cfg.RetryStorm = true
+	storm := countSpans(cfg)
+	if storm <= calm*5/4 {
+		t.Fatalf("spans with retry storm=%d without=%d want clearly more", storm, calm)
+	}
+}
+
+func TestRetryPolicyForServiceOverridesDefault(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Errors = 0.4
+	cfg.Retries = 1
+	cfg.RetryMaxAttempts = 3
+	cfg.RetryPolicies = []config.RetryPolicy{{Service: "svc-1", MaxAttempts: 1}}
+	g := New(cfg)
+
+	retried := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		for _, span := range g.GenerateTrace(time.Unix(1700000000, 0).UTC()).Spans {
+			if span.Attributes["retry.attempt"] != nil {
+				retried[span.Attributes["service.name"].(string)] = true
+			}
+		}
+	}
+	if retried["svc-1"] {
+		t.Fatal("svc-1 calls were retried despite a one-attempt policy")
+	}
+	if len(retried) == 0 {
+		t.Fatal("expected retries for other services")
+	}
+}
+
+func TestDeadlinesEndSlowCallsAndSkipLaterCalls(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Errors = 0
+	cfg.Retries = 0
+	cfg.Fanout = 2
+	cfg.Deadline = 40 * time.Millisecond
+	g := New(cfg)
+
+	timedOut, continued := 0, 0
+	for i := 0; i < 50; i++ {
+		trace := g.GenerateTrace(time.Unix(1700000000, 0).UTC())
+		byID := map[model.SpanID]model.Span{}
+		for _, span := range trace.Spans {
+			byID[span.SpanID] = span
+		}
+		failedChild := map[model.SpanID]bool{}
+		for _, span := range trace.Spans {
+			if !span.HasParent {
+				continue
+			}
+			if failedChild[span.ParentSpanID] {
+				t.Fatalf("span %q was called after a sibling timed out", span.Name)
+			}
+			if span.Kind == "CLIENT" && span.Duration > cfg.Deadline {
+				t.Fatalf("call %q lasted %s past the %s deadline", span.Name, span.Duration, cfg.Deadline)
+			}
+			if span.Status.Message == "DEADLINE_EXCEEDED" {
+				timedOut++
+				failedChild[span.ParentSpanID] = true
+				if span.Attributes["http.status_code"] != 504 {
+					t.Fatalf("timed-out call http.status_code=%v want 504", span.Attributes["http.status_code"])
+				}
+				for _, event := range span.Events {
+					msg, _ := event.Attributes["exception.message"].(string)
+					if event.Name == "exception" && strings.HasPrefix(msg, "deadline of") && msg != "deadline of 40ms exceeded" {
+						t.Fatalf("exception.message=%q want the 40ms deadline that applied", msg)
+					}
+				}
+			}
+			if parent := byID[span.ParentSpanID]; parent.Status.Message == "DEADLINE_EXCEEDED" && span.StartTime.Add(span.Duration).After(parent.StartTime.Add(parent.Duration)) {
+				continued++
+			}
+		}
+	}
+	if timedOut == 0 || continued == 0 {
+		t.Fatalf("timed-out calls=%d calls continuing after the deadline=%d want both > 0", timedOut, continued)
+	}
+}
+
+func TestDeadlineCancelCutsWorkBehindTimedOutCall(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Errors = 0
+	cfg.Retries = 0
+	cfg.Fanout = 2
+	cfg.DeadlinePolicies = []config.DeadlinePolicy{{Caller: "api-gateway", Deadline: 30 * time.Millisecond}}
+	cfg.DeadlineCancel = 1
+	g := New(cfg)
+
+	cancelled := 0
+	for i := 0; i < 50; i++ {
+		trace := g.GenerateTrace(time.Unix(1700000000, 0).UTC())
+		byID := map[model.SpanID]model.Span{}
+		for _, span := range trace.Spans {
+			byID[span.SpanID] = span
+		}
+		for _, span := range trace.Spans {
+			parent, ok := byID[span.ParentSpanID]
+			if !span.HasParent || !ok {
+				continue
+			}
+			if parent.Status.Code == "ERROR" && (parent.Status.Message == "DEADLINE_EXCEEDED" || parent.Status.Message == "cancelled") &&
+				span.StartTime.Add(span.Duration).After(parent.StartTime.Add(parent.Duration)) {
+				t.Fatalf("span %q outlived its cancelled caller", span.Name)
+			}
+			if span.Status.Message == "cancelled" {
+				cancelled++
+				if len(span.Events) == 0 || span.Events[len(span.Events)-1].Name != "cancelled" {
+					t.Fatalf("cancelled span %q has no cancelled event", span.Name)
+				}
+			}
+		}
+	}
+	if cancelled == 0 {
+		t.Fatal("expected cancelled spans")
+	}
+}
@@ -0,0 +1,205 @@
+package generator
+
+import (
+	"math"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+const (
+	z99 = 2.3263478740408408
+	// bimodalHitSigma is the spread of cache hits around their median.
+	bimodalHitSigma = 0.25
+	// paretoMaxQuantile caps Pareto samples so one span cannot last for hours.
+	paretoMaxQuantile = 0.9999
+)
+
+// latencyModel samples durations by inverting a quantile function fitted to
+// p50, p95 and p99. The lognormal, bimodal and Pareto models pass through
+// all three; the fixed model is a least-squares fit.
+type latencyModel struct {
+	kind string
+	p50  float64
+	p95  float64
+	p99  float64
+	// lognormal spread below and above p95.
+	sigmaBody float64
+	sigmaTail float64
+	// pareto tail index above p95.
+	alpha float64
+	// fixed base plus uniform jitter.
+	base   float64
+	jitter float64
+	// bimodal hit share, hit median and miss lognormal.
+	hitShare  float64
+	hitMedian float64
+	missMu    float64
+	missSigma float64
+}
+
+// operationLatency applies a fitted model to spans of one service or operation.
+type operationLatency struct {
+	service   string
+	operation string
+	model     latencyModel
+}
+
+func fitLatencyModel(m config.LatencyModel, cacheHitRate float64) latencyModel {
+	fit := latencyModel{
+		kind: m.Kind,
+		p50:  math.Max(float64(m.P50), float64(time.Microsecond)),
+		p95:  math.Max(float64(m.P95), float64(time.Microsecond)),
+		p99:  math.Max(float64(m.P99), float64(time.Microsecond)),
+	}
+	fit.p95 = math.Max(fit.p95, fit.p50)
+	fit.p99 = math.Max(fit.p99, fit.p95)
+	fit.sigmaBody = math.Log(fit.p95/fit.p50) / z95
+	fit.sigmaTail = math.Log(fit.p99/fit.p95) / (z99 - z95)
+	switch m.Kind {
+	case config.LatencyPareto:
+		if fit.p99 > fit.p95 {
+			fit.alpha = math.Log(5) / math.Log(fit.p99/fit.p95)
+		}
+	case config.LatencyFixed:
+		qs := []float64{0.5, 0.95, 0.99}
+		ps := []float64{fit.p50, fit.p95, fit.p99}
+		var meanQ, meanP float64
+		for i := range qs {
+			meanQ += qs[i] / 3
+			meanP += ps[i] / 3
+		}
+		var cov, variance float64
+		for i := range qs {
+			cov += (qs[i] - meanQ) * (ps[i] - meanP)
+			variance += (qs[i] - meanQ) * (qs[i] - meanQ)
+		}
+		fit.jitter = math.Max(cov/variance, 0)
+		fit.base = math.Max(meanP-fit.jitter*meanQ, 0)
+	case config.LatencyBimodal:
+		// Hits must hold the median and misses the tail; otherwise there is
+		// no second mode to fit and the model stays lognormal.
+		if cacheHitRate <= 0.5 || cacheHitRate >= 0.95 {
+			fit.kind = config.LatencyLognormal
+			break
+		}
+		fit.hitShare = cacheHitRate
+		fit.hitMedian = fit.p50 / math.Exp(bimodalHitSigma*probit(0.5/cacheHitRate))
+		c95 := probit((0.95 - cacheHitRate) / (1 - cacheHitRate))
+		c99 := probit((0.99 - cacheHitRate) / (1 - cacheHitRate))
+		fit.missSigma = math.Log(fit.p99/fit.p95) / (c99 - c95)
+		fit.missMu = math.Log(fit.p95) - fit.missSigma*c95
+	}
+	return fit
+}
+
+// sample draws a duration for span. Bimodal models follow the span's
+// cache.hit attribute when it has one.
+func (m latencyModel) sample(rng *RNG, span *model.Span) time.Duration {
+	var x float64
+	switch m.kind {
+	case config.LatencyBimodal:
+		hit, ok := span.Attributes["cache.hit"].(bool)
+		if !ok {
+			hit = rng.Float64() < m.hitShare
+		}
+		z := probit(rng.Float64())
+		if hit {
+			x = m.hitMedian * math.Exp(bimodalHitSigma*z)
+		} else {
+			x = math.Exp(m.missMu + m.missSigma*z)
+		}
+	default:
+		x = m.quantile(rng.Float64())
+	}
+	if x < float64(time.Microsecond) {
+		x = float64(time.Microsecond)
+	}
+	return time.Duration(x)
+}
+
+func (m latencyModel) quantile(u float64) float64 {
+	switch m.kind {
+	case config.LatencyFixed:
+		return m.base + u*m.jitter
+	case config.LatencyPareto:
+		if u > 0.95 && m.alpha > 0 {
+			return m.p95 * math.Pow(0.05/(1-math.Min(u, paretoMaxQuantile)), 1/m.alpha)
+		}
+	}
+	return m.lognormal(probit(u))
+}
+
+// lognormal maps a standard normal z to the spliced lognormal through p50,
+// p95 and p99.
+func (m latencyModel) lognormal(z float64) float64 {
+	if z <= z95 {
+		return m.p50 * math.Exp(m.sigmaBody*z)
+	}
+	return m.p95 * math.Exp(m.sigmaTail*(z-z95))
+}
+
+// probit is the standard normal quantile function.
+func probit(u float64) float64 {
+	u = math.Min(math.Max(u, 1e-9), 1-1e-9)
+	return math.Sqrt2 * math.Erfinv(2*u-1)
+}
+
+// profileLatencyModels gives profile operations their own latency shapes
+// when --profile-latency-models is set. Their percentiles come from --p50,
+// --p95 and --p99.
+func profileLatencyModels(cfg config.Config) []config.LatencyModel {
+	if !cfg.ProfileLatency {
+		return nil
+	}
+	switch cfg.Profile {
+	case "payment-system":
+		return []config.LatencyModel{
+			{Operation: "load cart", Kind: config.LatencyBimodal},
+			{Operation: "authorize payment", Kind: config.LatencyPareto},
+		}
+	case "api-gateway":
+		return []config.LatencyModel{
+			{Operation: "check rate limit", Kind: config.LatencyFixed},
+		}
+	}
+	return nil
+}
+
+// operationLatencies fits --latency-model entries, then the profile's own
+// models. The first model that matches a span is used.
+func operationLatencies(cfg config.Config) []operationLatency {
+	models := append(append([]config.LatencyModel(nil), cfg.LatencyModels...), profileLatencyModels(cfg)...)
+	ops := make([]operationLatency, 0, len(models))
+	for _, m := range models {
+		filled := m.Fill(cfg.P50, cfg.P95, cfg.P99)
+		ops = append(ops, operationLatency{service: m.Service, operation: m.Operation, model: fitLatencyModel(filled, cfg.CacheHitRate)})
+	}
+	return ops
+}
+
+// sampleOperationLatency replaces span's duration when a latency model
+// covers its service or operation. The model sets the whole duration, so
+// the profile and --variety multipliers do not apply on top.
+func (g *Generator) sampleOperationLatency(span *model.Span) {
+	for _, op := range g.operations {
+		if spanMatches(op.service, op.operation, *span) {
+			span.Duration = op.model.sample(g.rng, span)
+			return
+		}
+	}
+}
+
+// LatencyTarget returns the latency model cfg generates span with, with all
+// percentiles filled in. It reports false for spans no model covers: their
+// durations also carry the profile and --variety multipliers and stretch to
+// cover children, so --p50, --p95 and --p99 are not a fair target for them.
+func LatencyTarget(cfg config.Config, span model.Span) (config.LatencyModel, bool) {
+	for _, m := range append(append([]config.LatencyModel(nil), cfg.LatencyModels...), profileLatencyModels(cfg)...) {
+		if spanMatches(m.Service, m.Operation, span) {
+			return m.Fill(cfg.P50, cfg.P95, cfg.P99), true
+		}
+	}
+	return config.LatencyModel{}, false
+}
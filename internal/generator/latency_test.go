@@ -0,0 +1,82 @@
+package generator
+
+import (
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+func TestLatencyModelsHonourPercentiles(t *testing.T) {
+	tests := []struct {
+		kind          string
+		p50, p95, p99 time.Duration
+		tolerance     float64
+	}{
+		{kind: config.LatencyLognormal, p50: 20 * time.Millisecond, p95: 100 * time.Millisecond, p99: 400 * time.Millisecond, tolerance: 0.08},
+		{kind: config.LatencyPareto, p50: 20 * time.Millisecond, p95: 100 * time.Millisecond, p99: 400 * time.Millisecond, tolerance: 0.08},
+		{kind: config.LatencyBimodal, p50: 5 * time.Millisecond, p95: 80 * time.Millisecond, p99: 200 * time.Millisecond, tolerance: 0.15},
+		{kind: config.LatencyFixed, p50: 100 * time.Millisecond, p95: 145 * time.Millisecond, p99: 149 * time.Millisecond, tolerance: 0.03},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			m := fitLatencyModel(config.LatencyModel{Kind: tt.kind, P50: tt.p50, P95: tt.p95, P99: tt.p99}, 0.8)
+			rng := NewRNG(7)
+			samples := make([]float64, 40000)
+			for i := range samples {
+				samples[i] = float64(m.sample(rng, &model.Span{}))
+			}
+			sort.Float64s(samples)
+			for _, want := range []struct {
+				q      float64
+				target time.Duration
+			}{{0.5, tt.p50}, {0.95, tt.p95}, {0.99, tt.p99}} {
+				got := samples[int(want.q*float64(len(samples)))]
+				if math.Abs(got-float64(want.target))/float64(want.target) > tt.tolerance {
+					t.Fatalf("p%.0f=%s want %s", want.q*100, time.Duration(got), want.target)
+				}
+			}
+		})
+	}
+}
+
+func TestOperationLatencyModelOverridesDefault(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Errors = 0
+	cfg.LatencyModels = []config.LatencyModel{{Operation: "GET /cart", Kind: config.LatencyFixed, P50: 500 * time.Millisecond, P95: 590 * time.Millisecond, P99: 598 * time.Millisecond}}
+	g := New(cfg)
+
+	matched := 0
+	for i := 0; i < 50; i++ {
+		for _, span := range g.GenerateTrace(time.Unix(1700000000, 0).UTC()).Spans {
+			if span.Attributes["http.route"] != "/cart" || span.Attributes["retry.attempt"] != nil {
+				continue
+			}
+			matched++
+			if span.Duration < 400*time.Millisecond || span.Duration > 600*time.Millisecond {
+				t.Fatalf("GET /cart span lasted %s want fixed 400ms-600ms", span.Duration)
+			}
+		}
+	}
+	if matched == 0 {
+		t.Fatal("expected GET /cart spans")
+	}
+
+	target, ok := LatencyTarget(cfg, model.Span{Name: "GET /cart", Attributes: model.Attrs{"http.route": "/cart"}})
+	if !ok || target.Kind != config.LatencyFixed || target.P99 != 598*time.Millisecond {
+		t.Fatalf("target=%+v want the fixed model", target)
+	}
+	paymentCfg := baseConfig()
+	paymentCfg.Profile = "payment-system"
+	if target, ok = LatencyTarget(paymentCfg, model.Span{Name: "authorize payment"}); ok {
+		t.Fatalf("payment target=%+v want no model until profile models are turned on", target)
+	}
+	paymentCfg.ProfileLatency = true
+	target, ok = LatencyTarget(paymentCfg, model.Span{Name: "authorize payment"})
+	if !ok || target.Kind != config.LatencyPareto || target.P95 != paymentCfg.P95 {
+		t.Fatalf("payment target=%+v want pareto with the --p95 shape", target)
+	}
+}
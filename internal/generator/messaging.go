@@ -0,0 +1,175 @@
+package generator
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+// messagingPartitions is the number of partitions each synthetic topic is spread over.
+const messagingPartitions = 12
+
+// publishedMessage is what a linked consumer needs to point back at the producer of one message.
+type publishedMessage struct {
+	traceID     model.TraceID
+	spanID      model.SpanID
+	resource    model.Resource
+	consumer    model.Span
+	partition   string
+	offset      int64
+	level       int
+	publishedAt time.Time
+}
+
+// messagingState holds partition offsets and messages waiting for a linked consumer.
+type messagingState struct {
+	offsets map[string]int64
+	pending map[string][]publishedMessage
+	ready   []model.Trace
+}
+
+// stampMessage places a message on a random partition of its topic and records its offset on span.
+func (g *Generator) stampMessage(span *model.Span) (string, int64) {
+	if g.messaging.offsets == nil {
+		g.messaging.offsets = map[string]int64{}
+	}
+	topic, _ := span.Attributes["messaging.destination.name"].(string)
+	partition := strconv.Itoa(g.rng.Intn(messagingPartitions))
+	key := topic + "/" + partition
+	offset, ok := g.messaging.offsets[key]
+	if !ok {
+		offset = int64(g.rng.Intn(1_000_000))
+	}
+	g.messaging.offsets[key] = offset + 1
+	span.Attributes["messaging.destination.partition.id"] = partition
+	span.Attributes["messaging.kafka.offset"] = offset
+	return partition, offset
+}
+
+func consumerGroup(service string) string {
+	return service + "-consumers"
+}
+
+// publishLinked records producer in trace and queues its message for a
+// consumer that runs in a separate trace, as current messaging semantic
+// conventions model it. The consumer span built with the producer is only a template.
+func (g *Generator) publishLinked(trace *model.Trace, producer model.Span, consumer model.Span, level int) {
+	partition, offset := g.stampMessage(&producer)
+	g.applyRunAttrs(&producer)
+	g.applyCardinalityAttrs(&producer)
+	g.maybeAddProfileEvent(&producer)
+	g.maybeFail(&producer)
+	trace.Spans = append(trace.Spans, producer)
+
+	if g.messaging.pending == nil {
+		g.messaging.pending = map[string][]publishedMessage{}
+	}
+	topic, _ := producer.Attributes["messaging.destination.name"].(string)
+	g.messaging.pending[topic] = append(g.messaging.pending[topic], publishedMessage{
+		traceID:     producer.TraceID,
+		spanID:      producer.SpanID,
+		resource:    trace.Resource,
+		consumer:    consumer,
+		partition:   partition,
+		offset:      offset,
+		level:       level,
+		publishedAt: producer.StartTime.Add(producer.Duration),
+	})
+	if len(g.messaging.pending[topic]) >= g.messagingBatch() {
+		g.receiveLinked(topic)
+	}
+}
+
+// receiveLinked builds one consumer trace for the messages pending on topic.
+// A single message is processed directly; a batch gets a receive span with
+// one link per producer.
+func (g *Generator) receiveLinked(topic string) {
+	msgs := g.messaging.pending[topic]
+	if len(msgs) == 0 {
+		return
+	}
+	delete(g.messaging.pending, topic)
+
+	publishedAt := msgs[0].publishedAt
+	for _, msg := range msgs[1:] {
+		if msg.publishedAt.After(publishedAt) {
+			publishedAt = msg.publishedAt
+		}
+	}
+	service, _ := msgs[0].consumer.Attributes["service.name"].(string)
+	root := msgs[0].consumer
+	root.Attributes = cloneAttrs(root.Attributes)
+	root.TraceID = g.newTraceID()
+	root.SpanID = g.newSpanID()
+	root.ParentSpanID = model.SpanID{}
+	root.HasParent = false
+	root.Links = nil
+	root.StartTime = publishedAt.Add(g.cfg.MessagingLag.Sample(g.rng.Float64))
+	root.Attributes["messaging.consumer.group.name"] = consumerGroup(service)
+	if len(msgs) == 1 {
+		root.Attributes["messaging.destination.partition.id"] = msgs[0].partition
+		root.Attributes["messaging.kafka.offset"] = msgs[0].offset
+	} else {
+		root.Name = "receive " + topic
+		root.Attributes["messaging.operation"] = "receive"
+		root.Attributes["messaging.batch.message_count"] = len(msgs)
+	}
+	for _, msg := range msgs {
+		root.Links = append(root.Links, model.Link{
+			TraceID: msg.traceID,
+			SpanID:  msg.spanID,
+			Attributes: model.Attrs{
+				"link.type":                          "follows_from",
+				"messaging.destination.partition.id": msg.partition,
+				"messaging.kafka.offset":             msg.offset,
+			},
+		})
+	}
+
+	trace := model.Trace{TraceID: root.TraceID, Resource: model.Resource{Attributes: cloneAttrs(msgs[0].resource.Attributes)}}
+	g.applyRunAttrs(&root)
+	g.applyCardinalityAttrs(&root)
+	g.maybeAddProfileEvent(&root)
+	g.maybeFail(&root)
+	trace.Spans = append(trace.Spans, root)
+	g.generateChildren(&trace, root, msgs[0].level+1)
+	g.finishTrace(&trace)
+	g.messaging.ready = append(g.messaging.ready, trace)
+}
+
+// TakeLinked returns the consumer traces completed since the last call. Only
+// the queue profile with --messaging-mode linked produces them.
+func (g *Generator) TakeLinked() []model.Trace {
+	ready := g.messaging.ready
+	g.messaging.ready = nil
+	return ready
+}
+
+// FlushLinked delivers partially filled batches and returns every pending consumer trace.
+func (g *Generator) FlushLinked() []model.Trace {
+	for len(g.messaging.pending) > 0 {
+		topics := make([]string, 0, len(g.messaging.pending))
+		for topic := range g.messaging.pending {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+		for _, topic := range topics {
+			g.receiveLinked(topic)
+		}
+	}
+	return g.TakeLinked()
+}
+
+func (g *Generator) messagingBatch() int {
+	return max(1, g.cfg.MessagingBatch)
+}
+
+func cloneAttrs(attrs model.Attrs) model.Attrs {
+	out := make(model.Attrs, len(attrs))
+	for k, v := range attrs {
+		out[k] = v
+	}
+	return out
+}
@@ -93,6 +93,17 @@ func Profiles() []ProfileInfo {
 				"route cardinality",
 			},
 		},
+		{
+			Name:        "workflow",
+			Description: "Long-running saga and orchestration traces whose spans finish over minutes or hours.",
+			Services:    []string{"workflow-engine", "inventory-service", "payment-service", "warehouse-service", "shipping-service", "notification-service", "etl-worker"},
+			Routes:      []string{"order-fulfilment", "payment-saga", "batch-orchestration", "refund-saga"},
+			FailureModes: []string{
+				"activity failures",
+				"slow compensation steps",
+				"retry attempts",
+			},
+		},
 	}
 }
 
@@ -117,6 +128,8 @@ func moduleFor(name string) profileModule {
 		return paymentProfile{}
 	case "api-gateway":
 		return apiGatewayProfile{}
+	case "workflow":
+		return workflowProfile{}
 	default:
 		return webProfile{}
 	}
@@ -302,7 +315,7 @@ func (queueProfile) buildQueuePair(parent model.Span, service string, routeIdx i
 		Status:   model.SpanStatus{Code: "OK"},
 		Resource: model.Resource{Attributes: model.Attrs{"service.name": service}},
 	}
-	producer.Links = append(producer.Links, model.Link{TraceID: traceID, SpanID: consumerID, Attributes: model.Attrs{"link.type": "follows_from"}})
+	consumer.Links = append(consumer.Links, model.Link{TraceID: traceID, SpanID: producerID, Attributes: model.Attrs{"link.type": "follows_from"}})
 	return producer, consumer
 }
 
@@ -495,6 +508,54 @@ func (apiGatewayProfile) buildQueuePair(parent model.Span, service string, route
 	return model.Span{}, model.Span{}
 }
 
+type workflowProfile struct{}
+
+func (workflowProfile) buildRoot(frontdoor string, routeIdx int, start time.Time, spanID model.SpanID, traceID model.TraceID, dur time.Duration) model.Span {
+	name := workflowName(routeIdx)
+	return model.Span{
+		TraceID:   traceID,
+		SpanID:    spanID,
+		Name:      "workflow " + name,
+		Kind:      "INTERNAL",
+		StartTime: start,
+		Duration:  dur,
+		Attributes: model.Attrs{
+			"service.name":  "workflow-engine",
+			"workflow.name": name,
+			"workflow.id":   fmtHexID(traceID, spanID),
+		},
+		Status:   model.SpanStatus{Code: "OK"},
+		Resource: model.Resource{Attributes: model.Attrs{"service.name": "workflow-engine"}},
+	}
+}
+
+func (workflowProfile) buildChild(parent model.Span, service string, routeIdx int, start time.Time, spanID model.SpanID, traceID model.TraceID, dur time.Duration, dbHeavy bool, cacheHit bool) model.Span {
+	attrs := model.Attrs{
+		"service.name":  service,
+		"peer.service":  service,
+		"workflow.name": parent.Attributes["workflow.name"],
+		"workflow.step": parent.Attributes["workflow.step"],
+	}
+	addStoreAttrs(attrs, dbHeavy, cacheHit)
+	return model.Span{
+		TraceID:      traceID,
+		SpanID:       spanID,
+		ParentSpanID: parent.SpanID,
+		HasParent:    true,
+		Name:         "activity " + fmt.Sprint(parent.Attributes["workflow.step"]),
+		Kind:         "CLIENT",
+		StartTime:    start,
+		Duration:     dur,
+		Attributes:   attrs,
+		Status:       model.SpanStatus{Code: "OK"},
+		Resource:     model.Resource{Attributes: model.Attrs{"service.name": service}},
+	}
+}
+
+func (workflowProfile) buildQueuePair(parent model.Span, service string, routeIdx int, start time.Time, producerID model.SpanID, consumerID model.SpanID, traceID model.TraceID, dur time.Duration) (model.Span, model.Span) {
+	return model.Span{}, model.Span{}
+}
+
 func addStoreAttrs(attrs model.Attrs, dbHeavy bool, cacheHit bool) {
 	if dbHeavy {
 		attrs["db.system"] = "postgresql"
@@ -546,6 +607,51 @@ func batchJob(idx int) string {
 	return jobs[idx%len(jobs)]
 }
 
+func workflowName(idx int) string {
+	names := []string{"order-fulfilment", "payment-saga", "batch-orchestration", "refund-saga"}
+	return names[idx%len(names)]
+}
+
+type workflowStep struct {
+	name    string
+	service string
+}
+
+func workflowSteps(name string) []workflowStep {
+	switch name {
+	case "payment-saga":
+		return []workflowStep{
+			{name: "authorize-payment", service: "payment-service"},
+			{name: "fraud-review", service: "payment-service"},
+			{name: "capture-payment", service: "payment-service"},
+			{name: "notify-customer", service: "notification-service"},
+		}
+	case "batch-orchestration":
+		return []workflowStep{
+			{name: "extract", service: "etl-worker"},
+			{name: "transform", service: "etl-worker"},
+			{name: "load", service: "etl-worker"},
+			{name: "publish-report", service: "notification-service"},
+		}
+	case "refund-saga":
+		return []workflowStep{
+			{name: "validate-refund", service: "payment-service"},
+			{name: "reverse-payment", service: "payment-service"},
+			{name: "restock-items", service: "inventory-service"},
+			{name: "notify-customer", service: "notification-service"},
+		}
+	default:
+		return []workflowStep{
+			{name: "reserve-inventory", service: "inventory-service"},
+			{name: "charge-payment", service: "payment-service"},
+			{name: "pick-items", service: "warehouse-service"},
+			{name: "pack-order", service: "warehouse-service"},
+			{name: "ship-order", service: "shipping-service"},
+			{name: "notify-customer", service: "notification-service"},
+		}
+	}
+}
+
 func paymentOperation(idx int) (string, string) {
 	ops := []struct {
 		method string
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/robmcelhinney/spanforge/internal/config"
 	"github.com/robmcelhinney/spanforge/internal/model"
 )
 
@@ -26,25 +27,90 @@ func TestQueueProfileCreatesProducerConsumerWithLink(t *testing.T) {
 	cfg.Depth = 2
 	cfg.Fanout = 2
 	trace := New(cfg).GenerateTrace(time.Now().UTC())
-	producerFound := false
-	consumerFound := false
-	linkFound := false
+	producers := map[model.SpanID]model.Span{}
+	var consumers []model.Span
 	for _, s := range trace.Spans {
 		if s.Kind == "PRODUCER" {
-			producerFound = true
-			if len(s.Links) > 0 {
-				linkFound = true
-			}
+			producers[s.SpanID] = s
 		}
 		if s.Kind == "CONSUMER" {
-			consumerFound = true
+			consumers = append(consumers, s)
 		}
 	}
-	if !producerFound || !consumerFound {
-		t.Fatalf("expected producer and consumer spans; producer=%v consumer=%v", producerFound, consumerFound)
+	if len(producers) == 0 || len(consumers) == 0 {
+		t.Fatalf("expected producer and consumer spans; producers=%d consumers=%d", len(producers), len(consumers))
+	}
+	for _, consumer := range consumers {
+		if len(consumer.Links) != 1 {
+			t.Fatalf("consumer links=%d want 1", len(consumer.Links))
+		}
+		producer, ok := producers[consumer.Links[0].SpanID]
+		if !ok {
+			t.Fatal("expected consumer span link to producer span")
+		}
+		if consumer.Attributes["messaging.kafka.offset"] != producer.Attributes["messaging.kafka.offset"] {
+			t.Fatalf("consumer offset=%v producer offset=%v", consumer.Attributes["messaging.kafka.offset"], producer.Attributes["messaging.kafka.offset"])
+		}
+		if consumer.Attributes["messaging.consumer.group.name"] == nil {
+			t.Fatal("expected messaging.consumer.group.name on consumer")
+		}
 	}
-	if !linkFound {
-		t.Fatal("expected producer span link to consumer span")
+	for _, producer := range producers {
+		if len(producer.Links) != 0 {
+			t.Fatal("producer span should not link to its consumer")
+		}
+	}
+}
+
+func TestQueueProfileLinkedConsumersStartNewTraces(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Profile = "queue"
+	cfg.Routes = 1
+	cfg.Depth = 2
+	cfg.Fanout = 2
+	cfg.MessagingMode = "linked"
+	cfg.MessagingBatch = 3
+	cfg.MessagingLag = config.Distribution{Kind: config.DistributionFixed, A: time.Second}
+	g := New(cfg)
+
+	producers := map[model.SpanID]model.Span{}
+	for i := 0; i < 4; i++ {
+		trace := g.GenerateTrace(time.Unix(1700000000, 0).UTC())
+		for _, s := range trace.Spans {
+			if s.Kind == "CONSUMER" {
+				t.Fatal("producer trace should not contain consumer spans")
+			}
+			if s.Kind == "PRODUCER" {
+				producers[s.SpanID] = s
+			}
+		}
+	}
+	linked := append(g.TakeLinked(), g.FlushLinked()...)
+	if len(linked) == 0 {
+		t.Fatal("expected linked consumer traces")
+	}
+	links := 0
+	for _, trace := range linked {
+		root := trace.Spans[0]
+		if root.Kind != "CONSUMER" || root.HasParent {
+			t.Fatalf("consumer root kind=%s hasParent=%v", root.Kind, root.HasParent)
+		}
+		if len(root.Links) > 1 && root.Attributes["messaging.batch.message_count"] != len(root.Links) {
+			t.Fatalf("batch message_count=%v links=%d", root.Attributes["messaging.batch.message_count"], len(root.Links))
+		}
+		for _, link := range root.Links {
+			producer, ok := producers[link.SpanID]
+			if !ok || link.TraceID != producer.TraceID {
+				t.Fatal("consumer link does not point at a producer span")
+			}
+			if root.StartTime.Before(producer.StartTime.Add(producer.Duration).Add(time.Second)) {
+				t.Fatal("consumer started before producer end plus lag")
+			}
+			links++
+		}
+	}
+	if links != len(producers) {
+		t.Fatalf("consumer links=%d want one per producer (%d)", links, len(producers))
 	}
 }
 
@@ -155,6 +221,43 @@ func TestProfileRegistryIncludesNamedProfiles(t *testing.T) {
 	}
 }
 
+func TestWorkflowProfileSpansLifetime(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Profile = "workflow"
+	cfg.WorkflowLifetime = time.Hour
+	now := time.Unix(1700000000, 0).UTC()
+	trace := New(cfg).GenerateTrace(now)
+
+	root := trace.Spans[0]
+	if root.Duration < 45*time.Minute || root.Duration > time.Hour {
+		t.Fatalf("root duration=%s want 45m-1h", root.Duration)
+	}
+	if root.Attributes["workflow.name"] == nil || root.Attributes["workflow.id"] == nil {
+		t.Fatalf("missing workflow attrs on root: %v", root.Attributes)
+	}
+	steps := 0
+	var lastEnd time.Time
+	for _, span := range trace.Spans[1:] {
+		if span.ParentSpanID != root.SpanID {
+			continue
+		}
+		steps++
+		if span.StartTime.Before(root.StartTime) || span.StartTime.Add(span.Duration).After(root.StartTime.Add(root.Duration).Add(time.Millisecond)) {
+			t.Fatalf("step %q outside root lifetime", span.Name)
+		}
+		if !lastEnd.IsZero() && span.StartTime.Before(lastEnd.Add(-time.Millisecond)) {
+			t.Fatalf("step %q starts before previous step ends", span.Name)
+		}
+		lastEnd = span.StartTime.Add(span.Duration)
+	}
+	if steps < 2 {
+		t.Fatalf("workflow steps=%d want >=2", steps)
+	}
+	if end := root.StartTime.Add(root.Duration); lastEnd.Before(end.Add(-time.Second)) {
+		t.Fatalf("last step ends at %s want near root end %s", lastEnd, end)
+	}
+}
+
 func modelTraceID(seed byte) model.TraceID {
 	var id model.TraceID
 	id[0] = seed
@@ -0,0 +1,94 @@
+package generator
+
+import (
+	"net/http"
+
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+// propagateErrors lets failures climb the call tree. Children are visited
+// before their parents, so a failure can surface through several levels.
+// A caller surfaces a failed call with probability --error-propagation and
+// otherwise absorbs it. When it surfaces, the caller skips the calls it had
+// not made yet with probability --error-short-circuit.
+func (g *Generator) propagateErrors(trace *model.Trace) {
+	if g.cfg.ErrorPropagation <= 0 || len(trace.Spans) < 2 {
+		return
+	}
+	children := map[model.SpanID][]int{}
+	for i, span := range trace.Spans {
+		if span.HasParent {
+			children[span.ParentSpanID] = append(children[span.ParentSpanID], i)
+		}
+	}
+	removed := map[int]bool{}
+	var removeSubtree func(idx int)
+	removeSubtree = func(idx int) {
+		removed[idx] = true
+		for _, child := range children[trace.Spans[idx].SpanID] {
+			removeSubtree(child)
+		}
+	}
+
+	// visit reports whether the span at idx ended in a failure its caller sees.
+	var visit func(idx int) bool
+	visit = func(idx int) bool {
+		kids := children[trace.Spans[idx].SpanID]
+		for n, child := range kids {
+			if removed[child] {
+				continue
+			}
+			if !visit(child) || trace.Spans[child].Kind == "CONSUMER" || retried(trace.Spans, kids[n+1:], trace.Spans[child]) {
+				continue
+			}
+			if g.rng.Float64() >= g.cfg.ErrorPropagation {
+				continue
+			}
+			surfaceFailure(&trace.Spans[idx], trace.Spans[child])
+			if g.rng.Float64() < g.cfg.ErrorShortCircuit {
+				for _, sibling := range kids[n+1:] {
+					removeSubtree(sibling)
+				}
+				break
+			}
+		}
+		return trace.Spans[idx].Status.Code == "ERROR"
+	}
+	for i, span := range trace.Spans {
+		if !span.HasParent {
+			visit(i)
+		}
+	}
+
+	dropSpans(trace, removed)
+}
+
+// retried reports whether a later sibling is the next attempt of the call
+// made by span, so the caller did not give up when span failed.
+func retried(spans []model.Span, later []int, span model.Span) bool {
+	attempt, _ := span.Attributes["retry.attempt"].(int)
+	for _, sibling := range later {
+		next, ok := spans[sibling].Attributes["retry.attempt"].(int)
+		if ok && next == attempt+1 && spans[sibling].Name == span.Name && spans[sibling].Attributes["service.name"] == span.Attributes["service.name"] {
+			return true
+		}
+	}
+	return false
+}
+
+// surfaceFailure fails caller because of the failed call. Timeouts stay 504;
+// other failures become 502 at the edge and 500 in the services behind it.
+func surfaceFailure(caller *model.Span, failed model.Span) {
+	if caller.Status.Code == "ERROR" {
+		return
+	}
+	status := http.StatusInternalServerError
+	switch {
+	case failed.Attributes["http.status_code"] == http.StatusGatewayTimeout:
+		status = http.StatusGatewayTimeout
+	case !caller.HasParent:
+		status = http.StatusBadGateway
+	}
+	service, _ := failed.Attributes["service.name"].(string)
+	failSpan(caller, status, "DownstreamError", "downstream failure", "call to "+service+" failed")
+}
@@ -0,0 +1,117 @@
+package generator
+
+import (
+	"strings"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+// stormFailureRate is the least chance a retry fails during a retry storm,
+// because the callee is still overloaded when the retry lands.
+const stormFailureRate = 0.5
+
+// maybeFail fails span with the generated error rate and reports whether it failed.
+func (g *Generator) maybeFail(span *model.Span) bool {
+	if g.rng.Float64() >= g.errorProbability(span) {
+		return false
+	}
+	markError(span)
+	return true
+}
+
+// failCall is maybeFail for a call its caller may retry. When the call
+// fails it also returns the call as it was before failing, for the retries.
+func (g *Generator) failCall(span *model.Span) (model.Span, bool) {
+	if g.rng.Float64() >= g.errorProbability(span) {
+		return model.Span{}, false
+	}
+	template := *span
+	template.Attributes = cloneAttrs(span.Attributes)
+	template.Resource.Attributes = cloneAttrs(span.Resource.Attributes)
+	markError(span)
+	return template, true
+}
+
+// retryCall retries the failed call at trace.Spans[idx]. Every retry is a
+// sibling of the first attempt built from template, the call before it
+// failed. It starts one jittered backoff after the previous attempt ended
+// and makes its own calls through calls, so retries at several levels
+// multiply the load on the services below. Retrying stops at the first
+// success or when the policy runs out of attempts.
+func (g *Generator) retryCall(trace *model.Trace, template model.Span, idx int, calls func(idx int)) {
+	if !g.cfg.RetryStorm && g.rng.Float64() >= g.cfg.Retries {
+		return
+	}
+	service, _ := template.Attributes["service.name"].(string)
+	policy := g.retryPolicy(service)
+	prev := trace.Spans[idx]
+	for attempt := 1; attempt < policy.MaxAttempts; attempt++ {
+		retry := template
+		retry.SpanID = g.newSpanID()
+		retry.StartTime = prev.StartTime.Add(prev.Duration + policy.Delay(attempt, g.rng.Float64))
+		retry.Attributes = cloneAttrs(template.Attributes)
+		retry.Resource.Attributes = cloneAttrs(template.Resource.Attributes)
+		retry.Duration = g.sampleDurationForProfile()
+		g.sampleOperationLatency(&retry)
+		retry.Attributes["retry.attempt"] = attempt
+		if _, ok := retry.Attributes["http.method"]; ok {
+			retry.Attributes["http.resend_count"] = attempt
+		}
+		retry.Events = nil
+		failed := g.maybeFail(&retry)
+		if !failed && g.cfg.RetryStorm && g.rng.Float64() < stormFailureRate {
+			markError(&retry)
+			failed = true
+		}
+		attemptIdx := len(trace.Spans)
+		trace.Spans = append(trace.Spans, retry)
+		calls(attemptIdx)
+		prev = trace.Spans[attemptIdx]
+		if !failed {
+			break
+		}
+	}
+	if prev.SpanID != trace.Spans[idx].SpanID {
+		extendAncestors(trace, prev.ParentSpanID, prev.StartTime.Add(prev.Duration))
+	}
+}
+
+// retryPolicy picks the policy for calls into service. A --retry-policy for
+// the service wins over one for the running profile, which wins over the
+// --retry-* defaults.
+func (g *Generator) retryPolicy(service string) config.RetryPolicy {
+	policy := g.cfg.DefaultRetryPolicy()
+	profileMatched := false
+	for _, p := range g.cfg.RetryPolicies {
+		switch {
+		case p.Service != "" && p.Service == service && (p.Profile == "" || strings.EqualFold(p.Profile, g.cfg.Profile)):
+			return p
+		case p.Service == "" && !profileMatched && strings.EqualFold(p.Profile, g.cfg.Profile):
+			policy = p
+			profileMatched = true
+		}
+	}
+	return policy
+}
+
+// extendAncestors stretches the span with id parentID, and its callers in
+// turn, so that each lasts until at least end. Callers wait for their retries.
+func extendAncestors(trace *model.Trace, parentID model.SpanID, end time.Time) {
+	for i := len(trace.Spans) - 1; i >= 0; i-- {
+		span := &trace.Spans[i]
+		if span.SpanID != parentID {
+			continue
+		}
+		if !span.StartTime.Add(span.Duration).Before(end) {
+			return
+		}
+		span.Duration = end.Sub(span.StartTime) + time.Millisecond
+		if !span.HasParent {
+			return
+		}
+		parentID = span.ParentSpanID
+		end = span.StartTime.Add(span.Duration)
+	}
+}
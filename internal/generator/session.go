@@ -0,0 +1,165 @@
+package generator
+
+import (
+	"container/heap"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+// journeyAbandonRate is the chance a session ends after any step but the last,
+// like a shopper who leaves with items in the cart.
+const journeyAbandonRate = 0.15
+
+// journeyStep is one request in a user journey. A non-empty route replaces
+// the route the profile would use for routeIdx.
+type journeyStep struct {
+	routeIdx int
+	method   string
+	route    string
+}
+
+// journeySteps returns the requests a user session walks through for profile,
+// or nil when the profile has no user-facing journey.
+func journeySteps(profile string) []journeyStep {
+	switch profile {
+	case "web":
+		return []journeyStep{{routeIdx: 0}, {routeIdx: 7}, {routeIdx: 1}, {routeIdx: 2}}
+	case "grpc":
+		return []journeyStep{{routeIdx: 0}, {routeIdx: 1}, {routeIdx: 2}, {routeIdx: 3}}
+	case "payment-system":
+		return []journeyStep{
+			{routeIdx: 2, method: "GET", route: "/products"},
+			{routeIdx: 2, method: "POST", route: "/cart"},
+			{routeIdx: 0},
+		}
+	case "api-gateway":
+		return []journeyStep{{routeIdx: 2}, {routeIdx: 3}, {routeIdx: 0}, {routeIdx: 4}}
+	default:
+		return nil
+	}
+}
+
+type userSession struct {
+	userIdx   int
+	sessionID string
+	step      int
+	nextAt    time.Time
+}
+
+type sessionQueue []*userSession
+
+func (q sessionQueue) Len() int { return len(q) }
+
+func (q sessionQueue) Less(i, j int) bool { return q[i].nextAt.Before(q[j].nextAt) }
+
+func (q sessionQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *sessionQueue) Push(x any) { *q = append(*q, x.(*userSession)) }
+
+func (q *sessionQueue) Pop() any {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[:n-1]
+	return item
+}
+
+// Population is the users that sessions are drawn from. Generators sharing
+// one never put a user in two sessions at once, and any of them may carry
+// on a session another left waiting.
+type Population struct {
+	mu sync.Mutex
+	// idle users have no session.
+	idle     []int
+	sessions sessionQueue
+}
+
+// NewPopulation returns a population of users, all idle.
+func NewPopulation(users int) *Population {
+	p := &Population{idle: make([]int, users)}
+	for i := range p.idle {
+		p.idle[i] = i
+	}
+	return p
+}
+
+// SetPopulation makes g draw its sessions from p, shared with other
+// generators of the same profile.
+func (g *Generator) SetPopulation(p *Population) {
+	g.users = p
+}
+
+// nextSession returns the session that issues the request starting at start.
+// A waiting session is reused once its think time has passed; otherwise an
+// idle user starts a new session. When every user has a session, the one due
+// soonest cuts its think time short. It returns nil when every user is busy
+// with a request on another generator, leaving the request anonymous.
+func (g *Generator) nextSession(start time.Time) *userSession {
+	p := g.users
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.sessions) > 0 && (!p.sessions[0].nextAt.After(start) || len(p.idle) == 0) {
+		return heap.Pop(&p.sessions).(*userSession)
+	}
+	if len(p.idle) == 0 {
+		return nil
+	}
+	i := g.rng.Intn(len(p.idle))
+	user := p.idle[i]
+	p.idle[i] = p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	var id [16]byte
+	g.rng.Read(id[:])
+	return &userSession{
+		userIdx:   user,
+		sessionID: hex.EncodeToString(id[:]),
+	}
+}
+
+// finishRequest moves the session to its next step after a think time, or
+// ends it and leaves its user idle.
+func (g *Generator) finishRequest(session *userSession, end time.Time) {
+	p := g.users
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	session.step++
+	if session.step >= len(journeySteps(g.cfg.Profile)) || g.rng.Float64() < journeyAbandonRate {
+		p.idle = append(p.idle, session.userIdx)
+		return
+	}
+	session.nextAt = end.Add(g.cfg.ThinkTime.Sample(g.rng.Float64))
+	heap.Push(&p.sessions, session)
+}
+
+func applyJourneyStep(root *model.Span, step journeyStep) {
+	if step.route == "" {
+		return
+	}
+	root.Name = step.method + " " + step.route
+	root.Attributes["http.method"] = step.method
+	root.Attributes["http.route"] = step.route
+}
+
+// applySession stamps the user and session on every span of trace so traces
+// from one visit can be joined.
+func applySession(trace *model.Trace, session *userSession) {
+	userID := fmt.Sprintf("user-%06d", session.userIdx)
+	role := userRole(session.userIdx)
+	for i := range trace.Spans {
+		attrs := trace.Spans[i].Attributes
+		attrs["session.id"] = session.sessionID
+		attrs["user.id"] = userID
+		attrs["enduser.id"] = userID
+		attrs["enduser.role"] = role
+		attrs["journey.step"] = session.step
+	}
+}
+
+func userRole(idx int) string {
+	roles := []string{"customer", "customer", "member", "guest"}
+	return roles[idx%len(roles)]
+}
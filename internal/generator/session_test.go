@@ -0,0 +1,125 @@
+package generator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/config"
+)
+
+func TestSessionsFollowJourneyWithThinkTime(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Profile = "payment-system"
+	cfg.Users = 50
+	cfg.ThinkTime = config.Distribution{Kind: config.DistributionFixed, A: time.Second}
+	g := New(cfg)
+
+	type visit struct {
+		step int
+		end  time.Time
+	}
+	last := map[any]visit{}
+	start := time.Unix(1700000000, 0).UTC()
+	for i := 0; i < 40; i++ {
+		trace := g.GenerateTrace(start.Add(time.Duration(i) * 300 * time.Millisecond))
+		root := trace.Spans[0]
+		session := root.Attributes["session.id"]
+		for _, span := range trace.Spans {
+			if span.Attributes["session.id"] != session || span.Attributes["user.id"] != root.Attributes["user.id"] {
+				t.Fatalf("span %q has different session/user than its root", span.Name)
+			}
+		}
+		if root.Attributes["enduser.id"] != root.Attributes["user.id"] {
+			t.Fatalf("enduser.id=%v user.id=%v", root.Attributes["enduser.id"], root.Attributes["user.id"])
+		}
+
+		step := root.Attributes["journey.step"].(int)
+		prev, seen := last[session]
+		if !seen && step != 0 {
+			t.Fatalf("new session started at step %d", step)
+		}
+		if seen {
+			if step != prev.step+1 {
+				t.Fatalf("session step=%d want %d", step, prev.step+1)
+			}
+			if root.StartTime.Before(prev.end.Add(time.Second)) {
+				t.Fatal("next request started before think time elapsed")
+			}
+		}
+		if step == 0 && root.Attributes["http.route"] != "/products" {
+			t.Fatalf("first journey route=%v want /products", root.Attributes["http.route"])
+		}
+		if step == 2 && root.Attributes["http.route"] != "/checkout" {
+			t.Fatalf("last journey route=%v want /checkout", root.Attributes["http.route"])
+		}
+		last[session] = visit{step: step, end: root.StartTime.Add(root.Duration)}
+	}
+	if len(last) < 2 {
+		t.Fatalf("sessions=%d want several", len(last))
+	}
+}
+
+func TestSessionsStayWithinUserPopulation(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Users = 2
+	cfg.ThinkTime = config.Distribution{Kind: config.DistributionFixed, A: time.Minute}
+	g := New(cfg)
+
+	users := map[any]bool{}
+	start := time.Unix(1700000000, 0).UTC()
+	for i := 0; i < 50; i++ {
+		trace := g.GenerateTrace(start.Add(time.Duration(i) * time.Millisecond))
+		users[trace.Spans[0].Attributes["user.id"]] = true
+	}
+	if len(users) > cfg.Users {
+		t.Fatalf("users=%d want at most %d", len(users), cfg.Users)
+	}
+}
+
+func TestSharedPopulationKeepsOneSessionPerUser(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Users = 3
+	cfg.ThinkTime = config.Distribution{Kind: config.DistributionFixed, A: 5 * time.Millisecond}
+	users := NewPopulation(cfg.Users)
+	var generators []*Generator
+	for seed := int64(1); seed <= 2; seed++ {
+		workerCfg := cfg
+		workerCfg.Seed = seed
+		g := New(workerCfg)
+		g.SetPopulation(users)
+		generators = append(generators, g)
+	}
+
+	// Each session's first and last request, by index.
+	type interval struct{ user, first, last int }
+	sessions := map[any]*interval{}
+	start := time.Unix(1700000000, 0).UTC()
+	for i := 0; i < 400; i++ {
+		root := generators[i%2].GenerateTrace(start.Add(time.Duration(i) * time.Millisecond)).Spans[0]
+		id, ok := root.Attributes["session.id"]
+		if !ok {
+			continue
+		}
+		var user int
+		fmt.Sscanf(root.Attributes["user.id"].(string), "user-%d", &user)
+		if s, ok := sessions[id]; ok {
+			s.last = i
+			continue
+		}
+		sessions[id] = &interval{user: user, first: i, last: i}
+	}
+	for a, s := range sessions {
+		if s.user >= cfg.Users {
+			t.Fatalf("user %d outside a population of %d", s.user, cfg.Users)
+		}
+		for b, o := range sessions {
+			if a != b && s.user == o.user && s.first <= o.last && o.first <= s.last {
+				t.Fatalf("user %d has overlapping sessions %v and %v", s.user, a, b)
+			}
+		}
+	}
+	if len(sessions) < 2*cfg.Users {
+		t.Fatalf("sessions=%d want users to start several", len(sessions))
+	}
+}
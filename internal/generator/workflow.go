@@ -0,0 +1,68 @@
+package generator
+
+import (
+	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/model"
+)
+
+// generateWorkflow lays the steps of a long-running workflow out across the
+// root span's lifetime. Each step calls one activity when it starts and ends
+// when the next step is due, so the spans of one trace finish minutes or hours apart.
+func (g *Generator) generateWorkflow(trace *model.Trace, root model.Span) {
+	name, _ := root.Attributes["workflow.name"].(string)
+	steps := workflowSteps(name)
+	weights := make([]float64, len(steps))
+	total := 0.0
+	for i := range weights {
+		weights[i] = 0.5 + g.rng.Float64()
+		total += weights[i]
+	}
+
+	cursor := root.StartTime
+	for i, step := range steps {
+		length := time.Duration(float64(root.Duration) * weights[i] / total)
+		stepSpan := model.Span{
+			TraceID:      trace.TraceID,
+			SpanID:       g.newSpanID(),
+			ParentSpanID: root.SpanID,
+			HasParent:    true,
+			Name:         "step " + step.name,
+			Kind:         "INTERNAL",
+			StartTime:    cursor,
+			Duration:     length,
+			Attributes: model.Attrs{
+				"service.name":        "workflow-engine",
+				"workflow.name":       name,
+				"workflow.step":       step.name,
+				"workflow.step_index": i,
+			},
+			Status:   model.SpanStatus{Code: "OK"},
+			Resource: model.Resource{Attributes: model.Attrs{"service.name": "workflow-engine"}},
+		}
+		g.applyRunAttrs(&stepSpan)
+		g.applyCardinalityAttrs(&stepSpan)
+		trace.Spans = append(trace.Spans, stepSpan)
+
+		activity := g.profile.buildChild(stepSpan, step.service, i, cursor.Add(time.Millisecond), g.newSpanID(), trace.TraceID, g.sampleDurationForProfile(), g.rng.Float64() < g.cfg.DBHeavy, g.rng.Float64() < g.cfg.CacheHitRate)
+		g.sampleOperationLatency(&activity)
+		g.applyRunAttrs(&activity)
+		g.applyCardinalityAttrs(&activity)
+		g.maybeAddProfileEvent(&activity)
+		template, failed := g.failCall(&activity)
+		trace.Spans = append(trace.Spans, activity)
+		if failed {
+			g.retryCall(trace, template, len(trace.Spans)-1, func(int) {})
+		}
+		cursor = cursor.Add(length)
+	}
+}
+
+// workflowLifetime samples the root duration of a workflow trace between 75% and 100% of --workflow-lifetime.
+func (g *Generator) workflowLifetime() time.Duration {
+	lifetime := g.cfg.WorkflowLifetime
+	if lifetime <= 0 {
+		lifetime = 10 * time.Minute
+	}
+	return time.Duration(float64(lifetime) * (0.75 + 0.25*g.rng.Float64()))
+}
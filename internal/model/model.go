@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // TraceID is a 16-byte trace identifier.
 type TraceID [16]byte
@@ -15,6 +18,21 @@ type Resource struct {
 	Attributes Attrs
 }
 
+// Exported returns the resource attributes encoders send: service.name and
+// what rollouts and tenants add, such as service.version, deployment.id and
+// spanforge.tenant. The other spanforge.* attributes, such as the profile,
+// phase and invalid-mode blob, only label spans for spanforge's own reports.
+func (r Resource) Exported() Attrs {
+	out := make(Attrs, len(r.Attributes))
+	for k, v := range r.Attributes {
+		if strings.HasPrefix(k, "spanforge.") && k != "spanforge.tenant" {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
 type Event struct {
 	Name       string
 	Time       time.Time
@@ -52,4 +70,11 @@ type Trace struct {
 	TraceID  TraceID
 	Resource Resource
 	Spans    []Span
+	// Fragment marks a later delivery of a trace whose first spans were already emitted.
+	Fragment bool
+	// Headers are the transport headers to send the trace with. Load phases
+	// can change them during a run.
+	Headers map[string]string
+	// Tenant names the tenant the trace is sent to, if the run has tenants.
+	Tenant string
 }
@@ -0,0 +1,54 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression algorithms for request bodies. None and the empty string
+// both send bodies as they are.
+const (
+	CompressionNone   = "none"
+	CompressionGzip   = "gzip"
+	CompressionZstd   = "zstd"
+	CompressionSnappy = "snappy"
+)
+
+// Compressed reports whether algorithm compresses bodies.
+func Compressed(algorithm string) bool {
+	return algorithm != "" && algorithm != CompressionNone
+}
+
+// zstdEncoder is shared, since EncodeAll is safe for concurrent use and
+// encoders are expensive to create.
+var zstdEncoder, _ = zstd.NewWriter(nil)
+
+// Compress returns body compressed with algorithm, for a request with the
+// same Content-Encoding. Snappy uses the block format, as OTLP HTTP
+// receivers expect.
+func Compress(algorithm string, body []byte) ([]byte, error) {
+	switch algorithm {
+	case "", CompressionNone:
+		return body, nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(body, make([]byte, 0, len(body)/2)), nil
+	case CompressionSnappy:
+		return snappy.Encode(nil, body), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", algorithm)
+	}
+}
@@ -0,0 +1,214 @@
+// Package file writes trace output to rotating, optionally compressed files.
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Options control rotation, compression and retention. Zero values turn
+// each feature off.
+type Options struct {
+	// MaxSize rotates the file once this many bytes have been written to
+	// it, counted before compression.
+	MaxSize int64
+	// MaxAge rotates the file once it has been open this long.
+	MaxAge time.Duration
+	// Compress is "gzip", "zstd", or "" for none.
+	Compress string
+	// MaxFiles keeps only the newest completed files, deleting older ones.
+	MaxFiles int
+}
+
+// partialSuffix marks a file still being written. Completed files are
+// renamed to drop it, so readers never see half a file under its final
+// name.
+const partialSuffix = ".partial"
+
+// Writer writes to a series of files named after path. Without rotation
+// it writes one file, path plus the compression suffix. With rotation the
+// files number from 1, so traces.jsonl becomes traces-000001.jsonl,
+// traces-000002.jsonl and so on.
+//
+// Writes are buffered. Flush marks the end of a batch: the writer only
+// rotates there, so a batch never spans two files.
+type Writer struct {
+	path     string
+	opts     Options
+	now      func() time.Time
+	seq      int
+	file     *os.File
+	written  int64
+	encoder  io.WriteCloser
+	buf      *bufio.Writer
+	opened   time.Time
+	finished []string
+	closed   bool
+}
+
+// New opens the first file.
+func New(path string, opts Options) (*Writer, error) {
+	w := &Writer{path: path, opts: opts, now: time.Now}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.buf.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *Writer) WriteString(s string) (int, error) {
+	n, err := w.buf.WriteString(s)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush ends a batch. It writes out the buffer and, when the file has
+// reached MaxSize or MaxAge, completes it and opens the next one.
+func (w *Writer) Flush() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if !w.due() {
+		return nil
+	}
+	if err := w.finish(); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+// Close completes the current file. A file opened by rotation that never
+// got a write is removed instead, so it neither shows up as an empty file
+// nor pushes a real one out of MaxFiles.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.buf.Flush(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	if w.seq > 1 && w.written == 0 {
+		return w.discard()
+	}
+	return w.finish()
+}
+
+// Files returns the completed files that are still on disk, oldest first.
+func (w *Writer) Files() []string {
+	return append([]string(nil), w.finished...)
+}
+
+func (w *Writer) rotating() bool {
+	return w.opts.MaxSize > 0 || w.opts.MaxAge > 0
+}
+
+func (w *Writer) due() bool {
+	if !w.rotating() || w.written == 0 {
+		return false
+	}
+	if w.opts.MaxSize > 0 && w.written >= w.opts.MaxSize {
+		return true
+	}
+	return w.opts.MaxAge > 0 && w.now().Sub(w.opened) >= w.opts.MaxAge
+}
+
+// name returns the completed name of the current file.
+func (w *Writer) name() string {
+	name := w.path
+	if w.rotating() {
+		ext := filepath.Ext(name)
+		name = fmt.Sprintf("%s-%06d%s", strings.TrimSuffix(name, ext), w.seq, ext)
+	}
+	switch w.opts.Compress {
+	case "gzip":
+		name += ".gz"
+	case "zstd":
+		name += ".zst"
+	}
+	return name
+}
+
+func (w *Writer) open() error {
+	w.seq++
+	f, err := os.Create(w.name() + partialSuffix)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.written = 0
+	w.opened = w.now()
+	var out io.Writer = f
+	w.encoder = nil
+	switch w.opts.Compress {
+	case "gzip":
+		w.encoder = gzip.NewWriter(f)
+	case "zstd":
+		enc, err := zstd.NewWriter(f)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		w.encoder = enc
+	}
+	if w.encoder != nil {
+		out = w.encoder
+	}
+	w.buf = bufio.NewWriter(out)
+	return nil
+}
+
+// finish closes the current file and renames it to its completed name,
+// then deletes the oldest completed files beyond MaxFiles.
+func (w *Writer) finish() error {
+	if w.encoder != nil {
+		if err := w.encoder.Close(); err != nil {
+			_ = w.file.Close()
+			return err
+		}
+	}
+	if err := w.file.Sync(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	name := w.name()
+	if err := os.Rename(name+partialSuffix, name); err != nil {
+		return err
+	}
+	w.finished = append(w.finished, name)
+	for w.opts.MaxFiles > 0 && len(w.finished) > w.opts.MaxFiles {
+		if err := os.Remove(w.finished[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		w.finished = w.finished[1:]
+	}
+	return nil
+}
+
+// discard closes the current file and removes it.
+func (w *Writer) discard() error {
+	if w.encoder != nil {
+		_ = w.encoder.Close()
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(w.name() + partialSuffix)
+}
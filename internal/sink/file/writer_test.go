@@ -0,0 +1,171 @@
+package file
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestWriterRotatesBySizeAndKeepsMaxFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	w, err := New(path, Options{MaxSize: 10, MaxFiles: 2})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, batch := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n", "eeeeee\n"} {
+		if _, err := w.WriteString(batch); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("flush: %v", err)
+		}
+	}
+	if _, err := os.Stat(strings.TrimSuffix(path, ".jsonl") + "-000003.jsonl.partial"); err != nil {
+		t.Fatalf("open file is not partial: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	dir := filepath.Dir(path)
+	want := []string{filepath.Join(dir, "traces-000002.jsonl"), filepath.Join(dir, "traces-000003.jsonl")}
+	if got := w.Files(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("files=%v want %v", got, want)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("%d files on disk, want 2 after retention", len(entries))
+	}
+	// Files rotate between batches, so no batch is split.
+	for file, content := range map[string]string{want[0]: "cccccc\ndddddd\n", want[1]: "eeeeee\n"} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		if string(data) != content {
+			t.Fatalf("%s=%q want %q", file, data, content)
+		}
+	}
+}
+
+func TestWriterCloseDropsEmptyRotatedFile(t *testing.T) {
+	for _, compress := range []string{"", "gzip", "zstd"} {
+		t.Run("compress="+compress, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := New(filepath.Join(dir, "traces.jsonl"), Options{MaxSize: 10, MaxFiles: 2, Compress: compress})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			// Each batch fills a file, so every flush opens an empty one.
+			for _, batch := range []string{"aaaaaaaaaa\n", "bbbbbbbbbb\n"} {
+				if _, err := w.WriteString(batch); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+				if err := w.Flush(); err != nil {
+					t.Fatalf("flush: %v", err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+			if got := len(w.Files()); got != 2 {
+				t.Fatalf("files=%v want both written files", w.Files())
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("read dir: %v", err)
+			}
+			for _, entry := range entries {
+				if strings.Contains(entry.Name(), "000003") {
+					t.Fatalf("empty rotated file %s left on disk", entry.Name())
+				}
+			}
+			if len(entries) != 2 {
+				t.Fatalf("%d files on disk, want 2", len(entries))
+			}
+		})
+	}
+}
+
+func TestWriterRotatesByTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.pb")
+	w, err := New(path, Options{MaxAge: time.Minute})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	clock := time.Unix(0, 0)
+	w.now = func() time.Time { return clock }
+	w.opened = clock
+	for i := 0; i < 3; i++ {
+		// An empty file does not rotate, however old.
+		clock = clock.Add(2 * time.Minute)
+		if err := w.Flush(); err != nil {
+			t.Fatalf("flush: %v", err)
+		}
+	}
+	if _, err := w.Write([]byte("x")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if _, err := w.Write([]byte("y")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if got := len(w.Files()); got != 2 {
+		t.Fatalf("files=%v want 2", w.Files())
+	}
+}
+
+func TestWriterCompresses(t *testing.T) {
+	for _, tc := range []struct {
+		compress string
+		suffix   string
+		reader   func(io.Reader) (io.Reader, error)
+	}{
+		{"gzip", ".gz", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"zstd", ".zst", func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) }},
+	} {
+		t.Run(tc.compress, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "traces.jsonl")
+			w, err := New(path, Options{Compress: tc.compress})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if _, err := w.WriteString("{\"span\":1}\n"); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+			f, err := os.Open(path + tc.suffix)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer f.Close()
+			r, err := tc.reader(f)
+			if err != nil {
+				t.Fatalf("reader: %v", err)
+			}
+			data, err := io.ReadAll(r)
+			if err != nil || string(data) != "{\"span\":1}\n" {
+				t.Fatalf("data=%q err=%v", data, err)
+			}
+		})
+	}
+}
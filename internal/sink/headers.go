@@ -0,0 +1,21 @@
+// Package sink holds what the trace sinks share.
+package sink
+
+import "context"
+
+type headersKey struct{}
+
+// WithHeaders returns ctx carrying headers for the requests a sink makes
+// under it. They replace the sink's own headers of the same name.
+func WithHeaders(ctx context.Context, headers map[string]string) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, headersKey{}, headers)
+}
+
+// Headers returns the headers ctx carries.
+func Headers(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(headersKey{}).(map[string]string)
+	return headers
+}
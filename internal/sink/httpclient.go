@@ -0,0 +1,73 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// HTTP versions for HTTP sinks.
+const (
+	// HTTPVersionAuto uses HTTP/2 when a TLS server offers it and HTTP/1.1
+	// otherwise.
+	HTTPVersionAuto = "auto"
+	HTTPVersion1    = "1.1"
+	// HTTPVersion2 uses HTTP/2 only, with prior knowledge (h2c) for http://
+	// endpoints.
+	HTTPVersion2 = "2"
+)
+
+// HTTPOptions tunes the connections of an HTTP sink. The zero value keeps
+// Go's defaults.
+type HTTPOptions struct {
+	Version string
+	// MaxConns caps the connections to the endpoint; 0 is no limit.
+	MaxConns int
+	// MaxIdleConns caps the idle connections kept for reuse; 0 keeps Go's
+	// default of 2.
+	MaxIdleConns int
+	// IdleConnTimeout closes connections idle this long; 0 keeps Go's
+	// default of 90s.
+	IdleConnTimeout time.Duration
+	// DialAddress is the host:port to connect to instead of the endpoint's
+	// host, which still names the server in requests and TLS handshakes.
+	DialAddress string
+}
+
+// NewHTTPClient returns a client for one endpoint. With a nil tlsConfig and
+// zero opts it shares Go's default transport.
+func NewHTTPClient(timeout time.Duration, tlsConfig *tls.Config, opts HTTPOptions) *http.Client {
+	if tlsConfig == nil && opts == (HTTPOptions{}) {
+		return &http.Client{Timeout: timeout}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxConnsPerHost = opts.MaxConns
+	if opts.MaxIdleConns > 0 {
+		transport.MaxIdleConnsPerHost = opts.MaxIdleConns
+		if transport.MaxIdleConns < opts.MaxIdleConns {
+			transport.MaxIdleConns = opts.MaxIdleConns
+		}
+	}
+	if opts.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = opts.IdleConnTimeout
+	}
+	switch opts.Version {
+	case HTTPVersion1:
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP1(true)
+	case HTTPVersion2:
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP2(true)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+	if opts.DialAddress != "" {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, opts.DialAddress)
+		}
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
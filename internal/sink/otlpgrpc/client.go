@@ -2,40 +2,77 @@ package otlpgrpc
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/robmcelhinney/spanforge/internal/auth"
 	"github.com/robmcelhinney/spanforge/internal/encode/otlp"
 	"github.com/robmcelhinney/spanforge/internal/model"
+	"github.com/robmcelhinney/spanforge/internal/sink"
 	collectortracev1 "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 type Client struct {
 	endpoint string
-	headers  map[string]string
-	insecure bool
-	timeout  time.Duration
+	opts     Options
 
-	mu     sync.Mutex
-	conn   *grpc.ClientConn
-	client collectortracev1.TraceServiceClient
+	mu      sync.Mutex
+	conns   []*grpc.ClientConn
+	clients []collectortracev1.TraceServiceClient
+	next    atomic.Uint64
 }
 
-func New(endpoint string, headers map[string]string, insecureConn bool, timeout time.Duration) *Client {
-	if timeout <= 0 {
-		timeout = 10 * time.Second
+// Options configures a Client.
+type Options struct {
+	Headers map[string]string
+	// Insecure picks plaintext over TLS with Go's defaults when TLS is nil.
+	// A non-nil TLS turns TLS on whatever Insecure says.
+	Insecure bool
+	// Compression names a registered gRPC compressor (gzip, zstd or
+	// snappy), or is none or empty to send exports as they are.
+	Compression string
+	Timeout     time.Duration
+	TLS         *tls.Config
+	// Auth sets the authorization metadata of each export.
+	Auth auth.Provider
+	// Connections is how many connections to open, taking exports in
+	// turn; 0 opens one.
+	Connections int
+	// KeepaliveTime pings a connection idle this long, and
+	// KeepaliveTimeout closes it when the ping goes unanswered that long.
+	// 0 sends no pings.
+	KeepaliveTime    time.Duration
+	KeepaliveTimeout time.Duration
+	// MaxMessageSize caps the messages sent and received; 0 keeps gRPC's
+	// limits.
+	MaxMessageSize int
+	// DialAddress is the host:port to connect to instead of the endpoint,
+	// which stays the authority that names the server.
+	DialAddress string
+}
+
+// New returns a client for endpoint. Connections are opened on first use.
+func New(endpoint string, opts Options) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Connections <= 0 {
+		opts.Connections = 1
 	}
 	return &Client{
 		endpoint: strings.TrimPrefix(strings.TrimPrefix(endpoint, "http://"), "https://"),
-		headers:  headers,
-		insecure: insecureConn,
-		timeout:  timeout,
+		opts:     opts,
 	}
 }
 
@@ -54,15 +91,37 @@ func (c *Client) SendSpans(ctx context.Context, spans []model.Span) error {
 	}
 
 	callCtx := ctx
-	if len(c.headers) > 0 {
-		md := metadata.New(c.headers)
+	md := metadata.New(c.opts.Headers)
+	for k, v := range sink.Headers(ctx) {
+		md.Set(k, v)
+	}
+	if c.opts.Auth != nil && len(md.Get("authorization")) == 0 {
+		value, err := c.opts.Auth.Authorization(ctx)
+		if err != nil {
+			return err
+		}
+		md.Set("authorization", value)
+	}
+	if md.Len() > 0 {
 		callCtx = metadata.NewOutgoingContext(callCtx, md)
 	}
-	callCtx, cancel := context.WithTimeout(callCtx, c.timeout)
+	callCtx, cancel := context.WithTimeout(callCtx, c.opts.Timeout)
 	defer cancel()
 
-	if _, err := cli.Export(callCtx, req); err != nil {
-		return fmt.Errorf("otlp grpc export: %w", err)
+	var opts []grpc.CallOption
+	if sink.Compressed(c.opts.Compression) {
+		opts = append(opts, grpc.UseCompressor(c.opts.Compression))
+	}
+	if _, err := cli.Export(callCtx, req, opts...); err != nil {
+		code := status.Code(err)
+		if c.opts.Auth != nil && code == codes.Unauthenticated {
+			auth.Rejected(c.opts.Auth)
+		}
+		err = fmt.Errorf("otlp grpc export: %w", err)
+		if code == codes.ResourceExhausted || code == codes.Unavailable {
+			return sink.Throttle(err)
+		}
+		return err
 	}
 	return nil
 }
@@ -70,35 +129,73 @@ func (c *Client) SendSpans(ctx context.Context, spans []model.Span) error {
 func (c *Client) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.conn != nil {
-		return c.conn.Close()
+	var first error
+	for _, conn := range c.conns {
+		if err := conn.Close(); err != nil && first == nil {
+			first = err
+		}
 	}
-	return nil
+	return first
 }
 
+// ensureClient returns the next connection's client, dialing them all on
+// first use.
 func (c *Client) ensureClient(ctx context.Context) (collectortracev1.TraceServiceClient, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.client != nil {
-		return c.client, nil
+	if len(c.clients) == 0 {
+		if err := c.dial(ctx); err != nil {
+			return nil, err
+		}
 	}
+	return c.clients[(c.next.Add(1)-1)%uint64(len(c.clients))], nil
+}
+
+func (c *Client) dial(ctx context.Context) error {
 	if c.endpoint == "" {
-		return nil, fmt.Errorf("empty OTLP gRPC endpoint")
+		return fmt.Errorf("empty OTLP gRPC endpoint")
 	}
 
 	var creds credentials.TransportCredentials
-	if c.insecure {
+	if c.opts.TLS != nil {
+		creds = credentials.NewTLS(c.opts.TLS)
+	} else if c.opts.Insecure {
 		creds = insecure.NewCredentials()
 	} else {
 		creds = credentials.NewClientTLSFromCert(nil, "")
 	}
-	dialCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(creds), grpc.WithStatsHandler(sizeHandler{}), grpc.WithBlock()}
+	if c.opts.KeepaliveTime > 0 {
+		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                c.opts.KeepaliveTime,
+			Timeout:             c.opts.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		}))
+	}
+	if c.opts.MaxMessageSize > 0 {
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(
+			grpc.MaxCallSendMsgSize(c.opts.MaxMessageSize),
+			grpc.MaxCallRecvMsgSize(c.opts.MaxMessageSize),
+		))
+	}
+	target := c.endpoint
+	if c.opts.DialAddress != "" {
+		target = c.opts.DialAddress
+		dialOpts = append(dialOpts, grpc.WithAuthority(c.endpoint))
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
 	defer cancel()
-	conn, err := grpc.DialContext(dialCtx, c.endpoint, grpc.WithTransportCredentials(creds), grpc.WithBlock())
-	if err != nil {
-		return nil, err
+	for i := 0; i < c.opts.Connections; i++ {
+		conn, err := grpc.DialContext(dialCtx, target, dialOpts...)
+		if err != nil {
+			for _, open := range c.conns {
+				_ = open.Close()
+			}
+			c.conns, c.clients = nil, nil
+			return err
+		}
+		c.conns = append(c.conns, conn)
+		c.clients = append(c.clients, collectortracev1.NewTraceServiceClient(conn))
 	}
-	c.conn = conn
-	c.client = collectortracev1.NewTraceServiceClient(conn)
-	return c.client, nil
+	return nil
 }
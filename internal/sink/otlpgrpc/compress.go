@@ -0,0 +1,71 @@
+package otlpgrpc
+
+import (
+	"context"
+	"io"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/robmcelhinney/spanforge/internal/sink"
+	"google.golang.org/grpc/encoding"
+	// Registers the gzip compressor.
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/stats"
+)
+
+// The collector registers zstd and snappy gRPC compressors under these
+// names, so registering matching ones here lets --compress pick them.
+func init() {
+	encoding.RegisterCompressor(zstdCompressor{})
+	encoding.RegisterCompressor(snappyCompressor{})
+}
+
+type zstdCompressor struct{}
+
+func (zstdCompressor) Name() string { return sink.CompressionZstd }
+
+func (zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+}
+
+func (zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return dec.IOReadCloser(), nil
+}
+
+// snappyCompressor uses the framed snappy format, as gRPC streams
+// messages through the compressor.
+type snappyCompressor struct{}
+
+func (snappyCompressor) Name() string { return sink.CompressionSnappy }
+
+func (snappyCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return snappy.NewBufferedWriter(w), nil
+}
+
+func (snappyCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	return snappy.NewReader(r), nil
+}
+
+// sizeHandler records the size of each export before and after
+// compression, which gRPC only reports through stats handlers.
+type sizeHandler struct{}
+
+func (sizeHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (sizeHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if out, ok := s.(*stats.OutPayload); ok && out.Client {
+		sink.RecordPayloadSize(ctx, out.Length, out.CompressedLength)
+	}
+}
+
+func (sizeHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (sizeHandler) HandleConn(context.Context, stats.ConnStats) {}
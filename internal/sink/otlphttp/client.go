@@ -2,35 +2,52 @@ package otlphttp
 
 import (
 	"bytes"
-	"compress/gzip"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/robmcelhinney/spanforge/internal/auth"
 	"github.com/robmcelhinney/spanforge/internal/encode/otlp"
 	"github.com/robmcelhinney/spanforge/internal/model"
+	"github.com/robmcelhinney/spanforge/internal/sink"
 	"google.golang.org/protobuf/proto"
 )
 
 type Client struct {
-	endpoint string
-	headers  map[string]string
-	gzip     bool
-	http     *http.Client
+	endpoint    string
+	headers     map[string]string
+	compression string
+	http        *http.Client
 }
 
-func New(endpoint string, headers map[string]string, gzipEnabled bool, timeout time.Duration) *Client {
-	if timeout <= 0 {
-		timeout = 10 * time.Second
+// Options configures a Client.
+type Options struct {
+	Headers map[string]string
+	// Compression is gzip, zstd, snappy, or none or empty to send bodies as
+	// they are.
+	Compression string
+	Timeout     time.Duration
+	// TLS nil uses Go's TLS defaults for https endpoints.
+	TLS *tls.Config
+	// Auth nil sends no credentials beyond Headers.
+	Auth auth.Provider
+	HTTP sink.HTTPOptions
+}
+
+// New returns a client for endpoint.
+func New(endpoint string, opts Options) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
 	}
 	return &Client{
-		endpoint: strings.TrimRight(endpoint, "/"),
-		headers:  headers,
-		gzip:     gzipEnabled,
-		http:     &http.Client{Timeout: timeout},
+		endpoint:    strings.TrimRight(endpoint, "/"),
+		headers:     opts.Headers,
+		compression: opts.Compression,
+		http:        auth.WrapClient(sink.NewHTTPClient(opts.Timeout, opts.TLS, opts.HTTP), opts.Auth),
 	}
 }
 
@@ -46,72 +63,36 @@ func (c *Client) SendSpans(ctx context.Context, spans []model.Span) error {
 	if err != nil {
 		return fmt.Errorf("marshal otlp request: %w", err)
 	}
-	body := payload
-
-	reqBody := io.Reader(bytes.NewReader(body))
-	if c.gzip {
-		var gzBuf bytes.Buffer
-		zw := gzip.NewWriter(&gzBuf)
-		if _, err := zw.Write(body); err != nil {
-			return err
-		}
-		if err := zw.Close(); err != nil {
-			return err
-		}
-		reqBody = bytes.NewReader(gzBuf.Bytes())
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/v1/traces", reqBody)
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/x-protobuf")
-	if c.gzip {
-		req.Header.Set("Content-Encoding", "gzip")
-	}
-	for k, v := range c.headers {
-		req.Header.Set(k, v)
-	}
-
-	resp, err := c.http.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return fmt.Errorf("otlp http error: %s: %s", resp.Status, strings.TrimSpace(string(data)))
-	}
-	return nil
+	return c.post(ctx, payload)
 }
 
 func (c *Client) SendRaw(ctx context.Context, body []byte) error {
 	if len(body) == 0 {
 		return nil
 	}
-	reqBody := io.Reader(bytes.NewReader(body))
-	if c.gzip {
-		var gzBuf bytes.Buffer
-		zw := gzip.NewWriter(&gzBuf)
-		if _, err := zw.Write(body); err != nil {
-			return err
-		}
-		if err := zw.Close(); err != nil {
-			return err
-		}
-		reqBody = bytes.NewReader(gzBuf.Bytes())
+	return c.post(ctx, body)
+}
+
+func (c *Client) post(ctx context.Context, payload []byte) error {
+	body, err := sink.Compress(c.compression, payload)
+	if err != nil {
+		return err
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/v1/traces", reqBody)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/v1/traces", bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/x-protobuf")
-	if c.gzip {
-		req.Header.Set("Content-Encoding", "gzip")
+	if sink.Compressed(c.compression) {
+		req.Header.Set("Content-Encoding", c.compression)
 	}
 	for k, v := range c.headers {
 		req.Header.Set(k, v)
 	}
+	for k, v := range sink.Headers(ctx) {
+		req.Header.Set(k, v)
+	}
+
 	resp, err := c.http.Do(req)
 	if err != nil {
 		return err
@@ -119,7 +100,12 @@ func (c *Client) SendRaw(ctx context.Context, body []byte) error {
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return fmt.Errorf("otlp http error: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+		err := fmt.Errorf("otlp http error: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+		if sink.ThrottledStatus(resp.StatusCode) {
+			return sink.Throttle(err)
+		}
+		return err
 	}
+	sink.RecordPayloadSize(ctx, len(payload), len(body))
 	return nil
 }
@@ -0,0 +1,27 @@
+package sink
+
+import "context"
+
+// PayloadSize is the size of a request body before and after compression.
+type PayloadSize struct {
+	Uncompressed int
+	Compressed   int
+}
+
+type payloadSizeKey struct{}
+
+// WithPayloadSize returns ctx asking the sink to record the size of the
+// request it sends under it in size. A retried request records its last
+// attempt.
+func WithPayloadSize(ctx context.Context, size *PayloadSize) context.Context {
+	return context.WithValue(ctx, payloadSizeKey{}, size)
+}
+
+// RecordPayloadSize records a request body's size in the PayloadSize ctx
+// carries, if any.
+func RecordPayloadSize(ctx context.Context, uncompressed, compressed int) {
+	if size, ok := ctx.Value(payloadSizeKey{}).(*PayloadSize); ok {
+		size.Uncompressed = uncompressed
+		size.Compressed = compressed
+	}
+}
@@ -0,0 +1,31 @@
+package sink
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrThrottled matches errors from servers asking the client to slow
+// down, such as HTTP 429 or gRPC RESOURCE_EXHAUSTED.
+var ErrThrottled = errors.New("throttled")
+
+type throttledError struct {
+	err error
+}
+
+func (e throttledError) Error() string   { return e.err.Error() }
+func (e throttledError) Unwrap() []error { return []error{e.err, ErrThrottled} }
+
+// Throttle returns err marked to match ErrThrottled, keeping its message.
+func Throttle(err error) error {
+	if err == nil {
+		return nil
+	}
+	return throttledError{err: err}
+}
+
+// ThrottledStatus reports whether an HTTP status asks the client to slow
+// down.
+func ThrottledStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
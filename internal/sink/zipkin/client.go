@@ -3,6 +3,7 @@ package zipkin
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,24 +11,43 @@ import (
 	"strings"
 	"time"
 
+	"github.com/robmcelhinney/spanforge/internal/auth"
 	"github.com/robmcelhinney/spanforge/internal/encode/zipkin"
 	"github.com/robmcelhinney/spanforge/internal/model"
+	"github.com/robmcelhinney/spanforge/internal/sink"
 )
 
 type Client struct {
-	endpoint string
-	headers  map[string]string
-	http     *http.Client
+	endpoint    string
+	headers     map[string]string
+	compression string
+	http        *http.Client
 }
 
-func New(endpoint string, headers map[string]string, timeout time.Duration) *Client {
-	if timeout <= 0 {
-		timeout = 10 * time.Second
+// Options configures a Client.
+type Options struct {
+	Headers map[string]string
+	// Compression is gzip, or none or empty to send bodies as they are;
+	// Zipkin servers accept no other encoding.
+	Compression string
+	Timeout     time.Duration
+	// TLS nil uses Go's TLS defaults for https endpoints.
+	TLS *tls.Config
+	// Auth nil sends no credentials beyond Headers.
+	Auth auth.Provider
+	HTTP sink.HTTPOptions
+}
+
+// New returns a client for endpoint.
+func New(endpoint string, opts Options) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
 	}
 	return &Client{
-		endpoint: endpoint,
-		headers:  headers,
-		http:     &http.Client{Timeout: timeout},
+		endpoint:    endpoint,
+		headers:     opts.Headers,
+		compression: opts.Compression,
+		http:        auth.WrapClient(sink.NewHTTPClient(opts.Timeout, opts.TLS, opts.HTTP), opts.Auth),
 	}
 }
 
@@ -39,46 +59,39 @@ func (c *Client) SendSpans(ctx context.Context, spans []model.Span) error {
 	if err != nil {
 		return fmt.Errorf("encode zipkin spans: %w", err)
 	}
-	endpoint, err := zipkinSpansURL(c.endpoint)
-	if err != nil {
-		return err
-	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	for k, v := range c.headers {
-		req.Header.Set(k, v)
-	}
-	resp, err := c.http.Do(req)
-	if err != nil {
-		return fmt.Errorf("zipkin export request: %w", err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return fmt.Errorf("zipkin http error: %s: %s", resp.Status, strings.TrimSpace(string(data)))
-	}
-	return nil
+	return c.post(ctx, payload)
 }
 
 func (c *Client) SendRaw(ctx context.Context, payload []byte) error {
 	if len(payload) == 0 {
 		return nil
 	}
+	return c.post(ctx, payload)
+}
+
+func (c *Client) post(ctx context.Context, payload []byte) error {
 	endpoint, err := zipkinSpansURL(c.endpoint)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	body, err := sink.Compress(c.compression, payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if sink.Compressed(c.compression) {
+		req.Header.Set("Content-Encoding", c.compression)
+	}
 	for k, v := range c.headers {
 		req.Header.Set(k, v)
 	}
+	for k, v := range sink.Headers(ctx) {
+		req.Header.Set(k, v)
+	}
 	resp, err := c.http.Do(req)
 	if err != nil {
 		return fmt.Errorf("zipkin export request: %w", err)
@@ -86,8 +99,13 @@ func (c *Client) SendRaw(ctx context.Context, payload []byte) error {
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
 		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return fmt.Errorf("zipkin http error: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+		err := fmt.Errorf("zipkin http error: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+		if sink.ThrottledStatus(resp.StatusCode) {
+			return sink.Throttle(err)
+		}
+		return err
 	}
+	sink.RecordPayloadSize(ctx, len(payload), len(body))
 	return nil
 }
 
@@ -0,0 +1,245 @@
+// Package tlsconfig builds client TLS settings for the trace sinks and the
+// validate clients. Certificate files are read again when they change, so
+// certificates can be rotated during a long run without restarting it.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options are the TLS settings for a client. The zero value uses Go's
+// defaults: system roots, no client certificate and TLS 1.2 or later.
+type Options struct {
+	// CAFile is a PEM bundle of roots to trust instead of the system roots.
+	CAFile string
+	// CertFile and KeyFile are a PEM client certificate and key for mutual
+	// TLS. Both or neither are set.
+	CertFile string
+	KeyFile  string
+	// ServerName is checked against the server certificate instead of the
+	// endpoint's host.
+	ServerName string
+	// MinVersion is "1.2" or "1.3"; empty means 1.2.
+	MinVersion string
+	// InsecureSkipVerify accepts any server certificate.
+	InsecureSkipVerify bool
+}
+
+// IsZero reports whether no option is set.
+func (o Options) IsZero() bool {
+	return o == Options{}
+}
+
+// Validate checks the options without reading the files.
+func (o Options) Validate() error {
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return errors.New("tls-cert-file and tls-key-file must be set together")
+	}
+	if _, err := ParseVersion(o.MinVersion); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ParseVersion parses a TLS version such as "1.2" or "1.3". Empty is 1.2.
+func ParseVersion(raw string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(raw)), "tls") {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("invalid tls-min-version %q: must be 1.2 or 1.3", raw)
+	}
+}
+
+// Host returns the host of an endpoint given as a URL or as host:port.
+func Host(endpoint string) string {
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	if host, _, err := net.SplitHostPort(endpoint); err == nil {
+		return host
+	}
+	return endpoint
+}
+
+// Loader reads the files named in Options and reads them again when they
+// change. One loader serves every client of a run, so the files are shared.
+type Loader struct {
+	opts       Options
+	minVersion uint16
+	roots      *reloading[*x509.CertPool]
+	cert       *reloading[*tls.Certificate]
+}
+
+// NewLoader reads the files in opts, failing if any cannot be loaded.
+func NewLoader(opts Options) (*Loader, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	minVersion, _ := ParseVersion(opts.MinVersion)
+	l := &Loader{opts: opts, minVersion: minVersion}
+	if opts.CAFile != "" {
+		l.roots = &reloading[*x509.CertPool]{paths: []string{opts.CAFile}, load: loadRoots}
+		if _, err := l.roots.get(); err != nil {
+			return nil, err
+		}
+	}
+	if opts.CertFile != "" {
+		l.cert = &reloading[*tls.Certificate]{
+			paths: []string{opts.CertFile, opts.KeyFile},
+			load: func(files [][]byte) (*tls.Certificate, error) {
+				cert, err := tls.X509KeyPair(files[0], files[1])
+				if err != nil {
+					return nil, fmt.Errorf("load client certificate %s: %w", opts.CertFile, err)
+				}
+				return &cert, nil
+			},
+		}
+		if _, err := l.cert.get(); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Config returns TLS settings for a client of host. A nil loader returns
+// nil, which leaves the client on Go's defaults.
+//
+// With a CA file the server certificate is verified here rather than by
+// crypto/tls, so a changed bundle is used for the next handshake.
+func (l *Loader) Config(host string) *tls.Config {
+	if l == nil {
+		return nil
+	}
+	serverName := l.opts.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+	cfg := &tls.Config{
+		MinVersion:         l.minVersion,
+		ServerName:         serverName,
+		InsecureSkipVerify: l.opts.InsecureSkipVerify,
+	}
+	if l.roots != nil && !l.opts.InsecureSkipVerify {
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return l.verify(cs, serverName)
+		}
+	}
+	if l.cert != nil {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return l.cert.get()
+		}
+	}
+	return cfg
+}
+
+func (l *Loader) verify(cs tls.ConnectionState, serverName string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: server sent no certificate")
+	}
+	roots, err := l.roots.get()
+	if err != nil {
+		return err
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err = cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+func loadRoots(files [][]byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(files[0]) {
+		return nil, errors.New("tls-ca-file holds no PEM certificates")
+	}
+	return pool, nil
+}
+
+// reloading holds a value loaded from files, loading it again when any of
+// the files changes size or modification time. A failed reload keeps the
+// last good value, so a half-written file during rotation does not break
+// connections.
+type reloading[T any] struct {
+	paths []string
+	load  func(files [][]byte) (T, error)
+
+	mu     sync.Mutex
+	stamp  string
+	value  T
+	loaded bool
+}
+
+func (r *reloading[T]) get() (T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stamp, err := r.fileStamp()
+	if err == nil && r.loaded && stamp == r.stamp {
+		return r.value, nil
+	}
+	if err == nil {
+		var value T
+		if value, err = r.read(); err == nil {
+			r.stamp, r.value, r.loaded = stamp, value, true
+			return value, nil
+		}
+	}
+	if r.loaded {
+		return r.value, nil
+	}
+	return r.value, err
+}
+
+func (r *reloading[T]) fileStamp() (string, error) {
+	var b strings.Builder
+	for _, path := range r.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%d:%d;", info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+func (r *reloading[T]) read() (T, error) {
+	files := make([][]byte, len(r.paths))
+	for i, path := range r.paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		files[i] = data
+	}
+	return r.load(files)
+}
+
+// HTTPClient returns an HTTP client with timeout that uses cfg for TLS. A
+// nil cfg uses the default transport.
+func HTTPClient(timeout time.Duration, cfg *tls.Config) *http.Client {
+	if cfg == nil {
+		return &http.Client{Timeout: timeout}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
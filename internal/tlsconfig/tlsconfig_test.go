@@ -0,0 +1,273 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA signs certificates for the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse CA: %v", err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key for a server at names, or for a
+// client when names is empty.
+func (ca *testCA) issue(t *testing.T, names ...string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "spanforge-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if len(names) > 0 {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, name)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// startServer starts an HTTPS server with a certificate from ca for names.
+// With clientCA set it requires a client certificate signed by it.
+func startServer(t *testing.T, ca, clientCA *testCA, names ...string) *httptest.Server {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	certPEM, keyPEM := ca.issue(t, names...)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("server key pair: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.Listener = lis
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	if clientCA != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(clientCA.cert)
+		srv.TLS.ClientCAs = pool
+		srv.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// get makes one request on a new connection.
+func get(l *Loader, url string) error {
+	client := HTTPClient(5*time.Second, l.Config(Host(url)))
+	defer client.CloseIdleConnections()
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestLoaderMutualTLS(t *testing.T) {
+	ca := newTestCA(t, "spanforge test CA")
+	srv := startServer(t, ca, ca, "127.0.0.1")
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t)
+	opts := Options{
+		CAFile:   filepath.Join(dir, "ca.pem"),
+		CertFile: filepath.Join(dir, "client.pem"),
+		KeyFile:  filepath.Join(dir, "client-key.pem"),
+	}
+	writeFile(t, opts.CAFile, ca.pem)
+	writeFile(t, opts.CertFile, certPEM)
+	writeFile(t, opts.KeyFile, keyPEM)
+
+	l, err := NewLoader(opts)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+	if err := get(l, srv.URL); err != nil {
+		t.Fatalf("mutual TLS request: %v", err)
+	}
+
+	noCert, err := NewLoader(Options{CAFile: opts.CAFile})
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+	if err := get(noCert, srv.URL); err == nil {
+		t.Fatalf("request without a client certificate succeeded")
+	}
+}
+
+func TestLoaderReloadsChangedFiles(t *testing.T) {
+	ca := newTestCA(t, "spanforge test CA")
+	other := newTestCA(t, "spanforge other CA")
+	srv := startServer(t, ca, ca, "127.0.0.1")
+	dir := t.TempDir()
+	opts := Options{
+		CAFile:   filepath.Join(dir, "ca.pem"),
+		CertFile: filepath.Join(dir, "client.pem"),
+		KeyFile:  filepath.Join(dir, "client-key.pem"),
+	}
+	// Start trusting the wrong CA with a client certificate the server
+	// does not accept.
+	certPEM, keyPEM := other.issue(t)
+	writeFile(t, opts.CAFile, other.pem)
+	writeFile(t, opts.CertFile, certPEM)
+	writeFile(t, opts.KeyFile, keyPEM)
+	l, err := NewLoader(opts)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+	if err := get(l, srv.URL); err == nil {
+		t.Fatalf("request with the wrong CA succeeded")
+	}
+
+	certPEM, keyPEM = ca.issue(t)
+	writeFile(t, opts.CAFile, ca.pem)
+	writeFile(t, opts.CertFile, certPEM)
+	writeFile(t, opts.KeyFile, keyPEM)
+	// Move the modification times on in case the rewrite lands within the
+	// filesystem's timestamp resolution.
+	later := time.Now().Add(time.Minute)
+	for _, path := range []string{opts.CAFile, opts.CertFile, opts.KeyFile} {
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	if err := get(l, srv.URL); err != nil {
+		t.Fatalf("request after rotating the files: %v", err)
+	}
+
+	// A broken rewrite keeps the last good files.
+	writeFile(t, opts.CAFile, []byte("not a certificate"))
+	if err := get(l, srv.URL); err != nil {
+		t.Fatalf("request after a broken CA rewrite: %v", err)
+	}
+}
+
+func TestLoaderServerNameAndSkipVerify(t *testing.T) {
+	ca := newTestCA(t, "spanforge test CA")
+	srv := startServer(t, ca, nil, "collector.internal")
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeFile(t, caFile, ca.pem)
+
+	for _, tc := range []struct {
+		name string
+		opts Options
+		ok   bool
+	}{
+		{"host mismatch", Options{CAFile: caFile}, false},
+		{"server name", Options{CAFile: caFile, ServerName: "collector.internal"}, true},
+		{"system roots", Options{ServerName: "collector.internal"}, false},
+		{"skip verify", Options{InsecureSkipVerify: true}, true},
+		{"min version 1.3", Options{InsecureSkipVerify: true, MinVersion: "1.3"}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := NewLoader(tc.opts)
+			if err != nil {
+				t.Fatalf("NewLoader: %v", err)
+			}
+			if err := get(l, srv.URL); (err == nil) != tc.ok {
+				t.Fatalf("err=%v want ok=%v", err, tc.ok)
+			}
+		})
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	if err := (Options{CertFile: "client.pem"}).Validate(); err == nil {
+		t.Fatalf("cert without key validated")
+	}
+	if err := (Options{MinVersion: "1.1"}).Validate(); err == nil {
+		t.Fatalf("TLS 1.1 validated")
+	}
+	if v, err := ParseVersion("TLS1.3"); err != nil || v != tls.VersionTLS13 {
+		t.Fatalf("ParseVersion(TLS1.3)=%x, %v", v, err)
+	}
+	if _, err := NewLoader(Options{CAFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Fatalf("missing CA file loaded")
+	}
+}
+
+func TestHost(t *testing.T) {
+	for endpoint, want := range map[string]string{
+		"https://tempo:4318":       "tempo",
+		"collector.internal:4317":  "collector.internal",
+		"https://127.0.0.1:9411/x": "127.0.0.1",
+		"[::1]:4317":               "::1",
+		"otel-collector":           "otel-collector",
+	} {
+		if got := Host(endpoint); got != want {
+			t.Fatalf("Host(%q)=%q want %q", endpoint, got, want)
+		}
+	}
+}
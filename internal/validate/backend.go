@@ -12,10 +12,11 @@ import (
 type tempoClient struct {
 	endpoint   string
 	httpClient *http.Client
+	headers    map[string]string
 }
 
-func (c tempoClient) Trace(ctx context.Context, traceID string) (traceObservation, error) {
-	data, err := getJSON(ctx, c.httpClient, c.endpoint+"/api/traces/"+traceID)
+func (c tempoClient) Trace(ctx context.Context, traceID string, headers map[string]string) (traceObservation, error) {
+	data, err := getJSON(ctx, c.httpClient, c.endpoint+"/api/traces/"+traceID, c.headers, headers)
 	if err != nil {
 		return traceObservation{TraceID: traceID}, err
 	}
@@ -27,10 +28,11 @@ func (c tempoClient) Trace(ctx context.Context, traceID string) (traceObservatio
 type jaegerClient struct {
 	endpoint   string
 	httpClient *http.Client
+	headers    map[string]string
 }
 
-func (c jaegerClient) Trace(ctx context.Context, traceID string) (traceObservation, error) {
-	data, err := getJSON(ctx, c.httpClient, c.endpoint+"/api/traces/"+traceID)
+func (c jaegerClient) Trace(ctx context.Context, traceID string, headers map[string]string) (traceObservation, error) {
+	data, err := getJSON(ctx, c.httpClient, c.endpoint+"/api/traces/"+traceID, c.headers, headers)
 	if err != nil {
 		return traceObservation{TraceID: traceID}, err
 	}
@@ -39,11 +41,18 @@ func (c jaegerClient) Trace(ctx context.Context, traceID string) (traceObservati
 	return obs, nil
 }
 
-func getJSON(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+// getJSON fetches url with each set of headers applied in turn, so later
+// sets win.
+func getJSON(ctx context.Context, client *http.Client, url string, headers ...map[string]string) ([]byte, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
+	for _, set := range headers {
+		for k, v := range set {
+			req.Header.Set(k, v)
+		}
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
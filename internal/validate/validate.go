@@ -2,15 +2,20 @@ package validate
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/robmcelhinney/spanforge/internal/auth"
+	"github.com/robmcelhinney/spanforge/internal/tlsconfig"
 )
 
 type Status string
@@ -28,6 +33,12 @@ type Options struct {
 	Wait         time.Duration
 	PollInterval time.Duration
 	HTTPClient   *http.Client
+	// TLS configures HTTPS queries when HTTPClient is nil.
+	TLS *tls.Config
+	// Auth sets the Authorization header of queries that have none.
+	Auth auth.Provider
+	// Headers go on every query, under any tenant's headers.
+	Headers map[string]string
 }
 
 type Result struct {
@@ -48,12 +59,29 @@ type report struct {
 	Services       []string      `json:"services"`
 	SampleTraceIDs []string      `json:"sample_trace_ids"`
 	Phases         []phaseReport `json:"phases"`
+	// Profiles lists each profile of a --profile-mix run.
+	Profiles []shareReport `json:"profiles"`
+	// Tenants lists each tenant of a --tenant run.
+	Tenants []tenantReport `json:"tenants"`
 }
 
 type phaseReport struct {
 	Name string `json:"name"`
 }
 
+// shareReport is one profile of a mix, or one tenant.
+type shareReport struct {
+	Name           string   `json:"name"`
+	Services       []string `json:"services"`
+	SampleTraceIDs []string `json:"sample_trace_ids"`
+}
+
+type tenantReport struct {
+	shareReport
+	// Headers pick the tenant on queries.
+	Headers map[string]string `json:"headers"`
+}
+
 type traceObservation struct {
 	TraceID         string
 	Found           bool
@@ -66,7 +94,7 @@ type traceObservation struct {
 }
 
 type backendClient interface {
-	Trace(ctx context.Context, traceID string) (traceObservation, error)
+	Trace(ctx context.Context, traceID string, headers map[string]string) (traceObservation, error)
 }
 
 func Run(ctx context.Context, opts Options) (Result, error) {
@@ -78,8 +106,9 @@ func Run(ctx context.Context, opts Options) (Result, error) {
 		opts.PollInterval = 2 * time.Second
 	}
 	if opts.HTTPClient == nil {
-		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+		opts.HTTPClient = tlsconfig.HTTPClient(10*time.Second, opts.TLS)
 	}
+	opts.HTTPClient = auth.WrapClient(opts.HTTPClient, opts.Auth)
 	if strings.TrimSpace(opts.ReportFile) == "" {
 		return Result{}, errors.New("report-file is required")
 	}
@@ -96,7 +125,7 @@ func Run(ctx context.Context, opts Options) (Result, error) {
 	var observations []traceObservation
 	var lastErr error
 	for {
-		observations, lastErr = fetchSamples(ctx, client, rep.SampleTraceIDs)
+		observations, lastErr = fetchSamples(ctx, client, sampleTraceIDs(rep), rep.Tenants)
 		if foundAny(observations) || time.Now().After(deadline) {
 			break
 		}
@@ -168,23 +197,53 @@ func newBackendClient(opts Options) (backendClient, string, error) {
 		if endpoint == "" {
 			endpoint = "http://localhost:3200"
 		}
-		return tempoClient{endpoint: endpoint, httpClient: opts.HTTPClient}, endpoint, nil
+		return tempoClient{endpoint: endpoint, httpClient: opts.HTTPClient, headers: opts.Headers}, endpoint, nil
 	case "jaeger":
 		if endpoint == "" {
 			endpoint = "http://localhost:16686"
 		}
-		return jaegerClient{endpoint: endpoint, httpClient: opts.HTTPClient}, endpoint, nil
+		return jaegerClient{endpoint: endpoint, httpClient: opts.HTTPClient, headers: opts.Headers}, endpoint, nil
 	default:
 		return nil, "", fmt.Errorf("unsupported validation backend %q", opts.Backend)
 	}
 }
 
-func fetchSamples(ctx context.Context, client backendClient, traceIDs []string) ([]traceObservation, error) {
+// sampleTraceIDs returns the report's sampled trace IDs followed by those
+// sampled for each profile, without repeats.
+func sampleTraceIDs(rep report) []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(ids []string) {
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				out = append(out, id)
+			}
+		}
+	}
+	add(rep.SampleTraceIDs)
+	for _, profile := range rep.Profiles {
+		add(profile.SampleTraceIDs)
+	}
+	for _, tenant := range rep.Tenants {
+		add(tenant.SampleTraceIDs)
+	}
+	return out
+}
+
+// fetchSamples looks up each trace. In a multi-tenant run it queries with
+// the headers of the tenant that sampled the trace, or tries each tenant in
+// turn when no tenant did.
+func fetchSamples(ctx context.Context, client backendClient, traceIDs []string, tenants []tenantReport) ([]traceObservation, error) {
 	observations := make([]traceObservation, 0, len(traceIDs))
 	var lastErr error
 	for _, traceID := range traceIDs {
-		obs, err := client.Trace(ctx, traceID)
-		if err != nil {
+		var obs traceObservation
+		for _, headers := range queryHeaders(traceID, tenants) {
+			var err error
+			if obs, err = client.Trace(ctx, traceID, headers); err == nil {
+				break
+			}
 			lastErr = err
 			obs = traceObservation{TraceID: traceID}
 		}
@@ -193,6 +252,23 @@ func fetchSamples(ctx context.Context, client backendClient, traceIDs []string)
 	return observations, lastErr
 }
 
+// queryHeaders returns the header sets to try when looking up traceID.
+func queryHeaders(traceID string, tenants []tenantReport) []map[string]string {
+	if len(tenants) == 0 {
+		return []map[string]string{nil}
+	}
+	for _, tenant := range tenants {
+		if slices.Contains(tenant.SampleTraceIDs, traceID) {
+			return []map[string]string{tenant.Headers}
+		}
+	}
+	out := make([]map[string]string, 0, len(tenants))
+	for _, tenant := range tenants {
+		out = append(out, tenant.Headers)
+	}
+	return out
+}
+
 func foundAny(observations []traceObservation) bool {
 	for _, obs := range observations {
 		if obs.Found {
@@ -253,6 +329,17 @@ func buildChecks(rep report, observations []traceObservation, lastErr error) []C
 		checks = append(checks, Check{"phase_labels", StatusWarn, fmt.Sprintf("missing phase labels in sampled traces: %s", strings.Join(missingPhases, ", "))})
 	}
 
+	if len(rep.Profiles) > 0 {
+		checks = append(checks, shareCheck("profiles", rep.Profiles, observations))
+	}
+	if len(rep.Tenants) > 0 {
+		tenants := make([]shareReport, 0, len(rep.Tenants))
+		for _, tenant := range rep.Tenants {
+			tenants = append(tenants, tenant.shareReport)
+		}
+		checks = append(checks, shareCheck("tenants", tenants, observations))
+	}
+
 	errorSpans, highLatencySpans := 0, 0
 	for _, obs := range observations {
 		errorSpans += obs.ErrorSpans
@@ -272,6 +359,37 @@ func buildChecks(rep report, observations []traceObservation, lastErr error) []C
 	return checks
 }
 
+// shareCheck looks for the services of every profile or tenant in the traces
+// sampled for it.
+func shareCheck(name string, shares []shareReport, observations []traceObservation) Check {
+	byID := map[string]traceObservation{}
+	for _, obs := range observations {
+		byID[obs.TraceID] = obs
+	}
+	var problems, names []string
+	for _, share := range shares {
+		names = append(names, share.Name)
+		var sampled []traceObservation
+		for _, id := range share.SampleTraceIDs {
+			if obs, ok := byID[id]; ok && obs.Found {
+				sampled = append(sampled, obs)
+			}
+		}
+		if len(sampled) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: no sampled traces found", share.Name))
+			continue
+		}
+		observed := collectSet(sampled, func(obs traceObservation) map[string]struct{} { return obs.Services })
+		if missingServices := missing(share.Services, observed); len(missingServices) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: missing %s", share.Name, strings.Join(missingServices, ", ")))
+		}
+	}
+	if len(problems) > 0 {
+		return Check{name, StatusWarn, strings.Join(problems, "; ")}
+	}
+	return Check{name, StatusPass, fmt.Sprintf("found expected services for %s: %s", name, strings.Join(names, ", "))}
+}
+
 func countRunIDMatches(observations []traceObservation, expected string) int {
 	count := 0
 	for _, obs := range observations {
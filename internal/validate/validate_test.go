@@ -3,9 +3,13 @@ package validate
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -120,6 +124,136 @@ func TestRunFailsWhenNoSampleTracesFound(t *testing.T) {
 	}
 }
 
+func TestRunChecksEachProfileOfMix(t *testing.T) {
+	reportPath := writeReport(t, report{
+		RunID:          "sf_seed_1",
+		Services:       []string{"checkout", "ledger"},
+		SampleTraceIDs: []string{"web1"},
+		Profiles: []shareReport{
+			{Name: "web", Services: []string{"checkout"}, SampleTraceIDs: []string{"web1"}},
+			{Name: "batch", Services: []string{"ledger", "scheduler"}, SampleTraceIDs: []string{"batch1"}},
+		},
+	})
+	var fetched []string
+	client := fakeHTTPClient(func(r *http.Request) (int, string) {
+		traceID := strings.TrimPrefix(r.URL.Path, "/api/traces/")
+		fetched = append(fetched, traceID)
+		service := map[string]string{"web1": "checkout", "batch1": "ledger"}[traceID]
+		return http.StatusOK, `{"batches": [{
+		  "resource": {"attributes": [{"key":"service.name","value":{"stringValue":"` + service + `"}}]},
+		  "scopeSpans": [{"spans": [{"startTimeUnixNano": "1000000000", "endTimeUnixNano": "1001000000"}]}]
+		}]}`
+	})
+
+	result, err := Run(context.Background(), Options{
+		Backend:      "tempo",
+		Endpoint:     "http://tempo.test",
+		ReportFile:   reportPath,
+		Wait:         time.Millisecond,
+		PollInterval: time.Millisecond,
+		HTTPClient:   client,
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if strings.Join(fetched, ",") != "web1,batch1" {
+		t.Fatalf("fetched=%v want each sample once", fetched)
+	}
+	var check Check
+	for _, c := range result.Checks {
+		if c.Name == "profiles" {
+			check = c
+		}
+	}
+	if check.Status != StatusWarn || check.Message != "batch: missing scheduler" {
+		t.Fatalf("profiles check=%+v", check)
+	}
+}
+
+func TestRunQueriesEachTenantWithItsHeaders(t *testing.T) {
+	reportPath := writeReport(t, report{
+		RunID:          "sf_seed_1",
+		Services:       []string{"checkout"},
+		SampleTraceIDs: []string{"acme1", "stray"},
+		Tenants: []tenantReport{
+			{shareReport: shareReport{Name: "acme", Services: []string{"checkout"}, SampleTraceIDs: []string{"acme1"}}, Headers: map[string]string{"X-Scope-OrgID": "acme"}},
+			{shareReport: shareReport{Name: "globex", Services: []string{"checkout"}, SampleTraceIDs: []string{"globex1"}}, Headers: map[string]string{"X-Scope-OrgID": "globex"}},
+		},
+	})
+	owners := map[string]string{"acme1": "acme", "globex1": "globex", "stray": "globex"}
+	var queries []string
+	client := fakeHTTPClient(func(r *http.Request) (int, string) {
+		traceID := strings.TrimPrefix(r.URL.Path, "/api/traces/")
+		tenant := r.Header.Get("X-Scope-OrgID")
+		queries = append(queries, traceID+"@"+tenant+"@"+r.Header.Get("Authorization"))
+		if owners[traceID] != tenant {
+			return http.StatusNotFound, ""
+		}
+		return http.StatusOK, `{"batches": [{
+		  "resource": {"attributes": [{"key":"service.name","value":{"stringValue":"checkout"}}]},
+		  "scopeSpans": [{"spans": [{"startTimeUnixNano": "1000000000", "endTimeUnixNano": "1001000000"}]}]
+		}]}`
+	})
+
+	result, err := Run(context.Background(), Options{
+		Backend:      "tempo",
+		Endpoint:     "http://tempo.test",
+		ReportFile:   reportPath,
+		Wait:         time.Millisecond,
+		PollInterval: time.Millisecond,
+		HTTPClient:   client,
+		Headers:      map[string]string{"Authorization": "Bearer q"},
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	want := "acme1@acme@Bearer q,stray@acme@Bearer q,stray@globex@Bearer q,globex1@globex@Bearer q"
+	if got := strings.Join(queries, ","); got != want {
+		t.Fatalf("queries=%s want %s", got, want)
+	}
+	passed := 0
+	for _, check := range result.Checks {
+		if (check.Name == "tenants" || check.Name == "sample_traces") && check.Status == StatusPass {
+			passed++
+		}
+	}
+	if passed != 2 {
+		t.Fatalf("checks=%+v want tenants and sample_traces to pass", result.Checks)
+	}
+}
+
+func TestRunQueriesOverTLS(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen unavailable in this environment: %v", err)
+	}
+	var gotTLS bool
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTLS = r.TLS != nil
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	srv.Listener = lis
+	srv.StartTLS()
+	defer srv.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	reportPath := writeReport(t, report{RunID: "sf_seed_1", SampleTraceIDs: []string{"abc123"}})
+	if _, err := Run(context.Background(), Options{
+		Backend:      "tempo",
+		Endpoint:     srv.URL,
+		ReportFile:   reportPath,
+		Wait:         time.Millisecond,
+		PollInterval: time.Millisecond,
+		TLS:          &tls.Config{RootCAs: roots},
+	}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !gotTLS {
+		t.Fatalf("backend was not queried over TLS")
+	}
+}
+
 type roundTripFunc func(*http.Request) (int, string)
 
 func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {